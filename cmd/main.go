@@ -23,35 +23,96 @@ package main
 
 import (
 	"context"
-	"log"
+	"fmt"
+	"log/slog"
 	"net/http"
 	"os"
-	"os/signal"
+	"strconv"
+	"time"
 
 	"github.com/jackc/pgx/v5"
 	"github.com/jackc/pgx/v5/pgxpool"
 	"github.com/rs/cors"
 
 	_ "GO2GETHER_BACK-END/docs" // This is required for swagger
+	"GO2GETHER_BACK-END/internal/buildinfo"
 	"GO2GETHER_BACK-END/internal/config"
+	"GO2GETHER_BACK-END/internal/digest"
 	"GO2GETHER_BACK-END/internal/handlers"
+	"GO2GETHER_BACK-END/internal/health"
+	"GO2GETHER_BACK-END/internal/lifecycle"
+	"GO2GETHER_BACK-END/internal/logging"
+	"GO2GETHER_BACK-END/internal/metrics"
+	"GO2GETHER_BACK-END/internal/middleware"
+	"GO2GETHER_BACK-END/internal/migrations"
+	"GO2GETHER_BACK-END/internal/oauth"
+	"GO2GETHER_BACK-END/internal/outbox"
+	"GO2GETHER_BACK-END/internal/realtime"
+	"GO2GETHER_BACK-END/internal/repository"
+	"GO2GETHER_BACK-END/internal/repository/postgres"
 	"GO2GETHER_BACK-END/internal/routes"
+	"GO2GETHER_BACK-END/internal/tracing"
+	"GO2GETHER_BACK-END/internal/utils"
+	"GO2GETHER_BACK-END/internal/worker"
 )
 
 func main() {
+	// ---- `migrate create <name>` only writes files; no config or DB needed ----
+	if len(os.Args) > 2 && os.Args[1] == "migrate" && os.Args[2] == "create" {
+		if len(os.Args) != 4 {
+			fatal("usage: main migrate create <name>")
+		}
+		up, down, err := migrations.Create(migrations.SourceDir, os.Args[3])
+		if err != nil {
+			fatal("create migration", "error", err)
+		}
+		fmt.Println(up)
+		fmt.Println(down)
+		return
+	}
+
 	// ---- config + pgxpool เหมือนเดิม ----
 	cfg, err := config.Load()
 	if err != nil {
-		log.Fatalf("Failed to load configuration: %v", err)
+		fatal("load configuration", "error", err)
+	}
+	logging.Setup(cfg.Log.Level, cfg.Log.Format)
+	for _, w := range cfg.Warnings {
+		slog.Warn(w)
+	}
+
+	// ---- Tracing (OTEL_TRACES_EXPORTER) ----
+	shutdownTracing, err := tracing.Setup(context.Background(), cfg.Telemetry)
+	if err != nil {
+		fatal("set up tracing", "error", err)
 	}
-	log.Printf("Email configured: %v", cfg.IsEmailConfigured())
+	bi := buildinfo.Get()
+	slog.Info("starting", "env", cfg.Env, "commit", bi.Commit, "build_time", bi.BuildTime, "email_configured", cfg.IsEmailConfigured())
 
-	dsn := cfg.GetDSN()
-	log.Println("Connecting to:", dsn)
+	// ---- Errors: storage errors reach clients as 404/409/400/503 ----
+	utils.UseErrorMapper(repository.AppError)
 
-	dbCfg, err := pgxpool.ParseConfig(dsn)
+	// ---- Email: SMTP, console or memory (EMAIL_MAILER); test mode always captures in memory ----
+	if cfg.Env == config.EnvTest {
+		cfg.Email.Mailer = utils.MailerMemory
+	}
+	mailer := utils.NewMailer(&cfg.Email)
+	mailSink, _ := mailer.(*utils.MailSink)
+	utils.UseMailer(mailer)
+	slog.Info("email", "mailer", cfg.Email.Mailer, "default_locale", cfg.Email.DefaultLocale)
+	if !cfg.IsProduction() {
+		slog.Warn("test helper endpoints that expose OTP codes are enabled; never use this APP_ENV in production", "env", cfg.Env)
+	}
+
+	// DSN มีรหัสผ่าน ห้าม log ทั้งก้อน
+	slog.Info("connecting to database",
+		"host", cfg.Database.Host, "port", cfg.Database.Port,
+		"database", cfg.Database.Name, "user", cfg.Database.User, "sslmode", cfg.Database.SSLMode)
+
+	dbCfg, err := pgxpool.ParseConfig(cfg.GetDSN())
 	if err != nil {
-		log.Fatalf("parse dsn: %v", err)
+		// ไม่ใส่ err: ข้อความของ pgx อาจยกส่วนของ DSN มา
+		fatal("invalid database settings")
 	}
 	dbCfg.ConnConfig.DefaultQueryExecMode = pgx.QueryExecModeSimpleProtocol
 	dbCfg.ConnConfig.RuntimeParams["application_name"] = "go2gether-backend"
@@ -59,47 +120,163 @@ func main() {
 	dbCfg.MaxConns = cfg.Database.MaxConns
 	dbCfg.MinConns = cfg.Database.MinConns
 	dbCfg.MaxConnLifetime = cfg.Database.MaxLifetime
+	dbCfg.ConnConfig.Tracer = tracing.PgxTracer{}
 
 	pool, err := pgxpool.NewWithConfig(context.Background(), dbCfg)
 	if err != nil {
-		log.Fatalf("connect: %v", err)
+		fatal("connect to database", "error", err)
 	}
 	defer pool.Close()
+	metrics.RegisterPool(pool)
 
 	{
 		ctx, cancel := context.WithTimeout(context.Background(), cfg.Database.ConnTimeout)
 		defer cancel()
 		if err := pool.Ping(ctx); err != nil {
-			log.Fatalf("ping: %v", err)
+			fatal("ping database", "error", err)
 		}
 	}
 
+	// ---- Schema migrations: `migrate up|down|status`, or refuse to serve a stale schema ----
+	migrator, err := migrations.New(pool)
+	if err != nil {
+		fatal("load migrations", "error", err)
+	}
+	if len(os.Args) > 1 && os.Args[1] == "migrate" {
+		if err := runMigrate(context.Background(), migrator, os.Args[2:]); err != nil {
+			fatal("migrate", "error", err)
+		}
+		return
+	}
+	if cfg.Database.AutoMigrate {
+		applied, err := migrator.Up(context.Background())
+		if err != nil {
+			fatal("migrate up", "error", err)
+		}
+		slog.Info("applied migrations", "count", len(applied))
+	}
+	if err := migrator.Check(context.Background()); err != nil {
+		fatal("schema is not up to date; run `go run ./cmd/main.go migrate up` or set DB_AUTO_MIGRATE=true", "error", err)
+	}
+
+	// ---- Token signing keys (fail fast on a bad JWT_KEYS_FILE) ----
+	keyRing, err := middleware.LoadKeyRing(&cfg.JWT)
+	if err != nil {
+		fatal("load JWT keys", "error", err)
+	}
+
+	// ---- Access token revocation (users.token_version) ----
+	middleware.UseTokenVersionCache(middleware.NewTokenVersionCache(pool, cfg.JWT.VersionCacheTTL))
+	// ---- Client IP: forwarding headers only from our own proxies ----
+	trustedProxies, err := cfg.Server.TrustedProxyPrefixes()
+	if err != nil {
+		fatal("parse trusted proxies", "error", err)
+	}
+	utils.UseTrustedProxies(trustedProxies)
+	utils.UseJSONPolicy(utils.JSONPolicy{
+		MaxBodyBytes:       cfg.Server.MaxBodyBytes,
+		AllowUnknownFields: cfg.Server.AllowUnknownFields,
+	})
+
+	// ---- External sign-in providers (only those with credentials) ----
+	providers, err := oauth.NewRegistry(cfg)
+	if err != nil {
+		fatal("configure sign-in providers", "error", err)
+	}
+	slog.Info("sign-in providers", "providers", providers.Names())
+
+	// ---- Repositories (pgx) ----
+	repos := postgres.New(pool)
+
+	// ---- Background jobs (outbox deliveries), drained on shutdown ----
+	jobs := worker.New("outbox", cfg.Workers.Count, cfg.Workers.QueueSize)
+
+	// ---- Mail queue: emails sent from requests go out in the background, with retries ----
+	mailJobs := worker.New("mail", cfg.Email.QueueWorkers, cfg.Email.QueueSize)
+	if _, ok := mailer.(*utils.SMTPMailer); ok {
+		utils.UseMailQueue(utils.NewMailQueue(mailer, mailJobs, cfg.Email.MaxAttempts, cfg.Email.RetryBackoff))
+	}
+
 	// ---- Handlers ----
 	authHandler := handlers.NewAuthHandler(pool, cfg)
-	healthHandler := handlers.NewHealthHandler(pool)
 	forgotPasswordHandler := handlers.NewForgotPasswordHandler(pool, cfg)
-	tripsHandler := handlers.NewTripsHandler(pool, cfg)
-	profileHandler := handlers.NewProfileHandler(pool)
-	googleAuthHandler := handlers.NewGoogleAuthHandler(
-		pool,
-		cfg.GoogleOAuth.ClientID,
-		cfg.GoogleOAuth.ClientSecret,
-		cfg.GoogleOAuth.RedirectURL,
-		cfg,
-	)
+	emailVerificationHandler := handlers.NewEmailVerificationHandler(pool, cfg)
+	mfaHandler := handlers.NewMFAHandler(pool, cfg)
+	testHelpersHandler := handlers.NewTestHelpersHandler(pool, mailSink)
+	tripsHandler := handlers.NewTripsHandler(repos, cfg)
+	profileHandler := handlers.NewProfileHandler(repos.Profiles)
+	keysHandler := handlers.NewKeysHandler(keyRing)
+	identitiesHandler := handlers.NewIdentitiesHandler(pool)
+	oauthHandler := handlers.NewOAuthHandler(pool, cfg, providers)
+
+	// ---- Health checks (/healthz, /readyz) ----
+	checks := health.NewRegistry(cfg.Health.CacheTTL, cfg.Health.CheckTimeout)
+	checks.Register(health.DBPing(pool))
+	checks.Register(health.PoolSaturation(pool, cfg.Health.PoolSaturation))
+	checks.Register(health.Migrations(migrator.Check))
+	if cfg.Health.SMTPCheck && cfg.Email.Mailer == utils.MailerSMTP && cfg.IsEmailConfigured() && cfg.Env != config.EnvTest {
+		checks.Register(health.SMTP(cfg.Email.SMTPHost, cfg.Email.SMTPPort))
+	}
+
+	// ---- Realtime: stream notifications, synced between replicas by LISTEN/NOTIFY ----
+	hub := realtime.NewHub(cfg.Notifications.StreamBuffer)
+	bridge := realtime.NewPGBridge(pool, hub, repos.Notifications)
+	bridge.Start()
 
 	// ✅ เพิ่มบรรทัดนี้: สร้าง NotificationsHandler
-	notificationsHandler := handlers.NewNotificationsHandler(pool)
+	notificationsHandler := handlers.NewNotificationsHandler(repos, hub, bridge, cfg)
+
+	// ---- Outbox: ส่ง event ที่ commit แล้วเป็น in-app notification และอีเมล ----
+	dispatcher := outbox.NewDispatcher(repos.Outbox, jobs, outbox.Config{
+		PollInterval: cfg.Outbox.PollInterval,
+		BatchSize:    cfg.Outbox.BatchSize,
+		MaxAttempts:  cfg.Outbox.MaxAttempts,
+		Lease:        cfg.Outbox.Lease,
+		BaseBackoff:  cfg.Outbox.RetryBackoff,
+		MaxBackoff:   cfg.Outbox.RetryMaxBackoff,
+		Retention:    cfg.Outbox.Retention,
+	},
+		handlers.InAppChannel(notificationsHandler.Service()),
+		outbox.Email(repos.Users, notificationsHandler.Preferences(), utils.NewEmailService(&cfg.Email)),
+	)
+	dispatcher.Start()
+
+	// ---- Digests: สรุปแจ้งเตือนที่ยังไม่อ่านเป็นอีเมลรายวัน/รายสัปดาห์ ----
+	digests := digest.NewScheduler(repos, utils.NewEmailService(&cfg.Email), digest.Config{
+		Interval:         cfg.Digest.Interval,
+		Hour:             cfg.Digest.Hour,
+		Weekday:          cfg.Digest.WeekdayOf(),
+		MaxNotifications: cfg.Digest.MaxNotifications,
+		NotificationsURL: cfg.Frontend.URL + "/notifications",
+	})
+	if cfg.Digest.Enabled {
+		digests.Start()
+		// digest ของแต่ละคนจำกัดไว้ 30 วินาที
+		checks.Register(health.WorkerAlive("digest_scheduler", digests.Tracker(), 2*time.Minute))
+	}
+
+	// การส่งหนึ่ง event ถูกจำกัดด้วย lease ค้างนานกว่านั้นมากแปลว่าติด
+	checks.Register(health.WorkerAlive("outbox_worker", jobs.Tracker(), 2*cfg.Outbox.Lease))
+	checks.Register(health.WorkerAlive("outbox_dispatcher", dispatcher.Tracker(), 30*time.Second))
+	checks.Register(jobs.QueueCheck())
+	checks.Register(mailJobs.QueueCheck())
+	healthHandler := handlers.NewHealthHandler(checks, migrator)
 
 	// ✅ และส่งเข้า routes.SetupRoutes (ต้องแก้ routes.go ให้รับตัวนี้ด้วย)
-	routes.SetupRoutes(
+	router := routes.SetupRoutes(
 		authHandler,
 		healthHandler,
-		googleAuthHandler,
+		oauthHandler,
+		identitiesHandler,
 		forgotPasswordHandler,
+		emailVerificationHandler,
+		mfaHandler,
+		testHelpersHandler,
 		tripsHandler,
 		profileHandler,
 		notificationsHandler, // <- เพิ่มพารามิเตอร์นี้
+		keysHandler,
+		providers,
 		cfg,
 	)
 
@@ -109,8 +286,11 @@ func main() {
 		AllowedMethods:   cfg.CORS.AllowedMethods,
 		AllowedHeaders:   cfg.CORS.AllowedHeaders,
 		AllowCredentials: cfg.CORS.AllowCredentials,
+		ExposedHeaders:   []string{utils.RequestIDHeader},
 	})
-	handler := c.Handler(http.DefaultServeMux)
+	// Request ID อยู่นอกสุด เพื่อให้ทุก response (รวม CORS preflight) มี X-Request-ID
+	// และ access log, metrics, trace มี request_id และ route
+	handler := middleware.RequestID(middleware.Tracing(middleware.Metrics(middleware.AccessLog(c.Handler(router)))))
 
 	srv := &http.Server{
 		Addr:              ":" + cfg.Server.Port,
@@ -122,21 +302,92 @@ func main() {
 	}
 
 	go func() {
-		log.Printf("HTTP server listening on :%s", cfg.Server.Port)
+		slog.Info("HTTP server listening", "addr", srv.Addr)
 		if err := srv.ListenAndServe(); err != nil && err != http.ErrServerClosed {
-			log.Fatalf("ListenAndServe: %v", err)
+			fatal("listen and serve", "error", err)
 		}
 	}()
 
-	quit := make(chan os.Signal, 1)
-	signal.Notify(quit, os.Interrupt)
-	<-quit
-	log.Println("Shutting down server...")
+	// SIGTERM (docker stop) หรือ SIGINT: /readyz fail → รอ drain → ปิด stream → ปิด HTTP → หยุด poll outbox → drain jobs และ mail queue → flush traces → ปิด DB
+	lc := lifecycle.New(checks, cfg.Server.DrainDelay, cfg.Server.ShutdownTimeout)
+	// ปิด stream ก่อน ไม่งั้น srv.Shutdown จะรอ connection ที่เปิดค้าง
+	lc.OnStop("notification streams", hub.Shutdown)
+	lc.OnStop("http server", srv.Shutdown)
+	lc.OnStop("outbox dispatcher", dispatcher.Shutdown)
+	if cfg.Digest.Enabled {
+		lc.OnStop("digest scheduler", digests.Shutdown)
+	}
+	lc.OnStop("outbox jobs", jobs.Shutdown)
+	lc.OnStop("mail queue", mailJobs.Shutdown)
+	lc.OnStop("notification listener", bridge.Shutdown)
+	lc.OnStop("tracing", shutdownTracing)
+	lc.OnStop("database", func(context.Context) error {
+		pool.Close()
+		return nil
+	})
+	if err := lc.Wait(context.Background()); err != nil {
+		slog.Error("unclean shutdown", "error", err)
+		os.Exit(1)
+	}
+	slog.Info("server stopped")
+}
+
+// fatal logs msg at error level and exits
+func fatal(msg string, args ...any) {
+	slog.Error(msg, args...)
+	os.Exit(1)
+}
+
+// runMigrate implements `migrate up | down [steps] | status`
+func runMigrate(ctx context.Context, m *migrations.Migrator, args []string) error {
+	if len(args) == 0 {
+		return fmt.Errorf("usage: main migrate up|down [steps]|status|create <name>")
+	}
+
+	switch args[0] {
+	case "up":
+		applied, err := m.Up(ctx)
+		for _, mig := range applied {
+			fmt.Printf("applied  %06d_%s\n", mig.Version, mig.Name)
+		}
+		if err == nil && len(applied) == 0 {
+			fmt.Println("database is up to date")
+		}
+		return err
+
+	case "down":
+		steps := 1
+		if len(args) > 1 {
+			n, err := strconv.Atoi(args[1])
+			if err != nil || n <= 0 {
+				return fmt.Errorf("steps must be a positive integer")
+			}
+			steps = n
+		}
+		rolledBack, err := m.Down(ctx, steps)
+		for _, mig := range rolledBack {
+			fmt.Printf("reverted %06d_%s\n", mig.Version, mig.Name)
+		}
+		return err
+
+	case "status":
+		statuses, err := m.Status(ctx)
+		if err != nil {
+			return err
+		}
+		for _, s := range statuses {
+			state := "pending"
+			if s.Applied {
+				state = "applied " + s.AppliedAt.Format("2006-01-02 15:04:05")
+			}
+			if s.Modified {
+				state += " (MODIFIED since applied)"
+			}
+			fmt.Printf("%06d_%-40s %s\n", s.Version, s.Name, state)
+		}
+		return nil
 
-	shutdownCtx, cancel := context.WithTimeout(context.Background(), cfg.Server.ShutdownTimeout)
-	defer cancel()
-	if err := srv.Shutdown(shutdownCtx); err != nil {
-		log.Printf("Server shutdown error: %v", err)
+	default:
+		return fmt.Errorf("unknown migrate command %q", args[0])
 	}
-	log.Println("Server stopped.")
 }
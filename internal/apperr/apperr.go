@@ -0,0 +1,180 @@
+// Package apperr defines the typed errors handlers return to clients. An
+// Error carries the HTTP status, a stable machine-readable code, a title and
+// detail safe to show, and optional per-field validation errors; the cause is
+// kept for logs only. utils.WriteError renders it as RFC 7807 problem+json.
+package apperr
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+)
+
+// Code is a stable, machine-readable error identifier. Clients may switch on
+// it; titles and details are for people and may change.
+type Code string
+
+const (
+	CodeBadRequest       Code = "bad_request"
+	CodeValidation       Code = "validation_failed"
+	CodeMalformedJSON    Code = "malformed_json"
+	CodeUnauthorized     Code = "unauthorized"
+	CodeInvalidToken     Code = "invalid_token"
+	CodeInvalidCode      Code = "invalid_code" // wrong or used one-time code (OTP, TOTP, sign-in code)
+	CodeCodeExpired      Code = "code_expired"
+	CodeForbidden        Code = "forbidden"
+	CodeMFARequired      Code = "mfa_required"
+	CodeEmailNotVerified Code = "email_not_verified"
+	CodeAccountLocked    Code = "account_locked"
+	CodeNotFound         Code = "not_found"
+	CodeMethodNotAllowed Code = "method_not_allowed"
+	CodeConflict         Code = "conflict"
+	CodeAlreadyExists    Code = "already_exists"
+	CodeUsernameTaken    Code = "username_taken"
+	CodeReferenced       Code = "reference_conflict"
+	CodePayloadTooLarge  Code = "payload_too_large"
+	CodeRateLimited      Code = "rate_limited"
+	CodeInternal         Code = "internal_error"
+	CodeUnavailable      Code = "service_unavailable"
+)
+
+// CodeForStatus is the code of an error that only has a status
+func CodeForStatus(status int) Code {
+	switch status {
+	case http.StatusBadRequest:
+		return CodeBadRequest
+	case http.StatusUnauthorized:
+		return CodeUnauthorized
+	case http.StatusForbidden:
+		return CodeForbidden
+	case http.StatusNotFound:
+		return CodeNotFound
+	case http.StatusMethodNotAllowed:
+		return CodeMethodNotAllowed
+	case http.StatusConflict:
+		return CodeConflict
+	case http.StatusLocked:
+		return CodeAccountLocked
+	case http.StatusRequestEntityTooLarge:
+		return CodePayloadTooLarge
+	case http.StatusUnprocessableEntity:
+		return CodeValidation
+	case http.StatusTooManyRequests:
+		return CodeRateLimited
+	case http.StatusServiceUnavailable:
+		return CodeUnavailable
+	}
+	if status >= 500 {
+		return CodeInternal
+	}
+	return CodeBadRequest
+}
+
+// FieldError is a validation error of one request field
+type FieldError struct {
+	Field   string `json:"field"`
+	Code    string `json:"code"` // e.g. required, invalid, out_of_range
+	Message string `json:"message"`
+}
+
+// Error is an application error
+type Error struct {
+	Status int
+	Code   Code
+	Title  string
+	Detail string
+	Fields []FieldError
+	// Err is the underlying cause; it is logged, never sent
+	Err error
+}
+
+func (e *Error) Error() string {
+	if e.Err != nil {
+		return fmt.Sprintf("%s: %s: %v", e.Code, e.Detail, e.Err)
+	}
+	return string(e.Code) + ": " + e.Detail
+}
+
+func (e *Error) Unwrap() error { return e.Err }
+
+// New creates an Error; an empty title defaults to the status text
+func New(status int, code Code, title, detail string) *Error {
+	if title == "" {
+		title = http.StatusText(status)
+	}
+	return &Error{Status: status, Code: code, Title: title, Detail: detail}
+}
+
+// WithCause returns a copy of e with err as its cause
+func (e *Error) WithCause(err error) *Error {
+	c := *e
+	c.Err = err
+	return &c
+}
+
+// WithTitle returns a copy of e with another title
+func (e *Error) WithTitle(title string) *Error {
+	c := *e
+	c.Title = title
+	return &c
+}
+
+func BadRequest(detail string) *Error {
+	return New(http.StatusBadRequest, CodeBadRequest, "Bad Request", detail)
+}
+
+func Unauthorized(detail string) *Error {
+	return New(http.StatusUnauthorized, CodeUnauthorized, "Unauthorized", detail)
+}
+
+func Forbidden(detail string) *Error {
+	return New(http.StatusForbidden, CodeForbidden, "Forbidden", detail)
+}
+
+func NotFound(detail string) *Error {
+	return New(http.StatusNotFound, CodeNotFound, "Not Found", detail)
+}
+
+func Conflict(detail string) *Error {
+	return New(http.StatusConflict, CodeConflict, "Conflict", detail)
+}
+
+// Internal wraps an unexpected error; the client only sees a generic detail
+func Internal(err error) *Error {
+	return &Error{
+		Status: http.StatusInternalServerError,
+		Code:   CodeInternal,
+		Title:  "Internal Server Error",
+		Detail: "An unexpected error occurred",
+		Err:    err,
+	}
+}
+
+// Validation reports invalid request fields
+func Validation(fields ...FieldError) *Error {
+	detail := "The request has invalid fields"
+	if len(fields) == 1 {
+		detail = fields[0].Message
+	}
+	return &Error{
+		Status: http.StatusBadRequest,
+		Code:   CodeValidation,
+		Title:  "Validation error",
+		Detail: detail,
+		Fields: fields,
+	}
+}
+
+// Field is a shorthand for one FieldError
+func Field(field, code, message string) FieldError {
+	return FieldError{Field: field, Code: code, Message: message}
+}
+
+// As returns err as an *Error, or Internal(err) when it is not one
+func As(err error) *Error {
+	var e *Error
+	if errors.As(err, &e) {
+		return e
+	}
+	return Internal(err)
+}
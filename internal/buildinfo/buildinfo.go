@@ -0,0 +1,64 @@
+// Package buildinfo reports which build of the server is running. Commit and
+// BuildTime are set at build time:
+//
+//	go build -ldflags "-X GO2GETHER_BACK-END/internal/buildinfo.Commit=$(git rev-parse HEAD) \
+//	  -X GO2GETHER_BACK-END/internal/buildinfo.BuildTime=$(date -u +%Y-%m-%dT%H:%M:%SZ)" ./cmd
+//
+// When they are not, the revision and commit time the go command stamps into
+// binaries built from a git checkout are reported instead.
+package buildinfo
+
+import (
+	"runtime"
+	"runtime/debug"
+	"sync"
+)
+
+// Set with -ldflags -X
+var (
+	Commit    = ""
+	BuildTime = ""
+)
+
+// Info describes the running build
+type Info struct {
+	Commit    string `json:"commit"`
+	BuildTime string `json:"build_time"`
+	Modified  bool   `json:"modified,omitempty"` // built from a checkout with uncommitted changes
+	GoVersion string `json:"go_version"`
+}
+
+var (
+	once sync.Once
+	info Info
+)
+
+// Get returns the build information
+func Get() Info {
+	once.Do(func() {
+		info = Info{Commit: Commit, BuildTime: BuildTime, GoVersion: runtime.Version()}
+		if bi, ok := debug.ReadBuildInfo(); ok {
+			for _, s := range bi.Settings {
+				switch s.Key {
+				case "vcs.revision":
+					if info.Commit == "" {
+						info.Commit = s.Value
+					}
+				case "vcs.time":
+					if info.BuildTime == "" {
+						info.BuildTime = s.Value
+					}
+				case "vcs.modified":
+					info.Modified = s.Value == "true"
+				}
+			}
+		}
+		if info.Commit == "" {
+			info.Commit = "unknown"
+		}
+		if info.BuildTime == "" {
+			info.BuildTime = "unknown"
+		}
+	})
+	return info
+}
@@ -2,16 +2,28 @@ package config
 
 import (
 	"fmt"
-	"log"
+	"net/netip"
 	"os"
 	"strconv"
+	"strings"
 	"time"
 
 	"github.com/joho/godotenv"
 )
 
+// Environment modes (APP_ENV)
+const (
+	EnvDevelopment = "development"
+	EnvTest        = "test"
+	EnvProduction  = "production"
+)
+
 // Config holds all configuration for the application
 type Config struct {
+	// Env is the environment mode: development, test or production.
+	// Test helpers (e.g. reading OTP codes) are only served outside production.
+	Env string
+
 	// Server configuration
 	Server ServerConfig
 
@@ -21,14 +33,154 @@ type Config struct {
 	// JWT configuration
 	JWT JWTConfig
 
+	// Account/auth policy configuration
+	Auth AuthConfig
+
+	// Rate limiting configuration
+	RateLimit RateLimitConfig
+
 	// Email configuration
 	Email EmailConfig
 
 	// Google OAuth configuration
 	GoogleOAuth GoogleOAuthConfig
 
+	// Other OAuth sign-in providers (LINE, Facebook, Apple, fake)
+	OAuth OAuthConfig
+
 	// CORS configuration
 	CORS CORSConfig
+
+	// Frontend configuration
+	Frontend FrontendConfig
+
+	// Logging configuration
+	Log LogConfig
+
+	// Metrics and tracing configuration
+	Telemetry TelemetryConfig
+
+	// Health check configuration
+	Health HealthConfig
+
+	// Background worker configuration
+	Workers WorkerConfig
+
+	// Outbox dispatcher configuration
+	Outbox OutboxConfig
+
+	// Notification streaming configuration
+	Notifications NotificationsConfig
+
+	// Notification digest email configuration
+	Digest DigestConfig
+
+	// Warnings found while loading; logged once the logger is set up
+	Warnings []string
+}
+
+// LogConfig holds logging configuration
+type LogConfig struct {
+	Level  string // debug | info | warn | error
+	Format string // json | text
+}
+
+// TelemetryConfig holds metrics and tracing configuration
+type TelemetryConfig struct {
+	// MetricsEnabled serves Prometheus metrics on GET /metrics
+	MetricsEnabled bool
+	// MetricsToken, when set, must be sent as a bearer token to scrape
+	MetricsToken string
+	// TracesExporter is none, stdout or otlp (OTLP/HTTP, configured by the
+	// standard OTEL_EXPORTER_OTLP_* variables)
+	TracesExporter string
+	// ServiceName names the service in exported spans
+	ServiceName string
+	// TraceSampleRatio is the share of new traces sampled, 0 to 1
+	TraceSampleRatio float64
+}
+
+// WorkerConfig holds background worker pool configuration
+type WorkerConfig struct {
+	// Count is the number of goroutines running background jobs
+	Count int
+	// QueueSize is how many jobs may wait; further jobs are dropped
+	QueueSize int
+}
+
+// OutboxConfig holds the configuration of the outbox dispatcher, which
+// delivers notifications (see internal/outbox)
+type OutboxConfig struct {
+	// PollInterval is the wait between two polls for due events
+	PollInterval time.Duration
+	// BatchSize is the most events claimed by one poll
+	BatchSize int
+	// MaxAttempts is how many times an event is tried before it is dead-lettered
+	MaxAttempts int
+	// Lease is how long a claimed event is hidden from other replicas
+	Lease time.Duration
+	// RetryBackoff is the wait before the first retry, doubled on each further
+	// one up to RetryMaxBackoff
+	RetryBackoff    time.Duration
+	RetryMaxBackoff time.Duration
+	// Retention is how long processed events are kept; 0 keeps them
+	Retention time.Duration
+}
+
+// NotificationsConfig holds the configuration of the notification streams
+// (GET /api/notifications/stream and /ws)
+type NotificationsConfig struct {
+	// StreamHeartbeat is the interval of keep-alive messages on open streams
+	StreamHeartbeat time.Duration
+	// StreamBuffer is how many notifications a stream may fall behind before
+	// it is closed for the client to resume
+	StreamBuffer int
+	// ReplayLimit is the most missed notifications replayed on resume; with
+	// more the client is told to reload instead
+	ReplayLimit int
+	// WebSocket also serves the stream over WebSocket
+	WebSocket bool
+}
+
+// DigestConfig holds notification digest configuration
+type DigestConfig struct {
+	// Enabled runs the digest scheduler on this replica; replicas claim each
+	// digest, so running it on several sends it once
+	Enabled bool
+	// Interval is how often the scheduler looks for due digests
+	Interval time.Duration
+	// Hour (0-23, in each user's timezone) digests are sent at; weekly ones
+	// on Weekday (monday ... sunday)
+	Hour    int
+	Weekday string
+	// MaxNotifications caps the notifications summarized in one digest
+	MaxNotifications int
+}
+
+var weekdays = map[string]time.Weekday{
+	"sunday": time.Sunday, "monday": time.Monday, "tuesday": time.Tuesday, "wednesday": time.Wednesday,
+	"thursday": time.Thursday, "friday": time.Friday, "saturday": time.Saturday,
+}
+
+// WeekdayOf returns Weekday as a time.Weekday; Monday when it is not a day name
+func (d DigestConfig) WeekdayOf() time.Weekday {
+	if w, ok := weekdays[d.Weekday]; ok {
+		return w
+	}
+	return time.Monday
+}
+
+// HealthConfig holds health check configuration
+type HealthConfig struct {
+	// CacheTTL is how long a check result is reused before probing again
+	CacheTTL time.Duration
+	// CheckTimeout bounds each check
+	CheckTimeout time.Duration
+	// PoolSaturation is the share of DB connections in use (0-1) at which
+	// the db_pool check warns
+	PoolSaturation float64
+	// SMTPCheck probes the SMTP server when email is configured
+	SMTPCheck bool
 }
 
 // ServerConfig holds server-related configuration
@@ -38,6 +190,38 @@ type ServerConfig struct {
 	WriteTimeout    time.Duration
 	IdleTimeout     time.Duration
 	ShutdownTimeout time.Duration
+	// DrainDelay is how long /readyz fails before shutdown starts, so load
+	// balancers stop sending requests first
+	DrainDelay time.Duration
+	// MaxBodyBytes caps JSON request bodies; larger bodies get 413
+	MaxBodyBytes int64
+	// AllowUnknownFields accepts JSON fields a request DTO does not declare;
+	// by default they are rejected as validation errors
+	AllowUnknownFields bool
+	// TrustedProxies are the CIDRs (or addresses) of the proxies in front of
+	// the server; X-Forwarded-For and X-Real-IP are only read from them
+	TrustedProxies []string
+}
+
+// TrustedProxyPrefixes parses TrustedProxies; an address is a single host
+func (s ServerConfig) TrustedProxyPrefixes() ([]netip.Prefix, error) {
+	prefixes := make([]netip.Prefix, 0, len(s.TrustedProxies))
+	for _, p := range s.TrustedProxies {
+		if !strings.Contains(p, "/") {
+			addr, err := netip.ParseAddr(p)
+			if err != nil {
+				return nil, fmt.Errorf("SERVER_TRUSTED_PROXIES: invalid address %q", p)
+			}
+			prefixes = append(prefixes, netip.PrefixFrom(addr.Unmap(), addr.Unmap().BitLen()))
+			continue
+		}
+		prefix, err := netip.ParsePrefix(p)
+		if err != nil {
+			return nil, fmt.Errorf("SERVER_TRUSTED_PROXIES: invalid CIDR %q", p)
+		}
+		prefixes = append(prefixes, prefix.Masked())
+	}
+	return prefixes, nil
 }
 
 // DatabaseConfig holds database-related configuration
@@ -53,6 +237,9 @@ type DatabaseConfig struct {
 	MaxLifetime  time.Duration
 	ConnTimeout  time.Duration
 	QueryTimeout time.Duration
+	// AutoMigrate applies pending migrations at startup; otherwise the server
+	// refuses to start until `migrate up` has been run
+	AutoMigrate bool
 }
 
 // JWTConfig holds JWT-related configuration
@@ -61,6 +248,46 @@ type JWTConfig struct {
 	AccessTokenTTL  time.Duration
 	RefreshTokenTTL time.Duration
 	ResetTokenTTL   time.Duration
+	// VersionCacheTTL bounds how long a revoked access token may still be
+	// accepted by another replica after users.token_version is bumped
+	VersionCacheTTL time.Duration
+	// KeysFile is an optional JSON key set (kid, purpose, alg, key material)
+	// enabling RS256/EdDSA signing and key rotation; purposes not listed in it
+	// use an HS256 key derived from Secret
+	KeysFile string
+	// AcceptLegacyTokens accepts HS256 access and invitation tokens without a
+	// kid signed directly with Secret (issued before key rotation was
+	// introduced); legacy reset tokens are always rejected
+	AcceptLegacyTokens bool
+}
+
+// AuthConfig holds account verification policy
+type AuthConfig struct {
+	EmailVerificationTTL time.Duration
+	// RequireVerifiedEmail blocks trip creation and invitations until the
+	// user's email address is verified
+	RequireVerifiedEmail bool
+	// MaxCodeAttempts is how many wrong guesses invalidate a verification code
+	MaxCodeAttempts int32
+	// MaxLoginAttempts consecutive wrong passwords lock the account for LockoutDuration
+	MaxLoginAttempts int32
+	LockoutDuration  time.Duration
+	// MFAIssuer is the account label shown by authenticator apps
+	MFAIssuer string
+	// MFAChallengeTTL is how long the second login step may take
+	MFAChallengeTTL time.Duration
+	// MFASecretKey encrypts stored TOTP secrets; derived from JWT_SECRET when empty
+	MFASecretKey string
+}
+
+// RateLimitConfig holds token bucket limits for the auth endpoints.
+// Each limit allows N requests per window per key (IP, email or user ID).
+type RateLimitConfig struct {
+	Enabled      bool
+	AuthRequests int32 // login, register, refresh, reset password
+	AuthWindow   time.Duration
+	OTPRequests  int32 // sending and checking verification codes
+	OTPWindow    time.Duration
 }
 
 // EmailConfig holds email service configuration
@@ -73,6 +300,19 @@ type EmailConfig struct {
 	FromName     string
 	UseTLS       bool
 	UseSSL       bool
+	// Mailer is how emails go out: smtp, console (printed, for development)
+	// or memory (kept in process)
+	Mailer string
+	// DefaultLocale is the email language of users who have not picked one
+	DefaultLocale string
+	// SendTimeout bounds one SMTP conversation
+	SendTimeout time.Duration
+	// Emails sent from requests are queued and sent by QueueWorkers workers,
+	// retried up to MaxAttempts times starting RetryBackoff apart
+	QueueWorkers int
+	QueueSize    int
+	MaxAttempts  int
+	RetryBackoff time.Duration
 }
 
 // GoogleOAuthConfig holds Google OAuth configuration
@@ -82,6 +322,34 @@ type GoogleOAuthConfig struct {
 	RedirectURL  string
 }
 
+// OAuthProviderConfig holds the client credentials of an OAuth provider.
+// A provider is enabled when its ClientID and ClientSecret are set.
+type OAuthProviderConfig struct {
+	ClientID     string
+	ClientSecret string
+	RedirectURL  string
+}
+
+// AppleOAuthConfig holds Sign in with Apple configuration. Apple's client
+// secret is a short-lived JWT signed with the .p8 key, so no static secret.
+type AppleOAuthConfig struct {
+	ClientID       string // Services ID
+	TeamID         string
+	KeyID          string
+	PrivateKeyFile string
+	RedirectURL    string
+}
+
+// OAuthConfig holds the sign-in providers other than Google
+type OAuthConfig struct {
+	LINE     OAuthProviderConfig
+	Facebook OAuthProviderConfig
+	Apple    AppleOAuthConfig
+	// FakeProvider enables the in-process "fake" OIDC provider (never in production)
+	FakeProvider    bool
+	FakeRedirectURL string
+}
+
 // CORSConfig holds CORS configuration
 type CORSConfig struct {
 	AllowedOrigins   []string
@@ -90,23 +358,37 @@ type CORSConfig struct {
 	AllowCredentials bool
 }
 
+// FrontendConfig holds where the web frontend lives
+type FrontendConfig struct {
+	// URL is the frontend base URL used for links in emails and notifications
+	URL string
+	// AllowedRedirects lists URL prefixes the OAuth flow may send users back to
+	AllowedRedirects []string
+}
+
 // Load loads configuration from environment variables
 func Load() (*Config, error) {
 	// Load .env file
+	var envWarning string
 	if err := godotenv.Load("../.env"); err != nil {
 		// Try loading from current directory if not found in parent
 		if err := godotenv.Load(".env"); err != nil {
-			log.Printf("Warning: .env file not found: %v", err)
+			envWarning = fmt.Sprintf(".env file not found: %v", err)
 		}
 	}
 
 	config := &Config{
+		Env: strings.ToLower(getEnv("APP_ENV", EnvProduction)),
 		Server: ServerConfig{
-			Port:            getEnv("SERVER_PORT", "8080"),
-			ReadTimeout:     getDurationEnv("SERVER_READ_TIMEOUT", 5*time.Second),
-			WriteTimeout:    getDurationEnv("SERVER_WRITE_TIMEOUT", 10*time.Second),
-			IdleTimeout:     getDurationEnv("SERVER_IDLE_TIMEOUT", 120*time.Second),
-			ShutdownTimeout: getDurationEnv("SERVER_SHUTDOWN_TIMEOUT", 5*time.Second),
+			Port:               getEnv("SERVER_PORT", "8080"),
+			ReadTimeout:        getDurationEnv("SERVER_READ_TIMEOUT", 5*time.Second),
+			WriteTimeout:       getDurationEnv("SERVER_WRITE_TIMEOUT", 10*time.Second),
+			IdleTimeout:        getDurationEnv("SERVER_IDLE_TIMEOUT", 120*time.Second),
+			ShutdownTimeout:    getDurationEnv("SERVER_SHUTDOWN_TIMEOUT", 5*time.Second),
+			DrainDelay:         getDurationEnv("SERVER_DRAIN_DELAY", 0),
+			MaxBodyBytes:       getInt64Env("SERVER_MAX_BODY_BYTES", 1<<20),
+			AllowUnknownFields: getBoolEnv("API_ALLOW_UNKNOWN_FIELDS", false),
+			TrustedProxies:     getStringSliceEnv("SERVER_TRUSTED_PROXIES", nil),
 		},
 		Database: DatabaseConfig{
 			Host:         getEnv("DB_HOST", "localhost"),
@@ -120,34 +402,136 @@ func Load() (*Config, error) {
 			MaxLifetime:  getDurationEnv("DB_MAX_LIFETIME", time.Hour),
 			ConnTimeout:  getDurationEnv("DB_CONN_TIMEOUT", 10*time.Second),
 			QueryTimeout: getDurationEnv("DB_QUERY_TIMEOUT", 30*time.Second),
+			AutoMigrate:  getBoolEnv("DB_AUTO_MIGRATE", false),
 		},
 		JWT: JWTConfig{
-			Secret:          getEnv("JWT_SECRET", "your-secret-key-change-in-production"),
-			AccessTokenTTL:  getDurationEnv("JWT_ACCESS_TTL", 7*24*time.Hour),   // 7 days
-			RefreshTokenTTL: getDurationEnv("JWT_REFRESH_TTL", 30*24*time.Hour), // 30 days
-			ResetTokenTTL:   getDurationEnv("JWT_RESET_TTL", 10*time.Minute),    // 10 minutes
+			Secret:             getEnv("JWT_SECRET", "your-secret-key-change-in-production"),
+			AccessTokenTTL:     getDurationEnv("JWT_ACCESS_TTL", 15*time.Minute),   // 15 minutes; clients refresh
+			RefreshTokenTTL:    getDurationEnv("JWT_REFRESH_TTL", 30*24*time.Hour), // 30 days
+			ResetTokenTTL:      getDurationEnv("JWT_RESET_TTL", 10*time.Minute),    // 10 minutes
+			VersionCacheTTL:    getDurationEnv("JWT_VERSION_CACHE_TTL", 30*time.Second),
+			KeysFile:           getEnv("JWT_KEYS_FILE", ""),
+			AcceptLegacyTokens: getBoolEnv("JWT_ACCEPT_LEGACY_TOKENS", true),
+		},
+		Auth: AuthConfig{
+			EmailVerificationTTL: getDurationEnv("EMAIL_VERIFICATION_TTL", 30*time.Minute),
+			RequireVerifiedEmail: getBoolEnv("REQUIRE_VERIFIED_EMAIL", false),
+			MaxCodeAttempts:      getInt32Env("AUTH_MAX_CODE_ATTEMPTS", 5),
+			MaxLoginAttempts:     getInt32Env("AUTH_MAX_LOGIN_ATTEMPTS", 5),
+			LockoutDuration:      getDurationEnv("AUTH_LOCKOUT_DURATION", 15*time.Minute),
+			MFAIssuer:            getEnv("MFA_ISSUER", "Go2gether"),
+			MFAChallengeTTL:      getDurationEnv("MFA_CHALLENGE_TTL", 5*time.Minute),
+			MFASecretKey:         getEnv("MFA_SECRET_KEY", ""),
+		},
+		RateLimit: RateLimitConfig{
+			Enabled:      getBoolEnv("RATE_LIMIT_ENABLED", true),
+			AuthRequests: getInt32Env("RATE_LIMIT_AUTH_REQUESTS", 10),
+			AuthWindow:   getDurationEnv("RATE_LIMIT_AUTH_WINDOW", time.Minute),
+			OTPRequests:  getInt32Env("RATE_LIMIT_OTP_REQUESTS", 5),
+			OTPWindow:    getDurationEnv("RATE_LIMIT_OTP_WINDOW", 15*time.Minute),
 		},
 		Email: EmailConfig{
-			SMTPHost:     getEnv("SMTP_HOST", "smtp.gmail.com"),
-			SMTPPort:     getEnv("SMTP_PORT", "587"),
-			SMTPUsername: getEnv("SMTP_USERNAME", ""),
-			SMTPPassword: getEnv("SMTP_PASSWORD", ""),
-			FromEmail:    getEnv("EMAIL_FROM", ""),
-			FromName:     getEnv("EMAIL_FROM_NAME", "Go2gether Team"),
-			UseTLS:       getBoolEnv("SMTP_USE_TLS", true),
-			UseSSL:       getBoolEnv("SMTP_USE_SSL", false),
+			SMTPHost:      getEnv("SMTP_HOST", "smtp.gmail.com"),
+			SMTPPort:      getEnv("SMTP_PORT", "587"),
+			SMTPUsername:  getEnv("SMTP_USERNAME", ""),
+			SMTPPassword:  getEnv("SMTP_PASSWORD", ""),
+			FromEmail:     getEnv("EMAIL_FROM", ""),
+			FromName:      getEnv("EMAIL_FROM_NAME", "Go2gether Team"),
+			UseTLS:        getBoolEnv("SMTP_USE_TLS", true),
+			UseSSL:        getBoolEnv("SMTP_USE_SSL", false),
+			Mailer:        strings.ToLower(getEnv("EMAIL_MAILER", "smtp")),
+			DefaultLocale: strings.ToLower(getEnv("EMAIL_DEFAULT_LOCALE", "en")),
+			SendTimeout:   getDurationEnv("EMAIL_SEND_TIMEOUT", 30*time.Second),
+			QueueWorkers:  int(getInt32Env("EMAIL_QUEUE_WORKERS", 2)),
+			QueueSize:     int(getInt32Env("EMAIL_QUEUE_SIZE", 100)),
+			MaxAttempts:   int(getInt32Env("EMAIL_MAX_ATTEMPTS", 4)),
+			RetryBackoff:  getDurationEnv("EMAIL_RETRY_BACKOFF", 2*time.Second),
 		},
 		GoogleOAuth: GoogleOAuthConfig{
 			ClientID:     getEnv("GOOGLE_CLIENT_ID", ""),
 			ClientSecret: getEnv("GOOGLE_CLIENT_SECRET", ""),
 			RedirectURL:  getEnv("GOOGLE_REDIRECT_URL", "http://localhost:8080/api/auth/google/callback"),
 		},
+		OAuth: OAuthConfig{
+			LINE: OAuthProviderConfig{
+				ClientID:     getEnv("LINE_CHANNEL_ID", ""),
+				ClientSecret: getEnv("LINE_CHANNEL_SECRET", ""),
+				RedirectURL:  getEnv("LINE_REDIRECT_URL", "http://localhost:8080/api/auth/line/callback"),
+			},
+			Facebook: OAuthProviderConfig{
+				ClientID:     getEnv("FACEBOOK_APP_ID", ""),
+				ClientSecret: getEnv("FACEBOOK_APP_SECRET", ""),
+				RedirectURL:  getEnv("FACEBOOK_REDIRECT_URL", "http://localhost:8080/api/auth/facebook/callback"),
+			},
+			Apple: AppleOAuthConfig{
+				ClientID:       getEnv("APPLE_CLIENT_ID", ""),
+				TeamID:         getEnv("APPLE_TEAM_ID", ""),
+				KeyID:          getEnv("APPLE_KEY_ID", ""),
+				PrivateKeyFile: getEnv("APPLE_PRIVATE_KEY_FILE", ""),
+				RedirectURL:    getEnv("APPLE_REDIRECT_URL", "https://localhost:8080/api/auth/apple/callback"),
+			},
+			FakeProvider:    getBoolEnv("OAUTH_FAKE_PROVIDER", false),
+			FakeRedirectURL: getEnv("OAUTH_FAKE_REDIRECT_URL", "http://localhost:8080/api/auth/fake/callback"),
+		},
 		CORS: CORSConfig{
 			AllowedOrigins:   getStringSliceEnv("CORS_ALLOWED_ORIGINS", []string{"*"}),
 			AllowedMethods:   getStringSliceEnv("CORS_ALLOWED_METHODS", []string{"GET", "POST", "PUT", "DELETE", "OPTIONS"}),
 			AllowedHeaders:   getStringSliceEnv("CORS_ALLOWED_HEADERS", []string{"*"}),
 			AllowCredentials: getBoolEnv("CORS_ALLOW_CREDENTIALS", true),
 		},
+		Log: LogConfig{
+			Level:  getEnv("LOG_LEVEL", "info"),
+			Format: getEnv("LOG_FORMAT", "json"),
+		},
+		Workers: WorkerConfig{
+			Count:     int(getInt32Env("WORKER_COUNT", 4)),
+			QueueSize: int(getInt32Env("WORKER_QUEUE_SIZE", 1000)),
+		},
+		Outbox: OutboxConfig{
+			PollInterval:    getDurationEnv("OUTBOX_POLL_INTERVAL", time.Second),
+			BatchSize:       int(getInt32Env("OUTBOX_BATCH_SIZE", 50)),
+			MaxAttempts:     int(getInt32Env("OUTBOX_MAX_ATTEMPTS", 8)),
+			Lease:           getDurationEnv("OUTBOX_LEASE", 30*time.Second),
+			RetryBackoff:    getDurationEnv("OUTBOX_RETRY_BACKOFF", 5*time.Second),
+			RetryMaxBackoff: getDurationEnv("OUTBOX_RETRY_MAX_BACKOFF", 30*time.Minute),
+			Retention:       getDurationEnv("OUTBOX_RETENTION", 7*24*time.Hour),
+		},
+		Notifications: NotificationsConfig{
+			StreamHeartbeat: getDurationEnv("NOTIFICATIONS_STREAM_HEARTBEAT", 25*time.Second),
+			StreamBuffer:    int(getInt32Env("NOTIFICATIONS_STREAM_BUFFER", 64)),
+			ReplayLimit:     int(getInt32Env("NOTIFICATIONS_REPLAY_LIMIT", 100)),
+			WebSocket:       getBoolEnv("NOTIFICATIONS_WEBSOCKET", true),
+		},
+		Digest: DigestConfig{
+			Enabled:          getBoolEnv("DIGEST_ENABLED", true),
+			Interval:         getDurationEnv("DIGEST_INTERVAL", 5*time.Minute),
+			Hour:             int(getInt32Env("DIGEST_HOUR", 8)),
+			Weekday:          strings.ToLower(getEnv("DIGEST_WEEKDAY", "monday")),
+			MaxNotifications: int(getInt32Env("DIGEST_MAX_NOTIFICATIONS", 200)),
+		},
+		Health: HealthConfig{
+			CacheTTL:       getDurationEnv("HEALTH_CACHE_TTL", 5*time.Second),
+			CheckTimeout:   getDurationEnv("HEALTH_CHECK_TIMEOUT", 2*time.Second),
+			PoolSaturation: getFloat64Env("HEALTH_POOL_SATURATION", 0.9),
+			SMTPCheck:      getBoolEnv("HEALTH_SMTP_CHECK", true),
+		},
+		Telemetry: TelemetryConfig{
+			MetricsEnabled:   getBoolEnv("METRICS_ENABLED", true),
+			MetricsToken:     getEnv("METRICS_TOKEN", ""),
+			TracesExporter:   strings.ToLower(getEnv("OTEL_TRACES_EXPORTER", "none")),
+			ServiceName:      getEnv("OTEL_SERVICE_NAME", "go2gether-backend"),
+			TraceSampleRatio: getFloat64Env("OTEL_TRACES_SAMPLER_ARG", 1),
+		},
+	}
+
+	frontendURL := strings.TrimRight(getEnv("FRONTEND_URL", "http://localhost:8081"), "/")
+	config.Frontend = FrontendConfig{
+		URL:              frontendURL,
+		AllowedRedirects: getStringSliceEnv("FRONTEND_ALLOWED_REDIRECTS", []string{frontendURL}),
+	}
+
+	if envWarning != "" {
+		config.Warnings = append(config.Warnings, envWarning)
 	}
 
 	// Validate required configuration
@@ -160,21 +544,74 @@ func Load() (*Config, error) {
 
 // Validate validates the configuration
 func (c *Config) Validate() error {
+	switch c.Env {
+	case EnvDevelopment, EnvTest, EnvProduction:
+	default:
+		return fmt.Errorf("APP_ENV must be %s, %s or %s, got %q", EnvDevelopment, EnvTest, EnvProduction, c.Env)
+	}
+
+	if _, err := c.Server.TrustedProxyPrefixes(); err != nil {
+		return err
+	}
+
 	// Check required database configuration
 	if c.Database.Password == "" {
 		return fmt.Errorf("DB_PASSWORD is required")
 	}
 
+	switch c.Email.Mailer {
+	case "smtp", "console", "memory":
+	default:
+		return fmt.Errorf("EMAIL_MAILER must be smtp, console or memory, got %q", c.Email.Mailer)
+	}
+	switch c.Email.DefaultLocale {
+	case "en", "th":
+	default:
+		return fmt.Errorf("EMAIL_DEFAULT_LOCALE must be en or th, got %q", c.Email.DefaultLocale)
+	}
+	if c.Email.SendTimeout <= 0 || c.Email.QueueWorkers <= 0 || c.Email.QueueSize <= 0 || c.Email.MaxAttempts <= 0 {
+		return fmt.Errorf("EMAIL_SEND_TIMEOUT, EMAIL_QUEUE_WORKERS, EMAIL_QUEUE_SIZE and EMAIL_MAX_ATTEMPTS must be positive")
+	}
+
 	// Check required email configuration for production
-	if c.Email.SMTPUsername == "" || c.Email.SMTPPassword == "" {
-		log.Printf("Warning: SMTP credentials not configured. SMTP_USERNAME='%s', SMTP_PASSWORD='%s'. Email functionality will not work.", c.Email.SMTPUsername, c.Email.SMTPPassword)
-	} else {
-		log.Printf("Email configuration loaded: SMTP_HOST=%s, SMTP_PORT=%s, SMTP_USERNAME=%s", c.Email.SMTPHost, c.Email.SMTPPort, c.Email.SMTPUsername)
+	if c.Email.Mailer == "smtp" && (c.Email.SMTPUsername == "" || c.Email.SMTPPassword == "") {
+		c.Warnings = append(c.Warnings, "SMTP credentials not configured (SMTP_USERNAME/SMTP_PASSWORD); email functionality will not work")
 	}
 
 	// Check required Google OAuth configuration
 	if c.GoogleOAuth.ClientID == "" || c.GoogleOAuth.ClientSecret == "" {
-		log.Println("Warning: Google OAuth credentials not configured. Google login will not work.")
+		c.Warnings = append(c.Warnings, "Google OAuth credentials not configured; Google login will not work")
+	}
+
+	switch c.Telemetry.TracesExporter {
+	case "none", "stdout", "otlp":
+	default:
+		return fmt.Errorf("OTEL_TRACES_EXPORTER must be none, stdout or otlp, got %q", c.Telemetry.TracesExporter)
+	}
+	if c.Telemetry.TraceSampleRatio < 0 || c.Telemetry.TraceSampleRatio > 1 {
+		return fmt.Errorf("OTEL_TRACES_SAMPLER_ARG must be between 0 and 1")
+	}
+
+	if c.Outbox.PollInterval <= 0 || c.Outbox.BatchSize <= 0 || c.Outbox.MaxAttempts <= 0 || c.Outbox.Lease <= 0 {
+		return fmt.Errorf("OUTBOX_POLL_INTERVAL, OUTBOX_BATCH_SIZE, OUTBOX_MAX_ATTEMPTS and OUTBOX_LEASE must be positive")
+	}
+
+	if c.Notifications.StreamHeartbeat <= 0 || c.Notifications.ReplayLimit <= 0 {
+		return fmt.Errorf("NOTIFICATIONS_STREAM_HEARTBEAT and NOTIFICATIONS_REPLAY_LIMIT must be positive")
+	}
+
+	if c.Digest.Interval <= 0 || c.Digest.MaxNotifications <= 0 {
+		return fmt.Errorf("DIGEST_INTERVAL and DIGEST_MAX_NOTIFICATIONS must be positive")
+	}
+	if c.Digest.Hour < 0 || c.Digest.Hour > 23 {
+		return fmt.Errorf("DIGEST_HOUR must be between 0 and 23")
+	}
+	if _, ok := weekdays[c.Digest.Weekday]; !ok {
+		return fmt.Errorf("DIGEST_WEEKDAY must be a day of the week such as monday, got %q", c.Digest.Weekday)
+	}
+
+	if c.OAuth.FakeProvider && c.IsProduction() {
+		return fmt.Errorf("OAUTH_FAKE_PROVIDER must not be enabled in production")
 	}
 
 	return nil
@@ -194,6 +631,11 @@ func (c *Config) GetDSN() string {
 	)
 }
 
+// IsProduction reports whether the app runs in production mode
+func (c *Config) IsProduction() bool {
+	return c.Env == EnvProduction
+}
+
 // IsEmailConfigured checks if email service is properly configured
 func (c *Config) IsEmailConfigured() bool {
 	return c.Email.SMTPUsername != "" && c.Email.SMTPPassword != "" && c.Email.FromEmail != ""
@@ -222,6 +664,24 @@ func getInt32Env(key string, defaultValue int32) int32 {
 	return defaultValue
 }
 
+func getInt64Env(key string, defaultValue int64) int64 {
+	if value := os.Getenv(key); value != "" {
+		if intValue, err := strconv.ParseInt(value, 10, 64); err == nil {
+			return intValue
+		}
+	}
+	return defaultValue
+}
+
+func getFloat64Env(key string, defaultValue float64) float64 {
+	if value := os.Getenv(key); value != "" {
+		if f, err := strconv.ParseFloat(value, 64); err == nil {
+			return f
+		}
+	}
+	return defaultValue
+}
+
 func getBoolEnv(key string, defaultValue bool) bool {
 	if value := os.Getenv(key); value != "" {
 		if boolValue, err := strconv.ParseBool(value); err == nil {
@@ -245,8 +705,8 @@ func getStringSliceEnv(key string, defaultValue []string) []string {
 		// Simple comma-separated parsing
 		// For more complex parsing, consider using a proper CSV parser
 		parts := []string{}
-		for _, part := range []string{value} {
-			if part != "" {
+		for _, part := range strings.Split(value, ",") {
+			if part = strings.TrimSpace(part); part != "" {
 				parts = append(parts, part)
 			}
 		}
@@ -0,0 +1,337 @@
+// Package digest sends notification digests: users who turned them on get
+// their unread notifications of the last day or week as one summary email,
+// grouped by trip and type, instead of reading them one by one. The
+// Scheduler sends each user's digest at the configured hour in their
+// timezone (weekly ones on the configured weekday), outside their quiet
+// hours, and marks the notifications it summarized as digested so the next
+// digest does not repeat them.
+//
+// A digest is claimed (notification_preferences.last_digest_at) before it is
+// sent, so several replicas may run the scheduler; a digest that fails to
+// send is released and tried again on the next tick.
+package digest
+
+import (
+	"cmp"
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"slices"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+
+	"GO2GETHER_BACK-END/internal/health"
+	"GO2GETHER_BACK-END/internal/metrics"
+	"GO2GETHER_BACK-END/internal/models"
+	"GO2GETHER_BACK-END/internal/repository"
+	"GO2GETHER_BACK-END/internal/utils"
+)
+
+// Sender sends digest emails (utils.EmailService)
+type Sender interface {
+	// IsConfigured reports whether emails are actually delivered
+	IsConfigured() bool
+	SendDigest(ctx context.Context, to, locale string, d utils.DigestEmail) error
+}
+
+// Config tunes the Scheduler
+type Config struct {
+	// Interval is the wait between two looks for due digests
+	Interval time.Duration
+	// Hour (0-23, in the user's timezone) digests are sent at; weekly ones
+	// on Weekday
+	Hour    int
+	Weekday time.Weekday
+	// MaxNotifications caps the notifications summarized in one digest
+	MaxNotifications int
+	// NotificationsURL is the notifications page digests link to
+	NotificationsURL string
+}
+
+const (
+	// userTimeout bounds the digest of one user (queries and sending)
+	userTimeout = 30 * time.Second
+	// latestPerGroup is how many messages of a group are repeated
+	latestPerGroup = 3
+	// periodLayout formats the period of a digest
+	periodLayout = "2006-01-02 15:04"
+)
+
+// Scheduler sends the digests that are due
+type Scheduler struct {
+	prefs         repository.PreferenceRepository
+	notifications repository.NotificationRepository
+	users         repository.UserRepository
+	sender        Sender
+	cfg           Config
+	now           func() time.Time
+
+	tracker health.Worker
+
+	once sync.Once
+	stop chan struct{}
+	done chan struct{}
+}
+
+// NewScheduler creates a Scheduler sending digests of repos through sender
+func NewScheduler(repos repository.Repositories, sender Sender, cfg Config) *Scheduler {
+	if cfg.Interval <= 0 {
+		cfg.Interval = 5 * time.Minute
+	}
+	if cfg.MaxNotifications <= 0 {
+		cfg.MaxNotifications = 200
+	}
+	return &Scheduler{
+		prefs:         repos.Preferences,
+		notifications: repos.Notifications,
+		users:         repos.Users,
+		sender:        sender,
+		cfg:           cfg,
+		now:           time.Now,
+		stop:          make(chan struct{}),
+		done:          make(chan struct{}),
+	}
+}
+
+// Tracker tracks each user's digest (bounded by a 30s timeout), for
+// health.WorkerAlive
+func (s *Scheduler) Tracker() *health.Worker { return &s.tracker }
+
+// Start looks for due digests every Interval until Shutdown
+func (s *Scheduler) Start() {
+	go s.run()
+}
+
+// Shutdown stops the scheduler and waits for the digest being sent
+func (s *Scheduler) Shutdown(ctx context.Context) error {
+	s.once.Do(func() { close(s.stop) })
+	select {
+	case <-s.done:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+func (s *Scheduler) run() {
+	defer close(s.done)
+	t := time.NewTicker(s.cfg.Interval)
+	defer t.Stop()
+	for {
+		s.tick()
+		select {
+		case <-s.stop:
+			return
+		case <-t.C:
+		}
+	}
+}
+
+func (s *Scheduler) stopping() bool {
+	select {
+	case <-s.stop:
+		return true
+	default:
+		return false
+	}
+}
+
+// tick sends the digest of every user whose latest slot has not been sent
+func (s *Scheduler) tick() {
+	if !s.sender.IsConfigured() {
+		slog.Debug("email not configured, skipping notification digests")
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), userTimeout)
+	subscribers, err := s.prefs.DigestSubscribers(ctx)
+	cancel()
+	if err != nil {
+		slog.Error("listing digest subscribers failed", "error", err)
+		return
+	}
+	now := s.now()
+	for _, p := range subscribers {
+		if s.stopping() {
+			return
+		}
+		slot, ok := p.DigestSlot(now, s.cfg.Hour, s.cfg.Weekday)
+		if !ok || !p.DigestDue(slot) || !p.Email {
+			continue
+		}
+		if _, quiet := p.QuietUntil(now); quiet {
+			// ส่งหลังจบ quiet hours (tick ถัดไปยังเห็นว่าค้างอยู่)
+			continue
+		}
+		s.digest(p, slot)
+	}
+}
+
+// digest claims, builds and sends the digest of slot to p.UserID
+func (s *Scheduler) digest(p models.NotificationPreferences, slot time.Time) {
+	done := s.tracker.Start()
+	defer done()
+	ctx, cancel := context.WithTimeout(context.Background(), userTimeout)
+	defer cancel()
+	log := slog.With("user_id", p.UserID.String(), "frequency", p.DigestFrequency, "slot", slot)
+
+	claimed, err := s.prefs.ClaimDigest(ctx, p.UserID, slot)
+	if err != nil {
+		log.Error("claiming digest failed", "error", err)
+		return
+	}
+	if !claimed {
+		return // another replica has it
+	}
+
+	sent, err := s.send(ctx, p, slot)
+	if err != nil {
+		metrics.Digest(p.DigestFrequency, metrics.DigestFailed)
+		log.Error("sending digest failed", "error", err)
+		if utils.IsPermanentMailError(err) {
+			return // ส่งซ้ำก็ไม่ผ่าน ข้ามรอบนี้ไป
+		}
+		// ปล่อย claim ให้ tick ถัดไปลองใหม่
+		if err := s.prefs.ReleaseDigest(context.WithoutCancel(ctx), p.UserID, slot, p.LastDigestAt); err != nil {
+			log.Error("releasing digest failed", "error", err)
+		}
+		return
+	}
+	if sent == 0 {
+		metrics.Digest(p.DigestFrequency, metrics.DigestEmpty)
+		return
+	}
+	metrics.Digest(p.DigestFrequency, metrics.DigestSent)
+	log.Info("digest sent", "notifications", sent)
+}
+
+// send emails the unread notifications of the period ending at slot and
+// marks them digested; it returns how many there were
+func (s *Scheduler) send(ctx context.Context, p models.NotificationPreferences, slot time.Time) (int, error) {
+	items, err := s.notifications.ListForDigest(ctx, p.UserID, slot.Add(-p.DigestPeriod()), slot, s.cfg.MaxNotifications)
+	if err != nil {
+		return 0, fmt.Errorf("list notifications: %w", err)
+	}
+	trips, err := s.prefs.Trips(ctx, p.UserID)
+	if err != nil {
+		return 0, fmt.Errorf("load trip notification preferences: %w", err)
+	}
+	items = emailable(p, trips, items)
+	if len(items) == 0 {
+		return 0, nil
+	}
+
+	to, err := s.users.Email(ctx, p.UserID)
+	if errors.Is(err, repository.ErrNotFound) || (err == nil && to == "") {
+		return 0, nil // บัญชีถูกลบหรือไม่มีอีเมล
+	}
+	if err != nil {
+		return 0, fmt.Errorf("load email address: %w", err)
+	}
+	if err := s.sender.SendDigest(ctx, to, p.Locale, build(p, items, slot, s.cfg.NotificationsURL)); err != nil {
+		return 0, err
+	}
+
+	ids := make([]uuid.UUID, len(items))
+	for i, n := range items {
+		ids[i] = n.ID
+	}
+	if err := s.notifications.MarkDigested(ctx, ids, s.now()); err != nil {
+		// อีเมลออกไปแล้ว ไม่ปล่อย claim ไม่งั้นจะส่งซ้ำ
+		slog.ErrorContext(ctx, "marking notifications digested failed", "user_id", p.UserID.String(), "error", err)
+	}
+	return len(items), nil
+}
+
+// emailable keeps the notifications the digest is for: types the digest
+// summarizes (the others were emailed on their own) that the user gets by
+// email, also for their trip
+func emailable(p models.NotificationPreferences, trips []models.TripNotificationPreferences, items []models.Notification) []models.Notification {
+	byTrip := make(map[uuid.UUID]*models.TripNotificationPreferences, len(trips))
+	for i := range trips {
+		byTrip[trips[i].TripID] = &trips[i]
+	}
+	return slices.DeleteFunc(items, func(n models.Notification) bool {
+		var trip *models.TripNotificationPreferences
+		if n.TripID != nil {
+			trip = byTrip[*n.TripID]
+		}
+		typ := models.NotificationType(n.Type)
+		return !p.Digests(typ) || !p.Allows(models.ChannelEmail, typ, trip)
+	})
+}
+
+// build groups items (oldest first) by trip and type: the busiest trips and
+// types first, notifications not about a trip last
+func build(p models.NotificationPreferences, items []models.Notification, slot time.Time, link string) utils.DigestEmail {
+	loc := p.Location()
+	d := utils.DigestEmail{
+		Frequency: p.DigestFrequency,
+		From:      slot.Add(-p.DigestPeriod()).In(loc).Format(periodLayout),
+		To:        slot.In(loc).Format(periodLayout),
+		Total:     len(items),
+		Link:      link,
+	}
+
+	type tripGroups struct {
+		trip   utils.DigestTrip
+		groups map[string]int // type → index in trip.Groups
+	}
+	trips := make(map[uuid.UUID]*tripGroups)
+	var order []uuid.UUID
+	for i := len(items) - 1; i >= 0; i-- { // newest first
+		n := items[i]
+		key := uuid.Nil // not about a trip
+		if n.TripID != nil {
+			key = *n.TripID
+		}
+		t, ok := trips[key]
+		if !ok {
+			t = &tripGroups{groups: make(map[string]int)}
+			if key != uuid.Nil {
+				t.trip.Name, _ = n.Data["tripName"].(string)
+				if n.ActionURL != nil {
+					t.trip.Link = *n.ActionURL
+				}
+			}
+			trips[key] = t
+			order = append(order, key)
+		}
+		t.trip.Total++
+		gi, ok := t.groups[n.Type]
+		if !ok {
+			gi = len(t.trip.Groups)
+			t.groups[n.Type] = gi
+			t.trip.Groups = append(t.trip.Groups, utils.DigestGroup{Type: n.Type})
+		}
+		g := &t.trip.Groups[gi]
+		g.Count++
+		if len(g.Latest) < latestPerGroup {
+			text := n.Title
+			if n.Message != nil && *n.Message != "" {
+				text = *n.Message
+			}
+			g.Latest = append(g.Latest, text)
+		}
+	}
+
+	// ทริปที่มีแจ้งเตือนมากสุดก่อน แจ้งเตือนที่ไม่เกี่ยวกับทริปไว้ท้ายสุด
+	slices.SortStableFunc(order, func(a, b uuid.UUID) int {
+		if (a == uuid.Nil) != (b == uuid.Nil) {
+			if a == uuid.Nil {
+				return 1
+			}
+			return -1
+		}
+		return cmp.Compare(trips[b].trip.Total, trips[a].trip.Total)
+	})
+	for _, key := range order {
+		t := trips[key].trip
+		slices.SortStableFunc(t.Groups, func(a, b utils.DigestGroup) int { return cmp.Compare(b.Count, a.Count) })
+		d.Trips = append(d.Trips, t)
+	}
+	return d
+}
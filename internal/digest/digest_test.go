@@ -0,0 +1,139 @@
+package digest
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+
+	"GO2GETHER_BACK-END/internal/models"
+	"GO2GETHER_BACK-END/internal/repository/memory"
+	"GO2GETHER_BACK-END/internal/utils"
+)
+
+// fakeSender records the digests it is asked to send
+type fakeSender struct {
+	to      []string
+	digests []utils.DigestEmail
+}
+
+func (*fakeSender) IsConfigured() bool { return true }
+
+func (f *fakeSender) SendDigest(_ context.Context, to, _ string, d utils.DigestEmail) error {
+	f.to = append(f.to, to)
+	f.digests = append(f.digests, d)
+	return nil
+}
+
+func TestSchedulerSendsDigestOnce(t *testing.T) {
+	ctx := context.Background()
+	store := memory.New()
+	repos := store.Repositories()
+	u := store.PutUser(memory.User{Email: "member@example.com"})
+
+	p := models.DefaultNotificationPreferences(u.ID)
+	p.DigestFrequency = models.DigestDaily
+	p.Timezone = "Asia/Bangkok"
+	if err := repos.Preferences.Save(ctx, p); err != nil {
+		t.Fatal(err)
+	}
+
+	// slot ของวันนี้คือ 08:00 เวลากรุงเทพ
+	bangkok := time.FixedZone("ICT", 7*3600)
+	now := time.Date(2026, 10, 15, 9, 0, 0, 0, bangkok)
+	slot := time.Date(2026, 10, 15, 8, 0, 0, 0, bangkok)
+
+	trip := uuid.New()
+	insert := func(typ models.NotificationType, at time.Time) uuid.UUID {
+		t.Helper()
+		id := uuid.New()
+		if err := repos.Notifications.Insert(ctx, models.Notification{
+			ID: id, UserID: u.ID, TripID: &trip, Type: string(typ), Title: string(typ),
+			Data: map[string]any{"tripName": "Chiang Mai"}, CreatedAt: at,
+		}); err != nil {
+			t.Fatal(err)
+		}
+		return id
+	}
+	joined1 := insert(models.NotificationMemberJoined, slot.Add(-3*time.Hour))
+	joined2 := insert(models.NotificationMemberJoined, slot.Add(-2*time.Hour))
+	availability := insert(models.NotificationAvailability, slot.Add(-time.Hour))
+	update := insert(models.NotificationTripUpdate, slot.Add(-time.Hour))       // emailed on its own
+	tooOld := insert(models.NotificationMemberJoined, slot.Add(-25*time.Hour))  // previous period
+	tooNew := insert(models.NotificationMemberJoined, slot.Add(30*time.Minute)) // next period
+
+	sender := &fakeSender{}
+	s := NewScheduler(repos, sender, Config{Hour: 8, Weekday: time.Monday})
+	s.now = func() time.Time { return now }
+	s.tick()
+	s.tick() // claimed already: nothing more is sent
+
+	if len(sender.digests) != 1 {
+		t.Fatalf("sent %d digests, want 1", len(sender.digests))
+	}
+	if sender.to[0] != "member@example.com" {
+		t.Errorf("digest sent to %q", sender.to[0])
+	}
+	d := sender.digests[0]
+	if d.Total != 3 || len(d.Trips) != 1 {
+		t.Fatalf("digest total = %d, trips = %d; want 3, 1", d.Total, len(d.Trips))
+	}
+	if got := d.Trips[0]; got.Name != "Chiang Mai" || len(got.Groups) != 2 ||
+		got.Groups[0].Type != string(models.NotificationMemberJoined) || got.Groups[0].Count != 2 {
+		t.Errorf("unexpected trip summary %+v", got)
+	}
+
+	digested := map[uuid.UUID]bool{}
+	for _, n := range store.Notifications() {
+		digested[n.ID] = n.DigestedAt != nil
+	}
+	for id, want := range map[uuid.UUID]bool{
+		joined1: true, joined2: true, availability: true,
+		update: false, tooOld: false, tooNew: false,
+	} {
+		if digested[id] != want {
+			t.Errorf("notification %s digested = %v, want %v", id, digested[id], want)
+		}
+	}
+}
+
+func TestSchedulerSkipsDigestsNotDue(t *testing.T) {
+	ctx := context.Background()
+	store := memory.New()
+	repos := store.Repositories()
+
+	off := store.PutUser(memory.User{Email: "off@example.com"})
+	quiet := store.PutUser(memory.User{Email: "quiet@example.com"})
+	noEmail := store.PutUser(memory.User{Email: "no-email@example.com"})
+	now := time.Date(2026, 10, 15, 9, 0, 0, 0, time.UTC)
+
+	for _, u := range []memory.User{off, quiet, noEmail} {
+		p := models.DefaultNotificationPreferences(u.ID)
+		p.DigestFrequency = models.DigestDaily
+		switch u.ID {
+		case off.ID:
+			p.DigestFrequency = models.DigestOff
+		case quiet.ID:
+			p.QuietHours = &models.QuietHours{Start: 8 * 60, End: 10 * 60}
+		case noEmail.ID:
+			p.Email = false
+		}
+		if err := repos.Preferences.Save(ctx, p); err != nil {
+			t.Fatal(err)
+		}
+		if err := repos.Notifications.Insert(ctx, models.Notification{
+			UserID: u.ID, Type: string(models.NotificationMemberJoined), Title: "t", CreatedAt: now.Add(-2 * time.Hour),
+		}); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	sender := &fakeSender{}
+	s := NewScheduler(repos, sender, Config{Hour: 8})
+	s.now = func() time.Time { return now }
+	s.tick()
+	if len(sender.digests) != 0 {
+		t.Errorf("sent digests to %v, want none", sender.to)
+	}
+}
@@ -1,9 +1,11 @@
 package dto
 
+import "GO2GETHER_BACK-END/internal/apperr"
+
 // RegisterRequest represents the request payload for user registration
 type RegisterRequest struct {
-	Email    string `json:"email" validate:"required,email"`
-	Password string `json:"password" validate:"required,min=6"`
+	Email    string `json:"email" validate:"required,email,max=254"`
+	Password string `json:"password" validate:"required,min=6,max=72"`
 }
 
 // LoginRequest represents the request payload for user login
@@ -14,39 +16,101 @@ type LoginRequest struct {
 
 // AuthResponse represents the response after successful authentication
 type AuthResponse struct {
-	User  UserResponse `json:"user"`
-	Token string       `json:"token"`
+	User         UserResponse `json:"user"`
+	Token        string       `json:"token"`
+	RefreshToken string       `json:"refresh_token,omitempty"`
+	ExpiresIn    int64        `json:"expires_in,omitempty"` // access token lifetime in seconds
+}
+
+// RefreshTokenRequest represents the request to rotate a refresh token
+type RefreshTokenRequest struct {
+	RefreshToken string `json:"refresh_token" validate:"required" example:"3q2-7wAAAAB0ZXN0LXJlZnJlc2gtdG9rZW4"`
+}
+
+// TokenResponse represents a freshly issued access/refresh token pair
+type TokenResponse struct {
+	Token        string `json:"token" example:"eyJhbGciOiJIUzI1NiIsInR5cCI6IkpXVCJ9..."`
+	RefreshToken string `json:"refresh_token" example:"3q2-7wAAAAB0ZXN0LXJlZnJlc2gtdG9rZW4"`
+	ExpiresIn    int64  `json:"expires_in" example:"604800"`
+}
+
+// LogoutRequest represents the request to end the current session
+type LogoutRequest struct {
+	RefreshToken string `json:"refresh_token" validate:"required" example:"3q2-7wAAAAB0ZXN0LXJlZnJlc2gtdG9rZW4"`
+}
+
+// LogoutAllResponse represents the response after signing out of every device
+type LogoutAllResponse struct {
+	Message         string `json:"message" example:"Signed out from all devices"`
+	RevokedSessions int64  `json:"revoked_sessions" example:"3"`
 }
 
 // UserResponse represents user data in API responses
 type UserResponse struct {
-	ID        string `json:"id"`
-	Email     string `json:"email"`
-	CreatedAt string `json:"created_at"`
-	UpdatedAt string `json:"updated_at"`
+	ID              string  `json:"id"`
+	Email           string  `json:"email"`
+	EmailVerified   bool    `json:"email_verified"`
+	EmailVerifiedAt *string `json:"email_verified_at,omitempty"`
+	MFAEnabled      bool    `json:"mfa_enabled"`
+	CreatedAt       string  `json:"created_at"`
+	UpdatedAt       string  `json:"updated_at"`
 }
 
-// ErrorResponse represents an error response
+// VerifyEmailRequest represents the request to verify an email address
+type VerifyEmailRequest struct {
+	Email string `json:"email" validate:"required,email" example:"user@example.com"`
+	Code  string `json:"code" validate:"required" example:"123456"`
+}
+
+// VerifyEmailResponse represents the response after email verification
+type VerifyEmailResponse struct {
+	Message         string `json:"message" example:"Email verified successfully"`
+	EmailVerifiedAt string `json:"email_verified_at" example:"2025-10-27T23:39:00Z"`
+}
+
+// ResendVerificationResponse represents the response after resending the verification code
+type ResendVerificationResponse struct {
+	Message   string `json:"message" example:"Verification code has been sent to your email"`
+	Email     string `json:"email" example:"user@example.com"`
+	ExpiresIn string `json:"expires_in" example:"30 minutes"`
+}
+
+// DeleteAccountRequest represents the request to delete the current account
+type DeleteAccountRequest struct {
+	Password string `json:"password,omitempty" example:"password123"` // required for password accounts
+}
+
+// ErrorResponse is the RFC 7807 problem+json body of every error response.
+// error and message repeat title and detail for older clients.
 type ErrorResponse struct {
-	Error   string `json:"error"`
-	Message string `json:"message,omitempty"`
+	Type      string              `json:"type" example:"urn:go2gether:problem:validation_failed"`
+	Title     string              `json:"title" example:"Validation error"`
+	Status    int                 `json:"status" example:"400"`
+	Detail    string              `json:"detail,omitempty" example:"name is required"`
+	Instance  string              `json:"instance,omitempty" example:"/api/trips"`
+	Code      string              `json:"code" example:"validation_failed"`
+	RequestID string              `json:"request_id,omitempty" example:"9f1c2b7e4d3a8c60"`
+	Errors    []apperr.FieldError `json:"errors,omitempty"`
+
+	Error   string `json:"error" example:"Validation error"`
+	Message string `json:"message,omitempty" example:"name is required"`
 }
 
 // ForgotPasswordRequest represents the request to send verification code
 type ForgotPasswordRequest struct {
-	Email string `json:"email" example:"user@example.com"`
+	Email string `json:"email" validate:"required,email" example:"user@example.com"`
 }
 
 // VerifyOTPRequest represents the request to verify OTP code
 type VerifyOTPRequest struct {
-	Email string `json:"email" example:"user@example.com"`
-	Code  string `json:"code" example:"123456"`
+	Email string `json:"email" validate:"required,email" example:"user@example.com"`
+	Code  string `json:"code" validate:"required" example:"123456"`
 }
 
 // ResetPasswordRequest represents the request to reset password with reset token
 type ResetPasswordRequest struct {
-	ResetToken  string `json:"reset_token" example:"eyJhbGciOiJIUzI1NiIsInR5cCI6IkpXVCJ9..."`
-	NewPassword string `json:"new_password" example:"newPassword123"`
+	ResetToken  string `json:"reset_token" validate:"required" example:"eyJhbGciOiJIUzI1NiIsInR5cCI6IkpXVCJ9..."`
+	NewPassword string `json:"new_password" validate:"required,min=6,max=72" example:"newPassword123"`
 }
 
 // ForgotPasswordResponse represents the response after requesting password reset
@@ -70,7 +134,9 @@ type ResetPasswordResponse struct {
 
 // GetOTPRequest represents the request to get OTP code
 type GetOTPRequest struct {
-	Email string `json:"email" example:"user@example.com"`
+	Email string `json:"email" validate:"required,email" example:"user@example.com"`
+	// Purpose selects the code type; defaults to password_reset
+	Purpose string `json:"purpose,omitempty" validate:"oneof=password_reset email_verification" example:"password_reset" enums:"password_reset,email_verification"`
 }
 
 // GetOTPResponse represents the response with OTP code
@@ -81,3 +147,16 @@ type GetOTPResponse struct {
 	Used      bool   `json:"used" example:"false"`
 	CreatedAt string `json:"created_at" example:"2025-10-27T23:39:00Z"`
 }
+
+// SentEmailsResponse lists the emails captured by the test mail sink
+type SentEmailsResponse struct {
+	Emails []SentEmailResponse `json:"emails"`
+}
+
+// SentEmailResponse is one captured email
+type SentEmailResponse struct {
+	To      string `json:"to" example:"user@example.com"`
+	Subject string `json:"subject" example:"Password Reset Verification Code"`
+	Body    string `json:"body"`
+	SentAt  string `json:"sent_at" example:"2025-10-27T23:39:00Z"`
+}
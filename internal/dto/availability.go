@@ -21,7 +21,7 @@ type TripDatesResponse struct {
 
 // 2.2 Save availability
 type TripAvailabilityRequest struct {
-	Dates []string `json:"dates"` // array of "YYYY-MM-DD"
+	Dates []string `json:"dates" validate:"required,max=366,dive,date"` // array of "YYYY-MM-DD"
 }
 
 type TripAvailabilitySummary struct {
@@ -46,8 +46,8 @@ type TripMyAvailabilityResponse struct {
 
 // 2.4 Generate periods (request/response)
 type TripGeneratePeriodsRequest struct {
-	MinDays               int `json:"min_days"`                // ขั้นต่ำความยาวช่วง (วัน)
-	MinAvailabilityMember int `json:"min_availability_member"` // จำนวนสมาชิกขั้นต่ำที่ต้องว่าง "ทุกวัน" ในช่วง
+	MinDays               int `json:"min_days" validate:"min=0,max=366"`        // ขั้นต่ำความยาวช่วง (วัน)
+	MinAvailabilityMember int `json:"min_availability_member" validate:"min=0"` // จำนวนสมาชิกขั้นต่ำที่ต้องว่าง "ทุกวัน" ในช่วง
 }
 
 type TripGeneratedPeriod struct {
@@ -1,7 +1,31 @@
 package dto
 
+import "time"
+
 // HealthResponse represents the response structure for health checks
 type HealthResponse struct {
-	Status  string `json:"status"`
-	Details any    `json:"details,omitempty"`
+	Status  string              `json:"status"`
+	Details any                 `json:"details,omitempty"`
+	Checks  []HealthCheckResult `json:"checks,omitempty"`
+}
+
+// HealthCheckResult is the outcome of one dependency check
+type HealthCheckResult struct {
+	Name      string    `json:"name" example:"db"`
+	Status    string    `json:"status" example:"ok"` // ok | fail | warn (optional check failed)
+	Optional  bool      `json:"optional,omitempty"`
+	Error     string    `json:"error,omitempty"`
+	LatencyMs float64   `json:"latency_ms" example:"1.52"`
+	CheckedAt time.Time `json:"checked_at"`
+	Cached    bool      `json:"cached"`
+}
+
+// VersionResponse describes the running build and database schema
+type VersionResponse struct {
+	Commit          string `json:"commit" example:"4e3c3331f0d5"`
+	BuildTime       string `json:"build_time" example:"2026-10-01T08:00:00Z"`
+	Modified        bool   `json:"modified,omitempty"`
+	GoVersion       string `json:"go_version" example:"go1.24.2"`
+	SchemaVersion   int64  `json:"schema_version" example:"12"`
+	LatestMigration int64  `json:"latest_migration" example:"12"`
 }
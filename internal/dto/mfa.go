@@ -0,0 +1,56 @@
+package dto
+
+import (
+	"strings"
+
+	"GO2GETHER_BACK-END/internal/validate"
+)
+
+// MFAChallengeResponse is returned by login instead of tokens when the account
+// has two-factor authentication; send the token with a code to /api/auth/mfa/verify
+type MFAChallengeResponse struct {
+	MFARequired bool   `json:"mfa_required" example:"true"`
+	MFAToken    string `json:"mfa_token" example:"eyJhbGciOiJIUzI1NiIsInR5cCI6IkpXVCJ9..."`
+	ExpiresIn   int64  `json:"expires_in" example:"300"`
+}
+
+// MFAVerifyRequest completes a login with the authenticator code or a recovery code
+type MFAVerifyRequest struct {
+	MFAToken     string `json:"mfa_token" validate:"required" example:"eyJhbGciOiJIUzI1NiIsInR5cCI6IkpXVCJ9..."`
+	Code         string `json:"code,omitempty" example:"123456"`
+	RecoveryCode string `json:"recovery_code,omitempty" example:"ABCD-EFGH-JKLM-NPQR"`
+}
+
+// Check requires one of code and recovery_code
+func (r *MFAVerifyRequest) Check(v *validate.Errors) {
+	if strings.TrimSpace(r.Code) == "" && strings.TrimSpace(r.RecoveryCode) == "" {
+		v.Add("code", validate.CodeRequired, "code or recovery_code is required")
+	}
+}
+
+// MFACodeRequest confirms an MFA change with the authenticator code (or, to
+// disable, a recovery code)
+type MFACodeRequest struct {
+	Code         string `json:"code,omitempty" example:"123456"`
+	RecoveryCode string `json:"recovery_code,omitempty" example:"ABCD-EFGH-JKLM-NPQR"`
+}
+
+// MFASetupResponse carries the new TOTP secret; render ProvisioningURI as a QR code
+type MFASetupResponse struct {
+	Secret          string `json:"secret" example:"JBSWY3DPEHPK3PXPJBSWY3DPEHPK3PXP"`
+	ProvisioningURI string `json:"provisioning_uri" example:"otpauth://totp/Go2gether:user@example.com?secret=JBSWY3DPEHPK3PXP&issuer=Go2gether"`
+	Issuer          string `json:"issuer" example:"Go2gether"`
+	Account         string `json:"account" example:"user@example.com"`
+}
+
+// MFARecoveryCodesResponse shows new recovery codes; they are never shown again
+type MFARecoveryCodesResponse struct {
+	RecoveryCodes []string `json:"recovery_codes" example:"ABCD-EFGH-JKLM-NPQR"`
+}
+
+// MFAStatusResponse describes the current user's two-factor authentication
+type MFAStatusResponse struct {
+	Enabled                bool    `json:"enabled" example:"true"`
+	EnabledAt              *string `json:"enabled_at,omitempty" example:"2025-10-27T23:39:00Z"`
+	RecoveryCodesRemaining int     `json:"recovery_codes_remaining" example:"10"`
+}
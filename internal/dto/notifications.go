@@ -1,8 +1,19 @@
 package dto
 
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+
+	"GO2GETHER_BACK-END/internal/validate"
+)
+
 // NotificationItem ใช้สำหรับ list
 type NotificationItem struct {
 	ID        string         `json:"id"`
+	TripID    *string        `json:"trip_id,omitempty"`
 	Type      string         `json:"type"`
 	Title     string         `json:"title"`
 	Message   *string        `json:"message,omitempty"`
@@ -12,6 +23,14 @@ type NotificationItem struct {
 	CreatedAt string         `json:"created_at"`
 }
 
+// NotificationStreamMessage is a message of the notifications WebSocket:
+// type "notification" carries one, "resync" asks the client to reload the
+// list because missed notifications could not be replayed
+type NotificationStreamMessage struct {
+	Type         string            `json:"type"`
+	Notification *NotificationItem `json:"notification,omitempty"`
+}
+
 // NotificationListPagination
 type NotificationListPagination struct {
 	Total       int `json:"total"`
@@ -26,6 +45,92 @@ type NotificationListResponse struct {
 	Pagination    NotificationListPagination `json:"pagination"`
 }
 
+// QuietHoursLayout is the layout of quiet hours times (24-hour HH:MM)
+const QuietHoursLayout = "15:04"
+
+// NotificationChannels turns channels on or off; in trips a null channel
+// follows the user's setting
+type NotificationChannels struct {
+	InApp *bool `json:"in_app"`
+	Email *bool `json:"email"`
+	Push  *bool `json:"push"`
+}
+
+// QuietHours is a daily window, in the user's timezone, when notifications
+// arrive silently: they are stored but not streamed, and emails wait until
+// the end. A start after the end spans midnight.
+type QuietHours struct {
+	Start string `json:"start" example:"22:00"`
+	End   string `json:"end" example:"07:00"`
+}
+
+// TripNotificationPreferences are the settings of one trip
+type TripNotificationPreferences struct {
+	TripID   string               `json:"trip_id"`
+	Muted    bool                 `json:"muted"`
+	Channels NotificationChannels `json:"channels"`
+}
+
+// NotificationPreferencesRequest replaces the user's notification
+// preferences (PUT /api/notifications/preferences). Omitted channels are on;
+// trips not listed go back to the user's settings.
+type NotificationPreferencesRequest struct {
+	Channels        NotificationChannels          `json:"channels"`
+	MutedTypes      []string                      `json:"muted_types" validate:"dive,oneof=trip_invitation invitation_accepted invitation_declined trip_update availability_updated member_joined member_left"`
+	QuietHours      *QuietHours                   `json:"quiet_hours"`
+	Timezone        string                        `json:"timezone" validate:"max=64" example:"Asia/Bangkok"`                                           // IANA name, default UTC
+	Locale          string                        `json:"locale" validate:"oneof=en th" example:"th" enums:"en,th"`                                    // language of emails, default EMAIL_DEFAULT_LOCALE
+	DigestFrequency string                        `json:"digest_frequency" validate:"oneof=off daily weekly" example:"daily" enums:"off,daily,weekly"` // unread notifications in one email per day or week, default off
+	Trips           []TripNotificationPreferences `json:"trips" validate:"max=500"`
+}
+
+// Check validates quiet_hours, timezone and the trip IDs
+func (r *NotificationPreferencesRequest) Check(v *validate.Errors) {
+	if q := r.QuietHours; q != nil {
+		start, err1 := time.Parse(QuietHoursLayout, strings.TrimSpace(q.Start))
+		if err1 != nil {
+			v.Add("quiet_hours.start", validate.CodeInvalid, "quiet_hours.start must be HH:MM")
+		}
+		end, err2 := time.Parse(QuietHoursLayout, strings.TrimSpace(q.End))
+		if err2 != nil {
+			v.Add("quiet_hours.end", validate.CodeInvalid, "quiet_hours.end must be HH:MM")
+		}
+		if err1 == nil && err2 == nil && start.Equal(end) {
+			v.Add("quiet_hours.end", validate.CodeInvalid, "quiet_hours.end must differ from quiet_hours.start")
+		}
+	}
+	if tz := strings.TrimSpace(r.Timezone); tz != "" && !v.Has("timezone") {
+		if _, err := time.LoadLocation(tz); err != nil || strings.EqualFold(tz, "local") {
+			v.Add("timezone", validate.CodeInvalid, "timezone must be an IANA time zone such as Asia/Bangkok")
+		}
+	}
+	seen := make(map[uuid.UUID]bool, len(r.Trips))
+	for i, t := range r.Trips {
+		field := fmt.Sprintf("trips[%d].trip_id", i)
+		id, err := uuid.Parse(strings.TrimSpace(t.TripID))
+		switch {
+		case err != nil:
+			v.Add(field, validate.CodeInvalid, field+" must be a UUID")
+		case seen[id]:
+			v.Add(field, validate.CodeInvalid, "trip "+id.String()+" is listed twice")
+		}
+		seen[id] = true
+	}
+}
+
+// NotificationPreferencesResponse are the user's notification preferences
+type NotificationPreferencesResponse struct {
+	Channels        NotificationChannels          `json:"channels"`
+	MutedTypes      []string                      `json:"muted_types"`
+	QuietHours      *QuietHours                   `json:"quiet_hours"` // null when off
+	Timezone        string                        `json:"timezone"`
+	Locale          *string                       `json:"locale"`           // null when the default
+	DigestFrequency string                        `json:"digest_frequency"` // off, daily or weekly; sent at DIGEST_HOUR in timezone
+	LastDigestAt    *string                       `json:"last_digest_at"`   // RFC3339, null before the first
+	Trips           []TripNotificationPreferences `json:"trips"`
+	UpdatedAt       *string                       `json:"updated_at,omitempty"` // RFC3339, absent until first saved
+}
+
 // ---- (optional) สำหรับ mark read ทั้งหมดไม่มี body ----
 
 // ErrorResponse (คุณมีอยู่แล้วในโปรเจกต์)
@@ -0,0 +1,32 @@
+package dto
+
+// OAuthLoginResponse represents the response for OAuth login initiation
+type OAuthLoginResponse struct {
+	AuthURL string `json:"auth_url"`
+	State   string `json:"state"`
+}
+
+// AuthCodeExchangeRequest carries the one-time code from the OAuth callback redirect
+type AuthCodeExchangeRequest struct {
+	Code string `json:"code" validate:"required" example:"q1w2e3r4t5y6..."`
+}
+
+// OAuthProvidersResponse lists the enabled sign-in providers
+type OAuthProvidersResponse struct {
+	Providers []string `json:"providers" example:"google,line"`
+}
+
+// IdentityResponse is a sign-in provider linked to the current user
+type IdentityResponse struct {
+	Provider      string  `json:"provider" example:"google"`
+	Email         string  `json:"email" example:"user@gmail.com"`
+	EmailVerified bool    `json:"email_verified" example:"true"`
+	LinkedAt      string  `json:"linked_at" example:"2025-10-27T23:39:00Z"`
+	LastLoginAt   *string `json:"last_login_at,omitempty" example:"2025-10-28T08:00:00Z"`
+}
+
+// IdentitiesResponse lists the sign-in methods of the current user
+type IdentitiesResponse struct {
+	HasPassword bool               `json:"has_password" example:"true"`
+	Identities  []IdentityResponse `json:"identities"`
+}
@@ -1,42 +1,86 @@
 package dto
 
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"GO2GETHER_BACK-END/internal/validate"
+)
+
 // CreateTripRequest represents the payload to create a trip
 type CreateTripRequest struct {
-	Name        string `json:"name"`
-	Destination string `json:"destination"`
-	StartDate   string `json:"start_date"` // YYYY-MM-DD
-	EndDate     string `json:"end_date"`   // YYYY-MM-DD
-	Description string `json:"description"`
-	Status      string `json:"status"` // draft | published | cancelled
+	Name        string `json:"name" validate:"required,max=200"`
+	Destination string `json:"destination" validate:"required,max=200"`
+	StartDate   string `json:"start_date" validate:"required,datetime"` // YYYY-MM-DD
+	EndDate     string `json:"end_date" validate:"required,datetime"`   // YYYY-MM-DD
+	Description string `json:"description" validate:"max=5000"`
+	Status      string `json:"status" validate:"oneof=draft published cancelled"`
 
 	// NEW: budget ต่อหมวด
-	Food      float64 `json:"food"`
-	Hotel     float64 `json:"hotel"`
-	Shopping  float64 `json:"shopping"`
-	Transport float64 `json:"transport"`
+	Food      float64 `json:"food" validate:"currency"`
+	Hotel     float64 `json:"hotel" validate:"currency"`
+	Shopping  float64 `json:"shopping" validate:"currency"`
+	Transport float64 `json:"transport" validate:"currency"`
 
 	// ยังรองรับของเก่า
-	TotalBudget float64 `json:"total_budget"`
-	Currency    string  `json:"currency"`
+	TotalBudget float64 `json:"total_budget" validate:"currency"`
+	Currency    string  `json:"currency" validate:"iso4217"`
+}
+
+// Check requires end_date on or after start_date
+func (r *CreateTripRequest) Check(v *validate.Errors) {
+	checkDateRange(v, r.StartDate, r.EndDate, validate.ParseDateTime)
 }
 
 // UpdateTripRequest represents fields allowed to update a trip
 // All fields are optional; only provided ones will be updated
 type UpdateTripRequest struct {
-	Name        *string `json:"name"`
-	Destination *string `json:"destination"`
-	Description *string `json:"description"`
-	StartDate   *string `json:"start_date"` // YYYY-MM-DD
-	EndDate     *string `json:"end_date"`   // YYYY-MM-DD
+	Name        *string `json:"name" validate:"max=200"`
+	Destination *string `json:"destination" validate:"max=200"`
+	Description *string `json:"description" validate:"max=5000"`
+	StartDate   *string `json:"start_date" validate:"date"` // YYYY-MM-DD
+	EndDate     *string `json:"end_date" validate:"date"`   // YYYY-MM-DD
 
 	// NEW: budget ต่อหมวด (optional)
-	Food      *float64 `json:"food,omitempty"`
-	Hotel     *float64 `json:"hotel,omitempty"`
-	Shopping  *float64 `json:"shopping,omitempty"`
-	Transport *float64 `json:"transport,omitempty"`
+	Food      *float64 `json:"food,omitempty" validate:"currency"`
+	Hotel     *float64 `json:"hotel,omitempty" validate:"currency"`
+	Shopping  *float64 `json:"shopping,omitempty" validate:"currency"`
+	Transport *float64 `json:"transport,omitempty" validate:"currency"`
+
+	TotalBudget *float64 `json:"total_budget,omitempty" validate:"currency"`
+	Status      *string  `json:"status" validate:"oneof=draft published cancelled"`
 
-	TotalBudget *float64 `json:"total_budget,omitempty"`
-	Status      *string  `json:"status"` // draft | published | cancelled
+	// RequireOrganizerMFA can only be changed by the trip owner
+	RequireOrganizerMFA *bool `json:"require_organizer_mfa,omitempty"`
+}
+
+// Check rejects a blank name or destination and, when both dates are sent,
+// end_date before start_date. A single date is checked against the trip by
+// the service.
+func (r *UpdateTripRequest) Check(v *validate.Errors) {
+	if r.Name != nil && strings.TrimSpace(*r.Name) == "" {
+		v.Add("name", validate.CodeRequired, "name cannot be empty")
+	}
+	if r.Destination != nil && strings.TrimSpace(*r.Destination) == "" {
+		v.Add("destination", validate.CodeRequired, "destination cannot be empty")
+	}
+	if r.StartDate != nil && r.EndDate != nil {
+		checkDateRange(v, *r.StartDate, *r.EndDate, validate.ParseDate)
+	}
+}
+
+// checkDateRange adds an end_date error when both dates parse and end is
+// before start
+func checkDateRange(v *validate.Errors, start, end string, parse func(string) (time.Time, error)) {
+	if v.Has("start_date") || v.Has("end_date") {
+		return
+	}
+	s, err1 := parse(start)
+	e, err2 := parse(end)
+	if err1 == nil && err2 == nil && e.Before(s) {
+		v.Add("end_date", validate.CodeOutOfRange, "end_date cannot be before start_date")
+	}
 }
 
 // TripResponse represents a trip object in responses
@@ -108,10 +152,17 @@ type TripMember struct {
 
 // TripPermissions for detail
 type TripPermissions struct {
-	CanEdit         bool `json:"can_edit"`
-	CanDelete       bool `json:"can_delete"`
-	CanInvite       bool `json:"can_invite"`
-	CanManageBudget bool `json:"can_manage_budget"`
+	Role                 string `json:"role"` // owner | co_organizer | member | viewer
+	CanEdit              bool   `json:"can_edit"`
+	CanDelete            bool   `json:"can_delete"`
+	CanInvite            bool   `json:"can_invite"`
+	CanManageBudget      bool   `json:"can_manage_budget"`
+	CanFinalizeDates     bool   `json:"can_finalize_dates"`
+	CanRemoveMembers     bool   `json:"can_remove_members"`
+	CanManageRoles       bool   `json:"can_manage_roles"`
+	CanTransferOwnership bool   `json:"can_transfer_ownership"`
+	// MFARequired: the requester is a co-organizer who must enable two-factor auth first
+	MFARequired bool `json:"mfa_required"`
 }
 
 // TripStats for detail
@@ -137,6 +188,8 @@ type TripDetailTrip struct {
 	CreatedAt   string  `json:"created_at"`
 	UpdatedAt   string  `json:"updated_at"`
 
+	RequireOrganizerMFA bool `json:"require_organizer_mfa"`
+
 	// NEW
 	Budget TripBudgetResponse `json:"budget"`
 }
@@ -152,17 +205,32 @@ type TripDetailResponse struct {
 // ====== FR3: Invitations & Membership ======
 
 // 3.1 Invite members (via link)
-// TripInviteRequest is empty - no request body needed
-type TripInviteRequest struct{}
+// TripInviteRequest is optional - without a body only the link is generated
+type TripInviteRequest struct {
+	Emails []string `json:"emails" validate:"max=20,dive,email" example:"friend@example.com"` // also email the link to these addresses
+}
 type TripInviteResponse struct {
 	InvitationLink string `json:"invitation_link"`
 	ExpiresAt      string `json:"expires_at"` // RFC3339
 	Message        string `json:"message"`
+	EmailsSent     int    `json:"emails_sent,omitempty"`
+}
+
+// Check rejects an address listed twice
+func (r *TripInviteRequest) Check(v *validate.Errors) {
+	seen := make(map[string]bool, len(r.Emails))
+	for i, e := range r.Emails {
+		e = strings.ToLower(strings.TrimSpace(e))
+		if seen[e] {
+			v.Add(fmt.Sprintf("emails[%d]", i), validate.CodeInvalid, e+" is listed twice")
+		}
+		seen[e] = true
+	}
 }
 
 // Join via invitation link
 type TripJoinViaLinkRequest struct {
-	InvitationToken string `json:"invitation_token"`
+	InvitationToken string `json:"invitation_token" validate:"required"`
 }
 type TripJoinViaLinkResponse struct {
 	Message string `json:"message"`
@@ -200,6 +268,30 @@ type TripInvitationsListResponse struct {
 	Stats       TripInvitationsStats     `json:"stats"`
 }
 
+// 3.7 Promote / demote a member
+type TripMemberRoleRequest struct {
+	Role string `json:"role" validate:"required,oneof=co_organizer member viewer"`
+}
+type TripMemberRoleResponse struct {
+	Message string `json:"message"`
+	Member  struct {
+		UserID string `json:"user_id"`
+		Role   string `json:"role"`
+		Status string `json:"status"`
+	} `json:"member"`
+}
+
+// 3.8 Transfer ownership
+type TripTransferOwnershipRequest struct {
+	UserID string `json:"user_id" validate:"required,uuid"` // สมาชิกที่ accepted แล้ว
+}
+type TripTransferOwnershipResponse struct {
+	Message         string `json:"message"`
+	TripID          string `json:"trip_id"`
+	OwnerID         string `json:"owner_id"`
+	PreviousOwnerID string `json:"previous_owner_id"`
+}
+
 // NEW: budget breakdown ใน response
 type TripBudgetResponse struct {
 	Food      float64 `json:"food"`
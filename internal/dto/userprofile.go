@@ -2,14 +2,14 @@ package dto
 
 // รับ Body จาก POST /api/profile
 type ProfileCreateRequest struct {
-	Username         string  `json:"username"`
-	FirstName        *string `json:"first_name"`
-	LastName         *string `json:"last_name"`
-	DisplayName      *string `json:"display_name"`
-	AvatarURL        *string `json:"avatar_url"`
-	Phone            *string `json:"phone"`
-	Bio              *string `json:"bio"`
-	BirthDate        *string `json:"birth_date"` // "YYYY-MM-DD" หรือ RFC3339
+	Username         string  `json:"username" validate:"required,max=50"`
+	FirstName        *string `json:"first_name" validate:"max=100"`
+	LastName         *string `json:"last_name" validate:"max=100"`
+	DisplayName      *string `json:"display_name" validate:"max=100"`
+	AvatarURL        *string `json:"avatar_url" validate:"max=2048"`
+	Phone            *string `json:"phone" validate:"max=32"`
+	Bio              *string `json:"bio" validate:"max=2000"`
+	BirthDate        *string `json:"birth_date" validate:"datetime"` // "YYYY-MM-DD" หรือ RFC3339
 	FoodPreferences  *string `json:"food_preferences"`
 	ChronicDisease   *string `json:"chronic_disease"`
 	AllergicFood     *string `json:"allergic_food"`
@@ -50,19 +50,19 @@ type ProfileGetResponse struct {
 }
 
 type ProfileUpdateRequest struct {
-	Username         *string `json:"username"`
-	FirstName        *string `json:"first_name"`
-	LastName         *string `json:"last_name"`
-	DisplayName      *string `json:"display_name"`
-	AvatarURL        *string `json:"avatar_url"`        // "" => NULL
-	Phone            *string `json:"phone"`             // "" => NULL
-	Bio              *string `json:"bio"`               // "" => NULL
-	BirthDate        *string `json:"birth_date"`        // "" => NULL, else "YYYY-MM-DD" or RFC3339
-	FoodPreferences  *string `json:"food_preferences"`  // "" => NULL
-	ChronicDisease   *string `json:"chronic_disease"`   // "" => NULL
-	AllergicFood     *string `json:"allergic_food"`     // "" => NULL
-	AllergicDrugs    *string `json:"allergic_drugs"`    // "" => NULL
-	EmergencyContact *string `json:"emergency_contact"` // "" => NULL
+	Username         *string `json:"username" validate:"max=50"`
+	FirstName        *string `json:"first_name" validate:"max=100"`
+	LastName         *string `json:"last_name" validate:"max=100"`
+	DisplayName      *string `json:"display_name" validate:"max=100"`
+	AvatarURL        *string `json:"avatar_url" validate:"max=2048"` // "" => NULL
+	Phone            *string `json:"phone" validate:"max=32"`        // "" => NULL
+	Bio              *string `json:"bio" validate:"max=2000"`        // "" => NULL
+	BirthDate        *string `json:"birth_date" validate:"datetime"` // "" => NULL, else "YYYY-MM-DD" or RFC3339
+	FoodPreferences  *string `json:"food_preferences"`               // "" => NULL
+	ChronicDisease   *string `json:"chronic_disease"`                // "" => NULL
+	AllergicFood     *string `json:"allergic_food"`                  // "" => NULL
+	AllergicDrugs    *string `json:"allergic_drugs"`                 // "" => NULL
+	EmergencyContact *string `json:"emergency_contact"`              // "" => NULL
 }
 
 // ProfileCheckResponse สำหรับ GET /api/profile/check
@@ -2,14 +2,20 @@ package handlers
 
 import (
 	"context"
-	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"math"
 	"net/http"
+	"strconv"
 	"time"
 
 	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
 	"github.com/jackc/pgx/v5/pgxpool"
 	"golang.org/x/crypto/bcrypt"
 
+	"GO2GETHER_BACK-END/internal/apperr"
 	"GO2GETHER_BACK-END/internal/config"
 	"GO2GETHER_BACK-END/internal/dto"
 	"GO2GETHER_BACK-END/internal/middleware"
@@ -19,13 +25,20 @@ import (
 
 // AuthHandler handles authentication-related HTTP requests
 type AuthHandler struct {
-	db     *pgxpool.Pool
-	config *config.Config
+	db           *pgxpool.Pool
+	config       *config.Config
+	sessions     SessionsService
+	emailService *utils.EmailService
 }
 
 // NewAuthHandler creates a new AuthHandler instance
 func NewAuthHandler(db *pgxpool.Pool, cfg *config.Config) *AuthHandler {
-	return &AuthHandler{db: db, config: cfg}
+	return &AuthHandler{
+		db:           db,
+		config:       cfg,
+		sessions:     NewSessionsService(db, &cfg.JWT),
+		emailService: utils.NewEmailService(&cfg.Email),
+	}
 }
 
 // Register handles user registration
@@ -41,20 +54,8 @@ func NewAuthHandler(db *pgxpool.Pool, cfg *config.Config) *AuthHandler {
 // @Failure 500 {object} dto.ErrorResponse "Internal server error"
 // @Router /api/auth/register [post]
 func (h *AuthHandler) Register(w http.ResponseWriter, r *http.Request) {
-	if r.Method != http.MethodPost {
-		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
-		return
-	}
-
 	var req dto.RegisterRequest
-	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
-		utils.WriteErrorResponse(w, http.StatusBadRequest, "Invalid request body", err.Error())
-		return
-	}
-
-	// Validate required fields
-	if req.Email == "" || req.Password == "" {
-		utils.WriteErrorResponse(w, http.StatusBadRequest, "Missing required fields", "Email and password are required")
+	if !utils.DecodeJSON(w, r, &req) {
 		return
 	}
 
@@ -65,14 +66,14 @@ func (h *AuthHandler) Register(w http.ResponseWriter, r *http.Request) {
 		req.Email).Scan(&existingUserID)
 
 	if err == nil {
-		utils.WriteErrorResponse(w, http.StatusConflict, "User already exists", "Email already registered")
+		utils.WriteError(w, r, errEmailTaken)
 		return
 	}
 
 	// Hash password
 	hashedPassword, err := bcrypt.GenerateFromPassword([]byte(req.Password), bcrypt.DefaultCost)
 	if err != nil {
-		utils.WriteErrorResponse(w, http.StatusInternalServerError, "Failed to hash password", err.Error())
+		utils.WriteError(w, r, err)
 		return
 	}
 
@@ -86,14 +87,21 @@ func (h *AuthHandler) Register(w http.ResponseWriter, r *http.Request) {
 		userID, req.Email, string(hashedPassword), now, now)
 
 	if err != nil {
-		utils.WriteErrorResponse(w, http.StatusInternalServerError, "Failed to create user", err.Error())
+		// a concurrent sign-up with the same email is a unique violation: 409
+		utils.WriteError(w, r, err)
 		return
 	}
 
-	// Generate JWT token
-	token, err := middleware.GenerateToken(userID, req.Email, &h.config.JWT)
+	// Send the email verification code; the account works without it unless
+	// REQUIRE_VERIFIED_EMAIL is set, so a delivery failure must not fail sign-up
+	if err := sendEmailVerification(r.Context(), h.db, h.config, h.emailService, userID, req.Email); err != nil {
+		slog.WarnContext(r.Context(), "sending verification email failed", "user_id", userID.String(), "error", err)
+	}
+
+	// Issue access + refresh token
+	tokens, err := h.sessions.Issue(r.Context(), userID, req.Email, sessionClient(r))
 	if err != nil {
-		utils.WriteErrorResponse(w, http.StatusInternalServerError, "Failed to generate token", err.Error())
+		utils.WriteError(w, r, err)
 		return
 	}
 
@@ -106,16 +114,13 @@ func (h *AuthHandler) Register(w http.ResponseWriter, r *http.Request) {
 	}
 
 	// Convert user to DTO
-	userResponse := dto.UserResponse{
-		ID:        user.ID.String(),
-		Email:     user.Email,
-		CreatedAt: user.CreatedAt.Format(time.RFC3339),
-		UpdatedAt: user.UpdatedAt.Format(time.RFC3339),
-	}
+	userResponse := toUserResponse(user)
 
 	response := dto.AuthResponse{
-		User:  userResponse,
-		Token: token,
+		User:         userResponse,
+		Token:        tokens.AccessToken,
+		RefreshToken: tokens.RefreshToken,
+		ExpiresIn:    tokens.ExpiresIn,
 	}
 
 	utils.WriteJSONResponse(w, http.StatusCreated, response)
@@ -123,56 +128,78 @@ func (h *AuthHandler) Register(w http.ResponseWriter, r *http.Request) {
 
 // Login handles user login
 // @Summary Login user
-// @Description Authenticate user with email and password
+// @Description Authenticate user with email and password. Accounts with two-factor authentication get `mfa_required` and an `mfa_token` to complete at /api/auth/mfa/verify.
 // @Tags authentication
 // @Accept json
 // @Produce json
 // @Param request body dto.LoginRequest true "Login credentials"
-// @Success 200 {object} dto.AuthResponse "Login successful"
+// @Success 200 {object} dto.AuthResponse "Login successful (or dto.MFAChallengeResponse when two-factor authentication is enabled)"
 // @Failure 400 {object} dto.ErrorResponse "Invalid request data"
-// @Failure 401 {object} dto.ErrorResponse "Invalid credentials"
+// @Failure 401 {object} dto.ErrorResponse "Invalid credentials, or the account is temporarily locked"
+// @Failure 429 {object} dto.ErrorResponse "Too many requests"
 // @Failure 500 {object} dto.ErrorResponse "Internal server error"
 // @Router /api/auth/login [post]
 func (h *AuthHandler) Login(w http.ResponseWriter, r *http.Request) {
-	if r.Method != http.MethodPost {
-		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
-		return
-	}
-
 	var req dto.LoginRequest
-	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
-		utils.WriteErrorResponse(w, http.StatusBadRequest, "Invalid request body", err.Error())
-		return
-	}
-
-	// Validate required fields
-	if req.Email == "" || req.Password == "" {
-		utils.WriteErrorResponse(w, http.StatusBadRequest, "Missing required fields", "Email and password are required")
+	if !utils.DecodeJSON(w, r, &req) {
 		return
 	}
 
 	// Get user from database
 	var user models.User
+	var failedAttempts int32
+	var lockedUntil *time.Time
 	err := h.db.QueryRow(context.Background(),
-		`SELECT id, email, password_hash, created_at, updated_at FROM users WHERE email = $1`,
-		req.Email).Scan(&user.ID, &user.Email, &user.PasswordHash, &user.CreatedAt, &user.UpdatedAt)
+		`SELECT id, email, password_hash, email_verified_at, totp_enabled_at, failed_login_attempts, locked_until, created_at, updated_at
+		 FROM users WHERE email = $1`,
+		req.Email).Scan(&user.ID, &user.Email, &user.PasswordHash, &user.EmailVerifiedAt, &user.TOTPEnabledAt, &failedAttempts, &lockedUntil, &user.CreatedAt, &user.UpdatedAt)
 
 	if err != nil {
-		utils.WriteErrorResponse(w, http.StatusUnauthorized, "Invalid credentials", "Email or password is incorrect")
+		if errors.Is(err, pgx.ErrNoRows) {
+			err = errInvalidCredentials
+		}
+		utils.WriteError(w, r, err)
 		return
 	}
 
-	// Verify password
-	err = bcrypt.CompareHashAndPassword([]byte(user.PasswordHash), []byte(req.Password))
-	if err != nil {
-		utils.WriteErrorResponse(w, http.StatusUnauthorized, "Invalid credentials", "Email or password is incorrect")
+	// The hash is compared even for locked accounts so they answer as fast as
+	// a wrong password
+	passwordErr := bcrypt.CompareHashAndPassword([]byte(user.PasswordHash), []byte(req.Password))
+
+	// Refuse while locked, even with the right password, so guessing cannot
+	// continue. The answer is the same 401 as a wrong password: a 423 would
+	// reveal which emails have accounts (and, sent only for the right
+	// password, the password itself).
+	if lockedUntil != nil && time.Now().Before(*lockedUntil) {
+		utils.WriteError(w, r, errInvalidCredentials)
+		return
+	}
+
+	if passwordErr != nil {
+		if _, err := recordFailedLogin(r.Context(), h.db, &h.config.Auth, user.ID); err != nil {
+			slog.ErrorContext(r.Context(), "recording failed login failed", "error", err, "user_id", user.ID.String())
+		}
+		utils.WriteError(w, r, errInvalidCredentials)
+		return
+	}
+
+	if failedAttempts > 0 || lockedUntil != nil {
+		if _, err := h.db.Exec(r.Context(),
+			`UPDATE users SET failed_login_attempts = 0, locked_until = NULL WHERE id = $1`, user.ID); err != nil {
+			slog.ErrorContext(r.Context(), "resetting failed logins failed", "error", err, "user_id", user.ID.String())
+		}
+	}
+
+	// With two-factor authentication the password only earns a challenge
+	if user.TOTPEnabledAt != nil {
+		writeMFAChallenge(w, r, h.config, user.ID, "password")
 		return
 	}
 
-	// Generate JWT token
-	token, err := middleware.GenerateToken(user.ID, user.Email, &h.config.JWT)
+	// Issue access + refresh token
+	tokens, err := h.sessions.Issue(r.Context(), user.ID, user.Email, sessionClient(r))
 	if err != nil {
-		utils.WriteErrorResponse(w, http.StatusInternalServerError, "Failed to generate token", err.Error())
+		utils.WriteError(w, r, err)
 		return
 	}
 
@@ -180,16 +207,13 @@ func (h *AuthHandler) Login(w http.ResponseWriter, r *http.Request) {
 	user.PasswordHash = ""
 
 	// Convert user to DTO
-	userResponse := dto.UserResponse{
-		ID:        user.ID.String(),
-		Email:     user.Email,
-		CreatedAt: user.CreatedAt.Format(time.RFC3339),
-		UpdatedAt: user.UpdatedAt.Format(time.RFC3339),
-	}
+	userResponse := toUserResponse(user)
 
 	response := dto.AuthResponse{
-		User:  userResponse,
-		Token: token,
+		User:         userResponse,
+		Token:        tokens.AccessToken,
+		RefreshToken: tokens.RefreshToken,
+		ExpiresIn:    tokens.ExpiresIn,
 	}
 
 	utils.WriteJSONResponse(w, http.StatusOK, response)
@@ -208,36 +232,244 @@ func (h *AuthHandler) Login(w http.ResponseWriter, r *http.Request) {
 // @Failure 500 {object} dto.ErrorResponse "Internal server error"
 // @Router /api/auth/profile [get]
 func (h *AuthHandler) GetProfile(w http.ResponseWriter, r *http.Request) {
-	if r.Method != http.MethodGet {
-		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
-		return
-	}
-
 	// Get user ID from context (set by AuthMiddleware)
 	userID, ok := r.Context().Value("user_id").(uuid.UUID)
 	if !ok {
-		utils.WriteErrorResponse(w, http.StatusUnauthorized, "Unauthorized", "User not authenticated")
+		utils.WriteError(w, r, errNotAuthenticated)
 		return
 	}
 
 	// Get user from database
 	var user models.User
 	err := h.db.QueryRow(context.Background(),
-		`SELECT id, email, created_at, updated_at FROM users WHERE id = $1`,
-		userID).Scan(&user.ID, &user.Email, &user.CreatedAt, &user.UpdatedAt)
+		`SELECT id, email, email_verified_at, totp_enabled_at, created_at, updated_at FROM users WHERE id = $1`,
+		userID).Scan(&user.ID, &user.Email, &user.EmailVerifiedAt, &user.TOTPEnabledAt, &user.CreatedAt, &user.UpdatedAt)
 
 	if err != nil {
-		utils.WriteErrorResponse(w, http.StatusNotFound, "User not found", err.Error())
+		if errors.Is(err, pgx.ErrNoRows) {
+			err = errUserNotFound
+		}
+		utils.WriteError(w, r, err)
 		return
 	}
 
 	// Convert user to DTO
-	userResponse := dto.UserResponse{
-		ID:        user.ID.String(),
-		Email:     user.Email,
-		CreatedAt: user.CreatedAt.Format(time.RFC3339),
-		UpdatedAt: user.UpdatedAt.Format(time.RFC3339),
-	}
+	userResponse := toUserResponse(user)
 
 	utils.WriteJSONResponse(w, http.StatusOK, userResponse)
 }
+
+// RefreshToken exchanges a refresh token for a new access/refresh token pair
+// @Summary Refresh access token
+// @Description Rotate a refresh token. The presented token is invalidated; reusing it later revokes every session in its family.
+// @Tags authentication
+// @Accept json
+// @Produce json
+// @Param request body dto.RefreshTokenRequest true "Refresh token"
+// @Success 200 {object} dto.TokenResponse "Tokens refreshed successfully"
+// @Failure 400 {object} dto.ErrorResponse "Invalid request data"
+// @Failure 401 {object} dto.ErrorResponse "Invalid, expired or reused refresh token"
+// @Failure 500 {object} dto.ErrorResponse "Internal server error"
+// @Router /api/auth/refresh [post]
+func (h *AuthHandler) RefreshToken(w http.ResponseWriter, r *http.Request) {
+	var req dto.RefreshTokenRequest
+	if !utils.DecodeJSON(w, r, &req) {
+		return
+	}
+
+	tokens, err := h.sessions.Rotate(r.Context(), req.RefreshToken, sessionClient(r))
+	if err != nil {
+		switch {
+		case errors.Is(err, ErrSessionReused):
+			utils.WriteError(w, r, errRefreshReused)
+		case errors.Is(err, ErrSessionExpired):
+			utils.WriteError(w, r, errRefreshExpired)
+		case errors.Is(err, ErrSessionNotFound), errors.Is(err, ErrSessionRevoked):
+			utils.WriteError(w, r, errRefreshInvalid)
+		default:
+			utils.WriteError(w, r, err)
+		}
+		return
+	}
+
+	utils.WriteJSONResponse(w, http.StatusOK, dto.TokenResponse{
+		Token:        tokens.AccessToken,
+		RefreshToken: tokens.RefreshToken,
+		ExpiresIn:    tokens.ExpiresIn,
+	})
+}
+
+// Logout revokes the session the refresh token belongs to
+// @Summary Logout
+// @Description Revoke the current session (refresh token family). The access token stays valid until it expires.
+// @Tags authentication
+// @Accept json
+// @Produce json
+// @Param request body dto.LogoutRequest true "Refresh token of the session to end"
+// @Success 200 {object} map[string]string "Logged out successfully"
+// @Failure 400 {object} dto.ErrorResponse "Invalid request data"
+// @Failure 500 {object} dto.ErrorResponse "Internal server error"
+// @Router /api/auth/logout [post]
+func (h *AuthHandler) Logout(w http.ResponseWriter, r *http.Request) {
+	var req dto.LogoutRequest
+	if !utils.DecodeJSON(w, r, &req) {
+		return
+	}
+
+	// Unknown tokens are treated as already logged out so logout stays idempotent
+	if err := h.sessions.Revoke(r.Context(), req.RefreshToken); err != nil && !errors.Is(err, ErrSessionNotFound) {
+		utils.WriteError(w, r, err)
+		return
+	}
+
+	utils.WriteJSONResponse(w, http.StatusOK, map[string]string{
+		"message": "Logged out successfully",
+	})
+}
+
+// LogoutAll revokes every session of the current user
+// @Summary Logout from all devices
+// @Description Revoke every refresh-token session of the authenticated user and invalidate all access tokens issued so far
+// @Tags authentication
+// @Produce json
+// @Security BearerAuth
+// @Success 200 {object} dto.LogoutAllResponse "Signed out from all devices"
+// @Failure 401 {object} dto.ErrorResponse "Unauthorized"
+// @Failure 500 {object} dto.ErrorResponse "Internal server error"
+// @Router /api/auth/logout-all [post]
+func (h *AuthHandler) LogoutAll(w http.ResponseWriter, r *http.Request) {
+	userID, ok := r.Context().Value("user_id").(uuid.UUID)
+	if !ok {
+		utils.WriteError(w, r, errNotAuthenticated)
+		return
+	}
+
+	revoked, err := h.sessions.RevokeAll(r.Context(), userID)
+	if err != nil {
+		utils.WriteError(w, r, err)
+		return
+	}
+
+	utils.WriteJSONResponse(w, http.StatusOK, dto.LogoutAllResponse{
+		Message:         "Signed out from all devices",
+		RevokedSessions: revoked,
+	})
+}
+
+// DeleteAccount permanently deletes the current user's account
+// @Summary Delete account
+// @Description Delete the authenticated user's account and all of its data. Password accounts must confirm with their password. Every issued token stops working immediately.
+// @Tags authentication
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Param request body dto.DeleteAccountRequest false "Password confirmation"
+// @Success 200 {object} map[string]string "Account deleted"
+// @Failure 400 {object} dto.ErrorResponse "Invalid request data"
+// @Failure 401 {object} dto.ErrorResponse "Unauthorized or wrong password"
+// @Failure 404 {object} dto.ErrorResponse "User not found"
+// @Failure 500 {object} dto.ErrorResponse "Internal server error"
+// @Router /api/auth/account [delete]
+func (h *AuthHandler) DeleteAccount(w http.ResponseWriter, r *http.Request) {
+	userID, ok := r.Context().Value("user_id").(uuid.UUID)
+	if !ok {
+		utils.WriteError(w, r, errNotAuthenticated)
+		return
+	}
+
+	var req dto.DeleteAccountRequest
+	if r.ContentLength != 0 {
+		if !utils.DecodeJSON(w, r, &req) {
+			return
+		}
+	}
+
+	var passwordHash string
+	err := h.db.QueryRow(r.Context(),
+		`SELECT password_hash FROM users WHERE id = $1`, userID).Scan(&passwordHash)
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			err = errUserNotFound
+		}
+		utils.WriteError(w, r, err)
+		return
+	}
+
+	// Google-only accounts have no password to confirm
+	if passwordHash != "" {
+		if req.Password == "" {
+			utils.WriteError(w, r, apperr.Validation(apperr.Field("password", "required", "Password is required to delete this account")))
+			return
+		}
+		if bcrypt.CompareHashAndPassword([]byte(passwordHash), []byte(req.Password)) != nil {
+			utils.WriteError(w, r, apperr.New(http.StatusUnauthorized, apperr.CodeUnauthorized, "Invalid credentials", "Password is incorrect"))
+			return
+		}
+	}
+
+	// Sessions, profile, memberships etc. are removed by ON DELETE CASCADE
+	if _, err := h.db.Exec(r.Context(), `DELETE FROM users WHERE id = $1`, userID); err != nil {
+		utils.WriteError(w, r, err)
+		return
+	}
+	middleware.InvalidateTokenVersion(userID)
+
+	utils.WriteJSONResponse(w, http.StatusOK, map[string]string{
+		"message": "Account deleted successfully",
+	})
+}
+
+// recordFailedLogin counts a wrong password (or second factor) and locks the account once
+// MaxLoginAttempts is reached. It returns the lock expiry when the account got locked.
+func recordFailedLogin(ctx context.Context, db *pgxpool.Pool, cfg *config.AuthConfig, userID uuid.UUID) (*time.Time, error) {
+	var lockedUntil *time.Time
+	err := db.QueryRow(ctx,
+		`UPDATE users
+		    SET locked_until = CASE WHEN failed_login_attempts + 1 >= $2
+		                            THEN NOW() + $3 * INTERVAL '1 second' END,
+		        failed_login_attempts = CASE WHEN failed_login_attempts + 1 >= $2
+		                                     THEN 0 ELSE failed_login_attempts + 1 END
+		  WHERE id = $1
+		  RETURNING locked_until`,
+		userID, cfg.MaxLoginAttempts, int64(cfg.LockoutDuration.Seconds()),
+	).Scan(&lockedUntil)
+	if err != nil {
+		return nil, err
+	}
+	if lockedUntil != nil {
+		slog.WarnContext(ctx, "account locked after failed logins", "attempts", cfg.MaxLoginAttempts, "user_id", userID.String())
+	}
+	return lockedUntil, nil
+}
+
+func writeAccountLocked(w http.ResponseWriter, r *http.Request, lockedUntil time.Time) {
+	minutes := int(math.Ceil(time.Until(lockedUntil).Minutes()))
+	w.Header().Set("Retry-After", strconv.Itoa(int(math.Ceil(time.Until(lockedUntil).Seconds()))))
+	utils.WriteError(w, r, apperr.New(http.StatusLocked, apperr.CodeAccountLocked, "Account temporarily locked",
+		fmt.Sprintf("Too many failed login attempts. Try again in %d minutes or reset your password", minutes)))
+}
+
+// toUserResponse converts a user model to its API representation
+func toUserResponse(user models.User) dto.UserResponse {
+	resp := dto.UserResponse{
+		ID:            user.ID.String(),
+		Email:         user.Email,
+		EmailVerified: user.EmailVerifiedAt != nil,
+		MFAEnabled:    user.TOTPEnabledAt != nil,
+		CreatedAt:     user.CreatedAt.Format(time.RFC3339),
+		UpdatedAt:     user.UpdatedAt.Format(time.RFC3339),
+	}
+	if user.EmailVerifiedAt != nil {
+		verifiedAt := user.EmailVerifiedAt.Format(time.RFC3339)
+		resp.EmailVerifiedAt = &verifiedAt
+	}
+	return resp
+}
+
+// sessionClient describes the device making the request for session bookkeeping
+func sessionClient(r *http.Request) SessionClient {
+	return SessionClient{
+		UserAgent: r.UserAgent(),
+		IPAddress: utils.ClientIP(r),
+	}
+}
@@ -0,0 +1,97 @@
+package handlers
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/url"
+	"path"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+// authCodeTTL is how long the frontend has to exchange a one-time auth code
+const authCodeTTL = time.Minute
+
+// ErrAuthCodeInvalid is returned for unknown, expired or already used auth codes
+var ErrAuthCodeInvalid = errors.New("authorization code is invalid or expired")
+
+// issueAuthCode stores a one-time code the frontend exchanges for tokens after
+// an OAuth redirect, so tokens never appear in a URL. Only its hash is stored.
+func issueAuthCode(ctx context.Context, db *pgxpool.Pool, userID uuid.UUID, provider string) (string, error) {
+	code, err := generateRefreshToken() // same opaque 256-bit format
+	if err != nil {
+		return "", fmt.Errorf("failed to generate auth code: %w", err)
+	}
+
+	_, err = db.Exec(ctx,
+		`INSERT INTO oauth_auth_codes (code_hash, user_id, provider, expires_at)
+		 VALUES ($1, $2, $3, $4)`,
+		hashRefreshToken(code), userID, provider, time.Now().Add(authCodeTTL),
+	)
+	if err != nil {
+		return "", fmt.Errorf("failed to store auth code: %w", err)
+	}
+	return code, nil
+}
+
+// redeemAuthCode consumes the code and returns the user it was issued for
+func redeemAuthCode(ctx context.Context, db *pgxpool.Pool, code, provider string) (uuid.UUID, error) {
+	var userID uuid.UUID
+	err := db.QueryRow(ctx,
+		`DELETE FROM oauth_auth_codes
+		  WHERE code_hash = $1 AND provider = $2 AND expires_at > NOW()
+		  RETURNING user_id`,
+		hashRefreshToken(code), provider,
+	).Scan(&userID)
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return uuid.Nil, ErrAuthCodeInvalid
+		}
+		return uuid.Nil, err
+	}
+
+	// Expired codes are never redeemable; clean them up opportunistically
+	if _, err := db.Exec(ctx, `DELETE FROM oauth_auth_codes WHERE expires_at < NOW()`); err != nil {
+		return uuid.Nil, err
+	}
+	return userID, nil
+}
+
+// isAllowedRedirect reports whether target starts with one of the allowed
+// URL prefixes (same scheme and host, path under the allowed path). Paths
+// the browser would resolve elsewhere (dot segments, backslashes, "//") are
+// rejected, since the prefix check only sees the raw path.
+func isAllowedRedirect(target string, allowed []string) bool {
+	u, err := url.Parse(target)
+	if err != nil || u.Scheme == "" || u.Host == "" || u.User != nil || u.Fragment != "" {
+		return false
+	}
+	if strings.Contains(u.Path, `\`) {
+		return false
+	}
+	if u.Path != "" {
+		if clean := path.Clean(u.Path); u.Path != clean && u.Path != clean+"/" {
+			return false
+		}
+	}
+
+	for _, a := range allowed {
+		base, err := url.Parse(a)
+		if err != nil {
+			continue
+		}
+		if !strings.EqualFold(u.Scheme, base.Scheme) || !strings.EqualFold(u.Host, base.Host) {
+			continue
+		}
+		prefix := strings.TrimSuffix(base.Path, "/")
+		if u.Path == prefix || strings.HasPrefix(u.Path, prefix+"/") {
+			return true
+		}
+	}
+	return false
+}
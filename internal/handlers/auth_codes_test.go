@@ -0,0 +1,37 @@
+package handlers
+
+import "testing"
+
+func TestIsAllowedRedirect(t *testing.T) {
+	allowed := []string{"https://app.example/callback", "http://localhost:3000"}
+	tests := []struct {
+		target string
+		want   bool
+	}{
+		{"https://app.example/callback", true},
+		{"https://app.example/callback/", true},
+		{"https://app.example/callback/google?next=1", true},
+		{"HTTPS://APP.EXAMPLE/callback", true},
+		{"http://localhost:3000", true},
+		{"http://localhost:3000/callback", true},
+		{"https://app.example/callbackevil", false},
+		{"https://app.example/other", false},
+		{"https://evil.example/callback", false},
+		{"http://app.example/callback", false},
+		{"https://user@app.example/callback", false},
+		{"https://app.example/callback#x", false},
+		{"/callback", false},
+		// the browser resolves these outside /callback
+		{"https://app.example/callback/../evil", false},
+		{"https://app.example/callback/%2e%2e/evil", false},
+		{"https://app.example/callback/./x", false},
+		{"https://app.example/callback//evil.example", false},
+		{`https://app.example/callback/..\evil`, false},
+		{"https://app.example/callback/%5C..%5Cevil", false},
+	}
+	for _, tt := range tests {
+		if got := isAllowedRedirect(tt.target, allowed); got != tt.want {
+			t.Errorf("isAllowedRedirect(%q) = %v, want %v", tt.target, got, tt.want)
+		}
+	}
+}
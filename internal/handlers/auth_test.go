@@ -0,0 +1,30 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"GO2GETHER_BACK-END/internal/dto"
+)
+
+func TestWriteAccountLocked(t *testing.T) {
+	rec := httptest.NewRecorder()
+	writeAccountLocked(rec, httptest.NewRequest(http.MethodPost, "/api/auth/login", nil), time.Now().Add(90*time.Second))
+
+	if rec.Code != http.StatusLocked {
+		t.Errorf("status = %d, want 423", rec.Code)
+	}
+	if got := rec.Header().Get("Retry-After"); got != "90" && got != "89" {
+		t.Errorf("Retry-After = %q, want 90", got)
+	}
+	var body dto.ErrorResponse
+	if err := json.Unmarshal(rec.Body.Bytes(), &body); err != nil {
+		t.Fatal(err)
+	}
+	if body.Code != "account_locked" || body.Instance != "/api/auth/login" {
+		t.Errorf("code = %q, instance = %q", body.Code, body.Instance)
+	}
+}
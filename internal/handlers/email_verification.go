@@ -0,0 +1,257 @@
+package handlers
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+
+	"GO2GETHER_BACK-END/internal/apperr"
+	"GO2GETHER_BACK-END/internal/config"
+	"GO2GETHER_BACK-END/internal/dto"
+	"GO2GETHER_BACK-END/internal/utils"
+)
+
+// resendVerificationCooldown is the minimum time between two verification emails
+const resendVerificationCooldown = time.Minute
+
+// EmailVerificationHandler handles email address verification
+type EmailVerificationHandler struct {
+	db           *pgxpool.Pool
+	config       *config.Config
+	emailService *utils.EmailService
+}
+
+// NewEmailVerificationHandler creates a new EmailVerificationHandler instance
+func NewEmailVerificationHandler(db *pgxpool.Pool, cfg *config.Config) *EmailVerificationHandler {
+	return &EmailVerificationHandler{
+		db:           db,
+		config:       cfg,
+		emailService: utils.NewEmailService(&cfg.Email),
+	}
+}
+
+// VerifyEmail confirms the user's email address with the code sent at sign-up
+// @Summary Verify email address
+// @Description Confirm the email address with the 6-digit code sent after registration
+// @Tags authentication
+// @Accept json
+// @Produce json
+// @Param request body dto.VerifyEmailRequest true "Email and verification code"
+// @Success 200 {object} dto.VerifyEmailResponse "Email verified successfully"
+// @Failure 400 {object} dto.ErrorResponse "Invalid request data"
+// @Failure 401 {object} dto.ErrorResponse "Invalid or expired code"
+// @Failure 429 {object} dto.ErrorResponse "Too many attempts"
+// @Failure 500 {object} dto.ErrorResponse "Internal server error"
+// @Router /api/auth/verify-email [post]
+func (h *EmailVerificationHandler) VerifyEmail(w http.ResponseWriter, r *http.Request) {
+	var req dto.VerifyEmailRequest
+	if !utils.DecodeJSON(w, r, &req) {
+		return
+	}
+
+	req.Email = strings.TrimSpace(req.Email)
+	req.Code = strings.TrimSpace(req.Code)
+
+	tx, err := h.db.Begin(r.Context())
+	if err != nil {
+		utils.WriteError(w, r, err)
+		return
+	}
+	defer tx.Rollback(r.Context())
+
+	// Only the latest code is ever pending (sendEmailVerification retires older ones)
+	var (
+		verificationID  uuid.UUID
+		userID          uuid.UUID
+		storedCode      string
+		emailVerifiedAt *time.Time
+	)
+	err = tx.QueryRow(r.Context(),
+		`SELECT v.id, v.user_id, v.code, u.email_verified_at
+		   FROM auth_verifications v
+		   JOIN users u ON u.id = v.user_id
+		  WHERE v.email = $1 AND v.purpose = $2
+		    AND v.used = false AND v.expires_at > NOW()
+		  ORDER BY v.created_at DESC LIMIT 1
+		  FOR UPDATE OF v`,
+		req.Email, purposeEmailVerification,
+	).Scan(&verificationID, &userID, &storedCode, &emailVerifiedAt)
+	if err != nil {
+		if err == pgx.ErrNoRows {
+			utils.WriteError(w, r, apperr.New(http.StatusUnauthorized, apperr.CodeInvalidCode, "Invalid code", "Invalid or expired verification code"))
+		} else {
+			utils.WriteError(w, r, err)
+		}
+		return
+	}
+
+	if storedCode != req.Code {
+		remaining, err := recordFailedCodeAttempt(r.Context(), tx, verificationID, h.config.Auth.MaxCodeAttempts)
+		if err == nil {
+			err = tx.Commit(r.Context())
+		}
+		if err != nil {
+			utils.WriteError(w, r, err)
+			return
+		}
+		if remaining <= 0 {
+			utils.WriteError(w, r, errTooManyTries)
+			return
+		}
+		utils.WriteError(w, r, errWrongCode(remaining))
+		return
+	}
+
+	if _, err := tx.Exec(r.Context(),
+		`UPDATE auth_verifications SET used = true WHERE id = $1`, verificationID); err != nil {
+		utils.WriteError(w, r, err)
+		return
+	}
+
+	// Keep the original timestamp if the address was already verified
+	if emailVerifiedAt == nil {
+		if err := tx.QueryRow(r.Context(),
+			`UPDATE users SET email_verified_at = NOW() WHERE id = $1 RETURNING email_verified_at`,
+			userID,
+		).Scan(&emailVerifiedAt); err != nil {
+			utils.WriteError(w, r, err)
+			return
+		}
+	}
+
+	if err := tx.Commit(r.Context()); err != nil {
+		utils.WriteError(w, r, err)
+		return
+	}
+
+	utils.WriteJSONResponse(w, http.StatusOK, dto.VerifyEmailResponse{
+		Message:         "Email verified successfully",
+		EmailVerifiedAt: emailVerifiedAt.Format(time.RFC3339),
+	})
+}
+
+// ResendVerification sends a new email verification code to the current user
+// @Summary Resend verification email
+// @Description Send a new email verification code to the authenticated user's address
+// @Tags authentication
+// @Produce json
+// @Security BearerAuth
+// @Success 200 {object} dto.ResendVerificationResponse "Verification code sent"
+// @Failure 401 {object} dto.ErrorResponse "Unauthorized"
+// @Failure 409 {object} dto.ErrorResponse "Email already verified"
+// @Failure 429 {object} dto.ErrorResponse "Code requested too recently"
+// @Failure 500 {object} dto.ErrorResponse "Internal server error"
+// @Router /api/auth/resend-verification [post]
+func (h *EmailVerificationHandler) ResendVerification(w http.ResponseWriter, r *http.Request) {
+	userID, ok := r.Context().Value("user_id").(uuid.UUID)
+	if !ok {
+		utils.WriteError(w, r, errNotAuthenticated)
+		return
+	}
+
+	var (
+		email           string
+		emailVerifiedAt *time.Time
+	)
+	if err := h.db.QueryRow(r.Context(),
+		`SELECT email, email_verified_at FROM users WHERE id = $1`, userID,
+	).Scan(&email, &emailVerifiedAt); err != nil {
+		if err == pgx.ErrNoRows {
+			utils.WriteError(w, r, apperr.NotFound("User no longer exists").WithTitle("User not found"))
+		} else {
+			utils.WriteError(w, r, err)
+		}
+		return
+	}
+	if emailVerifiedAt != nil {
+		utils.WriteError(w, r, apperr.New(http.StatusConflict, apperr.CodeConflict, "Already verified", "Email address is already verified"))
+		return
+	}
+
+	// Cooldown so the endpoint cannot be used to flood the inbox
+	var lastSentAt time.Time
+	err := h.db.QueryRow(r.Context(),
+		`SELECT created_at FROM auth_verifications
+		  WHERE user_id = $1 AND purpose = $2
+		  ORDER BY created_at DESC LIMIT 1`,
+		userID, purposeEmailVerification,
+	).Scan(&lastSentAt)
+	if err == nil {
+		if wait := time.Until(lastSentAt.Add(resendVerificationCooldown)); wait > 0 {
+			utils.WriteError(w, r, errCodeAlreadySent(
+				fmt.Sprintf("Please wait %d seconds before requesting a new code", int(wait.Seconds())+1)))
+			return
+		}
+	} else if err != pgx.ErrNoRows {
+		utils.WriteError(w, r, err)
+		return
+	}
+
+	if err := sendEmailVerification(r.Context(), h.db, h.config, h.emailService, userID, email); err != nil {
+		utils.WriteError(w, r, err)
+		return
+	}
+
+	utils.WriteJSONResponse(w, http.StatusOK, dto.ResendVerificationResponse{
+		Message:   "Verification code has been sent to your email",
+		Email:     email,
+		ExpiresIn: fmt.Sprintf("%d minutes", int(h.config.Auth.EmailVerificationTTL.Minutes())),
+	})
+}
+
+// sendEmailVerification replaces any pending verification code of the user
+// with a new one and emails it (or logs it when email is not configured)
+func sendEmailVerification(ctx context.Context, db *pgxpool.Pool, cfg *config.Config, emailService *utils.EmailService, userID uuid.UUID, email string) error {
+	code, err := generateVerificationCode(6)
+	if err != nil {
+		return fmt.Errorf("failed to generate code: %w", err)
+	}
+
+	ttl := cfg.Auth.EmailVerificationTTL
+	tx, err := db.Begin(ctx)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback(ctx)
+
+	// Only the latest code is valid
+	if _, err := tx.Exec(ctx,
+		`UPDATE auth_verifications SET used = true
+		  WHERE user_id = $1 AND purpose = $2 AND used = false`,
+		userID, purposeEmailVerification,
+	); err != nil {
+		return err
+	}
+	if _, err := tx.Exec(ctx,
+		`INSERT INTO auth_verifications (user_id, email, code, purpose, expires_at, created_at)
+		 VALUES ($1, $2, $3, $4, $5, $6)`,
+		userID, email, code, purposeEmailVerification, time.Now().Add(ttl), time.Now(),
+	); err != nil {
+		return fmt.Errorf("failed to store verification code: %w", err)
+	}
+	if err := tx.Commit(ctx); err != nil {
+		return err
+	}
+
+	link := fmt.Sprintf("%s/verify-email?email=%s&code=%s", cfg.Frontend.URL, url.QueryEscape(email), code)
+
+	if !emailService.IsConfigured() {
+		if cfg.IsProduction() {
+			return fmt.Errorf("email delivery is not configured")
+		}
+		// For development, log the code when email is not configured (dev_code
+		// is not redacted on purpose; this branch never runs in production)
+		slog.WarnContext(ctx, "email not configured; development verification code",
+			"email", email, "dev_code", code, "expires_in", ttl.String())
+		return nil
+	}
+	return emailService.SendEmailVerification(ctx, email, code, link, ttl)
+}
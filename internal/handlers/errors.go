@@ -0,0 +1,51 @@
+package handlers
+
+import (
+	"fmt"
+	"net/http"
+
+	"GO2GETHER_BACK-END/internal/apperr"
+)
+
+// Errors of the auth handlers, written with utils.WriteError. Unexpected
+// errors (database, crypto, SMTP) are passed to utils.WriteError as they are,
+// so clients get a generic 500 and the cause only goes to the log.
+
+var (
+	errNotAuthenticated   = apperr.Unauthorized("User not authenticated")
+	errUserNotFound       = apperr.NotFound("User not found")
+	errNoAccount          = apperr.New(http.StatusNotFound, apperr.CodeNotFound, "User not found", "No account found with this email")
+	errEmailTaken         = apperr.New(http.StatusConflict, apperr.CodeAlreadyExists, "User already exists", "Email already registered")
+	errInvalidCredentials = apperr.New(http.StatusUnauthorized, apperr.CodeUnauthorized, "Invalid credentials", "Email or password is incorrect")
+
+	// refresh token rotation
+	errRefreshReused  = apperr.New(http.StatusUnauthorized, apperr.CodeInvalidToken, "Refresh token reused", "This refresh token was already used. All sessions on this device chain have been signed out")
+	errRefreshExpired = apperr.New(http.StatusUnauthorized, apperr.CodeInvalidToken, "Refresh token expired", "Please log in again")
+	errRefreshInvalid = apperr.New(http.StatusUnauthorized, apperr.CodeInvalidToken, "Invalid refresh token", "Refresh token is invalid or has been revoked")
+
+	// one-time codes: email verification, password reset, MFA, OAuth sign-in
+	errCodeRequired    = apperr.Validation(apperr.Field("code", "required", "code is required"))
+	errCodeUsed        = apperr.New(http.StatusUnauthorized, apperr.CodeInvalidCode, "Code already used", "This verification code has already been used")
+	errCodeExpired     = apperr.New(http.StatusUnauthorized, apperr.CodeCodeExpired, "Code expired", "Verification code has expired. Please request a new one")
+	errTooManyTries    = apperr.New(http.StatusTooManyRequests, apperr.CodeRateLimited, "Too many attempts", "This verification code has been invalidated after too many incorrect attempts. Please request a new one")
+	errSecondFactor    = apperr.New(http.StatusBadRequest, apperr.CodeInvalidCode, "Invalid code", "The authentication code is incorrect or was already used")
+	errMFANotEnabled   = apperr.New(http.StatusBadRequest, apperr.CodeBadRequest, "MFA not enabled", "Two-factor authentication is not enabled")
+	errMFAEnabled      = apperr.New(http.StatusConflict, apperr.CodeConflict, "MFA already enabled", "Two-factor authentication is already enabled")
+	errInvalidMFAToken = apperr.New(http.StatusUnauthorized, apperr.CodeInvalidToken, "Invalid MFA token", "The login challenge is invalid or expired. Sign in again")
+)
+
+// errWrongCode is a wrong verification code that may be retried
+func errWrongCode(remaining int32) error {
+	return apperr.New(http.StatusUnauthorized, apperr.CodeInvalidCode, "Invalid code",
+		fmt.Sprintf("The verification code you entered is incorrect. %d attempts remaining", remaining))
+}
+
+// errCodeAlreadySent asks the user to wait before another code is emailed
+func errCodeAlreadySent(detail string) error {
+	return apperr.New(http.StatusTooManyRequests, apperr.CodeRateLimited, "Code already sent", detail)
+}
+
+// errUnknownProvider is a {provider} path value that is not an enabled provider
+func errUnknownProvider(name string) error {
+	return apperr.New(http.StatusNotFound, apperr.CodeNotFound, "Unknown provider", "Sign-in provider is not enabled: "+name)
+}
@@ -5,8 +5,8 @@ package handlers
 import (
 	"context"
 	"crypto/rand"
-	"encoding/json"
 	"fmt"
+	"log/slog"
 	"math/big"
 	"net/http"
 	"time"
@@ -16,6 +16,7 @@ import (
 	"github.com/jackc/pgx/v5/pgxpool"
 	"golang.org/x/crypto/bcrypt"
 
+	"GO2GETHER_BACK-END/internal/apperr"
 	"GO2GETHER_BACK-END/internal/config"
 	"GO2GETHER_BACK-END/internal/dto"
 	"GO2GETHER_BACK-END/internal/middleware"
@@ -48,23 +49,12 @@ func NewForgotPasswordHandler(db *pgxpool.Pool, cfg *config.Config) *ForgotPassw
 // @Success 200 {object} dto.ForgotPasswordResponse "Verification code sent successfully"
 // @Failure 400 {object} dto.ErrorResponse "Invalid request data"
 // @Failure 404 {object} dto.ErrorResponse "User not found"
+// @Failure 429 {object} dto.ErrorResponse "Too many requests"
 // @Failure 500 {object} dto.ErrorResponse "Internal server error"
 // @Router /api/auth/forgot-password [post]
 func (h *ForgotPasswordHandler) ForgotPassword(w http.ResponseWriter, r *http.Request) {
-	if r.Method != http.MethodPost {
-		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
-		return
-	}
-
 	var req dto.ForgotPasswordRequest
-	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
-		utils.WriteErrorResponse(w, http.StatusBadRequest, "Invalid request body", err.Error())
-		return
-	}
-
-	// Validate email
-	if req.Email == "" {
-		utils.WriteErrorResponse(w, http.StatusBadRequest, "Missing required field", "Email is required")
+	if !utils.DecodeJSON(w, r, &req) {
 		return
 	}
 
@@ -75,9 +65,9 @@ func (h *ForgotPasswordHandler) ForgotPassword(w http.ResponseWriter, r *http.Re
 
 	if err != nil {
 		if err == pgx.ErrNoRows {
-			utils.WriteErrorResponse(w, http.StatusNotFound, "User not found", "No account found with this email")
+			utils.WriteError(w, r, errNoAccount)
 		} else {
-			utils.WriteErrorResponse(w, http.StatusInternalServerError, "Database error", err.Error())
+			utils.WriteError(w, r, err)
 		}
 		return
 	}
@@ -87,7 +77,7 @@ func (h *ForgotPasswordHandler) ForgotPassword(w http.ResponseWriter, r *http.Re
 	var expiresAt time.Time
 	err = h.db.QueryRow(context.Background(),
 		`SELECT code, expires_at FROM auth_verifications 
-		 WHERE user_id = $1 AND used = false AND expires_at > NOW()
+		 WHERE user_id = $1 AND purpose = 'password_reset' AND used = false AND expires_at > NOW()
 		 ORDER BY created_at DESC LIMIT 1`,
 		userID).Scan(&existingCode, &expiresAt)
 
@@ -95,9 +85,8 @@ func (h *ForgotPasswordHandler) ForgotPassword(w http.ResponseWriter, r *http.Re
 		// There's still a valid code, check if it's within cooldown period
 		timeRemaining := time.Until(expiresAt)
 		if timeRemaining > 0 {
-			utils.WriteErrorResponse(w, http.StatusTooManyRequests,
-				"Code already sent",
-				fmt.Sprintf("Please wait %d seconds before requesting a new code", int(timeRemaining.Seconds())))
+			utils.WriteError(w, r, errCodeAlreadySent(
+				fmt.Sprintf("Please wait %d seconds before requesting a new code", int(timeRemaining.Seconds()))))
 			return
 		}
 	}
@@ -105,7 +94,7 @@ func (h *ForgotPasswordHandler) ForgotPassword(w http.ResponseWriter, r *http.Re
 	// Generate 6-digit verification code
 	code, err := generateVerificationCode(6)
 	if err != nil {
-		utils.WriteErrorResponse(w, http.StatusInternalServerError, "Failed to generate code", err.Error())
+		utils.WriteError(w, r, err)
 		return
 	}
 
@@ -114,23 +103,28 @@ func (h *ForgotPasswordHandler) ForgotPassword(w http.ResponseWriter, r *http.Re
 	_, err = h.db.Exec(context.Background(),
 		`INSERT INTO auth_verifications (user_id, email, code, purpose, expires_at, created_at)
 		 VALUES ($1, $2, $3, $4, $5, $6)`,
-		userID, req.Email, code, "password_reset", expiresAt, time.Now())
+		userID, req.Email, code, purposePasswordReset, expiresAt, time.Now())
 
 	if err != nil {
-		utils.WriteErrorResponse(w, http.StatusInternalServerError, "Failed to store verification code", err.Error())
+		utils.WriteError(w, r, err)
 		return
 	}
 
 	// Send verification code via email service
-	if h.config.IsEmailConfigured() {
-		err = h.emailService.SendVerificationCode(req.Email, code)
+	if h.emailService.IsConfigured() {
+		err = h.emailService.SendVerificationCode(r.Context(), req.Email, code)
 		if err != nil {
-			utils.WriteErrorResponse(w, http.StatusInternalServerError, "Failed to send email", err.Error())
+			utils.WriteError(w, r, err)
 			return
 		}
+	} else if !h.config.IsProduction() {
+		// For development, log the code when email is not configured (dev_code
+		// is not redacted on purpose; this branch never runs in production)
+		slog.WarnContext(r.Context(), "email not configured; development password reset code",
+			"email", req.Email, "dev_code", code, "expires_in", "3m")
 	} else {
-		// For development, log the code when email is not configured
-		fmt.Printf("Verification code for %s: %s (expires in 3 minutes)\n", req.Email, code)
+		utils.WriteError(w, r, apperr.New(http.StatusServiceUnavailable, apperr.CodeUnavailable, "Email unavailable", "Email delivery is not configured"))
+		return
 	}
 
 	response := dto.ForgotPasswordResponse{
@@ -152,23 +146,12 @@ func (h *ForgotPasswordHandler) ForgotPassword(w http.ResponseWriter, r *http.Re
 // @Success 200 {object} dto.VerifyOTPResponse "OTP verified successfully"
 // @Failure 400 {object} dto.ErrorResponse "Invalid request data"
 // @Failure 401 {object} dto.ErrorResponse "Invalid or expired code"
+// @Failure 429 {object} dto.ErrorResponse "Too many attempts"
 // @Failure 500 {object} dto.ErrorResponse "Internal server error"
 // @Router /api/auth/verify-otp [post]
 func (h *ForgotPasswordHandler) VerifyOTP(w http.ResponseWriter, r *http.Request) {
-	if r.Method != http.MethodPost {
-		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
-		return
-	}
-
 	var req dto.VerifyOTPRequest
-	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
-		utils.WriteErrorResponse(w, http.StatusBadRequest, "Invalid request body", err.Error())
-		return
-	}
-
-	// Validate required fields
-	if req.Email == "" || req.Code == "" {
-		utils.WriteErrorResponse(w, http.StatusBadRequest, "Missing required fields", "Email and code are required")
+	if !utils.DecodeJSON(w, r, &req) {
 		return
 	}
 
@@ -179,9 +162,9 @@ func (h *ForgotPasswordHandler) VerifyOTP(w http.ResponseWriter, r *http.Request
 
 	if err != nil {
 		if err == pgx.ErrNoRows {
-			utils.WriteErrorResponse(w, http.StatusNotFound, "User not found", "No account found with this email")
+			utils.WriteError(w, r, errNoAccount)
 		} else {
-			utils.WriteErrorResponse(w, http.StatusInternalServerError, "Database error", err.Error())
+			utils.WriteError(w, r, err)
 		}
 		return
 	}
@@ -191,43 +174,57 @@ func (h *ForgotPasswordHandler) VerifyOTP(w http.ResponseWriter, r *http.Request
 	var storedCode string
 	var expiresAt time.Time
 	var used bool
+	var attempts int32
 	err = h.db.QueryRow(context.Background(),
-		`SELECT id, code, expires_at, used FROM auth_verifications 
-		 WHERE user_id = $1 AND email = $2 
+		`SELECT id, code, expires_at, used, attempts FROM auth_verifications 
+		 WHERE user_id = $1 AND email = $2 AND purpose = 'password_reset'
 		 ORDER BY created_at DESC LIMIT 1`,
-		userID, req.Email).Scan(&verificationID, &storedCode, &expiresAt, &used)
+		userID, req.Email).Scan(&verificationID, &storedCode, &expiresAt, &used, &attempts)
 
 	if err != nil {
 		if err == pgx.ErrNoRows {
-			utils.WriteErrorResponse(w, http.StatusUnauthorized, "Invalid code", "No verification code found")
+			utils.WriteError(w, r, apperr.New(http.StatusUnauthorized, apperr.CodeInvalidCode, "Invalid code", "No verification code found"))
 		} else {
-			utils.WriteErrorResponse(w, http.StatusInternalServerError, "Database error", err.Error())
+			utils.WriteError(w, r, err)
 		}
 		return
 	}
 
-	// Check if code has been used
+	// Check if code has been used (or invalidated after too many wrong guesses)
 	if used {
-		utils.WriteErrorResponse(w, http.StatusUnauthorized, "Code already used", "This verification code has already been used")
+		if attempts >= h.config.Auth.MaxCodeAttempts {
+			utils.WriteError(w, r, errTooManyTries)
+			return
+		}
+		utils.WriteError(w, r, errCodeUsed)
 		return
 	}
 
 	// Check if code has expired
 	if time.Now().After(expiresAt) {
-		utils.WriteErrorResponse(w, http.StatusUnauthorized, "Code expired", "Verification code has expired. Please request a new one")
+		utils.WriteError(w, r, errCodeExpired)
 		return
 	}
 
 	// Check if code matches
 	if storedCode != req.Code {
-		utils.WriteErrorResponse(w, http.StatusUnauthorized, "Invalid code", "The verification code you entered is incorrect")
+		remaining, err := recordFailedCodeAttempt(r.Context(), h.db, verificationID, h.config.Auth.MaxCodeAttempts)
+		if err != nil {
+			utils.WriteError(w, r, err)
+			return
+		}
+		if remaining <= 0 {
+			utils.WriteError(w, r, errTooManyTries)
+			return
+		}
+		utils.WriteError(w, r, errWrongCode(remaining))
 		return
 	}
 
 	// Generate reset token (valid for 10 minutes)
 	resetToken, err := middleware.GenerateResetToken(userID, req.Email, req.Code, &h.config.JWT)
 	if err != nil {
-		utils.WriteErrorResponse(w, http.StatusInternalServerError, "Failed to generate reset token", err.Error())
+		utils.WriteError(w, r, err)
 		return
 	}
 
@@ -242,7 +239,7 @@ func (h *ForgotPasswordHandler) VerifyOTP(w http.ResponseWriter, r *http.Request
 
 // ResetPassword resets user's password using reset token
 // @Summary Reset password
-// @Description Reset user's password with new password using reset token
+// @Description Reset user's password with new password using reset token. Signs the user out of every device.
 // @Tags authentication
 // @Accept json
 // @Produce json
@@ -253,33 +250,16 @@ func (h *ForgotPasswordHandler) VerifyOTP(w http.ResponseWriter, r *http.Request
 // @Failure 500 {object} dto.ErrorResponse "Internal server error"
 // @Router /api/auth/reset-password [post]
 func (h *ForgotPasswordHandler) ResetPassword(w http.ResponseWriter, r *http.Request) {
-	if r.Method != http.MethodPost {
-		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
-		return
-	}
-
 	var req dto.ResetPasswordRequest
-	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
-		utils.WriteErrorResponse(w, http.StatusBadRequest, "Invalid request body", err.Error())
-		return
-	}
-
-	// Validate required fields
-	if req.ResetToken == "" || req.NewPassword == "" {
-		utils.WriteErrorResponse(w, http.StatusBadRequest, "Missing required fields", "Reset token and new password are required")
-		return
-	}
-
-	// Validate password length
-	if len(req.NewPassword) < 6 {
-		utils.WriteErrorResponse(w, http.StatusBadRequest, "Password too short", "Password must be at least 6 characters long")
+	if !utils.DecodeJSON(w, r, &req) {
 		return
 	}
 
 	// Validate reset token
 	claims, err := middleware.ValidateResetToken(req.ResetToken, &h.config.JWT)
 	if err != nil {
-		utils.WriteErrorResponse(w, http.StatusUnauthorized, "Invalid reset token", err.Error())
+		utils.WriteError(w, r, apperr.New(http.StatusUnauthorized, apperr.CodeInvalidToken, "Invalid reset token",
+			"The reset token is invalid or has expired. Please request a new code").WithCause(err))
 		return
 	}
 
@@ -289,53 +269,54 @@ func (h *ForgotPasswordHandler) ResetPassword(w http.ResponseWriter, r *http.Req
 	var expiresAt time.Time
 	err = h.db.QueryRow(context.Background(),
 		`SELECT id, used, expires_at FROM auth_verifications 
-		 WHERE user_id = $1 AND email = $2 AND code = $3
+		 WHERE user_id = $1 AND email = $2 AND code = $3 AND purpose = 'password_reset'
 		 ORDER BY created_at DESC LIMIT 1`,
 		claims.UserID, claims.Email, claims.Code).Scan(&verificationID, &used, &expiresAt)
 
 	if err != nil {
 		if err == pgx.ErrNoRows {
-			utils.WriteErrorResponse(w, http.StatusUnauthorized, "Invalid verification", "No matching verification found")
+			utils.WriteError(w, r, apperr.New(http.StatusUnauthorized, apperr.CodeInvalidToken, "Invalid verification", "No matching verification found"))
 		} else {
-			utils.WriteErrorResponse(w, http.StatusInternalServerError, "Database error", err.Error())
+			utils.WriteError(w, r, err)
 		}
 		return
 	}
 
 	// Check if code has been used
 	if used {
-		utils.WriteErrorResponse(w, http.StatusUnauthorized, "Code already used", "This verification code has already been used")
+		utils.WriteError(w, r, errCodeUsed)
 		return
 	}
 
 	// Check if code has expired
 	if time.Now().After(expiresAt) {
-		utils.WriteErrorResponse(w, http.StatusUnauthorized, "Code expired", "Verification code has expired")
+		utils.WriteError(w, r, errCodeExpired)
 		return
 	}
 
 	// Hash new password
 	hashedPassword, err := bcrypt.GenerateFromPassword([]byte(req.NewPassword), bcrypt.DefaultCost)
 	if err != nil {
-		utils.WriteErrorResponse(w, http.StatusInternalServerError, "Failed to hash password", err.Error())
+		utils.WriteError(w, r, err)
 		return
 	}
 
 	// Start transaction
 	tx, err := h.db.Begin(context.Background())
 	if err != nil {
-		utils.WriteErrorResponse(w, http.StatusInternalServerError, "Failed to start transaction", err.Error())
+		utils.WriteError(w, r, err)
 		return
 	}
 	defer tx.Rollback(context.Background())
 
-	// Update user's password
+	// Update user's password; proving ownership of the email also lifts a login lockout
 	_, err = tx.Exec(context.Background(),
-		`UPDATE users SET password_hash = $1, updated_at = $2 WHERE id = $3`,
+		`UPDATE users SET password_hash = $1, failed_login_attempts = 0, locked_until = NULL, updated_at = $2
+		 WHERE id = $3`,
 		string(hashedPassword), time.Now(), claims.UserID)
 
 	if err != nil {
-		utils.WriteErrorResponse(w, http.StatusInternalServerError, "Failed to update password", err.Error())
+		utils.WriteError(w, r, err)
 		return
 	}
 
@@ -344,99 +325,66 @@ func (h *ForgotPasswordHandler) ResetPassword(w http.ResponseWriter, r *http.Req
 		"UPDATE auth_verifications SET used = true WHERE id = $1", verificationID)
 
 	if err != nil {
-		utils.WriteErrorResponse(w, http.StatusInternalServerError, "Failed to mark code as used", err.Error())
+		utils.WriteError(w, r, err)
 		return
 	}
 
-	// Commit transaction
-	if err := tx.Commit(context.Background()); err != nil {
-		utils.WriteErrorResponse(w, http.StatusInternalServerError, "Failed to commit transaction", err.Error())
-		return
-	}
-
-	response := dto.ResetPasswordResponse{
-		Message: "Password has been reset successfully",
-	}
-
-	utils.WriteJSONResponse(w, http.StatusOK, response)
-}
+	// Sign out everywhere: revoke refresh sessions and invalidate issued access tokens
+	_, err = tx.Exec(context.Background(),
+		`UPDATE auth_sessions SET revoked_at = NOW(), revoked_reason = 'password_reset'
+		 WHERE user_id = $1 AND revoked_at IS NULL`, claims.UserID)
 
-// GetOTP retrieves the latest OTP for testing/development purposes
-// @Summary Get OTP code
-// @Description Get the latest verification code for an email (Development/Testing only)
-// @Tags authentication
-// @Accept json
-// @Produce json
-// @Param request body dto.GetOTPRequest true "Email address"
-// @Success 200 {object} dto.GetOTPResponse "OTP retrieved successfully"
-// @Failure 400 {object} dto.ErrorResponse "Invalid request data"
-// @Failure 404 {object} dto.ErrorResponse "No OTP found"
-// @Failure 500 {object} dto.ErrorResponse "Internal server error"
-// @Router /api/auth/get-otp [post]
-func (h *ForgotPasswordHandler) GetOTP(w http.ResponseWriter, r *http.Request) {
-	if r.Method != http.MethodPost {
-		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+	if err != nil {
+		utils.WriteError(w, r, err)
 		return
 	}
 
-	var req dto.GetOTPRequest
-	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
-		utils.WriteErrorResponse(w, http.StatusBadRequest, "Invalid request body", err.Error())
+	if err := bumpTokenVersion(context.Background(), tx, claims.UserID); err != nil {
+		utils.WriteError(w, r, err)
 		return
 	}
 
-	// Validate email
-	if req.Email == "" {
-		utils.WriteErrorResponse(w, http.StatusBadRequest, "Missing required field", "Email is required")
+	// Commit transaction
+	if err := tx.Commit(context.Background()); err != nil {
+		utils.WriteError(w, r, err)
 		return
 	}
+	middleware.InvalidateTokenVersion(claims.UserID)
 
-	// Get user ID
-	var userID uuid.UUID
-	err := h.db.QueryRow(context.Background(),
-		"SELECT id FROM users WHERE email = $1", req.Email).Scan(&userID)
-
-	if err != nil {
-		if err == pgx.ErrNoRows {
-			utils.WriteErrorResponse(w, http.StatusNotFound, "User not found", "No account found with this email")
-		} else {
-			utils.WriteErrorResponse(w, http.StatusInternalServerError, "Database error", err.Error())
-		}
-		return
+	response := dto.ResetPasswordResponse{
+		Message: "Password has been reset successfully",
 	}
 
-	// Get latest OTP
-	var code string
-	var expiresAt time.Time
-	var used bool
-	var createdAt time.Time
+	utils.WriteJSONResponse(w, http.StatusOK, response)
+}
 
-	err = h.db.QueryRow(context.Background(),
-		`SELECT code, expires_at, used, created_at 
-		 FROM auth_verifications 
-		 WHERE user_id = $1 AND email = $2
-		 ORDER BY created_at DESC 
-		 LIMIT 1`,
-		userID, req.Email).Scan(&code, &expiresAt, &used, &createdAt)
+// auth_verifications.purpose values
+const (
+	purposePasswordReset     = "password_reset"
+	purposeEmailVerification = "email_verification"
+)
 
-	if err != nil {
-		if err == pgx.ErrNoRows {
-			utils.WriteErrorResponse(w, http.StatusNotFound, "No OTP found", "No verification code found for this email")
-		} else {
-			utils.WriteErrorResponse(w, http.StatusInternalServerError, "Database error", err.Error())
-		}
-		return
-	}
+// codeQueryer is satisfied by both *pgxpool.Pool and pgx.Tx
+type codeQueryer interface {
+	QueryRow(ctx context.Context, sql string, args ...any) pgx.Row
+}
 
-	response := dto.GetOTPResponse{
-		Email:     req.Email,
-		Code:      code,
-		ExpiresAt: expiresAt.Format(time.RFC3339),
-		Used:      used,
-		CreatedAt: createdAt.Format(time.RFC3339),
+// recordFailedCodeAttempt counts a wrong guess against a verification code and
+// invalidates the code once maxAttempts is reached. It returns the attempts left.
+func recordFailedCodeAttempt(ctx context.Context, db codeQueryer, verificationID uuid.UUID, maxAttempts int32) (int32, error) {
+	var attempts int32
+	err := db.QueryRow(ctx,
+		`UPDATE auth_verifications
+		    SET attempts = attempts + 1,
+		        used = used OR attempts + 1 >= $2
+		  WHERE id = $1
+		  RETURNING attempts`,
+		verificationID, maxAttempts,
+	).Scan(&attempts)
+	if err != nil {
+		return 0, err
 	}
-
-	utils.WriteJSONResponse(w, http.StatusOK, response)
+	return maxAttempts - attempts, nil
 }
 
 // generateVerificationCode generates a random n-digit verification code
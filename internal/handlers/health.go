@@ -1,36 +1,38 @@
 package handlers
 
 import (
-	"context"
 	"net/http"
-	"time"
 
+	"GO2GETHER_BACK-END/internal/buildinfo"
 	"GO2GETHER_BACK-END/internal/dto"
+	"GO2GETHER_BACK-END/internal/health"
+	"GO2GETHER_BACK-END/internal/migrations"
 	"GO2GETHER_BACK-END/internal/utils"
-
-	"github.com/jackc/pgx/v5/pgxpool"
 )
 
 // HealthHandler handles health check related requests
 type HealthHandler struct {
-	db *pgxpool.Pool
+	checks   *health.Registry
+	migrator *migrations.Migrator
 }
 
 // NewHealthHandler creates a new HealthHandler instance
-func NewHealthHandler(db *pgxpool.Pool) *HealthHandler {
-	return &HealthHandler{db: db}
+func NewHealthHandler(checks *health.Registry, migrator *migrations.Migrator) *HealthHandler {
+	return &HealthHandler{checks: checks, migrator: migrator}
 }
 
-// HealthCheck handles basic health check (no database)
+// HealthCheck handles health check: the liveness checks (background workers),
+// never the database, so an outage does not get every replica restarted
 // @Summary Health check
-// @Description Basic health check endpoint
+// @Description Health check endpoint; runs the liveness checks such as background worker liveness
 // @Tags health
 // @Accept json
 // @Produce json
 // @Success 200 {object} dto.HealthResponse "Service is healthy"
+// @Failure 503 {object} dto.HealthResponse "A liveness check failed"
 // @Router /healthz [get]
 func (h *HealthHandler) HealthCheck(w http.ResponseWriter, r *http.Request) {
-	utils.WriteJSONResponse(w, http.StatusOK, dto.HealthResponse{Status: "ok"})
+	writeHealthReport(w, h.checks.Liveness(r.Context()), "ok", "unhealthy")
 }
 
 // LivenessCheck handles process liveness check
@@ -45,9 +47,9 @@ func (h *HealthHandler) LivenessCheck(w http.ResponseWriter, r *http.Request) {
 	utils.WriteJSONResponse(w, http.StatusOK, dto.HealthResponse{Status: "alive"})
 }
 
-// ReadinessCheck handles readiness check (includes database connectivity)
+// ReadinessCheck handles readiness check: every registered dependency check
 // @Summary Readiness check
-// @Description Readiness check endpoint including database connectivity
+// @Description Readiness check endpoint: database, connection pool, migrations, SMTP (optional) and workers. Results are cached for HEALTH_CACHE_TTL.
 // @Tags health
 // @Accept json
 // @Produce json
@@ -55,19 +57,60 @@ func (h *HealthHandler) LivenessCheck(w http.ResponseWriter, r *http.Request) {
 // @Failure 503 {object} dto.HealthResponse "Service is degraded"
 // @Router /readyz [get]
 func (h *HealthHandler) ReadinessCheck(w http.ResponseWriter, r *http.Request) {
-	ctx, cancel := context.WithTimeout(r.Context(), 3*time.Second)
-	defer cancel()
+	writeHealthReport(w, h.checks.Readiness(r.Context()), "ready", "degraded")
+}
 
-	if err := h.db.Ping(ctx); err != nil {
-		utils.WriteJSONResponse(w, http.StatusServiceUnavailable, dto.HealthResponse{
-			Status:  "degraded",
-			Details: map[string]any{"db": err.Error()},
-		})
-		return
+// Version handles build and schema version
+// @Summary Version
+// @Description Git commit and build time of the running binary, and the applied database schema version
+// @Tags health
+// @Produce json
+// @Success 200 {object} dto.VersionResponse "Build information"
+// @Router /version [get]
+func (h *HealthHandler) Version(w http.ResponseWriter, r *http.Request) {
+	bi := buildinfo.Get()
+	resp := dto.VersionResponse{
+		Commit:          bi.Commit,
+		BuildTime:       bi.BuildTime,
+		Modified:        bi.Modified,
+		GoVersion:       bi.GoVersion,
+		SchemaVersion:   -1,
+		LatestMigration: h.migrator.Latest(),
+	}
+	// ถ้า DB ล่ม ยังตอบ version ของ binary ได้ (schema_version = -1)
+	if v, err := h.migrator.Version(r.Context()); err == nil {
+		resp.SchemaVersion = v
+	}
+	utils.WriteJSONResponse(w, http.StatusOK, resp)
+}
+
+func writeHealthReport(w http.ResponseWriter, rep health.Report, okStatus, failStatus string) {
+	resp := dto.HealthResponse{Status: okStatus}
+	status := http.StatusOK
+	if !rep.Healthy {
+		resp.Status = failStatus
+		status = http.StatusServiceUnavailable
 	}
 
-	utils.WriteJSONResponse(w, http.StatusOK, dto.HealthResponse{
-		Status:  "ready",
-		Details: map[string]any{"db": "ok"},
-	})
+	// details: ชื่อ check → "ok" หรือข้อความ error (รูปแบบเดิมของ /readyz)
+	details := make(map[string]any, len(rep.Checks))
+	for _, c := range rep.Checks {
+		details[c.Name] = c.Status
+		if c.Error != "" {
+			details[c.Name] = c.Error
+		}
+		resp.Checks = append(resp.Checks, dto.HealthCheckResult{
+			Name:      c.Name,
+			Status:    c.Status,
+			Optional:  c.Optional,
+			Error:     c.Error,
+			LatencyMs: float64(c.Latency.Microseconds()) / 1000,
+			CheckedAt: c.CheckedAt,
+			Cached:    c.Cached,
+		})
+	}
+	if len(details) > 0 {
+		resp.Details = details
+	}
+	utils.WriteJSONResponse(w, status, resp)
 }
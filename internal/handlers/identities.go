@@ -0,0 +1,287 @@
+package handlers
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
+	"github.com/jackc/pgx/v5/pgxpool"
+
+	"GO2GETHER_BACK-END/internal/apperr"
+	"GO2GETHER_BACK-END/internal/dto"
+	"GO2GETHER_BACK-END/internal/models"
+	"GO2GETHER_BACK-END/internal/utils"
+)
+
+// Errors returned when linking provider identities
+var (
+	ErrIdentityLinkedElsewhere = errors.New("provider account is linked to another user")
+	ErrProviderAlreadyLinked   = errors.New("user already has an account of this provider linked")
+	// ErrUnverifiedProviderEmail: an unverified provider email never signs into an existing account
+	ErrUnverifiedProviderEmail = errors.New("provider email is not verified")
+	// ErrAccountLinkRequired: an account with the email exists but cannot be merged automatically
+	ErrAccountLinkRequired = errors.New("account exists; sign in and link the provider from the profile")
+	// ErrProviderEmailRequired: new accounts need an email, which some providers let the user withhold
+	ErrProviderEmailRequired = errors.New("provider did not share an email address")
+)
+
+// ProviderIdentity is what a sign-in provider tells us about the user
+type ProviderIdentity struct {
+	Provider      string
+	Subject       string // stable provider user ID
+	Email         string
+	EmailVerified bool
+}
+
+// IdentitiesHandler lists and unlinks the sign-in providers of the current user
+type IdentitiesHandler struct {
+	db *pgxpool.Pool
+}
+
+// NewIdentitiesHandler creates a new IdentitiesHandler instance
+func NewIdentitiesHandler(db *pgxpool.Pool) *IdentitiesHandler {
+	return &IdentitiesHandler{db: db}
+}
+
+// ListIdentities returns the sign-in methods of the current user
+// @Summary List linked sign-in providers
+// @Description List external sign-in providers linked to the current user and whether a password is set
+// @Tags profile
+// @Produce json
+// @Security BearerAuth
+// @Success 200 {object} dto.IdentitiesResponse "Linked identities"
+// @Failure 401 {object} dto.ErrorResponse "Unauthorized"
+// @Failure 500 {object} dto.ErrorResponse "Internal server error"
+// @Router /api/profile/identities [get]
+func (h *IdentitiesHandler) ListIdentities(w http.ResponseWriter, r *http.Request) {
+	userID, ok := r.Context().Value("user_id").(uuid.UUID)
+	if !ok {
+		utils.WriteError(w, r, errNotAuthenticated)
+		return
+	}
+
+	var hasPassword bool
+	if err := h.db.QueryRow(r.Context(),
+		`SELECT password_hash <> '' FROM users WHERE id = $1`, userID,
+	).Scan(&hasPassword); err != nil {
+		utils.WriteError(w, r, err)
+		return
+	}
+
+	rows, err := h.db.Query(r.Context(),
+		`SELECT provider, email, email_verified, created_at, last_login_at
+		   FROM user_identities WHERE user_id = $1 ORDER BY created_at`, userID)
+	if err != nil {
+		utils.WriteError(w, r, err)
+		return
+	}
+	defer rows.Close()
+
+	resp := dto.IdentitiesResponse{HasPassword: hasPassword, Identities: []dto.IdentityResponse{}}
+	for rows.Next() {
+		var identity models.UserIdentity
+		if err := rows.Scan(&identity.Provider, &identity.Email, &identity.EmailVerified, &identity.CreatedAt, &identity.LastLoginAt); err != nil {
+			utils.WriteError(w, r, err)
+			return
+		}
+		item := dto.IdentityResponse{
+			Provider:      identity.Provider,
+			Email:         identity.Email,
+			EmailVerified: identity.EmailVerified,
+			LinkedAt:      identity.CreatedAt.Format(time.RFC3339),
+		}
+		if identity.LastLoginAt != nil {
+			lastLogin := identity.LastLoginAt.Format(time.RFC3339)
+			item.LastLoginAt = &lastLogin
+		}
+		resp.Identities = append(resp.Identities, item)
+	}
+	if err := rows.Err(); err != nil {
+		utils.WriteError(w, r, err)
+		return
+	}
+
+	utils.WriteJSONResponse(w, http.StatusOK, resp)
+}
+
+// UnlinkIdentity removes a linked sign-in provider from the current user
+// @Summary Unlink sign-in provider
+// @Description Unlink an external sign-in provider. Refused when it is the user's only way to sign in.
+// @Tags profile
+// @Produce json
+// @Security BearerAuth
+// @Param provider path string true "Provider name" example(google)
+// @Success 200 {object} map[string]string "Provider unlinked"
+// @Failure 401 {object} dto.ErrorResponse "Unauthorized"
+// @Failure 404 {object} dto.ErrorResponse "Provider not linked"
+// @Failure 409 {object} dto.ErrorResponse "Last sign-in method"
+// @Failure 500 {object} dto.ErrorResponse "Internal server error"
+// @Router /api/profile/identities/{provider} [delete]
+func (h *IdentitiesHandler) UnlinkIdentity(w http.ResponseWriter, r *http.Request) {
+	userID, ok := r.Context().Value("user_id").(uuid.UUID)
+	if !ok {
+		utils.WriteError(w, r, errNotAuthenticated)
+		return
+	}
+
+	provider := r.PathValue("provider")
+	if provider == "" {
+		utils.WriteError(w, r, apperr.Validation(apperr.Field("provider", "required", "missing or invalid provider")))
+		return
+	}
+
+	tx, err := h.db.Begin(r.Context())
+	if err != nil {
+		utils.WriteError(w, r, err)
+		return
+	}
+	defer tx.Rollback(r.Context())
+
+	// Lock the user so two concurrent unlinks cannot remove the last two methods
+	var hasPassword bool
+	var otherIdentities int
+	if err := tx.QueryRow(r.Context(),
+		`SELECT u.password_hash <> '',
+		        (SELECT COUNT(*) FROM user_identities i WHERE i.user_id = u.id AND i.provider <> $2)
+		   FROM users u WHERE u.id = $1 FOR UPDATE`,
+		userID, provider,
+	).Scan(&hasPassword, &otherIdentities); err != nil {
+		utils.WriteError(w, r, err)
+		return
+	}
+	if !hasPassword && otherIdentities == 0 {
+		utils.WriteError(w, r, apperr.New(http.StatusConflict, apperr.CodeConflict, "Last sign-in method",
+			"Set a password (via forgot password) or link another provider before unlinking this one"))
+		return
+	}
+
+	cmd, err := tx.Exec(r.Context(),
+		`DELETE FROM user_identities WHERE user_id = $1 AND provider = $2`, userID, provider)
+	if err != nil {
+		utils.WriteError(w, r, err)
+		return
+	}
+	if cmd.RowsAffected() == 0 {
+		utils.WriteError(w, r, apperr.NotFound("Provider is not linked to your account"))
+		return
+	}
+
+	if err := tx.Commit(r.Context()); err != nil {
+		utils.WriteError(w, r, err)
+		return
+	}
+
+	utils.WriteJSONResponse(w, http.StatusOK, map[string]string{"message": "Provider unlinked"})
+}
+
+// resolveProviderUser finds or creates the user signing in with a provider.
+// Identities are matched by provider subject; an email match with an existing
+// account is only merged when both sides have verified the address.
+func resolveProviderUser(ctx context.Context, db *pgxpool.Pool, identity ProviderIdentity) (models.User, error) {
+	var user models.User
+	err := db.QueryRow(ctx,
+		`UPDATE user_identities i
+		    SET last_login_at = NOW(), email = $3, email_verified = $4
+		   FROM users u
+		  WHERE u.id = i.user_id AND i.provider = $1 AND i.subject = $2
+		  RETURNING u.id, u.email, u.email_verified_at, u.created_at, u.updated_at`,
+		identity.Provider, identity.Subject, identity.Email, identity.EmailVerified,
+	).Scan(&user.ID, &user.Email, &user.EmailVerifiedAt, &user.CreatedAt, &user.UpdatedAt)
+	if err == nil {
+		return user, nil
+	}
+	if !errors.Is(err, pgx.ErrNoRows) {
+		return models.User{}, err
+	}
+	// LINE and Facebook let the user withhold the email; an account needs one
+	if identity.Email == "" {
+		return models.User{}, ErrProviderEmailRequired
+	}
+
+	tx, err := db.Begin(ctx)
+	if err != nil {
+		return models.User{}, err
+	}
+	defer tx.Rollback(ctx)
+
+	err = tx.QueryRow(ctx,
+		`SELECT id, email, email_verified_at, created_at, updated_at FROM users WHERE email = $1 FOR UPDATE`,
+		identity.Email,
+	).Scan(&user.ID, &user.Email, &user.EmailVerifiedAt, &user.CreatedAt, &user.UpdatedAt)
+	switch {
+	case err == nil:
+		// Someone could register an unverified provider email (or a local
+		// account) for an address they do not own; never merge those
+		if !identity.EmailVerified {
+			return models.User{}, ErrUnverifiedProviderEmail
+		}
+		if user.EmailVerifiedAt == nil {
+			return models.User{}, ErrAccountLinkRequired
+		}
+	case errors.Is(err, pgx.ErrNoRows):
+		now := time.Now()
+		user = models.User{ID: uuid.New(), Email: identity.Email, CreatedAt: now, UpdatedAt: now}
+		// The provider has already verified the address, so skip our own verification
+		if identity.EmailVerified {
+			user.EmailVerifiedAt = &now
+		}
+		if _, err := tx.Exec(ctx,
+			`INSERT INTO users (id, email, password_hash, email_verified_at, created_at, updated_at)
+			 VALUES ($1, $2, '', $3, $4, $5)`,
+			user.ID, user.Email, user.EmailVerifiedAt, now, now,
+		); err != nil {
+			return models.User{}, err
+		}
+	default:
+		return models.User{}, err
+	}
+
+	if err := insertIdentity(ctx, tx, user.ID, identity); err != nil {
+		return models.User{}, err
+	}
+	if err := tx.Commit(ctx); err != nil {
+		return models.User{}, err
+	}
+	return user, nil
+}
+
+// linkProviderIdentity links a provider account to an existing user (from the profile)
+func linkProviderIdentity(ctx context.Context, db *pgxpool.Pool, userID uuid.UUID, identity ProviderIdentity) error {
+	var ownerID uuid.UUID
+	err := db.QueryRow(ctx,
+		`SELECT user_id FROM user_identities WHERE provider = $1 AND subject = $2`,
+		identity.Provider, identity.Subject,
+	).Scan(&ownerID)
+	if err == nil {
+		if ownerID != userID {
+			return ErrIdentityLinkedElsewhere
+		}
+		return nil // already linked to this user
+	}
+	if !errors.Is(err, pgx.ErrNoRows) {
+		return err
+	}
+	return insertIdentity(ctx, db, userID, identity)
+}
+
+// insertIdentity maps unique violations to ErrIdentityLinkedElsewhere /
+// ErrProviderAlreadyLinked
+func insertIdentity(ctx context.Context, db sessionExecer, userID uuid.UUID, identity ProviderIdentity) error {
+	_, err := db.Exec(ctx,
+		`INSERT INTO user_identities (user_id, provider, subject, email, email_verified, last_login_at)
+		 VALUES ($1, $2, $3, $4, $5, NOW())`,
+		userID, identity.Provider, identity.Subject, identity.Email, identity.EmailVerified,
+	)
+	var pgErr *pgconn.PgError
+	if errors.As(err, &pgErr) && pgErr.Code == "23505" {
+		if pgErr.ConstraintName == "user_identities_user_id_provider_key" {
+			return ErrProviderAlreadyLinked
+		}
+		return ErrIdentityLinkedElsewhere
+	}
+	return err
+}
@@ -0,0 +1,31 @@
+package handlers
+
+import (
+	"net/http"
+
+	"GO2GETHER_BACK-END/internal/middleware"
+	"GO2GETHER_BACK-END/internal/utils"
+)
+
+// KeysHandler publishes the public token verification keys
+type KeysHandler struct {
+	keyRing *middleware.KeyRing
+}
+
+// NewKeysHandler creates a new KeysHandler instance
+func NewKeysHandler(keyRing *middleware.KeyRing) *KeysHandler {
+	return &KeysHandler{keyRing: keyRing}
+}
+
+// JWKS serves the JSON Web Key Set used to verify tokens
+// @Summary JSON Web Key Set
+// @Description Public keys (RS256/EdDSA) other services use to verify Go2gether tokens. Select the key by the token's kid header. HMAC keys are never published.
+// @Tags authentication
+// @Produce json
+// @Success 200 {object} middleware.JWKSet "Public keys"
+// @Router /.well-known/jwks.json [get]
+func (h *KeysHandler) JWKS(w http.ResponseWriter, r *http.Request) {
+	// Verifiers may cache briefly; rotation keeps the old key listed while its tokens live
+	w.Header().Set("Cache-Control", "public, max-age=300")
+	utils.WriteJSONResponse(w, http.StatusOK, h.keyRing.JWKS())
+}
@@ -0,0 +1,553 @@
+package handlers
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/base32"
+	"errors"
+	"log/slog"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+
+	"GO2GETHER_BACK-END/internal/apperr"
+	"GO2GETHER_BACK-END/internal/config"
+	"GO2GETHER_BACK-END/internal/dto"
+	"GO2GETHER_BACK-END/internal/middleware"
+	"GO2GETHER_BACK-END/internal/models"
+	"GO2GETHER_BACK-END/internal/utils"
+)
+
+// recoveryCodeCount is how many recovery codes a user gets per set
+const recoveryCodeCount = 10
+
+// Errors returned when checking a second factor
+var (
+	// ErrInvalidSecondFactor is returned for wrong, reused or unknown MFA codes
+	ErrInvalidSecondFactor = errors.New("invalid authentication code")
+	// ErrMFANotEnabled is returned when a second factor is checked for a user without one
+	ErrMFANotEnabled = errors.New("two-factor authentication is not enabled")
+)
+
+// MFAHandler handles TOTP two-factor enrolment and the second login step
+type MFAHandler struct {
+	db       *pgxpool.Pool
+	config   *config.Config
+	sessions SessionsService
+}
+
+// NewMFAHandler creates a new MFAHandler instance
+func NewMFAHandler(db *pgxpool.Pool, cfg *config.Config) *MFAHandler {
+	return &MFAHandler{
+		db:       db,
+		config:   cfg,
+		sessions: NewSessionsService(db, &cfg.JWT),
+	}
+}
+
+// Status returns whether the current user has two-factor authentication
+// @Summary Two-factor authentication status
+// @Description Show whether TOTP two-factor authentication is enabled and how many recovery codes are left
+// @Tags authentication
+// @Produce json
+// @Security BearerAuth
+// @Success 200 {object} dto.MFAStatusResponse "MFA status"
+// @Failure 401 {object} dto.ErrorResponse "Unauthorized"
+// @Failure 500 {object} dto.ErrorResponse "Internal server error"
+// @Router /api/auth/mfa [get]
+func (h *MFAHandler) Status(w http.ResponseWriter, r *http.Request) {
+	userID, ok := r.Context().Value("user_id").(uuid.UUID)
+	if !ok {
+		utils.WriteError(w, r, errNotAuthenticated)
+		return
+	}
+
+	var enabledAt *time.Time
+	var remaining int
+	if err := h.db.QueryRow(r.Context(),
+		`SELECT u.totp_enabled_at,
+		        (SELECT COUNT(*) FROM mfa_recovery_codes c WHERE c.user_id = u.id AND c.used_at IS NULL)
+		   FROM users u WHERE u.id = $1`, userID,
+	).Scan(&enabledAt, &remaining); err != nil {
+		utils.WriteError(w, r, err)
+		return
+	}
+
+	resp := dto.MFAStatusResponse{Enabled: enabledAt != nil, RecoveryCodesRemaining: remaining}
+	if enabledAt != nil {
+		at := enabledAt.Format(time.RFC3339)
+		resp.EnabledAt = &at
+	}
+	utils.WriteJSONResponse(w, http.StatusOK, resp)
+}
+
+// Setup starts TOTP enrolment
+// @Summary Start two-factor enrolment
+// @Description Generate a new TOTP secret. Show `provisioning_uri` as a QR code, then confirm with a code at /api/auth/mfa/enable. Calling it again replaces a secret that was not confirmed yet.
+// @Tags authentication
+// @Produce json
+// @Security BearerAuth
+// @Success 200 {object} dto.MFASetupResponse "TOTP secret and provisioning URI"
+// @Failure 401 {object} dto.ErrorResponse "Unauthorized"
+// @Failure 409 {object} dto.ErrorResponse "Two-factor authentication already enabled"
+// @Failure 500 {object} dto.ErrorResponse "Internal server error"
+// @Router /api/auth/mfa/setup [post]
+func (h *MFAHandler) Setup(w http.ResponseWriter, r *http.Request) {
+	userID, ok := r.Context().Value("user_id").(uuid.UUID)
+	if !ok {
+		utils.WriteError(w, r, errNotAuthenticated)
+		return
+	}
+
+	secret, err := utils.GenerateTOTPSecret()
+	if err != nil {
+		utils.WriteError(w, r, err)
+		return
+	}
+	box, err := mfaSecretBox(h.config)
+	if err != nil {
+		utils.WriteError(w, r, err)
+		return
+	}
+	sealed, err := box.Seal(secret)
+	if err != nil {
+		utils.WriteError(w, r, err)
+		return
+	}
+
+	// Only a pending (unconfirmed) secret may be replaced
+	var email string
+	err = h.db.QueryRow(r.Context(),
+		`UPDATE users SET totp_secret = $2 WHERE id = $1 AND totp_enabled_at IS NULL RETURNING email`,
+		userID, sealed,
+	).Scan(&email)
+	if errors.Is(err, pgx.ErrNoRows) {
+		utils.WriteError(w, r, apperr.New(http.StatusConflict, apperr.CodeConflict, "MFA already enabled",
+			"Disable two-factor authentication before setting it up again"))
+		return
+	}
+	if err != nil {
+		utils.WriteError(w, r, err)
+		return
+	}
+
+	issuer := h.config.Auth.MFAIssuer
+	utils.WriteJSONResponse(w, http.StatusOK, dto.MFASetupResponse{
+		Secret:          secret,
+		ProvisioningURI: utils.TOTPProvisioningURI(issuer, email, secret),
+		Issuer:          issuer,
+		Account:         email,
+	})
+}
+
+// Enable confirms TOTP enrolment with a first code
+// @Summary Enable two-factor authentication
+// @Description Confirm the secret from /api/auth/mfa/setup with a current code. Returns recovery codes, which are shown only once.
+// @Tags authentication
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Param request body dto.MFACodeRequest true "Authenticator code"
+// @Success 200 {object} dto.MFARecoveryCodesResponse "Two-factor authentication enabled"
+// @Failure 400 {object} dto.ErrorResponse "Invalid code or setup not started"
+// @Failure 401 {object} dto.ErrorResponse "Unauthorized"
+// @Failure 409 {object} dto.ErrorResponse "Two-factor authentication already enabled"
+// @Failure 500 {object} dto.ErrorResponse "Internal server error"
+// @Router /api/auth/mfa/enable [post]
+func (h *MFAHandler) Enable(w http.ResponseWriter, r *http.Request) {
+	userID, ok := r.Context().Value("user_id").(uuid.UUID)
+	if !ok {
+		utils.WriteError(w, r, errNotAuthenticated)
+		return
+	}
+
+	var req dto.MFACodeRequest
+	if !utils.DecodeJSON(w, r, &req) {
+		return
+	}
+	if req.Code == "" {
+		utils.WriteError(w, r, errCodeRequired)
+		return
+	}
+
+	tx, err := h.db.Begin(r.Context())
+	if err != nil {
+		utils.WriteError(w, r, err)
+		return
+	}
+	defer tx.Rollback(r.Context())
+
+	var sealed *string
+	var enabledAt *time.Time
+	if err := tx.QueryRow(r.Context(),
+		`SELECT totp_secret, totp_enabled_at FROM users WHERE id = $1 FOR UPDATE`, userID,
+	).Scan(&sealed, &enabledAt); err != nil {
+		utils.WriteError(w, r, err)
+		return
+	}
+	if enabledAt != nil {
+		utils.WriteError(w, r, errMFAEnabled)
+		return
+	}
+	if sealed == nil {
+		utils.WriteError(w, r, apperr.New(http.StatusBadRequest, apperr.CodeBadRequest, "Setup required", "Start with /api/auth/mfa/setup"))
+		return
+	}
+
+	step, err := h.checkTOTP(*sealed, req.Code)
+	if err != nil {
+		writeSecondFactorError(w, r, err)
+		return
+	}
+
+	if _, err := tx.Exec(r.Context(),
+		`UPDATE users SET totp_enabled_at = NOW(), totp_last_step = $2 WHERE id = $1`, userID, step,
+	); err != nil {
+		utils.WriteError(w, r, err)
+		return
+	}
+	codes, err := replaceRecoveryCodes(r.Context(), tx, userID)
+	if err != nil {
+		utils.WriteError(w, r, err)
+		return
+	}
+	if err := tx.Commit(r.Context()); err != nil {
+		utils.WriteError(w, r, err)
+		return
+	}
+
+	utils.WriteJSONResponse(w, http.StatusOK, dto.MFARecoveryCodesResponse{RecoveryCodes: codes})
+}
+
+// Disable turns two-factor authentication off
+// @Summary Disable two-factor authentication
+// @Description Turn off two-factor authentication with a current code or a recovery code. Recovery codes are deleted.
+// @Tags authentication
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Param request body dto.MFACodeRequest true "Authenticator or recovery code"
+// @Success 200 {object} map[string]string "Two-factor authentication disabled"
+// @Failure 400 {object} dto.ErrorResponse "Invalid code or not enabled"
+// @Failure 401 {object} dto.ErrorResponse "Unauthorized"
+// @Failure 500 {object} dto.ErrorResponse "Internal server error"
+// @Router /api/auth/mfa/disable [post]
+func (h *MFAHandler) Disable(w http.ResponseWriter, r *http.Request) {
+	userID, ok := r.Context().Value("user_id").(uuid.UUID)
+	if !ok {
+		utils.WriteError(w, r, errNotAuthenticated)
+		return
+	}
+
+	var req dto.MFACodeRequest
+	if !utils.DecodeJSON(w, r, &req) {
+		return
+	}
+	if req.Code == "" && req.RecoveryCode == "" {
+		utils.WriteError(w, r, apperr.Validation(apperr.Field("code", "required", "code or recovery_code is required")))
+		return
+	}
+
+	if err := h.verifySecondFactor(r.Context(), userID, req.Code, req.RecoveryCode); err != nil {
+		writeSecondFactorError(w, r, err)
+		return
+	}
+
+	tx, err := h.db.Begin(r.Context())
+	if err != nil {
+		utils.WriteError(w, r, err)
+		return
+	}
+	defer tx.Rollback(r.Context())
+
+	if _, err := tx.Exec(r.Context(),
+		`UPDATE users SET totp_secret = NULL, totp_enabled_at = NULL, totp_last_step = 0 WHERE id = $1`, userID,
+	); err != nil {
+		utils.WriteError(w, r, err)
+		return
+	}
+	if _, err := tx.Exec(r.Context(), `DELETE FROM mfa_recovery_codes WHERE user_id = $1`, userID); err != nil {
+		utils.WriteError(w, r, err)
+		return
+	}
+	if err := tx.Commit(r.Context()); err != nil {
+		utils.WriteError(w, r, err)
+		return
+	}
+
+	utils.WriteJSONResponse(w, http.StatusOK, map[string]string{"message": "Two-factor authentication disabled"})
+}
+
+// RegenerateRecoveryCodes replaces the recovery codes
+// @Summary Regenerate recovery codes
+// @Description Replace every recovery code with a new set, confirmed with a current authenticator code
+// @Tags authentication
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Param request body dto.MFACodeRequest true "Authenticator code"
+// @Success 200 {object} dto.MFARecoveryCodesResponse "New recovery codes"
+// @Failure 400 {object} dto.ErrorResponse "Invalid code or not enabled"
+// @Failure 401 {object} dto.ErrorResponse "Unauthorized"
+// @Failure 500 {object} dto.ErrorResponse "Internal server error"
+// @Router /api/auth/mfa/recovery-codes [post]
+func (h *MFAHandler) RegenerateRecoveryCodes(w http.ResponseWriter, r *http.Request) {
+	userID, ok := r.Context().Value("user_id").(uuid.UUID)
+	if !ok {
+		utils.WriteError(w, r, errNotAuthenticated)
+		return
+	}
+
+	var req dto.MFACodeRequest
+	if !utils.DecodeJSON(w, r, &req) {
+		return
+	}
+	if req.Code == "" {
+		utils.WriteError(w, r, errCodeRequired)
+		return
+	}
+
+	if err := h.verifySecondFactor(r.Context(), userID, req.Code, ""); err != nil {
+		writeSecondFactorError(w, r, err)
+		return
+	}
+
+	tx, err := h.db.Begin(r.Context())
+	if err != nil {
+		utils.WriteError(w, r, err)
+		return
+	}
+	defer tx.Rollback(r.Context())
+
+	codes, err := replaceRecoveryCodes(r.Context(), tx, userID)
+	if err != nil {
+		utils.WriteError(w, r, err)
+		return
+	}
+	if err := tx.Commit(r.Context()); err != nil {
+		utils.WriteError(w, r, err)
+		return
+	}
+
+	utils.WriteJSONResponse(w, http.StatusOK, dto.MFARecoveryCodesResponse{RecoveryCodes: codes})
+}
+
+// Verify completes a login that returned an MFA challenge
+// @Summary Complete two-factor login
+// @Description Exchange the `mfa_token` from login (or the OAuth code exchange) and an authenticator or recovery code for an access/refresh token pair. Wrong codes count towards the login lockout.
+// @Tags authentication
+// @Accept json
+// @Produce json
+// @Param request body dto.MFAVerifyRequest true "Challenge token and code"
+// @Success 200 {object} dto.AuthResponse "Login successful"
+// @Failure 400 {object} dto.ErrorResponse "Invalid request data"
+// @Failure 401 {object} dto.ErrorResponse "Invalid or expired challenge, or wrong code"
+// @Failure 423 {object} dto.ErrorResponse "Account temporarily locked"
+// @Failure 429 {object} dto.ErrorResponse "Too many requests"
+// @Failure 500 {object} dto.ErrorResponse "Internal server error"
+// @Router /api/auth/mfa/verify [post]
+func (h *MFAHandler) Verify(w http.ResponseWriter, r *http.Request) {
+	var req dto.MFAVerifyRequest
+	if !utils.DecodeJSON(w, r, &req) {
+		return
+	}
+
+	claims, err := middleware.ValidateMFAChallengeToken(req.MFAToken, &h.config.JWT)
+	if err != nil {
+		utils.WriteError(w, r, errInvalidMFAToken.WithCause(err))
+		return
+	}
+
+	var user models.User
+	var lockedUntil *time.Time
+	if err := h.db.QueryRow(r.Context(),
+		`SELECT id, email, email_verified_at, totp_enabled_at, locked_until, created_at, updated_at FROM users WHERE id = $1`,
+		claims.UserID,
+	).Scan(&user.ID, &user.Email, &user.EmailVerifiedAt, &user.TOTPEnabledAt, &lockedUntil, &user.CreatedAt, &user.UpdatedAt); err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			err = errInvalidMFAToken // the user was deleted meanwhile
+		}
+		utils.WriteError(w, r, err)
+		return
+	}
+	if lockedUntil != nil && time.Now().Before(*lockedUntil) {
+		writeAccountLocked(w, r, *lockedUntil)
+		return
+	}
+
+	if err := h.verifySecondFactor(r.Context(), user.ID, req.Code, req.RecoveryCode); err != nil {
+		if !errors.Is(err, ErrInvalidSecondFactor) {
+			utils.WriteError(w, r, err)
+			return
+		}
+		lockedUntil, err := recordFailedLogin(r.Context(), h.db, &h.config.Auth, user.ID)
+		if err != nil {
+			slog.ErrorContext(r.Context(), "recording failed login failed", "error", err, "user_id", user.ID.String())
+		} else if lockedUntil != nil {
+			writeAccountLocked(w, r, *lockedUntil)
+			return
+		}
+		utils.WriteError(w, r, apperr.New(http.StatusUnauthorized, apperr.CodeInvalidCode, "Invalid code",
+			"The authentication code is incorrect or was already used"))
+		return
+	}
+
+	if _, err := h.db.Exec(r.Context(),
+		`UPDATE users SET failed_login_attempts = 0, locked_until = NULL WHERE id = $1 AND failed_login_attempts > 0`, user.ID); err != nil {
+		slog.ErrorContext(r.Context(), "resetting failed logins failed", "error", err, "user_id", user.ID.String())
+	}
+
+	// Issue access + refresh token
+	tokens, err := h.sessions.Issue(r.Context(), user.ID, user.Email, sessionClient(r))
+	if err != nil {
+		utils.WriteError(w, r, err)
+		return
+	}
+
+	utils.WriteJSONResponse(w, http.StatusOK, dto.AuthResponse{
+		User:         toUserResponse(user),
+		Token:        tokens.AccessToken,
+		RefreshToken: tokens.RefreshToken,
+		ExpiresIn:    tokens.ExpiresIn,
+	})
+}
+
+// verifySecondFactor accepts a TOTP code (each time step once) or an unused
+// recovery code of a user with two-factor authentication enabled
+func (h *MFAHandler) verifySecondFactor(ctx context.Context, userID uuid.UUID, code, recoveryCode string) error {
+	var sealed *string
+	var enabledAt *time.Time
+	if err := h.db.QueryRow(ctx,
+		`SELECT totp_secret, totp_enabled_at FROM users WHERE id = $1`, userID,
+	).Scan(&sealed, &enabledAt); err != nil {
+		return err
+	}
+	if enabledAt == nil || sealed == nil {
+		return ErrMFANotEnabled
+	}
+
+	if code != "" {
+		step, err := h.checkTOTP(*sealed, code)
+		if err != nil {
+			return err
+		}
+		// Moving the last step forward atomically makes each code single use
+		cmd, err := h.db.Exec(ctx,
+			`UPDATE users SET totp_last_step = $2 WHERE id = $1 AND totp_last_step < $2`, userID, step)
+		if err != nil {
+			return err
+		}
+		if cmd.RowsAffected() == 0 {
+			return ErrInvalidSecondFactor
+		}
+		return nil
+	}
+
+	cmd, err := h.db.Exec(ctx,
+		`UPDATE mfa_recovery_codes SET used_at = NOW()
+		  WHERE user_id = $1 AND code_hash = $2 AND used_at IS NULL`,
+		userID, hashRefreshToken(normalizeRecoveryCode(recoveryCode)),
+	)
+	if err != nil {
+		return err
+	}
+	if cmd.RowsAffected() == 0 {
+		return ErrInvalidSecondFactor
+	}
+	return nil
+}
+
+// checkTOTP decrypts the stored secret and returns the time step the code matches
+func (h *MFAHandler) checkTOTP(sealed, code string) (int64, error) {
+	box, err := mfaSecretBox(h.config)
+	if err != nil {
+		return 0, err
+	}
+	secret, err := box.Open(sealed)
+	if err != nil {
+		return 0, err
+	}
+	step, ok := utils.ValidateTOTP(secret, code, time.Now())
+	if !ok {
+		return 0, ErrInvalidSecondFactor
+	}
+	return step, nil
+}
+
+func writeSecondFactorError(w http.ResponseWriter, r *http.Request, err error) {
+	switch {
+	case errors.Is(err, ErrInvalidSecondFactor):
+		err = errSecondFactor
+	case errors.Is(err, ErrMFANotEnabled):
+		err = errMFANotEnabled
+	}
+	utils.WriteError(w, r, err)
+}
+
+// writeMFAChallenge answers the first login step of an account with
+// two-factor authentication: no tokens, only a short-lived challenge
+func writeMFAChallenge(w http.ResponseWriter, r *http.Request, cfg *config.Config, userID uuid.UUID, method string) {
+	token, err := middleware.GenerateMFAChallengeToken(userID, method, cfg.Auth.MFAChallengeTTL, &cfg.JWT)
+	if err != nil {
+		utils.WriteError(w, r, err)
+		return
+	}
+	utils.WriteJSONResponse(w, http.StatusOK, dto.MFAChallengeResponse{
+		MFARequired: true,
+		MFAToken:    token,
+		ExpiresIn:   int64(cfg.Auth.MFAChallengeTTL.Seconds()),
+	})
+}
+
+// mfaSecretBox encrypts TOTP secrets with MFA_SECRET_KEY, or JWT_SECRET when unset
+func mfaSecretBox(cfg *config.Config) (*utils.SecretBox, error) {
+	key := cfg.Auth.MFASecretKey
+	if key == "" {
+		key = cfg.JWT.Secret
+	}
+	return utils.NewSecretBox(key)
+}
+
+// replaceRecoveryCodes generates a new set of recovery codes and stores their
+// hashes in place of the old set. The plain codes are returned once.
+func replaceRecoveryCodes(ctx context.Context, tx pgx.Tx, userID uuid.UUID) ([]string, error) {
+	if _, err := tx.Exec(ctx, `DELETE FROM mfa_recovery_codes WHERE user_id = $1`, userID); err != nil {
+		return nil, err
+	}
+
+	codes := make([]string, 0, recoveryCodeCount)
+	for i := 0; i < recoveryCodeCount; i++ {
+		code, err := generateRecoveryCode()
+		if err != nil {
+			return nil, err
+		}
+		if _, err := tx.Exec(ctx,
+			`INSERT INTO mfa_recovery_codes (user_id, code_hash) VALUES ($1, $2)`,
+			userID, hashRefreshToken(normalizeRecoveryCode(code)),
+		); err != nil {
+			return nil, err
+		}
+		codes = append(codes, code)
+	}
+	return codes, nil
+}
+
+// generateRecoveryCode returns 80 random bits as XXXX-XXXX-XXXX-XXXX
+func generateRecoveryCode() (string, error) {
+	b := make([]byte, 10)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	raw := base32.StdEncoding.EncodeToString(b) // 16 chars, no padding for 10 bytes
+	return raw[0:4] + "-" + raw[4:8] + "-" + raw[8:12] + "-" + raw[12:16], nil
+}
+
+// normalizeRecoveryCode ignores case, spaces and dashes as users type them
+func normalizeRecoveryCode(code string) string {
+	code = strings.ToUpper(code)
+	return strings.NewReplacer("-", "", " ", "").Replace(code)
+}
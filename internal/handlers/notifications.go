@@ -5,47 +5,65 @@ import (
 	"encoding/json"
 	"errors"
 	"fmt"
-	"log"
+	"log/slog"
 	"net/http"
 	"strconv"
 	"strings"
 	"time"
 
 	"github.com/google/uuid"
-	"github.com/jackc/pgx/v5/pgxpool"
 
+	"GO2GETHER_BACK-END/internal/config"
 	"GO2GETHER_BACK-END/internal/dto"
+	"GO2GETHER_BACK-END/internal/models"
+	"GO2GETHER_BACK-END/internal/outbox"
+	"GO2GETHER_BACK-END/internal/realtime"
+	"GO2GETHER_BACK-END/internal/repository"
+	"GO2GETHER_BACK-END/internal/service"
 	"GO2GETHER_BACK-END/internal/utils"
 )
 
-type Type string
+// Type ชนิดของ notification (ดู models.NotificationType)
+type Type = models.NotificationType
 
 const (
-	TypeTripInvitation     Type = "trip_invitation"
-	TypeInvitationAccepted Type = "invitation_accepted"
-	TypeInvitationDeclined Type = "invitation_declined"
-	TypeTripUpdate         Type = "trip_update"
-	TypeAvailability       Type = "availability_updated"
-	TypeMemberJoined       Type = "member_joined"
-	TypeMemberLeft         Type = "member_left"
+	TypeTripInvitation     = models.NotificationTripInvitation
+	TypeInvitationAccepted = models.NotificationInvitationAccepted
+	TypeInvitationDeclined = models.NotificationInvitationDeclined
+	TypeTripUpdate         = models.NotificationTripUpdate
+	TypeAvailability       = models.NotificationAvailability
+	TypeMemberJoined       = models.NotificationMemberJoined
+	TypeMemberLeft         = models.NotificationMemberLeft
 )
 
+// ErrInvalidNotification wraps the validation errors of NotificationsService
+var ErrInvalidNotification = errors.New("invalid notification")
+
 // NotificationsService: helper (สร้าง noti)
 type NotificationsService interface {
 	Create(ctx context.Context, userID uuid.UUID, nType string, title string, message *string, data map[string]any, actionURL *string) error
+	// Deliver validates and stores n like Create. Both consult the
+	// recipient's preferences first and store nothing they turned off. A set
+	// n.ID makes delivering the same notification again a no-op.
+	Deliver(ctx context.Context, n models.Notification) error
 }
 
 // concrete service
 type notificationsService struct {
-	db *pgxpool.Pool
+	repo  repository.NotificationRepository
+	prefs *service.PreferenceService
+	pub   realtime.Publisher
 }
 
-func NewNotificationsService(db *pgxpool.Pool) NotificationsService {
-	return &notificationsService{db: db}
+// NewNotificationsService creates the service. Notifications are routed by
+// the recipient's preferences (prefs, nil to deliver everything); stored
+// notifications are pushed to the open streams of their user through pub
+// (nil for none).
+func NewNotificationsService(repo repository.NotificationRepository, prefs *service.PreferenceService, pub realtime.Publisher) NotificationsService {
+	return &notificationsService{repo: repo, prefs: prefs, pub: pub}
 }
 
 // Implement the Create method for notificationsService
-// Production-ready: includes validation, proper error handling, and logging
 func (s *notificationsService) Create(
 	ctx context.Context,
 	userID uuid.UUID,
@@ -55,127 +73,186 @@ func (s *notificationsService) Create(
 	data map[string]any,
 	actionURL *string,
 ) error {
-	// Validation
-	if userID == uuid.Nil {
-		return errors.New("user_id cannot be nil")
-	}
-	if strings.TrimSpace(nType) == "" {
-		return errors.New("notification type is required")
+	return s.Deliver(ctx, models.Notification{
+		UserID:    userID,
+		Type:      nType,
+		Title:     title,
+		Message:   message,
+		Data:      data,
+		ActionURL: actionURL,
+	})
+}
+
+// Deliver: Production-ready: includes validation, proper error handling, and logging
+func (s *notificationsService) Deliver(ctx context.Context, n models.Notification) error {
+	if err := validateNotification(ctx, n); err != nil {
+		return fmt.Errorf("%w: %v", ErrInvalidNotification, err)
 	}
-	if strings.TrimSpace(title) == "" {
-		return errors.New("notification title is required")
+	if n.TripID == nil {
+		n.TripID = tripOf(n.Data)
 	}
-	if len(title) > 255 {
-		return errors.New("notification title exceeds maximum length of 255 characters")
+
+	// ตาม preferences ของผู้รับ: ปิด in-app/mute trip = ไม่เก็บเลย,
+	// quiet hours = เก็บไว้แต่ไม่ stream ไปรบกวน
+	route := models.NotificationRoute{InApp: true}
+	if s.prefs != nil {
+		var err error
+		route, err = s.prefs.Route(ctx, n.UserID, n.TripID, models.NotificationType(n.Type))
+		if err != nil {
+			return err
+		}
 	}
-	if message != nil && len(*message) > 10000 {
-		return errors.New("notification message exceeds maximum length of 10000 characters")
-	}
-	if actionURL != nil && len(*actionURL) > 2048 {
-		return errors.New("action_url exceeds maximum length of 2048 characters")
+	if !route.InApp {
+		slog.DebugContext(ctx, "notification turned off by preferences",
+			"recipient_id", n.UserID.String(), "type", n.Type)
+		return nil
 	}
 
-	// Validate notification type
-	validTypes := map[string]bool{
-		"trip_invitation":      true,
-		"invitation_accepted":  true,
-		"invitation_declined":  true,
-		"trip_update":          true,
-		"availability_updated": true,
-		"member_joined":        true,
-		"member_left":          true,
-	}
-	if !validTypes[nType] {
-		log.Printf("Warning: Unknown notification type: %s (user_id=%s)", nType, userID.String())
-		// ไม่ return error เพื่อไม่ให้บล็อกการทำงาน แต่ log warning
+	// กำหนด id และเวลาเอง เพื่อให้สิ่งที่ stream ออกไปตรงกับแถวใน DB
+	if n.ID == uuid.Nil {
+		n.ID = uuid.New()
 	}
-
-	// Prepare JSON data
-	var dataJSON interface{}
-	if len(data) > 0 {
-		jsonBytes, err := json.Marshal(data)
-		if err != nil {
-			return fmt.Errorf("failed to marshal notification data: %w", err)
-		}
-		// Limit JSON size to prevent abuse (1MB limit)
-		if len(jsonBytes) > 1024*1024 {
-			return errors.New("notification data exceeds maximum size of 1MB")
-		}
-		dataJSON = string(jsonBytes)
-	} else {
-		dataJSON = nil
+	if n.CreatedAt.IsZero() {
+		n.CreatedAt = time.Now().UTC()
 	}
 
 	// Insert with context timeout
 	insertCtx, cancel := context.WithTimeout(ctx, 3*time.Second)
 	defer cancel()
 
-	cmdTag, err := s.db.Exec(insertCtx, `
-		INSERT INTO notifications (user_id, type, title, message, data, action_url)
-		VALUES ($1, $2, $3, $4, $5::jsonb, $6)
-	`, userID, nType, title, message, dataJSON, actionURL)
-
-	if err != nil {
+	if err := s.repo.Insert(insertCtx, n); err != nil {
 		// Check for specific database errors
 		if errors.Is(err, context.DeadlineExceeded) {
 			return fmt.Errorf("notification creation timeout: %w", err)
 		}
 		// Log database errors for monitoring
 		if strings.Contains(err.Error(), "connection") || strings.Contains(err.Error(), "network") {
-			log.Printf("Database connection error creating notification: %v (user_id=%s, type=%s)",
-				err, userID.String(), nType)
+			slog.ErrorContext(ctx, "database connection error creating notification",
+				"error", err, "recipient_id", n.UserID.String(), "type", n.Type)
 		}
 		return fmt.Errorf("failed to insert notification: %w", err)
 	}
 
-	if cmdTag.RowsAffected() != 1 {
-		log.Printf("Warning: Notification insert affected %d rows instead of 1 (user_id=%s, type=%s)",
-			cmdTag.RowsAffected(), userID.String(), nType)
-		return errors.New("unexpected number of rows affected")
+	if s.pub != nil && !route.Quiet() {
+		s.pub.Publish(ctx, n)
 	}
-
 	return nil
 }
 
-// Create is deprecated - use NotificationsService.Create instead
-// This function is kept for backward compatibility but should not be used in new code
-// Deprecated: Use NotificationsService.Create instead
-func Create(
-	ctx context.Context,
-	db *pgxpool.Pool,
-	userID string,
-	typ Type,
-	title string,
-	message *string,
-	data map[string]any,
-	actionURL *string,
-) error {
-	// Parse userID to UUID
-	uid, err := uuid.Parse(userID)
+// tripOf reads the trip a notification is about from data["trip_id"], which
+// every trip notification carries
+func tripOf(data map[string]any) *uuid.UUID {
+	s, ok := data["trip_id"].(string)
+	if !ok {
+		return nil
+	}
+	id, err := uuid.Parse(s)
 	if err != nil {
-		return fmt.Errorf("invalid user_id format: %w", err)
+		return nil
+	}
+	return &id
+}
+
+func validateNotification(ctx context.Context, n models.Notification) error {
+	if n.UserID == uuid.Nil {
+		return errors.New("user_id cannot be nil")
+	}
+	if strings.TrimSpace(n.Type) == "" {
+		return errors.New("notification type is required")
+	}
+	if strings.TrimSpace(n.Title) == "" {
+		return errors.New("notification title is required")
+	}
+	if len(n.Title) > 255 {
+		return errors.New("notification title exceeds maximum length of 255 characters")
+	}
+	if n.Message != nil && len(*n.Message) > 10000 {
+		return errors.New("notification message exceeds maximum length of 10000 characters")
+	}
+	if n.ActionURL != nil && len(*n.ActionURL) > 2048 {
+		return errors.New("action_url exceeds maximum length of 2048 characters")
+	}
+
+	// Validate notification type
+	if !models.NotificationType(n.Type).Valid() {
+		slog.WarnContext(ctx, "unknown notification type", "type", n.Type, "recipient_id", n.UserID.String())
+		// ไม่ return error เพื่อไม่ให้บล็อกการทำงาน แต่ log warning
 	}
 
-	// Use the service implementation
-	service := NewNotificationsService(db)
-	return service.Create(ctx, uid, string(typ), title, message, data, actionURL)
+	// Limit JSON size to prevent abuse (1MB limit)
+	if len(n.Data) > 0 {
+		jsonBytes, err := json.Marshal(n.Data)
+		if err != nil {
+			return fmt.Errorf("failed to marshal notification data: %w", err)
+		}
+		if len(jsonBytes) > 1024*1024 {
+			return errors.New("notification data exceeds maximum size of 1MB")
+		}
+	}
+	return nil
 }
 
-// NotificationsHandler: HTTP endpoints (list/mark read/mark all read)
-type NotificationsHandler struct {
-	db  *pgxpool.Pool
+// inAppChannel delivers outbox events as in-app notifications
+type inAppChannel struct {
 	svc NotificationsService
 }
 
-func NewNotificationsHandler(db *pgxpool.Pool) *NotificationsHandler {
+// InAppChannel is the outbox channel storing events as notifications
+// through svc. The notification ID is derived from the event, so a retried
+// delivery does not insert it twice.
+func InAppChannel(svc NotificationsService) outbox.Channel {
+	return inAppChannel{svc: svc}
+}
+
+func (inAppChannel) Name() string { return models.ChannelInApp }
+
+func (c inAppChannel) Deliver(ctx context.Context, e models.OutboxEvent) error {
+	err := c.svc.Deliver(ctx, models.Notification{
+		ID:        uuid.NewSHA1(e.ID, []byte(models.ChannelInApp)),
+		UserID:    e.RecipientID,
+		TripID:    e.TripID,
+		Type:      string(e.Type),
+		Title:     e.Payload.Title,
+		Message:   e.Payload.Message,
+		Data:      e.Payload.Data,
+		ActionURL: e.Payload.ActionURL,
+	})
+	if errors.Is(err, ErrInvalidNotification) {
+		return outbox.Permanent(err)
+	}
+	return err
+}
+
+// NotificationsHandler: HTTP endpoints (list/mark read/mark all read/stream/preferences)
+type NotificationsHandler struct {
+	repo  repository.NotificationRepository
+	svc   NotificationsService
+	prefs *service.PreferenceService
+	hub   *realtime.Hub
+	cfg   config.NotificationsConfig
+	// wsOrigins are the hosts allowed to open the WebSocket cross-origin
+	wsOrigins []string
+}
+
+// NewNotificationsHandler creates the handler; new notifications are routed
+// by the preferences in repos, published through pub and streamed from hub
+func NewNotificationsHandler(repos repository.Repositories, hub *realtime.Hub, pub realtime.Publisher, cfg *config.Config) *NotificationsHandler {
+	prefs := service.NewPreferenceService(repos)
 	return &NotificationsHandler{
-		db:  db,
-		svc: NewNotificationsService(db),
+		repo:      repos.Notifications,
+		svc:       NewNotificationsService(repos.Notifications, prefs, pub),
+		prefs:     prefs,
+		hub:       hub,
+		cfg:       cfg.Notifications,
+		wsOrigins: originHosts(cfg.CORS.AllowedOrigins),
 	}
 }
 
 func (h *NotificationsHandler) Service() NotificationsService { return h.svc }
 
+// Preferences routes notifications by the users' preferences
+func (h *NotificationsHandler) Preferences() *service.PreferenceService { return h.prefs }
+
 // -----------------------------------------------------------------------------
 // 5.1 GET /api/notifications
 // @Summary List notifications
@@ -192,11 +269,6 @@ func (h *NotificationsHandler) Service() NotificationsService { return h.svc }
 // @Failure 500 {object} dto.ErrorResponse
 // @Router /api/notifications [get]
 func (h *NotificationsHandler) ListNotifications(w http.ResponseWriter, r *http.Request) {
-	if r.Method != http.MethodGet {
-		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
-		return
-	}
-
 	userID, ok := r.Context().Value("user_id").(uuid.UUID)
 	if !ok {
 		utils.WriteErrorResponse(w, http.StatusUnauthorized, "Unauthorized", "Invalid user context")
@@ -238,117 +310,35 @@ func (h *NotificationsHandler) ListNotifications(w http.ResponseWriter, r *http.
 	}
 
 	// Validate notification type if provided
-	if typ != "" {
-		validTypes := map[string]bool{
-			"trip_invitation":      true,
-			"invitation_accepted":  true,
-			"invitation_declined":  true,
-			"trip_update":          true,
-			"availability_updated": true,
-			"member_joined":        true,
-			"member_left":          true,
-		}
-		if !validTypes[typ] {
-			utils.WriteErrorResponse(w, http.StatusBadRequest, "Invalid type", "invalid notification type")
-			return
-		}
+	if typ != "" && !models.NotificationType(typ).Valid() {
+		utils.WriteErrorResponse(w, http.StatusBadRequest, "Invalid type", "invalid notification type")
+		return
 	}
 
 	// Count unread notifications
-	var unreadCount int
-	if err := h.db.QueryRow(ctx,
-		`SELECT COUNT(1) FROM notifications WHERE user_id=$1 AND read=false`, userID,
-	).Scan(&unreadCount); err != nil {
-		log.Printf("Error counting unread notifications: %v (user_id=%s)", err, userID.String())
+	unreadCount, err := h.repo.CountUnread(ctx, userID)
+	if err != nil {
+		slog.ErrorContext(ctx, "counting unread notifications failed", "error", err, "user_id", userID.String())
 		utils.WriteErrorResponse(w, http.StatusInternalServerError, "Database error", "Failed to count unread notifications")
 		return
 	}
 
-	// Build query with proper parameterization to prevent SQL injection
-	args := []any{userID}
-	where := `WHERE user_id=$1`
-	argNum := 2
-
-	if unreadOnly {
-		where += " AND read=false"
-	}
-	if typ != "" {
-		where += fmt.Sprintf(" AND type=$%d", argNum)
-		args = append(args, typ)
-		argNum++
-	}
-
-	// Count total matching notifications
-	var total int
-	if err := h.db.QueryRow(ctx,
-		fmt.Sprintf(`SELECT COUNT(1) FROM notifications %s`, where), args...,
-	).Scan(&total); err != nil {
-		log.Printf("Error counting notifications: %v (user_id=%s)", err, userID.String())
-		utils.WriteErrorResponse(w, http.StatusInternalServerError, "Database error", "Failed to count notifications")
-		return
-	}
-
 	// Fetch notifications with pagination
-	args = append(args, limit, offset)
-	query := fmt.Sprintf(`
-		SELECT id, type, title, message, data, action_url, read, created_at
-		FROM notifications %s
-		ORDER BY created_at DESC
-		LIMIT $%d OFFSET $%d
-	`, where, argNum, argNum+1)
-
-	rows, err := h.db.Query(ctx, query, args...)
+	list, total, err := h.repo.List(ctx, userID, repository.NotificationFilter{
+		UnreadOnly: unreadOnly,
+		Type:       typ,
+		Limit:      limit,
+		Offset:     offset,
+	})
 	if err != nil {
-		log.Printf("Error querying notifications: %v (user_id=%s)", err, userID.String())
+		slog.ErrorContext(ctx, "querying notifications failed", "error", err, "user_id", userID.String())
 		utils.WriteErrorResponse(w, http.StatusInternalServerError, "Database error", "Failed to fetch notifications")
 		return
 	}
-	defer rows.Close()
-
-	items := make([]dto.NotificationItem, 0, limit)
-	for rows.Next() {
-		var (
-			id        uuid.UUID
-			typStr    string
-			title     string
-			message   *string
-			dataRaw   []byte
-			actionURL *string
-			read      bool
-			createdAt time.Time
-		)
-		if err := rows.Scan(&id, &typStr, &title, &message, &dataRaw, &actionURL, &read, &createdAt); err != nil {
-			log.Printf("Error scanning notification row: %v (user_id=%s)", err, userID.String())
-			utils.WriteErrorResponse(w, http.StatusInternalServerError, "Database error", "Failed to process notification data")
-			return
-		}
-
-		// Parse JSON data safely
-		var data map[string]any
-		if len(dataRaw) > 0 && string(dataRaw) != "null" {
-			if err := json.Unmarshal(dataRaw, &data); err != nil {
-				log.Printf("Warning: Failed to unmarshal notification data: %v (notification_id=%s)", err, id.String())
-				// Continue with empty data instead of failing
-				data = nil
-			}
-		}
 
-		items = append(items, dto.NotificationItem{
-			ID:        id.String(),
-			Type:      typStr,
-			Title:     title,
-			Message:   message,
-			Data:      data,
-			ActionURL: actionURL,
-			Read:      read,
-			CreatedAt: createdAt.UTC().Format(time.RFC3339),
-		})
-	}
-
-	if err := rows.Err(); err != nil {
-		log.Printf("Error iterating notification rows: %v (user_id=%s)", err, userID.String())
-		utils.WriteErrorResponse(w, http.StatusInternalServerError, "Database error", "Failed to process notifications")
-		return
+	items := make([]dto.NotificationItem, 0, len(list))
+	for _, n := range list {
+		items = append(items, notificationItem(n))
 	}
 
 	utils.WriteJSONResponse(w, http.StatusOK, dto.NotificationListResponse{
@@ -376,32 +366,13 @@ func (h *NotificationsHandler) ListNotifications(w http.ResponseWriter, r *http.
 // @Failure 500 {object} dto.ErrorResponse
 // @Router /api/notifications/{id}/read [post]
 func (h *NotificationsHandler) MarkRead(w http.ResponseWriter, r *http.Request) {
-	if r.Method != http.MethodPost {
-		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
-		return
-	}
-
 	userID, ok := r.Context().Value("user_id").(uuid.UUID)
 	if !ok {
 		utils.WriteErrorResponse(w, http.StatusUnauthorized, "Unauthorized", "Invalid user context")
 		return
 	}
 
-	// Parse notification ID from URL path
-	path := r.URL.Path // /api/notifications/{id}/read
-	rest := strings.TrimPrefix(path, "/api/notifications/")
-	slash := strings.Index(rest, "/")
-	if slash <= 0 || !strings.HasSuffix(path, "/read") {
-		utils.WriteErrorResponse(w, http.StatusBadRequest, "Invalid path", "missing or invalid notification id")
-		return
-	}
-	idStr := strings.TrimSpace(rest[:slash])
-	if idStr == "" {
-		utils.WriteErrorResponse(w, http.StatusBadRequest, "Invalid path", "notification id is required")
-		return
-	}
-
-	nID, err := uuid.Parse(idStr)
+	nID, err := uuid.Parse(r.PathValue("id"))
 	if err != nil {
 		utils.WriteErrorResponse(w, http.StatusBadRequest, "Invalid id", "notification id must be a valid UUID")
 		return
@@ -412,23 +383,17 @@ func (h *NotificationsHandler) MarkRead(w http.ResponseWriter, r *http.Request)
 	defer cancel()
 
 	// Update notification - only allow users to mark their own notifications as read
-	cmd, err := h.db.Exec(ctx,
-		`UPDATE notifications SET read=true WHERE id=$1 AND user_id=$2 AND read=false`,
-		nID, userID,
-	)
+	updated, err := h.repo.MarkRead(ctx, nID, userID)
 	if err != nil {
-		log.Printf("Error marking notification as read: %v (notification_id=%s, user_id=%s)",
-			err, nID.String(), userID.String())
+		slog.ErrorContext(ctx, "marking notification as read failed",
+			"error", err, "notification_id", nID.String(), "user_id", userID.String())
 		utils.WriteErrorResponse(w, http.StatusInternalServerError, "Database error", "Failed to update notification")
 		return
 	}
 
-	if cmd.RowsAffected() == 0 {
+	if !updated {
 		// Check if notification exists but belongs to another user or already read
-		var exists bool
-		if err := h.db.QueryRow(ctx,
-			`SELECT EXISTS(SELECT 1 FROM notifications WHERE id=$1)`, nID,
-		).Scan(&exists); err == nil && exists {
+		if exists, err := h.repo.Exists(ctx, nID); err == nil && exists {
 			utils.WriteErrorResponse(w, http.StatusForbidden, "Forbidden",
 				"Notification not found or already marked as read")
 		} else {
@@ -453,11 +418,6 @@ func (h *NotificationsHandler) MarkRead(w http.ResponseWriter, r *http.Request)
 // @Failure 500 {object} dto.ErrorResponse
 // @Router /api/notifications/read-all [post]
 func (h *NotificationsHandler) MarkAllRead(w http.ResponseWriter, r *http.Request) {
-	if r.Method != http.MethodPost {
-		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
-		return
-	}
-
 	userID, ok := r.Context().Value("user_id").(uuid.UUID)
 	if !ok {
 		utils.WriteErrorResponse(w, http.StatusUnauthorized, "Unauthorized", "Invalid user context")
@@ -469,16 +429,13 @@ func (h *NotificationsHandler) MarkAllRead(w http.ResponseWriter, r *http.Reques
 	defer cancel()
 
 	// Update all unread notifications for the user
-	cmd, err := h.db.Exec(ctx,
-		`UPDATE notifications SET read=true WHERE user_id=$1 AND read=false`, userID,
-	)
+	updatedCount, err := h.repo.MarkAllRead(ctx, userID)
 	if err != nil {
-		log.Printf("Error marking all notifications as read: %v (user_id=%s)", err, userID.String())
+		slog.ErrorContext(ctx, "marking all notifications as read failed", "error", err, "user_id", userID.String())
 		utils.WriteErrorResponse(w, http.StatusInternalServerError, "Database error", "Failed to update notifications")
 		return
 	}
 
-	updatedCount := cmd.RowsAffected()
 	utils.WriteJSONResponse(w, http.StatusOK, map[string]interface{}{
 		"message":       "All notifications marked as read",
 		"updated_count": updatedCount,
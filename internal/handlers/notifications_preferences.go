@@ -0,0 +1,121 @@
+package handlers
+
+import (
+	"net/http"
+	"time"
+
+	"github.com/google/uuid"
+
+	"GO2GETHER_BACK-END/internal/dto"
+	"GO2GETHER_BACK-END/internal/models"
+	"GO2GETHER_BACK-END/internal/utils"
+)
+
+func quietHoursResponse(q *models.QuietHours) *dto.QuietHours {
+	if q == nil {
+		return nil
+	}
+	hhmm := func(m int) string {
+		return time.Date(0, 1, 1, m/60, m%60, 0, 0, time.UTC).Format(dto.QuietHoursLayout)
+	}
+	return &dto.QuietHours{Start: hhmm(q.Start), End: hhmm(q.End)}
+}
+
+func preferencesResponse(p models.NotificationPreferences, trips []models.TripNotificationPreferences) dto.NotificationPreferencesResponse {
+	inApp, email, push := p.InApp, p.Email, p.Push
+	resp := dto.NotificationPreferencesResponse{
+		Channels:        dto.NotificationChannels{InApp: &inApp, Email: &email, Push: &push},
+		MutedTypes:      make([]string, 0, len(p.MutedTypes)),
+		QuietHours:      quietHoursResponse(p.QuietHours),
+		Timezone:        p.Timezone,
+		DigestFrequency: p.DigestFrequency,
+		Trips:           make([]dto.TripNotificationPreferences, 0, len(trips)),
+	}
+	for _, t := range p.MutedTypes {
+		resp.MutedTypes = append(resp.MutedTypes, string(t))
+	}
+	for _, t := range trips {
+		resp.Trips = append(resp.Trips, dto.TripNotificationPreferences{
+			TripID:   t.TripID.String(),
+			Muted:    t.Muted,
+			Channels: dto.NotificationChannels{InApp: t.InApp, Email: t.Email, Push: t.Push},
+		})
+	}
+	if p.Locale != "" {
+		locale := p.Locale
+		resp.Locale = &locale
+	}
+	if p.LastDigestAt != nil {
+		last := p.LastDigestAt.UTC().Format(time.RFC3339)
+		resp.LastDigestAt = &last
+	}
+	if !p.UpdatedAt.IsZero() {
+		updated := p.UpdatedAt.UTC().Format(time.RFC3339)
+		resp.UpdatedAt = &updated
+	}
+	return resp
+}
+
+// -----------------------------------------------------------------------------
+// 5.6 GET /api/notifications/preferences
+// @Summary Get notification preferences
+// @Description The channels (in_app, email, push) the user receives notifications on, muted types, quiet hours in their timezone, digest frequency and per-trip settings.
+// @Description Users who never saved preferences get the defaults: every channel on, no quiet hours, UTC, no digest.
+// @Tags notifications
+// @Produce json
+// @Security BearerAuth
+// @Success 200 {object} dto.NotificationPreferencesResponse
+// @Failure 401 {object} dto.ErrorResponse
+// @Failure 500 {object} dto.ErrorResponse
+// @Router /api/notifications/preferences [get]
+func (h *NotificationsHandler) GetPreferences(w http.ResponseWriter, r *http.Request) {
+	userID, ok := r.Context().Value("user_id").(uuid.UUID)
+	if !ok {
+		utils.WriteErrorResponse(w, http.StatusUnauthorized, "Unauthorized", "Invalid user context")
+		return
+	}
+
+	p, trips, err := h.prefs.Get(r.Context(), userID)
+	if err != nil {
+		utils.WriteError(w, r, err)
+		return
+	}
+	utils.WriteJSONResponse(w, http.StatusOK, preferencesResponse(p, trips))
+}
+
+// -----------------------------------------------------------------------------
+// 5.7 PUT /api/notifications/preferences
+// @Summary Replace notification preferences
+// @Description Replaces the user's notification preferences. Omitted channels are on; `quiet_hours` null turns quiet hours off.
+// @Description A muted trip sends nothing; a trip channel set to null follows the user's setting. Trips not listed go back to the user's settings.
+// @Description During quiet hours notifications are stored but not streamed, and emails are sent when they end.
+// @Description `digest_frequency` (off, daily, weekly) emails a summary of unread notifications at the configured hour of the user's timezone.
+// @Tags notifications
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Param payload body dto.NotificationPreferencesRequest true "Preferences"
+// @Success 200 {object} dto.NotificationPreferencesResponse
+// @Failure 400 {object} dto.ErrorResponse
+// @Failure 401 {object} dto.ErrorResponse
+// @Failure 500 {object} dto.ErrorResponse
+// @Router /api/notifications/preferences [put]
+func (h *NotificationsHandler) UpdatePreferences(w http.ResponseWriter, r *http.Request) {
+	userID, ok := r.Context().Value("user_id").(uuid.UUID)
+	if !ok {
+		utils.WriteErrorResponse(w, http.StatusUnauthorized, "Unauthorized", "Invalid user context")
+		return
+	}
+
+	var req dto.NotificationPreferencesRequest
+	if !utils.DecodeJSON(w, r, &req) {
+		return
+	}
+
+	p, trips, err := h.prefs.Update(r.Context(), userID, req)
+	if err != nil {
+		utils.WriteError(w, r, err)
+		return
+	}
+	utils.WriteJSONResponse(w, http.StatusOK, preferencesResponse(p, trips))
+}
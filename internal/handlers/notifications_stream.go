@@ -0,0 +1,278 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/coder/websocket"
+	"github.com/coder/websocket/wsjson"
+	"github.com/google/uuid"
+
+	"GO2GETHER_BACK-END/internal/dto"
+	"GO2GETHER_BACK-END/internal/metrics"
+	"GO2GETHER_BACK-END/internal/models"
+	"GO2GETHER_BACK-END/internal/repository"
+	"GO2GETHER_BACK-END/internal/utils"
+)
+
+// sseRetry is the reconnect delay browsers are told to use
+const sseRetry = 3 * time.Second
+
+func notificationItem(n models.Notification) dto.NotificationItem {
+	var tripID *string
+	if n.TripID != nil {
+		id := n.TripID.String()
+		tripID = &id
+	}
+	return dto.NotificationItem{
+		ID:        n.ID.String(),
+		TripID:    tripID,
+		Type:      n.Type,
+		Title:     n.Title,
+		Message:   n.Message,
+		Data:      n.Data,
+		ActionURL: n.ActionURL,
+		Read:      n.Read,
+		CreatedAt: n.CreatedAt.UTC().Format(time.RFC3339),
+	}
+}
+
+// originHosts turns CORS origins (https://app.example.com) into the host
+// patterns the WebSocket origin check matches
+func originHosts(origins []string) []string {
+	hosts := make([]string, 0, len(origins))
+	for _, o := range origins {
+		if o == "*" {
+			hosts = append(hosts, "*")
+			continue
+		}
+		if u, err := url.Parse(o); err == nil && u.Host != "" {
+			hosts = append(hosts, u.Host)
+		}
+	}
+	return hosts
+}
+
+// lastEventID is where a reconnecting client resumes: the Last-Event-ID
+// header browsers resend, or last_event_id for clients that cannot set it
+func lastEventID(r *http.Request) string {
+	if id := strings.TrimSpace(r.Header.Get("Last-Event-ID")); id != "" {
+		return id
+	}
+	return strings.TrimSpace(r.URL.Query().Get("last_event_id"))
+}
+
+// replay sends the notifications created after lastID, or resync when they
+// cannot all be replayed. Call it after subscribing, so nothing created in
+// between is lost; the returned IDs were sent and the live stream skips them.
+func (h *NotificationsHandler) replay(ctx context.Context, userID uuid.UUID, lastID string,
+	send func(models.Notification) error, resync func() error,
+) (map[uuid.UUID]bool, error) {
+	if lastID == "" {
+		return nil, nil
+	}
+	after, err := uuid.Parse(lastID)
+	if err != nil {
+		return nil, resync()
+	}
+	list, err := h.repo.ListAfter(ctx, userID, after, h.cfg.ReplayLimit+1)
+	if err != nil {
+		if !errors.Is(err, repository.ErrNotFound) {
+			slog.ErrorContext(ctx, "replaying notifications failed", "error", err, "user_id", userID.String())
+		}
+		return nil, resync()
+	}
+	if len(list) > h.cfg.ReplayLimit {
+		return nil, resync()
+	}
+	sent := make(map[uuid.UUID]bool, len(list))
+	for _, n := range list {
+		if err := send(n); err != nil {
+			return nil, err
+		}
+		sent[n.ID] = true
+	}
+	return sent, nil
+}
+
+// sseWriter writes Server-Sent Events, flushing each one
+type sseWriter struct {
+	w  http.ResponseWriter
+	rc *http.ResponseController
+}
+
+func (s sseWriter) write(format string, args ...any) error {
+	if _, err := fmt.Fprintf(s.w, format, args...); err != nil {
+		return err
+	}
+	return s.rc.Flush()
+}
+
+func (s sseWriter) notification(n models.Notification) error {
+	data, err := json.Marshal(notificationItem(n))
+	if err != nil {
+		return err
+	}
+	return s.write("id: %s\nevent: notification\ndata: %s\n\n", n.ID, data)
+}
+
+// -----------------------------------------------------------------------------
+// 5.4 GET /api/notifications/stream
+// @Summary Stream notifications (SSE)
+// @Description Server-Sent Events stream of the user's new notifications: `event: notification` with the notification ID as `id` and the notification as JSON `data`.
+// @Description On reconnect, notifications after `Last-Event-ID` are replayed first; `event: resync` means they could not be and the client should reload the list.
+// @Description Comment lines are sent as heartbeats. Clients that cannot set headers (EventSource) may pass the token as `access_token`.
+// @Tags notifications
+// @Produce text/event-stream
+// @Security BearerAuth
+// @Param Last-Event-ID header string false "ID of the last notification received"
+// @Param last_event_id query string false "same as Last-Event-ID"
+// @Param access_token query string false "access token, when the Authorization header cannot be set"
+// @Success 200 {string} string "event stream"
+// @Failure 401 {object} dto.ErrorResponse
+// @Router /api/notifications/stream [get]
+func (h *NotificationsHandler) Stream(w http.ResponseWriter, r *http.Request) {
+	userID, ok := r.Context().Value("user_id").(uuid.UUID)
+	if !ok {
+		utils.WriteErrorResponse(w, http.StatusUnauthorized, "Unauthorized", "Invalid user context")
+		return
+	}
+	rc := http.NewResponseController(w)
+	// WriteTimeout ของ server มีไว้สำหรับ request ปกติ stream ต้องเปิดได้นาน
+	if err := rc.SetWriteDeadline(time.Time{}); err != nil && !errors.Is(err, http.ErrNotSupported) {
+		slog.WarnContext(r.Context(), "clearing stream write deadline failed", "error", err)
+	}
+
+	sub := h.hub.Subscribe(userID)
+	defer sub.Close()
+	defer metrics.StreamOpened("sse")()
+
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.Header().Set("X-Accel-Buffering", "no") // proxies such as nginx must not buffer
+	w.WriteHeader(http.StatusOK)
+	sse := sseWriter{w: w, rc: rc}
+	if err := sse.write("retry: %d\n\n", sseRetry.Milliseconds()); err != nil {
+		return
+	}
+
+	sent, err := h.replay(r.Context(), userID, lastEventID(r), sse.notification, func() error {
+		return sse.write("event: resync\ndata: {}\n\n")
+	})
+	if err != nil {
+		return
+	}
+
+	heartbeat := time.NewTicker(h.cfg.StreamHeartbeat)
+	defer heartbeat.Stop()
+	for {
+		select {
+		case <-r.Context().Done():
+			return
+		case n, ok := <-sub.C:
+			if !ok {
+				// ตามไม่ทันหรือ server กำลังปิด: client reconnect แล้ว resume เอง
+				return
+			}
+			if sent[n.ID] {
+				continue
+			}
+			if err := sse.notification(n); err != nil {
+				return
+			}
+		case <-heartbeat.C:
+			if err := sse.write(": ping\n\n"); err != nil {
+				return
+			}
+		}
+	}
+}
+
+// -----------------------------------------------------------------------------
+// 5.5 GET /api/notifications/ws
+// @Summary Stream notifications (WebSocket)
+// @Description The notification stream over WebSocket. The server sends dto.NotificationStreamMessage JSON messages (`notification` or `resync`) and pings every heartbeat; client messages are ignored.
+// @Description Resume with `last_event_id`. Browsers pass the token as `access_token`.
+// @Tags notifications
+// @Security BearerAuth
+// @Param last_event_id query string false "ID of the last notification received"
+// @Param access_token query string false "access token, when the Authorization header cannot be set"
+// @Success 101 {object} dto.NotificationStreamMessage
+// @Failure 401 {object} dto.ErrorResponse
+// @Router /api/notifications/ws [get]
+func (h *NotificationsHandler) WebSocket(w http.ResponseWriter, r *http.Request) {
+	userID, ok := r.Context().Value("user_id").(uuid.UUID)
+	if !ok {
+		utils.WriteErrorResponse(w, http.StatusUnauthorized, "Unauthorized", "Invalid user context")
+		return
+	}
+	// deadline ของ server ยังติดอยู่กับ connection หลัง hijack
+	rc := http.NewResponseController(w)
+	_ = rc.SetReadDeadline(time.Time{})
+	_ = rc.SetWriteDeadline(time.Time{})
+
+	c, err := websocket.Accept(w, r, &websocket.AcceptOptions{OriginPatterns: h.wsOrigins})
+	if err != nil {
+		// Accept ตอบ error ให้ client แล้ว
+		slog.DebugContext(r.Context(), "websocket upgrade failed", "error", err)
+		return
+	}
+	defer c.CloseNow()
+	// อ่าน control frame (close, pong) ให้ ข้อความจาก client ไม่ได้ใช้
+	ctx := c.CloseRead(r.Context())
+
+	sub := h.hub.Subscribe(userID)
+	defer sub.Close()
+	defer metrics.StreamOpened("websocket")()
+
+	write := func(msg dto.NotificationStreamMessage) error {
+		wctx, cancel := context.WithTimeout(ctx, 10*time.Second)
+		defer cancel()
+		return wsjson.Write(wctx, c, msg)
+	}
+	send := func(n models.Notification) error {
+		item := notificationItem(n)
+		return write(dto.NotificationStreamMessage{Type: "notification", Notification: &item})
+	}
+
+	sent, err := h.replay(ctx, userID, lastEventID(r), send, func() error {
+		return write(dto.NotificationStreamMessage{Type: "resync"})
+	})
+	if err != nil {
+		return
+	}
+
+	heartbeat := time.NewTicker(h.cfg.StreamHeartbeat)
+	defer heartbeat.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case n, ok := <-sub.C:
+			if !ok {
+				c.Close(websocket.StatusTryAgainLater, "reconnect and resume")
+				return
+			}
+			if sent[n.ID] {
+				continue
+			}
+			if err := send(n); err != nil {
+				return
+			}
+		case <-heartbeat.C:
+			pctx, cancel := context.WithTimeout(ctx, h.cfg.StreamHeartbeat)
+			err := c.Ping(pctx)
+			cancel()
+			if err != nil {
+				return
+			}
+		}
+	}
+}
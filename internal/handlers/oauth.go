@@ -0,0 +1,340 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+	"golang.org/x/oauth2"
+
+	"GO2GETHER_BACK-END/internal/apperr"
+	"GO2GETHER_BACK-END/internal/config"
+	"GO2GETHER_BACK-END/internal/dto"
+	"GO2GETHER_BACK-END/internal/middleware"
+	"GO2GETHER_BACK-END/internal/models"
+	"GO2GETHER_BACK-END/internal/oauth"
+	"GO2GETHER_BACK-END/internal/utils"
+)
+
+// OAuthHandler handles sign-in and account linking with external providers
+// (Google, LINE, Facebook, Apple, ...). Every provider shares the same flow:
+// signed state + PKCE, callback, one-time code exchange.
+type OAuthHandler struct {
+	db        *pgxpool.Pool
+	config    *config.Config
+	sessions  SessionsService
+	providers *oauth.Registry
+}
+
+// NewOAuthHandler creates a new OAuthHandler instance
+func NewOAuthHandler(db *pgxpool.Pool, cfg *config.Config, providers *oauth.Registry) *OAuthHandler {
+	return &OAuthHandler{
+		db:        db,
+		config:    cfg,
+		sessions:  NewSessionsService(db, &cfg.JWT),
+		providers: providers,
+	}
+}
+
+// oauthStateTTL bounds how long a user may take on the provider's consent screen
+const oauthStateTTL = 10 * time.Minute
+
+// oauthVerifierCookie holds the PKCE verifier; it never leaves the browser
+// except on the callback request, so a leaked callback URL is useless elsewhere
+const oauthVerifierCookie = "g2g_oauth_verifier"
+
+// WithProvider adapts an endpoint of /api/auth/{provider}/... to a plain
+// handler, resolving {provider} against the enabled providers
+func (h *OAuthHandler) WithProvider(next func(http.ResponseWriter, *http.Request, oauth.Provider)) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		name := r.PathValue("provider")
+		provider, err := h.providers.Get(name)
+		if err != nil {
+			utils.WriteError(w, r, errUnknownProvider(name))
+			return
+		}
+		next(w, r, provider)
+	}
+}
+
+// Providers lists the enabled sign-in providers
+// @Summary List sign-in providers
+// @Description List the external sign-in providers enabled on this server
+// @Tags authentication
+// @Produce json
+// @Success 200 {object} dto.OAuthProvidersResponse "Enabled providers"
+// @Router /api/auth/providers [get]
+func (h *OAuthHandler) Providers(w http.ResponseWriter, r *http.Request) {
+	utils.WriteJSONResponse(w, http.StatusOK, dto.OAuthProvidersResponse{Providers: h.providers.Names()})
+}
+
+// Login initiates OAuth login with a provider
+// @Summary OAuth login
+// @Description Initiate the OAuth login flow of a provider. Sets an HttpOnly cookie holding the PKCE verifier; the browser must send it back on the callback.
+// @Tags authentication
+// @Produce json
+// @Param provider path string true "Provider name (see /api/auth/providers)" example(google)
+// @Param redirect_uri query string false "Frontend page to return to; must match FRONTEND_ALLOWED_REDIRECTS (default FRONTEND_URL/callback)"
+// @Success 200 {object} dto.OAuthLoginResponse "Provider authorization URL"
+// @Failure 400 {object} dto.ErrorResponse "Redirect URI not allowed"
+// @Failure 404 {object} dto.ErrorResponse "Unknown provider"
+// @Router /api/auth/{provider}/login [get]
+func (h *OAuthHandler) Login(w http.ResponseWriter, r *http.Request, provider oauth.Provider) {
+	h.startOAuth(w, r, provider, middleware.OAuthIntentLogin, nil)
+}
+
+// Link starts linking a provider account to the current user
+// @Summary Link sign-in provider
+// @Description Start the OAuth flow of a provider to link it to the authenticated user
+// @Tags profile
+// @Produce json
+// @Security BearerAuth
+// @Param provider path string true "Provider name" example(line)
+// @Param redirect_uri query string false "Frontend page to return to; must match FRONTEND_ALLOWED_REDIRECTS"
+// @Success 200 {object} dto.OAuthLoginResponse "Provider authorization URL"
+// @Failure 401 {object} dto.ErrorResponse "Unauthorized"
+// @Failure 404 {object} dto.ErrorResponse "Unknown provider"
+// @Router /api/profile/identities/{provider} [post]
+func (h *OAuthHandler) Link(w http.ResponseWriter, r *http.Request) {
+	userID, ok := r.Context().Value("user_id").(uuid.UUID)
+	if !ok {
+		utils.WriteError(w, r, errNotAuthenticated)
+		return
+	}
+
+	name := r.PathValue("provider")
+	provider, err := h.providers.Get(name)
+	if err != nil {
+		utils.WriteError(w, r, errUnknownProvider(name))
+		return
+	}
+
+	h.startOAuth(w, r, provider, middleware.OAuthIntentLink, &userID)
+}
+
+// startOAuth issues a signed state bound to a fresh PKCE verifier and returns the authorization URL
+func (h *OAuthHandler) startOAuth(w http.ResponseWriter, r *http.Request, provider oauth.Provider, intent string, linkUserID *uuid.UUID) {
+	redirectURI := r.URL.Query().Get("redirect_uri")
+	if redirectURI == "" {
+		redirectURI = h.config.Frontend.URL + "/callback"
+	}
+	if !isAllowedRedirect(redirectURI, h.config.Frontend.AllowedRedirects) {
+		utils.WriteError(w, r, apperr.Validation(apperr.Field("redirect_uri", "not_allowed", "redirect_uri is not in the allowed redirect list")))
+		return
+	}
+
+	verifier := oauth2.GenerateVerifier()
+	state, err := middleware.GenerateOAuthState(provider.Name(), intent, linkUserID, redirectURI, verifier, oauthStateTTL, &h.config.JWT)
+	if err != nil {
+		utils.WriteError(w, r, err)
+		return
+	}
+
+	// Apple posts the callback cross-site (response_mode=form_post), which
+	// only carries SameSite=None cookies; those in turn require Secure
+	secure := r.TLS != nil || h.config.IsProduction()
+	sameSite := http.SameSiteLaxMode
+	if secure {
+		sameSite = http.SameSiteNoneMode
+	}
+	http.SetCookie(w, &http.Cookie{
+		Name:     oauthVerifierCookie,
+		Value:    verifier,
+		Path:     "/api/auth",
+		MaxAge:   int(oauthStateTTL.Seconds()),
+		HttpOnly: true,
+		Secure:   secure,
+		SameSite: sameSite,
+	})
+
+	utils.WriteJSONResponse(w, http.StatusOK, dto.OAuthLoginResponse{
+		AuthURL: provider.AuthCodeURL(state, verifier),
+		State:   state,
+	})
+}
+
+// Callback handles the provider's OAuth callback
+// @Summary OAuth callback
+// @Description Handle the provider callback with the authorization code (query string, or form body for Apple). Validates the signed state against the PKCE cookie, then signs in or links the account and redirects to the frontend with a one-time `code` (exchange it via /api/auth/{provider}/exchange) or `linked={provider}`.
+// @Tags authentication
+// @Accept x-www-form-urlencoded
+// @Produce json
+// @Param provider path string true "Provider name" example(google)
+// @Param code query string true "Authorization code from the provider"
+// @Param state query string true "Signed state returned by /api/auth/{provider}/login"
+// @Success 302 "Redirect to the frontend"
+// @Failure 400 {object} dto.ErrorResponse "Invalid request data"
+// @Failure 401 {object} dto.ErrorResponse "Invalid authorization code or state"
+// @Failure 403 {object} dto.ErrorResponse "Provider email not verified"
+// @Failure 409 {object} dto.ErrorResponse "Account must be linked from the profile"
+// @Failure 500 {object} dto.ErrorResponse "Internal server error"
+// @Router /api/auth/{provider}/callback [get]
+func (h *OAuthHandler) Callback(w http.ResponseWriter, r *http.Request, provider oauth.Provider) {
+	if oauthErr := r.FormValue("error"); oauthErr != "" {
+		utils.WriteError(w, r, apperr.New(http.StatusBadRequest, apperr.CodeBadRequest, "Sign-in cancelled",
+			"Sign-in was cancelled or refused by the provider"))
+		return
+	}
+
+	// Get authorization code from query parameters (or the form_post body)
+	code := r.FormValue("code")
+	if code == "" {
+		utils.WriteError(w, r, apperr.Validation(apperr.Field("code", "required", "Authorization code is required")))
+		return
+	}
+
+	// The verifier cookie is single use
+	var verifier string
+	if c, err := r.Cookie(oauthVerifierCookie); err == nil {
+		verifier = c.Value
+	}
+	http.SetCookie(w, &http.Cookie{Name: oauthVerifierCookie, Value: "", Path: "/api/auth", MaxAge: -1, HttpOnly: true})
+
+	state, err := middleware.ValidateOAuthState(r.FormValue("state"), provider.Name(), verifier, &h.config.JWT)
+	if err != nil {
+		utils.WriteError(w, r, apperr.New(http.StatusUnauthorized, apperr.CodeInvalidToken, "Invalid state",
+			"Sign-in state is invalid or has expired. Start again").WithCause(err))
+		return
+	}
+
+	// Exchange authorization code for the provider's user
+	userInfo, err := provider.Exchange(r.Context(), code, verifier)
+	if err != nil {
+		utils.WriteError(w, r, apperr.New(http.StatusUnauthorized, apperr.CodeInvalidCode, "Invalid authorization code",
+			"The provider did not accept the authorization code. Start again").WithCause(err))
+		return
+	}
+	identity := ProviderIdentity{
+		Provider:      provider.Name(),
+		Subject:       userInfo.Subject,
+		Email:         strings.ToLower(strings.TrimSpace(userInfo.Email)),
+		EmailVerified: userInfo.EmailVerified,
+	}
+
+	if state.Intent == middleware.OAuthIntentLink {
+		if err := linkProviderIdentity(r.Context(), h.db, *state.LinkUserID, identity); err != nil {
+			writeIdentityError(w, r, err)
+			return
+		}
+		http.Redirect(w, r, withQuery(state.RedirectURI, url.Values{"linked": {identity.Provider}}), http.StatusFound)
+		return
+	}
+
+	user, err := resolveProviderUser(r.Context(), h.db, identity)
+	if err != nil {
+		writeIdentityError(w, r, err)
+		return
+	}
+
+	// Tokens are never put in the URL; the frontend exchanges this code for them
+	authCode, err := issueAuthCode(r.Context(), h.db, user.ID, identity.Provider)
+	if err != nil {
+		utils.WriteError(w, r, err)
+		return
+	}
+
+	http.Redirect(w, r, withQuery(state.RedirectURI, url.Values{
+		"code":     {authCode},
+		"provider": {identity.Provider},
+	}), http.StatusFound)
+}
+
+// Exchange exchanges the one-time code from the callback redirect for tokens
+// @Summary Exchange sign-in code
+// @Description Exchange the one-time code the callback redirected with for an access/refresh token pair. Codes expire after one minute and work once.
+// @Tags authentication
+// @Accept json
+// @Produce json
+// @Param provider path string true "Provider name" example(google)
+// @Param request body dto.AuthCodeExchangeRequest true "One-time code"
+// @Success 200 {object} dto.AuthResponse "Login successful (or dto.MFAChallengeResponse when two-factor authentication is enabled)"
+// @Failure 400 {object} dto.ErrorResponse "Invalid request data"
+// @Failure 401 {object} dto.ErrorResponse "Invalid or expired code"
+// @Failure 500 {object} dto.ErrorResponse "Internal server error"
+// @Router /api/auth/{provider}/exchange [post]
+func (h *OAuthHandler) Exchange(w http.ResponseWriter, r *http.Request, provider oauth.Provider) {
+	var req dto.AuthCodeExchangeRequest
+	if !utils.DecodeJSON(w, r, &req) {
+		return
+	}
+	userID, err := redeemAuthCode(r.Context(), h.db, req.Code, provider.Name())
+	if err != nil {
+		if errors.Is(err, ErrAuthCodeInvalid) {
+			utils.WriteError(w, r, apperr.New(http.StatusUnauthorized, apperr.CodeInvalidCode, "Invalid code",
+				"Sign-in code is invalid, expired or already used"))
+		} else {
+			utils.WriteError(w, r, err)
+		}
+		return
+	}
+
+	var user models.User
+	if err := h.db.QueryRow(r.Context(),
+		`SELECT id, email, email_verified_at, totp_enabled_at, created_at, updated_at FROM users WHERE id = $1`, userID,
+	).Scan(&user.ID, &user.Email, &user.EmailVerifiedAt, &user.TOTPEnabledAt, &user.CreatedAt, &user.UpdatedAt); err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			err = apperr.New(http.StatusUnauthorized, apperr.CodeInvalidCode, "Invalid code", "User no longer exists")
+		}
+		utils.WriteError(w, r, err)
+		return
+	}
+
+	// The provider sign-in replaces the password, not the second factor
+	if user.TOTPEnabledAt != nil {
+		writeMFAChallenge(w, r, h.config, user.ID, provider.Name())
+		return
+	}
+
+	// Issue access + refresh token
+	tokens, err := h.sessions.Issue(r.Context(), user.ID, user.Email, sessionClient(r))
+	if err != nil {
+		utils.WriteError(w, r, err)
+		return
+	}
+
+	utils.WriteJSONResponse(w, http.StatusOK, dto.AuthResponse{
+		User:         toUserResponse(user),
+		Token:        tokens.AccessToken,
+		RefreshToken: tokens.RefreshToken,
+		ExpiresIn:    tokens.ExpiresIn,
+	})
+}
+
+// withQuery appends params to a URL that may already have a query string
+func withQuery(rawURL string, params url.Values) string {
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return rawURL
+	}
+	q := u.Query()
+	for k, v := range params {
+		q[k] = v
+	}
+	u.RawQuery = q.Encode()
+	return u.String()
+}
+
+// writeIdentityError maps account linking errors to responses
+func writeIdentityError(w http.ResponseWriter, r *http.Request, err error) {
+	switch {
+	case errors.Is(err, ErrUnverifiedProviderEmail):
+		err = apperr.New(http.StatusForbidden, apperr.CodeEmailNotVerified, "Email not verified",
+			"An account with this email already exists, but the provider has not verified the address. Sign in with your password and link the provider from your profile")
+	case errors.Is(err, ErrProviderEmailRequired):
+		err = apperr.New(http.StatusBadRequest, apperr.CodeBadRequest, "Email required",
+			"The provider did not share an email address. Allow access to your email, or register and link the provider from your profile")
+	case errors.Is(err, ErrAccountLinkRequired):
+		err = apperr.New(http.StatusConflict, apperr.CodeConflict, "Account already exists",
+			"An account with this email already exists. Sign in with your password and link the provider from your profile")
+	case errors.Is(err, ErrIdentityLinkedElsewhere):
+		err = apperr.New(http.StatusConflict, apperr.CodeConflict, "Already linked", "This provider account is linked to another user")
+	case errors.Is(err, ErrProviderAlreadyLinked):
+		err = apperr.New(http.StatusConflict, apperr.CodeConflict, "Already linked", "Another account of this provider is already linked. Unlink it first")
+	}
+	utils.WriteError(w, r, err)
+}
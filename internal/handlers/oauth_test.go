@@ -0,0 +1,309 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5/pgxpool"
+	"golang.org/x/oauth2"
+
+	"GO2GETHER_BACK-END/internal/apperr"
+	"GO2GETHER_BACK-END/internal/config"
+	"GO2GETHER_BACK-END/internal/dto"
+	"GO2GETHER_BACK-END/internal/middleware"
+	"GO2GETHER_BACK-END/internal/migrations"
+	"GO2GETHER_BACK-END/internal/oauth"
+)
+
+const (
+	testSecret   = "test-secret-at-least-32-bytes-long!!"
+	testFrontend = "http://localhost:3000"
+)
+
+// oauthFlow drives /api/auth/fake/... the way a browser does, against the
+// in-process fake OIDC provider
+type oauthFlow struct {
+	t   *testing.T
+	mux *http.ServeMux
+	cfg *config.Config
+}
+
+// newOAuthFlow mounts the OAuth endpoints; db may be nil for the paths that
+// fail before the database is reached
+func newOAuthFlow(t *testing.T, db *pgxpool.Pool) *oauthFlow {
+	t.Helper()
+	cfg := &config.Config{Env: config.EnvTest}
+	cfg.JWT = config.JWTConfig{Secret: testSecret, AccessTokenTTL: 15 * time.Minute, RefreshTokenTTL: 24 * time.Hour}
+	cfg.Frontend = config.FrontendConfig{URL: testFrontend, AllowedRedirects: []string{testFrontend}}
+	cfg.OAuth.FakeProvider = true
+	cfg.OAuth.FakeRedirectURL = "http://localhost:8080/api/auth/fake/callback"
+
+	providers, err := oauth.NewRegistry(cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	p, err := providers.Get("fake")
+	if err != nil {
+		t.Fatal(err)
+	}
+	h := NewOAuthHandler(db, cfg, providers)
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /api/auth/{provider}/login", h.WithProvider(h.Login))
+	mux.HandleFunc("GET /api/auth/{provider}/callback", h.WithProvider(h.Callback))
+	mux.HandleFunc("POST /api/auth/{provider}/exchange", h.WithProvider(h.Exchange))
+	mux.Handle("GET "+oauth.FakeAuthorizePath, p.(http.Handler))
+	return &oauthFlow{t: t, mux: mux, cfg: cfg}
+}
+
+func (f *oauthFlow) serve(req *http.Request) *httptest.ResponseRecorder {
+	rec := httptest.NewRecorder()
+	f.mux.ServeHTTP(rec, req)
+	return rec
+}
+
+// login starts the flow and returns the authorization URL and verifier cookie
+func (f *oauthFlow) login() (string, *http.Cookie) {
+	f.t.Helper()
+	rec := f.serve(httptest.NewRequest(http.MethodGet, "/api/auth/fake/login", nil))
+	if rec.Code != http.StatusOK {
+		f.t.Fatalf("login status = %d: %s", rec.Code, rec.Body)
+	}
+	var resp dto.OAuthLoginResponse
+	if err := json.Unmarshal(rec.Body.Bytes(), &resp); err != nil {
+		f.t.Fatal(err)
+	}
+	for _, c := range rec.Result().Cookies() {
+		if c.Name == oauthVerifierCookie {
+			return resp.AuthURL, c
+		}
+	}
+	f.t.Fatal("login did not set the verifier cookie")
+	return "", nil
+}
+
+// consent signs email in at the fake provider and returns the callback URL it
+// redirects to
+func (f *oauthFlow) consent(authURL, email string) *url.URL {
+	f.t.Helper()
+	u, _ := url.Parse(authURL)
+	q := u.Query()
+	q.Set("login_hint", email)
+	u.RawQuery = q.Encode()
+	rec := f.serve(httptest.NewRequest(http.MethodGet, u.String(), nil))
+	if rec.Code != http.StatusFound {
+		f.t.Fatalf("consent status = %d: %s", rec.Code, rec.Body)
+	}
+	callback, err := url.Parse(rec.Header().Get("Location"))
+	if err != nil {
+		f.t.Fatal(err)
+	}
+	return callback
+}
+
+func (f *oauthFlow) callback(callback *url.URL, cookie *http.Cookie) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(http.MethodGet, callback.RequestURI(), nil)
+	if cookie != nil {
+		req.AddCookie(cookie)
+	}
+	return f.serve(req)
+}
+
+func (f *oauthFlow) exchange(code string) *httptest.ResponseRecorder {
+	body, _ := json.Marshal(dto.AuthCodeExchangeRequest{Code: code})
+	req := httptest.NewRequest(http.MethodPost, "/api/auth/fake/exchange", strings.NewReader(string(body)))
+	req.Header.Set("Content-Type", "application/json")
+	return f.serve(req)
+}
+
+func TestOAuthLoginStartsPKCEFlow(t *testing.T) {
+	f := newOAuthFlow(t, nil)
+	authURL, cookie := f.login()
+
+	if !cookie.HttpOnly || cookie.Path != "/api/auth" || cookie.MaxAge <= 0 {
+		t.Errorf("verifier cookie = %+v", cookie)
+	}
+	u, err := url.Parse(authURL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	q := u.Query()
+	if u.Path != oauth.FakeAuthorizePath || q.Get("code_challenge_method") != "S256" {
+		t.Errorf("authorization URL = %s", authURL)
+	}
+	// the challenge is derived from the verifier kept in the cookie
+	if q.Get("code_challenge") != oauth2.S256ChallengeFromVerifier(cookie.Value) {
+		t.Error("code_challenge does not match the verifier cookie")
+	}
+	if q.Get("state") == "" {
+		t.Error("authorization URL has no state")
+	}
+}
+
+func TestOAuthLoginRejects(t *testing.T) {
+	f := newOAuthFlow(t, nil)
+	tests := []struct {
+		name   string
+		target string
+		status int
+	}{
+		{"unknown provider", "/api/auth/nope/login", http.StatusNotFound},
+		{"foreign redirect", "/api/auth/fake/login?redirect_uri=" + url.QueryEscape("https://evil.example/callback"), http.StatusBadRequest},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if rec := f.serve(httptest.NewRequest(http.MethodGet, tt.target, nil)); rec.Code != tt.status {
+				t.Errorf("status = %d, want %d", rec.Code, tt.status)
+			}
+		})
+	}
+}
+
+// These callbacks are all rejected before the database is reached
+func TestOAuthCallbackRejects(t *testing.T) {
+	f := newOAuthFlow(t, nil)
+	authURL, cookie := f.login()
+	callback := f.consent(authURL, "alice@example.com")
+
+	otherURL, otherCookie := f.login()
+	otherCallback := f.consent(otherURL, "alice@example.com")
+
+	with := func(u *url.URL, key, value string) *url.URL {
+		c := *u
+		q := c.Query()
+		q.Set(key, value)
+		c.RawQuery = q.Encode()
+		return &c
+	}
+
+	tests := []struct {
+		name     string
+		callback *url.URL
+		cookie   *http.Cookie
+		status   int
+		code     apperr.Code
+	}{
+		{"provider error", with(callback, "error", "access_denied"), cookie, http.StatusBadRequest, apperr.CodeBadRequest},
+		{"no code", with(callback, "code", ""), cookie, http.StatusBadRequest, apperr.CodeValidation},
+		{"no verifier cookie", callback, nil, http.StatusUnauthorized, apperr.CodeInvalidToken},
+		{"verifier of another flow", callback, otherCookie, http.StatusUnauthorized, apperr.CodeInvalidToken},
+		{"tampered state", with(callback, "state", callback.Query().Get("state")+"x"), cookie, http.StatusUnauthorized, apperr.CodeInvalidToken},
+		// state and cookie match, but the code was issued to the other flow's PKCE challenge
+		{"code of another flow", with(callback, "code", otherCallback.Query().Get("code")), cookie, http.StatusUnauthorized, apperr.CodeInvalidCode},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rec := f.callback(tt.callback, tt.cookie)
+			if rec.Code != tt.status {
+				t.Errorf("status = %d, want %d: %s", rec.Code, tt.status, rec.Body)
+			}
+			var body dto.ErrorResponse
+			if err := json.Unmarshal(rec.Body.Bytes(), &body); err != nil {
+				t.Fatal(err)
+			}
+			if body.Code != string(tt.code) {
+				t.Errorf("code = %q, want %q", body.Code, tt.code)
+			}
+			// the state and provider errors themselves are never sent
+			if strings.Contains(body.Detail, "invalid_grant") || strings.Contains(body.Detail, "token") {
+				t.Errorf("detail leaks the cause: %q", body.Detail)
+			}
+		})
+	}
+}
+
+// testDB connects to TEST_DATABASE_URL and migrates it, or skips the test
+func testDB(t *testing.T) *pgxpool.Pool {
+	t.Helper()
+	dsn := os.Getenv("TEST_DATABASE_URL")
+	if dsn == "" {
+		t.Skip("TEST_DATABASE_URL is not set")
+	}
+	ctx := context.Background()
+	pool, err := pgxpool.New(ctx, dsn)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(pool.Close)
+
+	m, err := migrations.New(pool)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := m.Up(ctx); err != nil {
+		t.Fatalf("migrate up: %v", err)
+	}
+	return pool
+}
+
+func TestOAuthFlowWithFakeProvider(t *testing.T) {
+	db := testDB(t)
+	f := newOAuthFlow(t, db)
+	email := "oauth-" + uuid.NewString() + "@example.com"
+	t.Cleanup(func() {
+		db.Exec(context.Background(), `DELETE FROM users WHERE email = $1`, email)
+	})
+
+	signIn := func() dto.AuthResponse {
+		t.Helper()
+		authURL, cookie := f.login()
+		rec := f.callback(f.consent(authURL, email), cookie)
+		if rec.Code != http.StatusFound {
+			t.Fatalf("callback status = %d: %s", rec.Code, rec.Body)
+		}
+		redirect, err := url.Parse(rec.Header().Get("Location"))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got := redirect.Scheme + "://" + redirect.Host + redirect.Path; got != testFrontend+"/callback" {
+			t.Errorf("callback redirected to %q", got)
+		}
+		if redirect.Query().Get("provider") != "fake" {
+			t.Errorf("redirect provider = %q", redirect.Query().Get("provider"))
+		}
+		code := redirect.Query().Get("code")
+
+		rec = f.exchange(code)
+		if rec.Code != http.StatusOK {
+			t.Fatalf("exchange status = %d: %s", rec.Code, rec.Body)
+		}
+		var resp dto.AuthResponse
+		if err := json.Unmarshal(rec.Body.Bytes(), &resp); err != nil {
+			t.Fatal(err)
+		}
+
+		// the sign-in code works once
+		if rec := f.exchange(code); rec.Code != http.StatusUnauthorized {
+			t.Errorf("second exchange status = %d, want 401", rec.Code)
+		}
+		return resp
+	}
+
+	first := signIn()
+	if first.User.Email != email || !first.User.EmailVerified {
+		t.Errorf("signed in as %+v, want a verified %s", first.User, email)
+	}
+	if first.RefreshToken == "" {
+		t.Error("exchange returned no refresh token")
+	}
+	claims, err := middleware.ValidateToken(first.Token, &f.cfg.JWT)
+	if err != nil {
+		t.Fatalf("access token: %v", err)
+	}
+	if claims.UserID.String() != first.User.ID {
+		t.Errorf("access token user = %s, want %s", claims.UserID, first.User.ID)
+	}
+
+	// signing in again finds the linked identity instead of creating a user
+	if again := signIn(); again.User.ID != first.User.ID {
+		t.Errorf("second sign-in user = %s, want %s", again.User.ID, first.User.ID)
+	}
+}
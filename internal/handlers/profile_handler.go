@@ -2,28 +2,26 @@ package handlers
 
 import (
 	"context"
-	"encoding/json"
 	"errors"
-	"fmt"
 	"net/http"
 	"strings"
 	"time"
 
 	"GO2GETHER_BACK-END/internal/dto"
+	"GO2GETHER_BACK-END/internal/models"
+	"GO2GETHER_BACK-END/internal/repository"
 	"GO2GETHER_BACK-END/internal/utils"
+	"GO2GETHER_BACK-END/internal/validate"
 
 	"github.com/google/uuid"
-	"github.com/jackc/pgx/v5"
-	"github.com/jackc/pgx/v5/pgconn"
-	"github.com/jackc/pgx/v5/pgxpool"
 )
 
 type ProfileHandler struct {
-	pool *pgxpool.Pool
+	profiles repository.ProfileRepository
 }
 
-func NewProfileHandler(pool *pgxpool.Pool) *ProfileHandler {
-	return &ProfileHandler{pool: pool}
+func NewProfileHandler(profiles repository.ProfileRepository) *ProfileHandler {
+	return &ProfileHandler{profiles: profiles}
 }
 
 // Create godoc
@@ -50,88 +48,62 @@ func (h *ProfileHandler) Create(w http.ResponseWriter, r *http.Request) {
 
 	// 2) decode body
 	var req dto.ProfileCreateRequest
-	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
-		utils.WriteErrorResponse(w, http.StatusBadRequest, "Invalid request body", err.Error())
-		return
-	}
-	if req.Username == "" {
-		utils.WriteErrorResponse(w, http.StatusBadRequest, "Invalid request body", "username is required")
+	if !utils.DecodeJSON(w, r, &req) {
 		return
 	}
 
-	// 3) parse birth_date (optional) — รองรับ "YYYY-MM-DD" และ RFC3339
+	// 3) birth_date (optional) — "YYYY-MM-DD" หรือ RFC3339, ตรวจรูปแบบแล้วใน DecodeJSON
 	var birthDatePtr *time.Time
-	if req.BirthDate != nil && *req.BirthDate != "" {
-		if t, err := time.Parse("2006-01-02", *req.BirthDate); err == nil {
-			birthDatePtr = &t
-		} else if t2, err2 := time.Parse(time.RFC3339, *req.BirthDate); err2 == nil {
-			tt := t2
-			birthDatePtr = &tt
-		} else {
-			utils.WriteErrorResponse(w, http.StatusBadRequest, "Invalid request body", "birth_date must be ISO 8601 date or datetime")
-			return
-		}
+	if req.BirthDate != nil && strings.TrimSpace(*req.BirthDate) != "" {
+		t, _ := validate.ParseDateTime(*req.BirthDate)
+		birthDatePtr = &t
 	}
 
 	ctx := r.Context()
 
 	// 4) ป้องกัน user เดิมมีโปรไฟล์แล้ว
-	const qHas = `select 1 from public.profiles where user_id = $1 limit 1`
-	{
-		var one int
-		err := h.pool.QueryRow(ctx, qHas, userID).Scan(&one)
-		if err == nil {
-			utils.WriteErrorResponse(w, http.StatusBadRequest, "Bad Request", "Profile already exists for this user")
-			return
-		}
-		if !errors.Is(err, pgx.ErrNoRows) {
-			utils.WriteErrorResponse(w, http.StatusInternalServerError, "Internal Server Error", err.Error())
-			return
-		}
+	exists, err := h.profiles.Exists(ctx, userID)
+	if err != nil {
+		utils.WriteError(w, r, err)
+		return
+	}
+	if exists {
+		utils.WriteErrorResponse(w, http.StatusBadRequest, "Bad Request", "Profile already exists for this user")
+		return
 	}
 
 	// 5) insert โปรไฟล์
-	const qIns = `
-insert into public.profiles(
-	user_id, username, first_name, last_name, display_name, avatar_url, phone, bio,
-	birth_date, food_preferences, chronic_disease, allergic_food, allergic_drugs, emergency_contact
-) values (
-	$1, $2, $3, $4, $5,
-	nullif($6,''), nullif($7,''), $8,
-	$9, $10, $11, $12, $13, $14
-)
-returning username;
-`
-	var username string
-	err := h.pool.QueryRow(
-		ctx, qIns,
-		userID, req.Username,
-		nullable(req.FirstName), nullable(req.LastName), nullable(req.DisplayName),
-		nullable(req.AvatarURL), nullable(req.Phone), nullable(req.Bio),
-		birthDatePtr,
-		nullable(req.FoodPreferences), nullable(req.ChronicDisease),
-		nullable(req.AllergicFood), nullable(req.AllergicDrugs),
-		nullable(req.EmergencyContact),
-	).Scan(&username)
-	if err != nil {
-		// แยกเคส unique violation: username ซ้ำ หรือ user_id ซ้ำ
-		var pgErr *pgconn.PgError
-		if errors.As(err, &pgErr) && pgErr.Code == "23505" {
-			if pgErr.ConstraintName == "profiles_username_key" {
-				utils.WriteErrorResponse(w, http.StatusConflict, "Conflict", "username already taken")
-				return
-			}
-			// profiles_user_id_key หรืออื่น ๆ
-			utils.WriteErrorResponse(w, http.StatusBadRequest, "Bad Request", "Profile already exists for this user")
-			return
-		}
-		utils.WriteErrorResponse(w, http.StatusInternalServerError, "Internal Server Error", err.Error())
+	err = h.profiles.Create(ctx, models.UserProfile{
+		UserID:           userID,
+		Username:         req.Username,
+		FirstName:        nullable(req.FirstName),
+		LastName:         nullable(req.LastName),
+		DisplayName:      nullable(req.DisplayName),
+		AvatarURL:        nullable(req.AvatarURL),
+		Phone:            nullable(req.Phone),
+		Bio:              nullable(req.Bio),
+		BirthDate:        birthDatePtr,
+		FoodPreferences:  nullable(req.FoodPreferences),
+		ChronicDisease:   nullable(req.ChronicDisease),
+		AllergicFood:     nullable(req.AllergicFood),
+		AllergicDrugs:    nullable(req.AllergicDrugs),
+		EmergencyContact: nullable(req.EmergencyContact),
+	})
+	switch {
+	case errors.Is(err, repository.ErrUsernameTaken):
+		utils.WriteErrorResponse(w, http.StatusConflict, "Conflict", "username already taken")
+		return
+	case errors.Is(err, repository.ErrAlreadyExists):
+		utils.WriteErrorResponse(w, http.StatusBadRequest, "Bad Request", "Profile already exists for this user")
+		return
+	case err != nil:
+		utils.WriteError(w, r, err)
 		return
 	}
 
 	// 6) success — ตามสเปค
 	var resp dto.ProfileCreateResponse
-	resp.User.Username = username
+	resp.User.Username = req.Username
 	resp.Message = "Profile create successfully"
 	utils.WriteJSONResponse(w, http.StatusOK, resp)
 }
@@ -148,11 +120,6 @@ returning username;
 // @Failure      500      {object}  dto.ErrorResponse
 // @Router       /api/profile/check [get]
 func (h *ProfileHandler) Check(w http.ResponseWriter, r *http.Request) {
-	if r.Method != http.MethodGet {
-		utils.WriteErrorResponse(w, http.StatusMethodNotAllowed, "Method Not Allowed", "only GET is allowed")
-		return
-	}
-
 	// 1) auth
 	userID, ok := userIDFromContext(r.Context())
 	if !ok {
@@ -163,42 +130,24 @@ func (h *ProfileHandler) Check(w http.ResponseWriter, r *http.Request) {
 	ctx := r.Context()
 
 	// 2) ตรวจสอบว่า profile มีอยู่หรือไม่
-	const q = `select 1 from public.profiles where user_id = $1 limit 1`
-	var one int
-	err := h.pool.QueryRow(ctx, q, userID).Scan(&one)
+	exists, err := h.profiles.Exists(ctx, userID)
+	if err != nil {
+		// database error
+		utils.WriteError(w, r, err)
+		return
+	}
 
 	var resp dto.ProfileCheckResponse
-	if err == nil {
-		// มี profile
-		resp.Exists = true
+	resp.Exists = exists
+	if exists {
 		resp.Message = "Profile exists"
-	} else if errors.Is(err, pgx.ErrNoRows) {
-		// ไม่มี profile
-		resp.Exists = false
-		resp.Message = "Profile does not exist"
 	} else {
-		// database error
-		utils.WriteErrorResponse(w, http.StatusInternalServerError, "Internal Server Error", err.Error())
-		return
+		resp.Message = "Profile does not exist"
 	}
 
 	utils.WriteJSONResponse(w, http.StatusOK, resp)
 }
 
-// วางใน struct/ไฟล์เดิม (ข้างๆ Create)
-func (h *ProfileHandler) Handle(w http.ResponseWriter, r *http.Request) {
-	switch r.Method {
-	case http.MethodPost:
-		h.Create(w, r)
-	case http.MethodGet:
-		h.GetMe(w, r)
-	case http.MethodPut: // <-- เพิ่ม
-		h.Update(w, r)
-	default:
-		utils.WriteErrorResponse(w, http.StatusMethodNotAllowed, "Method Not Allowed", "only GET, POST are allowed")
-	}
-}
-
 // GetMe godoc
 // @Summary      Get my profile
 // @Description  6.2 ดูโปรไฟล์ของตัวเอง (ต้องมี Bearer JWT)
@@ -220,98 +169,18 @@ func (h *ProfileHandler) GetMe(w http.ResponseWriter, r *http.Request) {
 	}
 
 	// 2) query: join users + profiles
-	const q = `
-select
-	u.id::text,
-	p.username,
-	u.email,
-	p.first_name,
-	p.last_name,
-	p.display_name,
-	p.avatar_url,
-	p.phone,
-	p.bio,
-	p.birth_date, -- date
-	p.food_preferences,
-	p.chronic_disease,
-	p.allergic_food,
-	p.allergic_drugs,
-	p.emergency_contact,
-	u.role,
-	u.created_at,
-	u.updated_at
-from public.users u
-join public.profiles p on p.user_id = u.id
-where u.id = $1
-limit 1;
-`
-	ctx := r.Context()
-
-	var (
-		id, username, email, role       string
-		firstName, lastName             *string
-		displayName, avatarURL, phone   *string
-		bio                             *string
-		birthDateNullable               *time.Time
-		foodPref, chronic, allergicFood *string
-		allergicDrugs, emergencyContact *string
-		createdAt, updatedAt            time.Time
-	)
-
-	err := h.pool.QueryRow(ctx, q, userID).Scan(
-		&id,
-		&username,
-		&email,
-		&firstName,
-		&lastName,
-		&displayName,
-		&avatarURL,
-		&phone,
-		&bio,
-		&birthDateNullable,
-		&foodPref,
-		&chronic,
-		&allergicFood,
-		&allergicDrugs,
-		&emergencyContact,
-		&role,
-		&createdAt,
-		&updatedAt,
-	)
+	p, err := h.profiles.Get(r.Context(), userID)
 	if err != nil {
-		if errors.Is(err, pgx.ErrNoRows) {
+		if errors.Is(err, repository.ErrNotFound) {
 			utils.WriteErrorResponse(w, http.StatusNotFound, "Not Found", "Profile not found")
 			return
 		}
-		utils.WriteErrorResponse(w, http.StatusInternalServerError, "Internal Server Error", err.Error())
+		utils.WriteError(w, r, err)
 		return
 	}
 
 	// 3) map -> DTO
-	var resp dto.ProfileGetResponse
-	resp.User.ID = id
-	resp.User.Username = username
-	resp.User.Email = email
-	resp.User.FirstName = firstName
-	resp.User.LastName = lastName
-	resp.User.DisplayName = displayName
-	resp.User.AvatarURL = avatarURL
-	resp.User.Phone = phone
-	resp.User.Bio = bio
-	if birthDateNullable != nil {
-		// ส่งเป็น "YYYY-MM-DD" (ตามตัวอย่าง)
-		bd := birthDateNullable.Format("2006-01-02")
-		resp.User.BirthDate = &bd
-	}
-	resp.User.FoodPreferences = foodPref
-	resp.User.ChronicDisease = chronic
-	resp.User.AllergicFood = allergicFood
-	resp.User.AllergicDrugs = allergicDrugs
-	resp.User.EmergencyContact = emergencyContact
-	resp.User.Role = role
-	resp.User.CreatedAt = createdAt.UTC().Format(time.RFC3339)
-	resp.User.UpdatedAt = updatedAt.UTC().Format(time.RFC3339)
-
+	resp := profileResponse(p)
 	utils.WriteJSONResponse(w, http.StatusOK, resp)
 }
 
@@ -331,11 +200,6 @@ limit 1;
 // @Failure      500      {object}  dto.ErrorResponse
 // @Router       /api/profile [put]
 func (h *ProfileHandler) Update(w http.ResponseWriter, r *http.Request) {
-	if r.Method != http.MethodPut {
-		utils.WriteErrorResponse(w, http.StatusMethodNotAllowed, "Method Not Allowed", "only PUT is allowed")
-		return
-	}
-
 	userID, ok := userIDFromContext(r.Context())
 	if !ok {
 		utils.WriteErrorResponse(w, http.StatusUnauthorized, "Unauthorized", "missing user in context")
@@ -343,68 +207,37 @@ func (h *ProfileHandler) Update(w http.ResponseWriter, r *http.Request) {
 	}
 
 	var req dto.ProfileUpdateRequest
-	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
-		utils.WriteErrorResponse(w, http.StatusBadRequest, "Invalid request body", err.Error())
+	if !utils.DecodeJSON(w, r, &req) {
 		return
 	}
 
-	// สร้างชุด SET แบบไดนามิก (อัปเดตเฉพาะฟิลด์ที่ถูกส่งมา)
-	set := []string{}
-	args := []any{}
-	i := 1
-
-	addStr := func(col string, p *string, nullIfEmpty bool) {
-		if p == nil {
-			return
-		}
-		var v any = *p
-		if nullIfEmpty && *p == "" {
-			v = nil
-		}
-		set = append(set, fmt.Sprintf("%s = $%d", col, i))
-		args = append(args, v)
-		i++
+	// อัปเดตเฉพาะฟิลด์ที่ถูกส่งมา
+	upd := models.ProfileUpdate{
+		Username:         req.Username,
+		FirstName:        req.FirstName,
+		LastName:         req.LastName,
+		DisplayName:      req.DisplayName,
+		AvatarURL:        req.AvatarURL,
+		Phone:            req.Phone,
+		Bio:              req.Bio,
+		FoodPreferences:  req.FoodPreferences,
+		ChronicDisease:   req.ChronicDisease,
+		AllergicFood:     req.AllergicFood,
+		AllergicDrugs:    req.AllergicDrugs,
+		EmergencyContact: req.EmergencyContact,
 	}
 
-	// username (unique) — nullIfEmpty = false (ไม่อนุญาตให้ลบ username)
-	if req.Username != nil {
-		addStr("username", req.Username, false)
-	}
-	addStr("first_name", req.FirstName, true)
-	addStr("last_name", req.LastName, true)
-	addStr("display_name", req.DisplayName, true)
-	addStr("avatar_url", req.AvatarURL, true)
-	addStr("phone", req.Phone, true)
-	addStr("bio", req.Bio, true)
-	addStr("food_preferences", req.FoodPreferences, true)
-	addStr("chronic_disease", req.ChronicDisease, true)
-	addStr("allergic_food", req.AllergicFood, true)
-	addStr("allergic_drugs", req.AllergicDrugs, true)
-	addStr("emergency_contact", req.EmergencyContact, true)
-
 	// birth_date: แปลงเป็น *time.Time หรือ NULL
 	if req.BirthDate != nil {
-		if *req.BirthDate == "" {
-			set = append(set, fmt.Sprintf("birth_date = $%d", i))
-			args = append(args, nil)
-			i++
+		if strings.TrimSpace(*req.BirthDate) == "" {
+			upd.ClearBirthDate = true
 		} else {
-			if t, err := time.Parse("2006-01-02", *req.BirthDate); err == nil {
-				set = append(set, fmt.Sprintf("birth_date = $%d", i))
-				args = append(args, t)
-				i++
-			} else if t2, err2 := time.Parse(time.RFC3339, *req.BirthDate); err2 == nil {
-				set = append(set, fmt.Sprintf("birth_date = $%d", i))
-				args = append(args, t2)
-				i++
-			} else {
-				utils.WriteErrorResponse(w, http.StatusBadRequest, "Invalid request body", "birth_date must be ISO 8601 date or datetime")
-				return
-			}
+			t, _ := validate.ParseDateTime(*req.BirthDate)
+			upd.BirthDate = &t
 		}
 	}
 
-	if len(set) == 0 {
+	if upd.IsEmpty() {
 		// ไม่ได้ส่งฟิลด์ใดมา
 		utils.WriteErrorResponse(w, http.StatusBadRequest, "Bad Request", "no fields to update")
 		return
@@ -413,114 +246,29 @@ func (h *ProfileHandler) Update(w http.ResponseWriter, r *http.Request) {
 	ctx := r.Context()
 
 	// อัปเดตโปรไฟล์ — ถ้าไม่มีแถว แปลว่า user นี้ยังไม่มีโปรไฟล์
-	qUpdate := fmt.Sprintf(`update public.profiles set %s where user_id = $%d`, strings.Join(set, ", "), i)
-	args = append(args, userID)
-
-	ct, err := h.pool.Exec(ctx, qUpdate, args...)
-	if err != nil {
-		// จับ unique violation เช่น username ซ้ำ
-		var pgErr *pgconn.PgError
-		if errors.As(err, &pgErr) && pgErr.Code == "23505" {
-			if pgErr.ConstraintName == "profiles_username_key" {
-				utils.WriteErrorResponse(w, http.StatusConflict, "Conflict", "username already taken")
-				return
-			}
-		}
-		utils.WriteErrorResponse(w, http.StatusInternalServerError, "Internal Server Error", err.Error())
+	switch err := h.profiles.Update(ctx, userID, upd); {
+	case errors.Is(err, repository.ErrUsernameTaken):
+		utils.WriteErrorResponse(w, http.StatusConflict, "Conflict", "username already taken")
 		return
-	}
-	if ct.RowsAffected() == 0 {
+	case errors.Is(err, repository.ErrNotFound):
 		utils.WriteErrorResponse(w, http.StatusNotFound, "Not Found", "Profile not found")
 		return
+	case err != nil:
+		utils.WriteError(w, r, err)
+		return
 	}
 
-	// select โปรไฟล์ล่าสุดเหมือน GetMe (เพื่อสร้าง response)
-	const q = `
-select
-	u.id::text,
-	p.username,
-	u.email,
-	p.first_name,
-	p.last_name,
-	p.display_name,
-	p.avatar_url,
-	p.phone,
-	p.bio,
-	p.birth_date, -- date
-	p.food_preferences,
-	p.chronic_disease,
-	p.allergic_food,
-	p.allergic_drugs,
-	p.emergency_contact,
-	u.role,
-	u.created_at,
-	u.updated_at
-from public.users u
-join public.profiles p on p.user_id = u.id
-where u.id = $1
-limit 1;
-`
-	var (
-		id, username, email, role       string
-		firstName, lastName             *string
-		displayName, avatarURL, phone   *string
-		bio                             *string
-		birthDateNullable               *time.Time
-		foodPref, chronic, allergicFood *string
-		allergicDrugs, emergencyContact *string
-		createdAt, updatedAt            time.Time
-	)
-	err = h.pool.QueryRow(ctx, q, userID).Scan(
-		&id,
-		&username,
-		&email,
-		&firstName,
-		&lastName,
-		&displayName,
-		&avatarURL,
-		&phone,
-		&bio,
-		&birthDateNullable,
-		&foodPref,
-		&chronic,
-		&allergicFood,
-		&allergicDrugs,
-		&emergencyContact,
-		&role,
-		&createdAt,
-		&updatedAt,
-	)
+	// อ่านโปรไฟล์ล่าสุดเหมือน GetMe (เพื่อสร้าง response)
+	p, err := h.profiles.Get(ctx, userID)
 	if err != nil {
-		if errors.Is(err, pgx.ErrNoRows) {
+		if errors.Is(err, repository.ErrNotFound) {
 			utils.WriteErrorResponse(w, http.StatusNotFound, "Not Found", "Profile not found")
 			return
 		}
-		utils.WriteErrorResponse(w, http.StatusInternalServerError, "Internal Server Error", err.Error())
+		utils.WriteError(w, r, err)
 		return
 	}
-
-	var res dto.ProfileGetResponse
-	res.User.ID = id
-	res.User.Username = username
-	res.User.Email = email
-	res.User.FirstName = firstName
-	res.User.LastName = lastName
-	res.User.DisplayName = displayName
-	res.User.AvatarURL = avatarURL
-	res.User.Phone = phone
-	res.User.Bio = bio
-	if birthDateNullable != nil {
-		bd := birthDateNullable.Format("2006-01-02")
-		res.User.BirthDate = &bd
-	}
-	res.User.FoodPreferences = foodPref
-	res.User.ChronicDisease = chronic
-	res.User.AllergicFood = allergicFood
-	res.User.AllergicDrugs = allergicDrugs
-	res.User.EmergencyContact = emergencyContact
-	res.User.Role = role
-	res.User.CreatedAt = createdAt.UTC().Format(time.RFC3339)
-	res.User.UpdatedAt = updatedAt.UTC().Format(time.RFC3339)
+	res := profileResponse(p)
 
 	utils.WriteJSONResponse(w, http.StatusOK, map[string]any{
 		"user":    res.User,
@@ -530,6 +278,34 @@ limit 1;
 
 // ---------- helpers ----------
 
+// profileResponse map โปรไฟล์ -> DTO (ใช้ทั้ง GetMe และ Update)
+func profileResponse(p models.UserProfile) dto.ProfileGetResponse {
+	var resp dto.ProfileGetResponse
+	resp.User.ID = p.UserID.String()
+	resp.User.Username = p.Username
+	resp.User.Email = p.Email
+	resp.User.FirstName = p.FirstName
+	resp.User.LastName = p.LastName
+	resp.User.DisplayName = p.DisplayName
+	resp.User.AvatarURL = p.AvatarURL
+	resp.User.Phone = p.Phone
+	resp.User.Bio = p.Bio
+	if p.BirthDate != nil {
+		// ส่งเป็น "YYYY-MM-DD" (ตามตัวอย่าง)
+		bd := p.BirthDate.Format("2006-01-02")
+		resp.User.BirthDate = &bd
+	}
+	resp.User.FoodPreferences = p.FoodPreferences
+	resp.User.ChronicDisease = p.ChronicDisease
+	resp.User.AllergicFood = p.AllergicFood
+	resp.User.AllergicDrugs = p.AllergicDrugs
+	resp.User.EmergencyContact = p.EmergencyContact
+	resp.User.Role = p.Role
+	resp.User.CreatedAt = p.CreatedAt.UTC().Format(time.RFC3339)
+	resp.User.UpdatedAt = p.UpdatedAt.UTC().Format(time.RFC3339)
+	return resp
+}
+
 func nullable(p *string) *string {
 	if p == nil || *p == "" {
 		return nil
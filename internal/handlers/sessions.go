@@ -0,0 +1,293 @@
+package handlers
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"log/slog"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
+	"github.com/jackc/pgx/v5/pgxpool"
+
+	"GO2GETHER_BACK-END/internal/config"
+	"GO2GETHER_BACK-END/internal/middleware"
+)
+
+// Errors returned by SessionsService
+var (
+	ErrSessionNotFound = errors.New("refresh token not found")
+	ErrSessionExpired  = errors.New("refresh token has expired")
+	ErrSessionRevoked  = errors.New("refresh token has been revoked")
+	ErrSessionReused   = errors.New("refresh token reuse detected")
+)
+
+// Reasons stored in auth_sessions.revoked_reason
+const (
+	sessionRevokedRotated   = "rotated"
+	sessionRevokedLogout    = "logout"
+	sessionRevokedLogoutAll = "logout_all"
+	sessionRevokedReuse     = "reuse_detected"
+	// "password_reset" is written directly by ForgotPasswordHandler.ResetPassword
+)
+
+// SessionClient identifies the device a session was issued to
+type SessionClient struct {
+	UserAgent string
+	IPAddress string
+}
+
+// TokenPair is an access token plus the refresh token that can renew it
+type TokenPair struct {
+	UserID       uuid.UUID
+	Email        string
+	AccessToken  string
+	RefreshToken string
+	ExpiresIn    int64 // access token lifetime in seconds
+}
+
+// SessionsService issues, rotates and revokes refresh-token sessions
+type SessionsService interface {
+	// Issue starts a new session family and returns the first token pair
+	Issue(ctx context.Context, userID uuid.UUID, email string, client SessionClient) (*TokenPair, error)
+	// Rotate exchanges a refresh token for a new pair; presenting an already
+	// rotated token revokes the whole family and returns ErrSessionReused
+	Rotate(ctx context.Context, refreshToken string, client SessionClient) (*TokenPair, error)
+	// Revoke ends the session family the refresh token belongs to
+	Revoke(ctx context.Context, refreshToken string) error
+	// RevokeAll ends every active session of the user and returns how many were revoked
+	RevokeAll(ctx context.Context, userID uuid.UUID) (int64, error)
+}
+
+// concrete service
+type sessionsService struct {
+	db  *pgxpool.Pool
+	jwt *config.JWTConfig
+}
+
+// NewSessionsService creates a SessionsService backed by the auth_sessions table
+func NewSessionsService(db *pgxpool.Pool, cfg *config.JWTConfig) SessionsService {
+	return &sessionsService{db: db, jwt: cfg}
+}
+
+func (s *sessionsService) Issue(ctx context.Context, userID uuid.UUID, email string, client SessionClient) (*TokenPair, error) {
+	var tokenVersion int
+	if err := s.db.QueryRow(ctx,
+		`SELECT token_version FROM users WHERE id = $1`, userID,
+	).Scan(&tokenVersion); err != nil {
+		return nil, fmt.Errorf("failed to load token version: %w", err)
+	}
+
+	refreshToken, err := s.insertSession(ctx, s.db, userID, uuid.New(), client)
+	if err != nil {
+		return nil, err
+	}
+	return s.pair(userID, email, tokenVersion, refreshToken)
+}
+
+func (s *sessionsService) Rotate(ctx context.Context, refreshToken string, client SessionClient) (*TokenPair, error) {
+	tx, err := s.db.Begin(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback(ctx)
+
+	// Lock the row so two concurrent refreshes with the same token cannot both win
+	var (
+		sessionID     uuid.UUID
+		userID        uuid.UUID
+		familyID      uuid.UUID
+		email         string
+		tokenVersion  int
+		expiresAt     time.Time
+		revokedAt     *time.Time
+		revokedReason *string
+	)
+	err = tx.QueryRow(ctx,
+		`SELECT s.id, s.user_id, s.family_id, u.email, u.token_version, s.expires_at, s.revoked_at, s.revoked_reason
+		   FROM auth_sessions s
+		   JOIN users u ON u.id = s.user_id
+		  WHERE s.token_hash = $1
+		  FOR UPDATE OF s`,
+		hashRefreshToken(refreshToken),
+	).Scan(&sessionID, &userID, &familyID, &email, &tokenVersion, &expiresAt, &revokedAt, &revokedReason)
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return nil, ErrSessionNotFound
+		}
+		return nil, err
+	}
+
+	if revokedAt != nil {
+		// A rotated token coming back means it was stolen (or replayed):
+		// kill the whole family so neither party can keep using it
+		if revokedReason != nil && *revokedReason == sessionRevokedRotated {
+			if _, err := tx.Exec(ctx,
+				`UPDATE auth_sessions
+				    SET revoked_at = NOW(), revoked_reason = $2
+				  WHERE family_id = $1 AND revoked_at IS NULL`,
+				familyID, sessionRevokedReuse,
+			); err != nil {
+				return nil, err
+			}
+			if err := tx.Commit(ctx); err != nil {
+				return nil, err
+			}
+			slog.WarnContext(ctx, "refresh token reuse detected, revoked session family",
+				"user_id", userID.String(), "family_id", familyID.String())
+			return nil, ErrSessionReused
+		}
+		return nil, ErrSessionRevoked
+	}
+
+	if time.Now().After(expiresAt) {
+		return nil, ErrSessionExpired
+	}
+
+	newToken, err := s.insertSession(ctx, tx, userID, familyID, client)
+	if err != nil {
+		return nil, err
+	}
+
+	if _, err := tx.Exec(ctx,
+		`UPDATE auth_sessions
+		    SET revoked_at = NOW(), revoked_reason = $2, last_used_at = NOW(),
+		        replaced_by = (SELECT id FROM auth_sessions WHERE token_hash = $3)
+		  WHERE id = $1`,
+		sessionID, sessionRevokedRotated, hashRefreshToken(newToken),
+	); err != nil {
+		return nil, err
+	}
+
+	if err := tx.Commit(ctx); err != nil {
+		return nil, err
+	}
+
+	return s.pair(userID, email, tokenVersion, newToken)
+}
+
+func (s *sessionsService) Revoke(ctx context.Context, refreshToken string) error {
+	var familyID uuid.UUID
+	err := s.db.QueryRow(ctx,
+		`SELECT family_id FROM auth_sessions WHERE token_hash = $1`,
+		hashRefreshToken(refreshToken),
+	).Scan(&familyID)
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return ErrSessionNotFound
+		}
+		return err
+	}
+
+	_, err = s.db.Exec(ctx,
+		`UPDATE auth_sessions
+		    SET revoked_at = NOW(), revoked_reason = $2
+		  WHERE family_id = $1 AND revoked_at IS NULL`,
+		familyID, sessionRevokedLogout,
+	)
+	return err
+}
+
+func (s *sessionsService) RevokeAll(ctx context.Context, userID uuid.UUID) (int64, error) {
+	tx, err := s.db.Begin(ctx)
+	if err != nil {
+		return 0, err
+	}
+	defer tx.Rollback(ctx)
+
+	cmd, err := tx.Exec(ctx,
+		`UPDATE auth_sessions
+		    SET revoked_at = NOW(), revoked_reason = $2
+		  WHERE user_id = $1 AND revoked_at IS NULL`,
+		userID, sessionRevokedLogoutAll,
+	)
+	if err != nil {
+		return 0, err
+	}
+
+	// Bump the token version so access tokens already handed out stop working too
+	if err := bumpTokenVersion(ctx, tx, userID); err != nil {
+		return 0, err
+	}
+
+	if err := tx.Commit(ctx); err != nil {
+		return 0, err
+	}
+	middleware.InvalidateTokenVersion(userID)
+
+	return cmd.RowsAffected(), nil
+}
+
+// sessionExecer is satisfied by both *pgxpool.Pool and pgx.Tx
+type sessionExecer interface {
+	Exec(ctx context.Context, sql string, args ...any) (pgconn.CommandTag, error)
+}
+
+// insertSession stores a new session row and returns the raw refresh token
+func (s *sessionsService) insertSession(ctx context.Context, db sessionExecer, userID, familyID uuid.UUID, client SessionClient) (string, error) {
+	refreshToken, err := generateRefreshToken()
+	if err != nil {
+		return "", fmt.Errorf("failed to generate refresh token: %w", err)
+	}
+
+	_, err = db.Exec(ctx,
+		`INSERT INTO auth_sessions (user_id, family_id, token_hash, user_agent, ip_address, expires_at)
+		 VALUES ($1, $2, $3, $4, $5, $6)`,
+		userID, familyID, hashRefreshToken(refreshToken),
+		truncate(client.UserAgent, 512), truncate(client.IPAddress, 64),
+		time.Now().Add(s.jwt.RefreshTokenTTL),
+	)
+	if err != nil {
+		return "", fmt.Errorf("failed to store session: %w", err)
+	}
+	return refreshToken, nil
+}
+
+func (s *sessionsService) pair(userID uuid.UUID, email string, tokenVersion int, refreshToken string) (*TokenPair, error) {
+	accessToken, err := middleware.GenerateToken(userID, email, tokenVersion, s.jwt)
+	if err != nil {
+		return nil, err
+	}
+	return &TokenPair{
+		UserID:       userID,
+		Email:        email,
+		AccessToken:  accessToken,
+		RefreshToken: refreshToken,
+		ExpiresIn:    int64(s.jwt.AccessTokenTTL.Seconds()),
+	}, nil
+}
+
+// bumpTokenVersion invalidates every access token of the user issued so far.
+// Callers must call middleware.InvalidateTokenVersion after the change is committed.
+func bumpTokenVersion(ctx context.Context, db sessionExecer, userID uuid.UUID) error {
+	_, err := db.Exec(ctx,
+		`UPDATE users SET token_version = token_version + 1 WHERE id = $1`, userID)
+	return err
+}
+
+// generateRefreshToken returns 32 random bytes encoded as URL-safe base64
+func generateRefreshToken() (string, error) {
+	b := make([]byte, 32)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return base64.RawURLEncoding.EncodeToString(b), nil
+}
+
+func hashRefreshToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
+
+func truncate(s string, max int) string {
+	if len(s) > max {
+		return s[:max]
+	}
+	return s
+}
@@ -0,0 +1,143 @@
+package handlers
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+
+	"GO2GETHER_BACK-END/internal/apperr"
+	"GO2GETHER_BACK-END/internal/dto"
+	"GO2GETHER_BACK-END/internal/utils"
+)
+
+// TestHelpersHandler serves endpoints that expose secrets (OTP codes, sent
+// emails) for development and integration tests. routes.SetupRoutes only
+// registers it when APP_ENV is not production.
+type TestHelpersHandler struct {
+	db   *pgxpool.Pool
+	sink *utils.MailSink // nil unless APP_ENV=test
+}
+
+// NewTestHelpersHandler creates a new TestHelpersHandler instance
+func NewTestHelpersHandler(db *pgxpool.Pool, sink *utils.MailSink) *TestHelpersHandler {
+	return &TestHelpersHandler{
+		db:   db,
+		sink: sink,
+	}
+}
+
+// GetOTP retrieves the latest OTP for testing/development purposes
+// @Summary Get OTP code
+// @Description Get the latest verification code for an email. Only served when APP_ENV is development or test.
+// @Tags testing
+// @Accept json
+// @Produce json
+// @Param request body dto.GetOTPRequest true "Email address"
+// @Success 200 {object} dto.GetOTPResponse "OTP retrieved successfully"
+// @Failure 400 {object} dto.ErrorResponse "Invalid request data"
+// @Failure 404 {object} dto.ErrorResponse "No OTP found"
+// @Failure 500 {object} dto.ErrorResponse "Internal server error"
+// @Router /api/auth/get-otp [post]
+func (h *TestHelpersHandler) GetOTP(w http.ResponseWriter, r *http.Request) {
+	var req dto.GetOTPRequest
+	if !utils.DecodeJSON(w, r, &req) {
+		return
+	}
+	switch req.Purpose = strings.ToLower(strings.TrimSpace(req.Purpose)); req.Purpose {
+	case "":
+		req.Purpose = purposePasswordReset
+	case purposePasswordReset, purposeEmailVerification:
+	default:
+		utils.WriteError(w, r, apperr.Validation(apperr.Field("purpose", "oneof", "purpose must be password_reset or email_verification")))
+		return
+	}
+
+	// Get user ID
+	var userID uuid.UUID
+	err := h.db.QueryRow(context.Background(),
+		"SELECT id FROM users WHERE email = $1", req.Email).Scan(&userID)
+
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			err = errNoAccount
+		}
+		utils.WriteError(w, r, err)
+		return
+	}
+
+	// Get latest OTP
+	var code string
+	var expiresAt time.Time
+	var used bool
+	var createdAt time.Time
+
+	err = h.db.QueryRow(context.Background(),
+		`SELECT code, expires_at, used, created_at 
+		 FROM auth_verifications 
+		 WHERE user_id = $1 AND email = $2 AND purpose = $3
+		 ORDER BY created_at DESC 
+		 LIMIT 1`,
+		userID, req.Email, req.Purpose).Scan(&code, &expiresAt, &used, &createdAt)
+
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			err = apperr.New(http.StatusNotFound, apperr.CodeNotFound, "No OTP found", "No verification code found for this email")
+		}
+		utils.WriteError(w, r, err)
+		return
+	}
+
+	response := dto.GetOTPResponse{
+		Email:     req.Email,
+		Code:      code,
+		ExpiresAt: expiresAt.Format(time.RFC3339),
+		Used:      used,
+		CreatedAt: createdAt.Format(time.RFC3339),
+	}
+
+	utils.WriteJSONResponse(w, http.StatusOK, response)
+}
+
+// SentEmails lists the emails captured by the test mail sink
+// @Summary List captured emails
+// @Description List emails captured instead of sent (APP_ENV=test only). DELETE clears the sink.
+// @Tags testing
+// @Produce json
+// @Param to query string false "Filter by recipient"
+// @Success 200 {object} dto.SentEmailsResponse "Captured emails"
+// @Success 204 "Sink cleared"
+// @Failure 404 {object} dto.ErrorResponse "Mail sink not enabled"
+// @Router /api/test/emails [get]
+// @Router /api/test/emails [delete]
+func (h *TestHelpersHandler) SentEmails(w http.ResponseWriter, r *http.Request) {
+	if h.sink == nil {
+		utils.WriteError(w, r, apperr.NotFound("Mail sink is only enabled when APP_ENV=test"))
+		return
+	}
+
+	switch r.Method {
+	case http.MethodGet:
+		messages := h.sink.Messages(strings.TrimSpace(r.URL.Query().Get("to")))
+		resp := dto.SentEmailsResponse{Emails: make([]dto.SentEmailResponse, 0, len(messages))}
+		for _, m := range messages {
+			resp.Emails = append(resp.Emails, dto.SentEmailResponse{
+				To:      m.To,
+				Subject: m.Subject,
+				Body:    m.Body,
+				SentAt:  m.SentAt.Format(time.RFC3339),
+			})
+		}
+		utils.WriteJSONResponse(w, http.StatusOK, resp)
+	case http.MethodDelete:
+		h.sink.Reset()
+		w.WriteHeader(http.StatusNoContent)
+	default:
+		utils.WriteError(w, r, apperr.New(http.StatusMethodNotAllowed, apperr.CodeMethodNotAllowed, "", "Only GET and DELETE are allowed"))
+	}
+}
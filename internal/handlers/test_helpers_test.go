@@ -0,0 +1,77 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"GO2GETHER_BACK-END/internal/config"
+	"GO2GETHER_BACK-END/internal/dto"
+	"GO2GETHER_BACK-END/internal/utils"
+)
+
+func TestSentEmailsServesTheSink(t *testing.T) {
+	sink := utils.NewMailSink()
+	utils.UseMailer(sink)
+	t.Cleanup(func() { utils.UseMailer(nil) })
+
+	// the emails the verification and reset flows send
+	email := utils.NewEmailService(&config.EmailConfig{Mailer: utils.MailerMemory})
+	ctx := context.Background()
+	if err := email.SendEmailVerification(ctx, "new@example.com", "482913", "http://localhost:3000/verify-email?token=abc", 15*time.Minute); err != nil {
+		t.Fatal(err)
+	}
+	if err := email.SendVerificationCode(ctx, "user@example.com", "105577"); err != nil {
+		t.Fatal(err)
+	}
+
+	h := NewTestHelpersHandler(nil, sink)
+	list := func(query string) []dto.SentEmailResponse {
+		t.Helper()
+		rec := httptest.NewRecorder()
+		h.SentEmails(rec, httptest.NewRequest(http.MethodGet, "/api/test/emails"+query, nil))
+		if rec.Code != http.StatusOK {
+			t.Fatalf("GET status = %d: %s", rec.Code, rec.Body)
+		}
+		var resp dto.SentEmailsResponse
+		if err := json.Unmarshal(rec.Body.Bytes(), &resp); err != nil {
+			t.Fatal(err)
+		}
+		return resp.Emails
+	}
+
+	if got := list(""); len(got) != 2 {
+		t.Fatalf("listed %d emails, want 2", len(got))
+	}
+	got := list("?to=user@example.com")
+	if len(got) != 1 || got[0].Subject != "Password Reset Verification Code" {
+		t.Fatalf("emails to user@example.com = %+v", got)
+	}
+
+	rec := httptest.NewRecorder()
+	h.SentEmails(rec, httptest.NewRequest(http.MethodDelete, "/api/test/emails", nil))
+	if rec.Code != http.StatusNoContent {
+		t.Errorf("DELETE status = %d, want 204", rec.Code)
+	}
+	if got := list(""); len(got) != 0 {
+		t.Errorf("listed %d emails after DELETE, want 0", len(got))
+	}
+}
+
+func TestSentEmailsWithoutSink(t *testing.T) {
+	rec := httptest.NewRecorder()
+	NewTestHelpersHandler(nil, nil).SentEmails(rec, httptest.NewRequest(http.MethodGet, "/api/test/emails", nil))
+	if rec.Code != http.StatusNotFound {
+		t.Errorf("status = %d, want 404", rec.Code)
+	}
+	var body dto.ErrorResponse
+	if err := json.Unmarshal(rec.Body.Bytes(), &body); err != nil {
+		t.Fatal(err)
+	}
+	if body.Code != "not_found" || body.Instance != "/api/test/emails" {
+		t.Errorf("code = %q, instance = %q", body.Code, body.Instance)
+	}
+}
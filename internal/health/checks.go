@@ -0,0 +1,124 @@
+package health
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"sync"
+	"time"
+
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+// DBPing checks that the database answers
+func DBPing(pool *pgxpool.Pool) Check {
+	return Check{Name: "db", Probe: pool.Ping}
+}
+
+// PoolSaturation warns when at least threshold (0-1) of the connections of
+// pool are in use. It is optional: a busy pool is slow, not broken.
+func PoolSaturation(pool *pgxpool.Pool, threshold float64) Check {
+	return Check{
+		Name:     "db_pool",
+		Optional: true,
+		Probe: func(context.Context) error {
+			s := pool.Stat()
+			if s.MaxConns() == 0 {
+				return nil
+			}
+			if used := float64(s.AcquiredConns()) / float64(s.MaxConns()); used >= threshold {
+				return fmt.Errorf("%d of %d connections in use", s.AcquiredConns(), s.MaxConns())
+			}
+			return nil
+		},
+	}
+}
+
+// Migrations fails while the schema is behind the migrations embedded in the
+// binary, or its history does not match them; check is Migrator.Check
+func Migrations(check func(ctx context.Context) error) Check {
+	return Check{Name: "migrations", Probe: check}
+}
+
+// SMTP checks that the mail server accepts TCP connections. It is optional:
+// without email, sign-up codes cannot be delivered but the API still works.
+func SMTP(host, port string) Check {
+	addr := net.JoinHostPort(host, port)
+	return Check{
+		Name:     "smtp",
+		Optional: true,
+		Probe: func(ctx context.Context) error {
+			var d net.Dialer
+			conn, err := d.DialContext(ctx, "tcp", addr)
+			if err != nil {
+				return err
+			}
+			return conn.Close()
+		},
+	}
+}
+
+// Worker tracks the jobs of a background worker so its liveness can be
+// checked: the worker is stuck when a job has been running for too long.
+// The zero value is ready to use.
+type Worker struct {
+	mu      sync.Mutex
+	next    uint64
+	running map[uint64]time.Time
+}
+
+// Start records a job as running; call the returned func when it ends
+func (w *Worker) Start() (done func()) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if w.running == nil {
+		w.running = make(map[uint64]time.Time)
+	}
+	w.next++
+	id := w.next
+	w.running[id] = time.Now()
+	return func() {
+		w.mu.Lock()
+		delete(w.running, id)
+		w.mu.Unlock()
+	}
+}
+
+// Running is the number of jobs running
+func (w *Worker) Running() int {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	return len(w.running)
+}
+
+// oldest is when the longest running job started, zero when idle
+func (w *Worker) oldest() time.Time {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	var t time.Time
+	for _, started := range w.running {
+		if t.IsZero() || started.Before(t) {
+			t = started
+		}
+	}
+	return t
+}
+
+// errWorkerStuck is reported when a job has run past its stall limit
+var errWorkerStuck = errors.New("worker is stuck")
+
+// WorkerAlive fails when a job of w has been running longer than stall. It is
+// a liveness check: a restart frees a stuck worker.
+func WorkerAlive(name string, w *Worker, stall time.Duration) Check {
+	return Check{
+		Name:     name,
+		Liveness: true,
+		Probe: func(context.Context) error {
+			if started := w.oldest(); !started.IsZero() && time.Since(started) > stall {
+				return fmt.Errorf("%w: a job has been running for %s", errWorkerStuck, time.Since(started).Round(time.Second))
+			}
+			return nil
+		},
+	}
+}
@@ -0,0 +1,180 @@
+// Package health runs the dependency checks behind /healthz and /readyz.
+//
+// Checks are registered once at startup on a Registry. Each result is cached
+// for a TTL, so frequent probes from load balancers and orchestrators do not
+// hammer the database or the mail server; concurrent probes of a stale check
+// wait for a single run.
+package health
+
+import (
+	"context"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// Statuses of a check result
+const (
+	StatusOK   = "ok"
+	StatusFail = "fail"
+	StatusWarn = "warn" // an optional check failed
+)
+
+// Check is one probe of a dependency
+type Check struct {
+	Name  string
+	Probe func(ctx context.Context) error
+	// Optional checks are reported as "warn" when they fail, without making
+	// the service unready (e.g. SMTP: sign-in works without email)
+	Optional bool
+	// Liveness checks also run on /healthz. Only put checks there whose
+	// failure a restart can fix, such as a stuck worker; a database outage
+	// must not restart every replica.
+	Liveness bool
+	// Timeout bounds one run; 0 uses the registry default
+	Timeout time.Duration
+}
+
+// Result is the outcome of a check
+type Result struct {
+	Name      string
+	Status    string
+	Optional  bool
+	Error     string
+	Latency   time.Duration
+	CheckedAt time.Time
+	Cached    bool // served from the cache of an earlier run
+}
+
+// Report is the outcome of a set of checks; Healthy is false when a
+// required check failed
+type Report struct {
+	Healthy bool
+	Checks  []Result
+}
+
+type entry struct {
+	check Check
+
+	mu     sync.Mutex // held while the check runs
+	last   Result
+	hasRun bool
+}
+
+// Registry holds the checks of the server
+type Registry struct {
+	ttl     time.Duration
+	timeout time.Duration
+
+	mu      sync.RWMutex
+	entries []*entry
+
+	draining atomic.Bool
+}
+
+// NewRegistry creates a registry caching results for ttl and bounding each
+// check by timeout unless the check sets its own
+func NewRegistry(ttl, timeout time.Duration) *Registry {
+	return &Registry{ttl: ttl, timeout: timeout}
+}
+
+// Register adds a check; names must be unique
+func (reg *Registry) Register(c Check) {
+	reg.mu.Lock()
+	defer reg.mu.Unlock()
+	for _, e := range reg.entries {
+		if e.check.Name == c.Name {
+			panic("health: duplicate check " + c.Name)
+		}
+	}
+	reg.entries = append(reg.entries, &entry{check: c})
+}
+
+// Readiness runs every check. Once Drain has been called it always fails.
+func (reg *Registry) Readiness(ctx context.Context) Report {
+	rep := reg.run(ctx, func(Check) bool { return true })
+	if reg.draining.Load() {
+		rep.Healthy = false
+		rep.Checks = append([]Result{{Name: "shutdown", Status: StatusFail, Error: "shutting down", CheckedAt: time.Now()}}, rep.Checks...)
+	}
+	return rep
+}
+
+// Drain makes Readiness fail from now on, so load balancers stop routing
+// new requests here before the server shuts down
+func (reg *Registry) Drain() {
+	reg.draining.Store(true)
+}
+
+// Liveness runs the checks marked Liveness
+func (reg *Registry) Liveness(ctx context.Context) Report {
+	return reg.run(ctx, func(c Check) bool { return c.Liveness })
+}
+
+func (reg *Registry) run(ctx context.Context, include func(Check) bool) Report {
+	reg.mu.RLock()
+	var entries []*entry
+	for _, e := range reg.entries {
+		if include(e.check) {
+			entries = append(entries, e)
+		}
+	}
+	reg.mu.RUnlock()
+
+	results := make([]Result, len(entries))
+	var wg sync.WaitGroup
+	for i, e := range entries {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			results[i] = reg.result(ctx, e)
+		}()
+	}
+	wg.Wait()
+
+	rep := Report{Healthy: true, Checks: results}
+	for _, res := range results {
+		if res.Status == StatusFail {
+			rep.Healthy = false
+		}
+	}
+	return rep
+}
+
+// result returns the cached result of e, running the check when it is stale
+func (reg *Registry) result(ctx context.Context, e *entry) Result {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	if e.hasRun && time.Since(e.last.CheckedAt) < reg.ttl {
+		res := e.last
+		res.Cached = true
+		return res
+	}
+
+	timeout := e.check.Timeout
+	if timeout <= 0 {
+		timeout = reg.timeout
+	}
+	// a probe that gave up must not cut the shared run short
+	cctx, cancel := context.WithTimeout(context.WithoutCancel(ctx), timeout)
+	defer cancel()
+
+	start := time.Now()
+	err := e.check.Probe(cctx)
+	res := Result{
+		Name:      e.check.Name,
+		Status:    StatusOK,
+		Optional:  e.check.Optional,
+		Latency:   time.Since(start),
+		CheckedAt: start,
+	}
+	if err != nil {
+		res.Error = err.Error()
+		res.Status = StatusFail
+		if e.check.Optional {
+			res.Status = StatusWarn
+		}
+	}
+	e.last, e.hasRun = res, true
+	return res
+}
@@ -0,0 +1,79 @@
+// Package lifecycle shuts the server down in order when the process is asked
+// to stop (SIGINT from a terminal, SIGTERM from Docker or Kubernetes):
+//
+//  1. readiness starts failing, so load balancers stop routing new requests;
+//  2. after the drain delay, the stop functions run in registration order,
+//     sharing ShutdownTimeout: first the HTTP server (in-flight requests
+//     finish), then the worker pools they fed (queued jobs finish), then
+//     exporters and connections.
+package lifecycle
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
+
+	"GO2GETHER_BACK-END/internal/health"
+)
+
+type stopFunc struct {
+	name string
+	fn   func(context.Context) error
+}
+
+// Lifecycle runs the shutdown sequence of the server
+type Lifecycle struct {
+	checks     *health.Registry
+	drainDelay time.Duration
+	timeout    time.Duration
+	stops      []stopFunc
+}
+
+// New creates a Lifecycle that fails the readiness of checks, waits
+// drainDelay, then gives the stop functions timeout to finish
+func New(checks *health.Registry, drainDelay, timeout time.Duration) *Lifecycle {
+	return &Lifecycle{checks: checks, drainDelay: drainDelay, timeout: timeout}
+}
+
+// OnStop registers fn to run at shutdown, after the ones registered before it
+func (l *Lifecycle) OnStop(name string, fn func(context.Context) error) {
+	l.stops = append(l.stops, stopFunc{name: name, fn: fn})
+}
+
+// Wait blocks until SIGINT or SIGTERM arrives or ctx is done, then shuts
+// down. A second signal during shutdown kills the process at once.
+func (l *Lifecycle) Wait(ctx context.Context) error {
+	sigCtx, stop := signal.NotifyContext(ctx, os.Interrupt, syscall.SIGTERM)
+	<-sigCtx.Done()
+	stop()
+	return l.Shutdown()
+}
+
+// Shutdown runs the shutdown sequence and returns the errors of the stop
+// functions
+func (l *Lifecycle) Shutdown() error {
+	slog.Info("shutting down", "drain_delay", l.drainDelay.String(), "timeout", l.timeout.String())
+	l.checks.Drain()
+	if l.drainDelay > 0 {
+		time.Sleep(l.drainDelay)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), l.timeout)
+	defer cancel()
+	var errs []error
+	for _, s := range l.stops {
+		start := time.Now()
+		if err := s.fn(ctx); err != nil {
+			slog.Error("shutdown step failed", "step", s.name, "error", err)
+			errs = append(errs, fmt.Errorf("%s: %w", s.name, err))
+			continue
+		}
+		slog.Debug("shutdown step done", "step", s.name, "took_ms", time.Since(start).Milliseconds())
+	}
+	return errors.Join(errs...)
+}
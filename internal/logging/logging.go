@@ -0,0 +1,240 @@
+// Package logging sets up the structured (log/slog) logger of the server.
+//
+// Records are JSON by default. Logged with a context (slog.InfoContext and
+// friends), they carry the request_id and user_id of the request. Attributes
+// whose key names a secret or sensitive personal data are redacted before
+// they are written, so handlers may log request fields without leaking
+// tokens, OTP codes or medical profile data.
+package logging
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"io"
+	"log/slog"
+	"os"
+	"strings"
+	"sync"
+
+	"go.opentelemetry.io/otel/trace"
+)
+
+// Redacted replaces the value of a sensitive attribute
+const Redacted = "[REDACTED]"
+
+// sensitiveKeys are attribute keys whose values are never logged. Keys are
+// compared in lower case; any key containing "password", "secret" or "token"
+// is sensitive too.
+var sensitiveKeys = map[string]bool{
+	"authorization":     true,
+	"cookie":            true,
+	"set-cookie":        true,
+	"dsn":               true,
+	"code":              true,
+	"otp":               true,
+	"otp_code":          true,
+	"recovery_code":     true,
+	"recovery_codes":    true,
+	"totp_secret":       true,
+	"phone":             true,
+	"birth_date":        true,
+	"food_preferences":  true,
+	"chronic_disease":   true,
+	"allergic_food":     true,
+	"allergic_drugs":    true,
+	"emergency_contact": true,
+}
+
+// Sensitive reports whether values logged under key are redacted
+func Sensitive(key string) bool {
+	k := strings.ToLower(key)
+	if sensitiveKeys[k] {
+		return true
+	}
+	return strings.Contains(k, "password") || strings.Contains(k, "secret") || strings.Contains(k, "token")
+}
+
+// MaskEmail keeps the first letter and the domain of an address:
+// "john@example.com" becomes "j***@example.com"
+func MaskEmail(email string) string {
+	local, domain, ok := strings.Cut(email, "@")
+	if !ok || local == "" {
+		return Redacted
+	}
+	return local[:1] + "***@" + domain
+}
+
+func redact(_ []string, a slog.Attr) slog.Attr {
+	switch {
+	case Sensitive(a.Key):
+		return slog.String(a.Key, Redacted)
+	case strings.EqualFold(a.Key, "email") && a.Value.Kind() == slog.KindString:
+		return slog.String(a.Key, MaskEmail(a.Value.String()))
+	}
+	return a
+}
+
+// New creates a logger writing to w. level is debug, info, warn or error
+// (default info); format is json (default) or text.
+func New(w io.Writer, level, format string) *slog.Logger {
+	opts := &slog.HandlerOptions{Level: ParseLevel(level), ReplaceAttr: redact}
+	var h slog.Handler
+	if strings.EqualFold(format, "text") {
+		h = slog.NewTextHandler(w, opts)
+	} else {
+		h = slog.NewJSONHandler(w, opts)
+	}
+	return slog.New(contextHandler{h})
+}
+
+// Setup makes a logger writing to stdout the default of slog and of the
+// standard log package, and returns it
+func Setup(level, format string) *slog.Logger {
+	logger := New(os.Stdout, level, format)
+	slog.SetDefault(logger)
+	return logger
+}
+
+// ParseLevel reads a level name; unknown names are info
+func ParseLevel(s string) slog.Level {
+	switch strings.ToLower(strings.TrimSpace(s)) {
+	case "debug":
+		return slog.LevelDebug
+	case "warn", "warning":
+		return slog.LevelWarn
+	case "error":
+		return slog.LevelError
+	}
+	return slog.LevelInfo
+}
+
+// ---- Request context ----
+
+// requestInfo is shared by every context derived from the request, so the
+// user ID set by the auth middleware deeper in the chain is visible to the
+// access log around it
+type requestInfo struct {
+	id    string
+	nonce string
+
+	mu     sync.Mutex
+	userID string
+	route  string
+}
+
+type requestInfoKey struct{}
+
+// WithRequestID returns ctx carrying the request ID id and a fresh nonce
+func WithRequestID(ctx context.Context, id string) context.Context {
+	return context.WithValue(ctx, requestInfoKey{}, &requestInfo{id: id, nonce: newNonce()})
+}
+
+func newNonce() string {
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		return ""
+	}
+	return hex.EncodeToString(b)
+}
+
+func info(ctx context.Context) *requestInfo {
+	if ctx == nil {
+		return nil
+	}
+	ri, _ := ctx.Value(requestInfoKey{}).(*requestInfo)
+	return ri
+}
+
+// RequestID returns the request ID of ctx, or ""
+func RequestID(ctx context.Context) string {
+	if ri := info(ctx); ri != nil {
+		return ri.id
+	}
+	return ""
+}
+
+// RequestNonce returns a random value generated by the server for the
+// request of ctx, or "". Unlike the request ID, which may come from the
+// X-Request-ID header, a client cannot choose or repeat it.
+func RequestNonce(ctx context.Context) string {
+	if ri := info(ctx); ri != nil {
+		return ri.nonce
+	}
+	return ""
+}
+
+// SetUserID records the authenticated user of the request of ctx
+func SetUserID(ctx context.Context, userID string) {
+	if ri := info(ctx); ri != nil {
+		ri.mu.Lock()
+		ri.userID = userID
+		ri.mu.Unlock()
+	}
+}
+
+// UserID returns the user recorded by SetUserID, or ""
+func UserID(ctx context.Context) string {
+	if ri := info(ctx); ri != nil {
+		ri.mu.Lock()
+		defer ri.mu.Unlock()
+		return ri.userID
+	}
+	return ""
+}
+
+// SetRoute records the route pattern that matched the request of ctx
+func SetRoute(ctx context.Context, pattern string) {
+	if ri := info(ctx); ri != nil {
+		ri.mu.Lock()
+		ri.route = pattern
+		ri.mu.Unlock()
+	}
+}
+
+// Route returns the pattern recorded by SetRoute, or ""
+func Route(ctx context.Context) string {
+	if ri := info(ctx); ri != nil {
+		ri.mu.Lock()
+		defer ri.mu.Unlock()
+		return ri.route
+	}
+	return ""
+}
+
+// contextHandler adds request_id and user_id of the record's context unless
+// the record already has them, and trace_id when the request is traced
+type contextHandler struct {
+	slog.Handler
+}
+
+func (h contextHandler) Handle(ctx context.Context, r slog.Record) error {
+	if ri := info(ctx); ri != nil {
+		var hasID, hasUser bool
+		r.Attrs(func(a slog.Attr) bool {
+			hasID = hasID || a.Key == "request_id"
+			hasUser = hasUser || a.Key == "user_id"
+			return true
+		})
+		if !hasID {
+			r.AddAttrs(slog.String("request_id", ri.id))
+		}
+		if uid := UserID(ctx); uid != "" && !hasUser {
+			r.AddAttrs(slog.String("user_id", uid))
+		}
+	}
+	if ctx != nil {
+		if sc := trace.SpanContextFromContext(ctx); sc.IsSampled() {
+			r.AddAttrs(slog.String("trace_id", sc.TraceID().String()))
+		}
+	}
+	return h.Handler.Handle(ctx, r)
+}
+
+func (h contextHandler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	return contextHandler{h.Handler.WithAttrs(attrs)}
+}
+
+func (h contextHandler) WithGroup(name string) slog.Handler {
+	return contextHandler{h.Handler.WithGroup(name)}
+}
@@ -0,0 +1,243 @@
+// Package metrics holds the Prometheus collectors of the server. They are
+// registered on Registry, which GET /metrics serves in the text exposition
+// format.
+//
+// Labels are kept to bounded sets: HTTP metrics are labelled with the route
+// pattern (e.g. /api/trips/{trip_id}), never the raw path.
+package metrics
+
+import (
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/collectors"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+)
+
+const namespace = "go2gether"
+
+// Registry holds every collector of the server, plus the Go runtime and
+// process collectors
+var Registry = prometheus.NewRegistry()
+
+var (
+	httpDuration = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Name:      "http_request_duration_seconds",
+		Help:      "Latency of HTTP requests by method, route and status code.",
+		Buckets:   []float64{.005, .01, .025, .05, .1, .25, .5, 1, 2.5, 5, 10},
+	}, []string{"method", "route", "status"})
+
+	httpErrors = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "http_request_errors_total",
+		Help:      "HTTP requests answered with a 4xx (class=client) or 5xx (class=server) status.",
+	}, []string{"method", "route", "class"})
+
+	httpInFlight = prometheus.NewGauge(prometheus.GaugeOpts{
+		Namespace: namespace,
+		Name:      "http_requests_in_flight",
+		Help:      "HTTP requests being served.",
+	})
+
+	outboxEvents = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "outbox_events_total",
+		Help:      "Outbox events handled by the dispatcher, by event type and result (delivered, postponed, retried, dead_lettered).",
+	}, []string{"type", "result"})
+
+	outboxDeliveries = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "outbox_deliveries_total",
+		Help:      "Deliveries of outbox events by channel and outcome (ok, failed).",
+	}, []string{"channel", "outcome"})
+
+	outboxLag = prometheus.NewHistogram(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Name:      "outbox_delivery_lag_seconds",
+		Help:      "Time from the commit of an outbox event to its delivery on every channel.",
+		Buckets:   []float64{.1, .25, .5, 1, 2.5, 5, 10, 30, 60, 300, 1800},
+	})
+
+	notificationStreams = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Namespace: namespace,
+		Name:      "notification_streams_open",
+		Help:      "Open notification streams by transport (sse, websocket).",
+	}, []string{"transport"})
+
+	emails = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "emails_total",
+		Help:      "Outgoing emails by kind and outcome (queued, sent, retried, failed, dropped, captured, not_configured).",
+	}, []string{"kind", "outcome"})
+
+	digests = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "digests_total",
+		Help:      "Notification digests by frequency and outcome (sent, empty, failed).",
+	}, []string{"frequency", "outcome"})
+
+	tripsCreated = prometheus.NewCounter(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "trips_created_total",
+		Help:      "Trips created.",
+	})
+
+	invitationsAccepted = prometheus.NewCounter(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "trip_invitations_accepted_total",
+		Help:      "Trip invitations accepted (members joined through an invitation link).",
+	})
+
+	workerJobs = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "worker_jobs_total",
+		Help:      "Background jobs by pool and result (done, panicked, rejected, dropped).",
+	}, []string{"pool", "result"})
+)
+
+func init() {
+	Registry.MustRegister(
+		collectors.NewGoCollector(),
+		collectors.NewProcessCollector(collectors.ProcessCollectorOpts{}),
+		httpDuration, httpErrors, httpInFlight,
+		outboxEvents, outboxDeliveries, outboxLag,
+		notificationStreams,
+		emails, digests,
+		tripsCreated, invitationsAccepted,
+		workerJobs,
+	)
+}
+
+// Handler serves the metrics of Registry
+func Handler() http.Handler {
+	return promhttp.HandlerFor(Registry, promhttp.HandlerOpts{})
+}
+
+// Email outcomes
+const (
+	EmailSent          = "sent"
+	EmailFailed        = "failed"
+	EmailCaptured      = "captured" // kept in the mail sink or printed, not sent
+	EmailNotConfigured = "not_configured"
+	EmailQueued        = "queued"
+	EmailRetried       = "retried"
+	EmailDropped       = "dropped" // the mail queue was full or shutting down
+)
+
+// unmatchedRoute labels requests no route matched, so scanners probing
+// random paths do not create new series
+const unmatchedRoute = "unmatched"
+
+// HTTPStarted counts a request in flight; call the returned func when done
+func HTTPStarted() func() {
+	httpInFlight.Inc()
+	return httpInFlight.Dec
+}
+
+// ObserveHTTP records a served request. route is the matched pattern, ""
+// when none matched.
+func ObserveHTTP(method, route string, status int, d time.Duration) {
+	if route == "" {
+		route = unmatchedRoute
+	}
+	method = normalizeMethod(method)
+	httpDuration.WithLabelValues(method, route, strconv.Itoa(status)).Observe(d.Seconds())
+	switch {
+	case status >= 500:
+		httpErrors.WithLabelValues(method, route, "server").Inc()
+	case status >= 400:
+		httpErrors.WithLabelValues(method, route, "client").Inc()
+	}
+}
+
+func normalizeMethod(m string) string {
+	switch m {
+	case http.MethodGet, http.MethodHead, http.MethodPost, http.MethodPut,
+		http.MethodPatch, http.MethodDelete, http.MethodOptions:
+		return m
+	}
+	return "OTHER"
+}
+
+// Outbox event results
+const (
+	OutboxDelivered    = "delivered"
+	OutboxRetried      = "retried"
+	OutboxDeadLettered = "dead_lettered"
+	OutboxPostponed    = "postponed"
+)
+
+// OutboxEvent counts an outbox event of typ handled with result
+func OutboxEvent(typ, result string) { outboxEvents.WithLabelValues(typ, result).Inc() }
+
+// OutboxDelivery counts one delivery attempt of an event on channel
+func OutboxDelivery(channel string, ok bool) {
+	outcome := "ok"
+	if !ok {
+		outcome = "failed"
+	}
+	outboxDeliveries.WithLabelValues(channel, outcome).Inc()
+}
+
+// OutboxLag records how long a delivered event waited in the outbox
+func OutboxLag(d time.Duration) { outboxLag.Observe(d.Seconds()) }
+
+// StreamOpened counts an open notification stream of transport; call the
+// returned func when it closes
+func StreamOpened(transport string) func() {
+	g := notificationStreams.WithLabelValues(transport)
+	g.Inc()
+	return g.Dec
+}
+
+// Email counts an outgoing email of kind (e.g. password_reset) by outcome
+func Email(kind, outcome string) { emails.WithLabelValues(kind, outcome).Inc() }
+
+// Digest outcomes
+const (
+	DigestSent   = "sent"
+	DigestEmpty  = "empty" // nothing unread in the period
+	DigestFailed = "failed"
+)
+
+// Digest counts a notification digest of frequency by outcome
+func Digest(frequency, outcome string) { digests.WithLabelValues(frequency, outcome).Inc() }
+
+// TripCreated counts a created trip
+func TripCreated() { tripsCreated.Inc() }
+
+// InvitationAccepted counts a member who joined through an invitation
+func InvitationAccepted() { invitationsAccepted.Inc() }
+
+// Background job results
+const (
+	JobDone     = "done"
+	JobPanicked = "panicked"
+	JobRejected = "rejected" // queue full or shutting down
+	JobDropped  = "dropped"  // still queued when shutdown ran out of time
+)
+
+// RegisterWorkerPool exports the queue depth and running jobs of a worker pool
+func RegisterWorkerPool(pool string, depth, running func() int) {
+	labels := prometheus.Labels{"pool": pool}
+	Registry.MustRegister(
+		prometheus.NewGaugeFunc(prometheus.GaugeOpts{
+			Namespace:   namespace,
+			Name:        "worker_queue_depth",
+			Help:        "Background jobs waiting in the queue of a worker pool.",
+			ConstLabels: labels,
+		}, func() float64 { return float64(depth()) }),
+		prometheus.NewGaugeFunc(prometheus.GaugeOpts{
+			Namespace:   namespace,
+			Name:        "worker_jobs_running",
+			Help:        "Background jobs being run by a worker pool.",
+			ConstLabels: labels,
+		}, func() float64 { return float64(running()) }),
+	)
+}
+
+// WorkerJob counts a background job of pool by result
+func WorkerJob(pool, result string) { workerJobs.WithLabelValues(pool, result).Inc() }
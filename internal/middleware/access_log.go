@@ -0,0 +1,100 @@
+package middleware
+
+import (
+	"bufio"
+	"errors"
+	"log/slog"
+	"net"
+	"net/http"
+	"time"
+
+	"GO2GETHER_BACK-END/internal/logging"
+	"GO2GETHER_BACK-END/internal/utils"
+)
+
+// probePaths are logged at debug level: orchestrators and Prometheus poll
+// them constantly
+var probePaths = map[string]bool{"/healthz": true, "/livez": true, "/readyz": true, "/metrics": true}
+
+// AccessLog logs one record per request with its status, size, latency and
+// the authenticated user. It must run inside RequestID so the record carries
+// the request ID. The query string is not logged: it may hold tokens.
+func AccessLog(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		start := time.Now()
+		sw := &StatusWriter{ResponseWriter: w}
+		next.ServeHTTP(sw, r)
+
+		level := slog.LevelInfo
+		switch {
+		case sw.Status() >= http.StatusInternalServerError:
+			level = slog.LevelError
+		case sw.Status() >= http.StatusBadRequest:
+			level = slog.LevelWarn
+		case probePaths[r.URL.Path]:
+			level = slog.LevelDebug
+		}
+		slog.LogAttrs(r.Context(), level, "http request",
+			slog.String("method", r.Method),
+			slog.String("path", r.URL.Path),
+			slog.String("route", logging.Route(r.Context())),
+			slog.Int("status", sw.Status()),
+			slog.Int64("bytes", sw.Bytes()),
+			slog.Float64("latency_ms", float64(time.Since(start).Microseconds())/1000),
+			slog.String("remote_ip", utils.ClientIP(r)),
+			slog.String("user_agent", r.UserAgent()),
+		)
+	})
+}
+
+// StatusWriter records the status code and body size written through it
+type StatusWriter struct {
+	http.ResponseWriter
+	status int
+	bytes  int64
+}
+
+// Status is the status written, 200 when the handler wrote none
+func (w *StatusWriter) Status() int {
+	if w.status == 0 {
+		return http.StatusOK
+	}
+	return w.status
+}
+
+// Bytes is the size of the body written so far
+func (w *StatusWriter) Bytes() int64 { return w.bytes }
+
+func (w *StatusWriter) WriteHeader(status int) {
+	if w.status == 0 {
+		w.status = status
+	}
+	w.ResponseWriter.WriteHeader(status)
+}
+
+func (w *StatusWriter) Write(b []byte) (int, error) {
+	if w.status == 0 {
+		w.status = http.StatusOK
+	}
+	n, err := w.ResponseWriter.Write(b)
+	w.bytes += int64(n)
+	return n, err
+}
+
+// Flush lets streaming handlers flush through the wrapper
+func (w *StatusWriter) Flush() {
+	if f, ok := w.ResponseWriter.(http.Flusher); ok {
+		f.Flush()
+	}
+}
+
+// Hijack lets WebSocket upgrades through the wrapper
+func (w *StatusWriter) Hijack() (net.Conn, *bufio.ReadWriter, error) {
+	if h, ok := w.ResponseWriter.(http.Hijacker); ok {
+		return h.Hijack()
+	}
+	return nil, nil, errors.New("response writer does not support hijacking")
+}
+
+// Unwrap exposes the wrapped writer to http.ResponseController
+func (w *StatusWriter) Unwrap() http.ResponseWriter { return w.ResponseWriter }
@@ -2,6 +2,8 @@ package middleware
 
 import (
 	"context"
+	"errors"
+	"log/slog"
 	"net/http"
 	"strings"
 	"time"
@@ -10,38 +12,49 @@ import (
 	"github.com/google/uuid"
 
 	"GO2GETHER_BACK-END/internal/config"
+	"GO2GETHER_BACK-END/internal/logging"
 	"GO2GETHER_BACK-END/internal/utils"
 )
 
 // JWTClaims represents the claims in the JWT token
 type JWTClaims struct {
-	UserID uuid.UUID `json:"user_id"`
-	Email  string    `json:"email"`
+	UserID       uuid.UUID `json:"user_id"`
+	Email        string    `json:"email"`
+	TokenVersion int       `json:"ver"`
 	jwt.RegisteredClaims
 }
 
-// GenerateToken generates a JWT token for the given user
-func GenerateToken(userID uuid.UUID, email string, cfg *config.JWTConfig) (string, error) {
+// GenerateToken generates a JWT token for the given user.
+// tokenVersion must be the user's current users.token_version; the token is
+// rejected by AuthMiddleware once that version is bumped.
+func GenerateToken(userID uuid.UUID, email string, tokenVersion int, cfg *config.JWTConfig) (string, error) {
 	claims := JWTClaims{
-		UserID: userID,
-		Email:  email,
+		UserID:       userID,
+		Email:        email,
+		TokenVersion: tokenVersion,
 		RegisteredClaims: jwt.RegisteredClaims{
+			ID:        uuid.New().String(),
 			ExpiresAt: jwt.NewNumericDate(time.Now().Add(cfg.AccessTokenTTL)),
 			IssuedAt:  jwt.NewNumericDate(time.Now()),
 			NotBefore: jwt.NewNumericDate(time.Now()),
 		},
 	}
 
-	token := jwt.NewWithClaims(jwt.SigningMethodHS256, claims)
-	return token.SignedString([]byte(cfg.Secret))
+	kr, err := keyRingFor(cfg)
+	if err != nil {
+		return "", err
+	}
+	return kr.Sign(PurposeAccess, claims)
 }
 
 // ValidateToken validates a JWT token and returns the claims
 func ValidateToken(tokenString string, cfg *config.JWTConfig) (*JWTClaims, error) {
-	token, err := jwt.ParseWithClaims(tokenString, &JWTClaims{}, func(token *jwt.Token) (interface{}, error) {
-		return []byte(cfg.Secret), nil
-	})
+	kr, err := keyRingFor(cfg)
+	if err != nil {
+		return nil, err
+	}
 
+	token, err := kr.Parse(PurposeAccess, tokenString, &JWTClaims{})
 	if err != nil {
 		return nil, err
 	}
@@ -71,16 +84,21 @@ func GenerateInvitationToken(tripID uuid.UUID, cfg *config.JWTConfig) (string, e
 		},
 	}
 
-	token := jwt.NewWithClaims(jwt.SigningMethodHS256, claims)
-	return token.SignedString([]byte(cfg.Secret))
+	kr, err := keyRingFor(cfg)
+	if err != nil {
+		return "", err
+	}
+	return kr.Sign(PurposeInvitation, claims)
 }
 
 // ValidateInvitationToken validates and parses the invitation token
 func ValidateInvitationToken(tokenString string, cfg *config.JWTConfig) (*InvitationTokenClaims, error) {
-	token, err := jwt.ParseWithClaims(tokenString, &InvitationTokenClaims{}, func(token *jwt.Token) (interface{}, error) {
-		return []byte(cfg.Secret), nil
-	})
+	kr, err := keyRingFor(cfg)
+	if err != nil {
+		return nil, err
+	}
 
+	token, err := kr.Parse(PurposeInvitation, tokenString, &InvitationTokenClaims{})
 	if err != nil {
 		return nil, err
 	}
@@ -100,28 +118,63 @@ func AuthMiddleware(next http.HandlerFunc, cfg *config.JWTConfig) http.HandlerFu
 	return func(w http.ResponseWriter, r *http.Request) {
 		authHeader := r.Header.Get("Authorization")
 		if authHeader == "" {
-            utils.WriteErrorResponse(w, http.StatusUnauthorized, "Unauthorized", "Authorization header required")
+			utils.WriteErrorResponse(w, http.StatusUnauthorized, "Unauthorized", "Authorization header required")
 			return
 		}
 
 		// Extract token from "Bearer <token>"
 		tokenParts := strings.Split(authHeader, " ")
 		if len(tokenParts) != 2 || tokenParts[0] != "Bearer" {
-            utils.WriteErrorResponse(w, http.StatusUnauthorized, "Unauthorized", "Invalid authorization header format")
+			utils.WriteErrorResponse(w, http.StatusUnauthorized, "Unauthorized", "Invalid authorization header format")
 			return
 		}
 
 		tokenString := tokenParts[1]
 		claims, err := ValidateToken(tokenString, cfg)
 		if err != nil {
-            utils.WriteErrorResponse(w, http.StatusUnauthorized, "Unauthorized", "Invalid token")
+			utils.WriteErrorResponse(w, http.StatusUnauthorized, "Unauthorized", "Invalid token")
 			return
 		}
 
+		// Reject tokens issued before a password reset, logout-all or account deletion
+		if tokenVersions != nil {
+			version, err := tokenVersions.Version(r.Context(), claims.UserID)
+			if err != nil && !errors.Is(err, ErrTokenUserNotFound) {
+				slog.ErrorContext(r.Context(), "checking token version failed", "error", err, "user_id", claims.UserID.String())
+				utils.WriteErrorResponse(w, http.StatusServiceUnavailable, "Service unavailable", "Could not verify token")
+				return
+			}
+			if err != nil || version != claims.TokenVersion {
+				utils.WriteErrorResponse(w, http.StatusUnauthorized, "Unauthorized", "Token has been revoked")
+				return
+			}
+		}
+
 		// Add user info to request context
+		logging.SetUserID(r.Context(), claims.UserID.String())
 		ctx := context.WithValue(r.Context(), "user_id", claims.UserID)
 		ctx = context.WithValue(ctx, "email", claims.Email)
+		ctx = context.WithValue(ctx, "token_id", claims.ID)
 
 		next.ServeHTTP(w, r.WithContext(ctx))
 	}
 }
+
+// QueryToken lets clients that cannot set headers (a browser's EventSource
+// or WebSocket) send the access token as the access_token query parameter.
+// Wrap AuthMiddleware with it on streaming routes only; the Authorization
+// header wins when both are set. Query strings are kept out of logs.
+func QueryToken(next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		q := r.URL.Query()
+		if token := q.Get("access_token"); token != "" {
+			r = r.Clone(r.Context())
+			if r.Header.Get("Authorization") == "" {
+				r.Header.Set("Authorization", "Bearer "+token)
+			}
+			q.Del("access_token")
+			r.URL.RawQuery = q.Encode()
+		}
+		next.ServeHTTP(w, r)
+	}
+}
@@ -0,0 +1,394 @@
+package middleware
+
+import (
+	"crypto/ed25519"
+	"crypto/hmac"
+	"crypto/rsa"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math/big"
+	"os"
+	"path/filepath"
+	"sync"
+
+	"github.com/golang-jwt/jwt/v5"
+
+	"GO2GETHER_BACK-END/internal/config"
+)
+
+// KeyPurpose separates signing keys by token type so a token minted for one
+// purpose (e.g. an invitation link) can never be accepted as another (e.g. access)
+type KeyPurpose string
+
+const (
+	PurposeAccess     KeyPurpose = "access"
+	PurposeInvitation KeyPurpose = "invitation"
+	PurposeReset      KeyPurpose = "reset"
+	PurposeOAuthState KeyPurpose = "oauth_state"
+	PurposeMFA        KeyPurpose = "mfa"
+)
+
+// knownPurposes lists every purpose the key ring must be able to sign for
+var knownPurposes = []KeyPurpose{PurposeAccess, PurposeInvitation, PurposeReset, PurposeOAuthState, PurposeMFA}
+
+// SigningKey is one entry of the key ring
+type SigningKey struct {
+	ID      string
+	Purpose KeyPurpose
+	Method  jwt.SigningMethod
+	Active  bool // signs new tokens; inactive keys only verify during rotation
+
+	signKey   any // nil for verification-only keys
+	verifyKey any
+}
+
+// KeyRing holds the signing keys of every token purpose
+type KeyRing struct {
+	keys   map[KeyPurpose][]*SigningKey
+	legacy []byte // raw JWT secret accepted for tokens without a kid
+}
+
+// keyFileEntry is one key in the JWT_KEYS_FILE JSON document
+type keyFileEntry struct {
+	KID            string `json:"kid"`
+	Purpose        string `json:"purpose"`
+	Alg            string `json:"alg"` // HS256 | RS256 | EdDSA
+	Active         bool   `json:"active"`
+	Secret         string `json:"secret,omitempty"`
+	PrivateKeyFile string `json:"private_key_file,omitempty"`
+	PublicKeyFile  string `json:"public_key_file,omitempty"`
+}
+
+// NewKeyRing builds the key ring from configuration. Purposes that have no
+// keys in JWT_KEYS_FILE get an HS256 key derived from JWT_SECRET.
+func NewKeyRing(cfg *config.JWTConfig) (*KeyRing, error) {
+	kr := &KeyRing{keys: make(map[KeyPurpose][]*SigningKey)}
+	if cfg.AcceptLegacyTokens {
+		kr.legacy = []byte(cfg.Secret)
+	}
+
+	if cfg.KeysFile != "" {
+		if err := kr.loadFile(cfg.KeysFile); err != nil {
+			return nil, err
+		}
+	}
+
+	for _, purpose := range knownPurposes {
+		if len(kr.keys[purpose]) > 0 {
+			continue
+		}
+		derived := deriveHMACKey(cfg.Secret, purpose)
+		kr.keys[purpose] = []*SigningKey{{
+			ID:        "hs256-" + string(purpose),
+			Purpose:   purpose,
+			Method:    jwt.SigningMethodHS256,
+			Active:    true,
+			signKey:   derived,
+			verifyKey: derived,
+		}}
+	}
+
+	for _, purpose := range knownPurposes {
+		active := 0
+		for _, k := range kr.keys[purpose] {
+			if k.Active {
+				if k.signKey == nil {
+					return nil, fmt.Errorf("active %s key %q has no private key", purpose, k.ID)
+				}
+				active++
+			}
+		}
+		if active != 1 {
+			return nil, fmt.Errorf("%s keys must have exactly one active key, found %d", purpose, active)
+		}
+	}
+
+	return kr, nil
+}
+
+func (kr *KeyRing) loadFile(path string) error {
+	raw, err := os.ReadFile(path)
+	if err != nil {
+		return fmt.Errorf("read JWT keys file: %w", err)
+	}
+	var doc struct {
+		Keys []keyFileEntry `json:"keys"`
+	}
+	if err := json.Unmarshal(raw, &doc); err != nil {
+		return fmt.Errorf("parse JWT keys file: %w", err)
+	}
+
+	seen := make(map[string]bool)
+	baseDir := filepath.Dir(path)
+	for _, e := range doc.Keys {
+		if e.KID == "" {
+			return errors.New("JWT keys file: every key needs a kid")
+		}
+		if seen[e.KID] {
+			return fmt.Errorf("JWT keys file: duplicate kid %q", e.KID)
+		}
+		seen[e.KID] = true
+
+		purpose := KeyPurpose(e.Purpose)
+		if !isKnownPurpose(purpose) {
+			return fmt.Errorf("JWT keys file: key %q has unknown purpose %q", e.KID, e.Purpose)
+		}
+
+		key, err := parseKeyEntry(e, baseDir)
+		if err != nil {
+			return fmt.Errorf("JWT keys file: key %q: %w", e.KID, err)
+		}
+		kr.keys[purpose] = append(kr.keys[purpose], key)
+	}
+	return nil
+}
+
+func parseKeyEntry(e keyFileEntry, baseDir string) (*SigningKey, error) {
+	key := &SigningKey{ID: e.KID, Purpose: KeyPurpose(e.Purpose), Active: e.Active}
+
+	readPEM := func(p string) ([]byte, error) {
+		if !filepath.IsAbs(p) {
+			p = filepath.Join(baseDir, p)
+		}
+		return os.ReadFile(p)
+	}
+
+	switch e.Alg {
+	case "HS256":
+		if e.Secret == "" {
+			return nil, errors.New("HS256 key needs a secret")
+		}
+		key.Method = jwt.SigningMethodHS256
+		key.signKey = []byte(e.Secret)
+		key.verifyKey = []byte(e.Secret)
+
+	case "RS256":
+		key.Method = jwt.SigningMethodRS256
+		if e.PrivateKeyFile != "" {
+			pemBytes, err := readPEM(e.PrivateKeyFile)
+			if err != nil {
+				return nil, err
+			}
+			priv, err := jwt.ParseRSAPrivateKeyFromPEM(pemBytes)
+			if err != nil {
+				return nil, err
+			}
+			key.signKey = priv
+			key.verifyKey = &priv.PublicKey
+		} else if e.PublicKeyFile != "" {
+			pemBytes, err := readPEM(e.PublicKeyFile)
+			if err != nil {
+				return nil, err
+			}
+			pub, err := jwt.ParseRSAPublicKeyFromPEM(pemBytes)
+			if err != nil {
+				return nil, err
+			}
+			key.verifyKey = pub
+		} else {
+			return nil, errors.New("RS256 key needs private_key_file or public_key_file")
+		}
+
+	case "EdDSA":
+		key.Method = jwt.SigningMethodEdDSA
+		if e.PrivateKeyFile != "" {
+			pemBytes, err := readPEM(e.PrivateKeyFile)
+			if err != nil {
+				return nil, err
+			}
+			priv, err := jwt.ParseEdPrivateKeyFromPEM(pemBytes)
+			if err != nil {
+				return nil, err
+			}
+			edPriv, ok := priv.(ed25519.PrivateKey)
+			if !ok {
+				return nil, errors.New("EdDSA private key must be Ed25519")
+			}
+			key.signKey = edPriv
+			key.verifyKey = edPriv.Public()
+		} else if e.PublicKeyFile != "" {
+			pemBytes, err := readPEM(e.PublicKeyFile)
+			if err != nil {
+				return nil, err
+			}
+			pub, err := jwt.ParseEdPublicKeyFromPEM(pemBytes)
+			if err != nil {
+				return nil, err
+			}
+			key.verifyKey = pub
+		} else {
+			return nil, errors.New("EdDSA key needs private_key_file or public_key_file")
+		}
+
+	default:
+		return nil, fmt.Errorf("unsupported alg %q (use HS256, RS256 or EdDSA)", e.Alg)
+	}
+
+	return key, nil
+}
+
+// Sign signs the claims with the active key of the purpose and sets the kid header
+func (kr *KeyRing) Sign(purpose KeyPurpose, claims jwt.Claims) (string, error) {
+	key := kr.activeKey(purpose)
+	if key == nil {
+		return "", fmt.Errorf("no active signing key for %s tokens", purpose)
+	}
+	token := jwt.NewWithClaims(key.Method, claims)
+	token.Header["kid"] = key.ID
+	return token.SignedString(key.signKey)
+}
+
+// Parse verifies the token with the purpose's key named by its kid header.
+// The algorithm is pinned to the key's own method to prevent alg confusion.
+//
+// Legacy tokens (no kid, signed with the raw secret) are accepted as access
+// and invitation tokens: invitation links live for 30 days, so those issued
+// before the key ring must keep working. Every purpose used to share that
+// secret, so a legacy token is told apart by its subject: access tokens never
+// had one, invitations carry "trip_invitation". Legacy reset tokens expire
+// within minutes and are not accepted.
+func (kr *KeyRing) Parse(purpose KeyPurpose, tokenString string, claims jwt.Claims) (*jwt.Token, error) {
+	return jwt.ParseWithClaims(tokenString, claims, func(token *jwt.Token) (interface{}, error) {
+		kid, _ := token.Header["kid"].(string)
+		if kid == "" {
+			wantSub, ok := legacySubjects[purpose]
+			if kr.legacy == nil || !ok {
+				return nil, errors.New("token has no kid")
+			}
+			if _, ok := token.Method.(*jwt.SigningMethodHMAC); !ok || token.Method.Alg() != jwt.SigningMethodHS256.Alg() {
+				return nil, errors.New("invalid signing method")
+			}
+			if sub, err := token.Claims.GetSubject(); err != nil || sub != wantSub {
+				return nil, fmt.Errorf("legacy token is not a %s token", purpose)
+			}
+			return kr.legacy, nil
+		}
+
+		for _, k := range kr.keys[purpose] {
+			if k.ID == kid {
+				if token.Method.Alg() != k.Method.Alg() {
+					return nil, errors.New("invalid signing method")
+				}
+				return k.verifyKey, nil
+			}
+		}
+		return nil, fmt.Errorf("unknown kid %q", kid)
+	})
+}
+
+// legacySubjects is the subject a legacy token of each accepted purpose has
+var legacySubjects = map[KeyPurpose]string{
+	PurposeAccess:     "",
+	PurposeInvitation: "trip_invitation",
+}
+
+func (kr *KeyRing) activeKey(purpose KeyPurpose) *SigningKey {
+	for _, k := range kr.keys[purpose] {
+		if k.Active {
+			return k
+		}
+	}
+	return nil
+}
+
+// JWK is a public key in RFC 7517 form
+type JWK struct {
+	Kty string `json:"kty"`
+	Kid string `json:"kid"`
+	Use string `json:"use"`
+	Alg string `json:"alg"`
+	// RSA
+	N string `json:"n,omitempty"`
+	E string `json:"e,omitempty"`
+	// OKP (Ed25519)
+	Crv string `json:"crv,omitempty"`
+	X   string `json:"x,omitempty"`
+}
+
+// JWKSet is the document served at /.well-known/jwks.json
+type JWKSet struct {
+	Keys []JWK `json:"keys"`
+}
+
+// JWKS returns the public keys of every asymmetric key, including
+// verification-only keys so tokens signed before a rotation keep verifying.
+// HMAC keys are secrets and are never published.
+func (kr *KeyRing) JWKS() JWKSet {
+	set := JWKSet{Keys: []JWK{}}
+	for _, purpose := range knownPurposes {
+		for _, k := range kr.keys[purpose] {
+			switch pub := k.verifyKey.(type) {
+			case *rsa.PublicKey:
+				set.Keys = append(set.Keys, JWK{
+					Kty: "RSA",
+					Kid: k.ID,
+					Use: "sig",
+					Alg: k.Method.Alg(),
+					N:   base64.RawURLEncoding.EncodeToString(pub.N.Bytes()),
+					E:   base64.RawURLEncoding.EncodeToString(big.NewInt(int64(pub.E)).Bytes()),
+				})
+			case ed25519.PublicKey:
+				set.Keys = append(set.Keys, JWK{
+					Kty: "OKP",
+					Kid: k.ID,
+					Use: "sig",
+					Alg: k.Method.Alg(),
+					Crv: "Ed25519",
+					X:   base64.RawURLEncoding.EncodeToString(pub),
+				})
+			}
+		}
+	}
+	return set
+}
+
+func isKnownPurpose(p KeyPurpose) bool {
+	for _, known := range knownPurposes {
+		if p == known {
+			return true
+		}
+	}
+	return false
+}
+
+// deriveHMACKey gives each purpose its own HS256 key from the one JWT secret
+func deriveHMACKey(secret string, purpose KeyPurpose) []byte {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte("go2gether/jwt/" + string(purpose)))
+	return mac.Sum(nil)
+}
+
+var (
+	keyRingMu sync.Mutex
+	keyRing   *KeyRing
+)
+
+// LoadKeyRing builds the key ring from configuration and makes it the one used
+// by every Generate*/Validate* function. Call it at startup to fail fast on bad keys.
+func LoadKeyRing(cfg *config.JWTConfig) (*KeyRing, error) {
+	kr, err := NewKeyRing(cfg)
+	if err != nil {
+		return nil, err
+	}
+	keyRingMu.Lock()
+	keyRing = kr
+	keyRingMu.Unlock()
+	return kr, nil
+}
+
+// keyRingFor returns the loaded key ring, building it lazily if LoadKeyRing was not called
+func keyRingFor(cfg *config.JWTConfig) (*KeyRing, error) {
+	keyRingMu.Lock()
+	defer keyRingMu.Unlock()
+	if keyRing == nil {
+		kr, err := NewKeyRing(cfg)
+		if err != nil {
+			return nil, err
+		}
+		keyRing = kr
+	}
+	return keyRing, nil
+}
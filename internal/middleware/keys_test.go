@@ -0,0 +1,85 @@
+package middleware
+
+import (
+	"testing"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/google/uuid"
+
+	"GO2GETHER_BACK-END/internal/config"
+)
+
+const testSecret = "test-secret-at-least-32-bytes-long!!"
+
+// legacyToken signs claims the way tokens were signed before the key ring:
+// HS256 with the raw secret and no kid
+func legacyToken(t *testing.T, claims jwt.Claims) string {
+	t.Helper()
+	s, err := jwt.NewWithClaims(jwt.SigningMethodHS256, claims).SignedString([]byte(testSecret))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return s
+}
+
+func TestKeyRingParseLegacyTokens(t *testing.T) {
+	exp := jwt.NewNumericDate(time.Now().Add(time.Hour))
+	access := legacyToken(t, JWTClaims{UserID: uuid.New(), Email: "a@example.com",
+		RegisteredClaims: jwt.RegisteredClaims{ExpiresAt: exp}})
+	reset := legacyToken(t, ResetTokenClaims{UserID: uuid.New(), Email: "a@example.com", Code: "123456",
+		RegisteredClaims: jwt.RegisteredClaims{ExpiresAt: exp, Issuer: "go2gether", Subject: "password_reset"}})
+	invitation := legacyToken(t, InvitationTokenClaims{TripID: uuid.New(),
+		RegisteredClaims: jwt.RegisteredClaims{ExpiresAt: exp, Subject: "trip_invitation"}})
+
+	tests := []struct {
+		name    string
+		accept  bool
+		purpose KeyPurpose
+		token   string
+		claims  jwt.Claims
+		ok      bool
+	}{
+		{"access as access", true, PurposeAccess, access, &JWTClaims{}, true},
+		{"access with legacy off", false, PurposeAccess, access, &JWTClaims{}, false},
+		{"reset as access", true, PurposeAccess, reset, &JWTClaims{}, false},
+		{"invitation as access", true, PurposeAccess, invitation, &JWTClaims{}, false},
+		{"reset as reset", true, PurposeReset, reset, &ResetTokenClaims{}, false},
+		{"invitation as invitation", true, PurposeInvitation, invitation, &InvitationTokenClaims{}, true},
+		{"invitation with legacy off", false, PurposeInvitation, invitation, &InvitationTokenClaims{}, false},
+		{"access as invitation", true, PurposeInvitation, access, &InvitationTokenClaims{}, false},
+		{"reset as invitation", true, PurposeInvitation, reset, &InvitationTokenClaims{}, false},
+		{"access as mfa", true, PurposeMFA, access, &MFAChallengeClaims{}, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			kr, err := NewKeyRing(&config.JWTConfig{Secret: testSecret, AcceptLegacyTokens: tt.accept})
+			if err != nil {
+				t.Fatal(err)
+			}
+			_, err = kr.Parse(tt.purpose, tt.token, tt.claims)
+			if (err == nil) != tt.ok {
+				t.Errorf("Parse() error = %v, want ok = %v", err, tt.ok)
+			}
+		})
+	}
+}
+
+func TestKeyRingPurposeSeparation(t *testing.T) {
+	kr, err := NewKeyRing(&config.JWTConfig{Secret: testSecret, AcceptLegacyTokens: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	exp := jwt.NewNumericDate(time.Now().Add(time.Hour))
+	reset, err := kr.Sign(PurposeReset, ResetTokenClaims{UserID: uuid.New(),
+		RegisteredClaims: jwt.RegisteredClaims{ExpiresAt: exp}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := kr.Parse(PurposeReset, reset, &ResetTokenClaims{}); err != nil {
+		t.Fatalf("reset token rejected as reset: %v", err)
+	}
+	if _, err := kr.Parse(PurposeAccess, reset, &JWTClaims{}); err == nil {
+		t.Fatal("reset token accepted as access token")
+	}
+}
@@ -0,0 +1,43 @@
+package middleware
+
+import (
+	"crypto/subtle"
+	"net/http"
+	"strings"
+	"time"
+
+	"GO2GETHER_BACK-END/internal/logging"
+	"GO2GETHER_BACK-END/internal/metrics"
+	"GO2GETHER_BACK-END/internal/utils"
+)
+
+// Metrics records the latency and status of every request per route. Like
+// AccessLog it must run inside RequestID, which carries the matched route.
+func Metrics(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		done := metrics.HTTPStarted()
+		defer done()
+
+		start := time.Now()
+		sw := &StatusWriter{ResponseWriter: w}
+		next.ServeHTTP(sw, r)
+		metrics.ObserveHTTP(r.Method, logging.Route(r.Context()), sw.Status(), time.Since(start))
+	})
+}
+
+// MetricsEndpoint serves the Prometheus metrics. With a token, scrapers must
+// send it as "Authorization: Bearer <token>".
+func MetricsEndpoint(token string) http.Handler {
+	h := metrics.Handler()
+	if token == "" {
+		return h
+	}
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		got, ok := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer ")
+		if !ok || subtle.ConstantTimeCompare([]byte(got), []byte(token)) != 1 {
+			utils.WriteErrorResponse(w, http.StatusUnauthorized, "Unauthorized", "A valid metrics token is required")
+			return
+		}
+		h.ServeHTTP(w, r)
+	})
+}
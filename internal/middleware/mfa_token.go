@@ -0,0 +1,60 @@
+package middleware
+
+import (
+	"errors"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/google/uuid"
+
+	"GO2GETHER_BACK-END/internal/config"
+)
+
+// MFAChallengeClaims is the short-lived token returned by the first login
+// step of an account with two-factor authentication. It proves the password
+// (or provider sign-in) was checked and is only accepted by the MFA verify endpoint.
+type MFAChallengeClaims struct {
+	UserID uuid.UUID `json:"user_id"`
+	Method string    `json:"method"` // password | <oauth provider>
+	jwt.RegisteredClaims
+}
+
+// GenerateMFAChallengeToken signs a challenge for the user's second factor
+func GenerateMFAChallengeToken(userID uuid.UUID, method string, ttl time.Duration, cfg *config.JWTConfig) (string, error) {
+	claims := &MFAChallengeClaims{
+		UserID: userID,
+		Method: method,
+		RegisteredClaims: jwt.RegisteredClaims{
+			ID:        uuid.NewString(),
+			ExpiresAt: jwt.NewNumericDate(time.Now().Add(ttl)),
+			IssuedAt:  jwt.NewNumericDate(time.Now()),
+			Issuer:    "go2gether",
+			Subject:   "mfa_challenge",
+		},
+	}
+
+	kr, err := keyRingFor(cfg)
+	if err != nil {
+		return "", err
+	}
+	return kr.Sign(PurposeMFA, claims)
+}
+
+// ValidateMFAChallengeToken validates and parses an MFA challenge token
+func ValidateMFAChallengeToken(tokenString string, cfg *config.JWTConfig) (*MFAChallengeClaims, error) {
+	kr, err := keyRingFor(cfg)
+	if err != nil {
+		return nil, err
+	}
+
+	token, err := kr.Parse(PurposeMFA, tokenString, &MFAChallengeClaims{})
+	if err != nil {
+		return nil, err
+	}
+
+	claims, ok := token.Claims.(*MFAChallengeClaims)
+	if !ok || !token.Valid || claims.Subject != "mfa_challenge" {
+		return nil, errors.New("invalid MFA challenge")
+	}
+	return claims, nil
+}
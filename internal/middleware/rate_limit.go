@@ -0,0 +1,181 @@
+package middleware
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log/slog"
+	"math"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+
+	"GO2GETHER_BACK-END/internal/utils"
+)
+
+// RateLimit allows Requests requests per Per window. The bucket holds at most
+// Requests tokens and refills continuously, so bursts up to Requests are allowed.
+type RateLimit struct {
+	Requests int
+	Per      time.Duration
+}
+
+// RateLimitStore keeps the token buckets. The in-memory store is per process;
+// plug in a shared store (e.g. Redis) when running several replicas.
+type RateLimitStore interface {
+	// Allow takes one token from the bucket of key. When the bucket is empty it
+	// returns false and how long until the next token is available.
+	Allow(ctx context.Context, key string, limit RateLimit) (bool, time.Duration, error)
+}
+
+// RateLimitKey extracts the value a limit is keyed by; "" skips the limit
+type RateLimitKey struct {
+	Name string
+	Key  func(r *http.Request) string
+}
+
+// Rate limit keys
+var (
+	// ByIP keys the limit by client IP address
+	ByIP = RateLimitKey{Name: "ip", Key: utils.ClientIP}
+	// ByEmail keys the limit by the "email" field of the JSON request body
+	ByEmail = RateLimitKey{Name: "email", Key: emailFromBody}
+	// ByUserID keys the limit by the authenticated user; wrap it inside AuthMiddleware
+	ByUserID = RateLimitKey{Name: "user", Key: func(r *http.Request) string {
+		if userID, ok := r.Context().Value("user_id").(uuid.UUID); ok {
+			return userID.String()
+		}
+		return ""
+	}}
+)
+
+// rateLimitStore is the store used by RateLimitMiddleware
+var rateLimitStore RateLimitStore = NewMemoryRateLimitStore()
+
+// UseRateLimitStore replaces the default in-memory store
+func UseRateLimitStore(s RateLimitStore) {
+	rateLimitStore = s
+}
+
+// RateLimitMiddleware rejects requests with 429 once any of the keyed buckets
+// of the named limit is empty. Store errors fail open so an outage of a shared
+// store does not take the auth endpoints down with it.
+func RateLimitMiddleware(next http.HandlerFunc, name string, limit RateLimit, keys ...RateLimitKey) http.HandlerFunc {
+	if limit.Requests <= 0 || limit.Per <= 0 {
+		return next
+	}
+
+	return func(w http.ResponseWriter, r *http.Request) {
+		for _, k := range keys {
+			value := k.Key(r)
+			if value == "" {
+				continue
+			}
+
+			allowed, retryAfter, err := rateLimitStore.Allow(r.Context(), name+":"+k.Name+":"+value, limit)
+			if err != nil {
+				slog.WarnContext(r.Context(), "rate limit store error", "limit", name, "error", err)
+				continue
+			}
+			if !allowed {
+				seconds := int(math.Ceil(retryAfter.Seconds()))
+				w.Header().Set("Retry-After", strconv.Itoa(seconds))
+				utils.WriteErrorResponse(w, http.StatusTooManyRequests, "Too many requests",
+					fmt.Sprintf("Too many attempts. Please try again in %d seconds", seconds))
+				return
+			}
+		}
+
+		next.ServeHTTP(w, r)
+	}
+}
+
+// emailFromBody reads the "email" field of a JSON body and restores the body
+// for the handler
+func emailFromBody(r *http.Request) string {
+	if r.Body == nil {
+		return ""
+	}
+	body, err := io.ReadAll(io.LimitReader(r.Body, 1<<20))
+	r.Body.Close()
+	r.Body = io.NopCloser(bytes.NewReader(body))
+	if err != nil {
+		return ""
+	}
+
+	var payload struct {
+		Email string `json:"email"`
+	}
+	if err := json.Unmarshal(body, &payload); err != nil {
+		return ""
+	}
+	return strings.ToLower(strings.TrimSpace(payload.Email))
+}
+
+// MemoryRateLimitStore is an in-process RateLimitStore
+type MemoryRateLimitStore struct {
+	mu        sync.Mutex
+	buckets   map[string]*tokenBucket
+	lastSweep time.Time
+}
+
+type tokenBucket struct {
+	tokens  float64
+	updated time.Time
+	idleTTL time.Duration // a bucket idle this long is full again and can be dropped
+}
+
+// NewMemoryRateLimitStore creates an empty in-memory store
+func NewMemoryRateLimitStore() *MemoryRateLimitStore {
+	return &MemoryRateLimitStore{
+		buckets:   make(map[string]*tokenBucket),
+		lastSweep: time.Now(),
+	}
+}
+
+// Allow implements RateLimitStore
+func (s *MemoryRateLimitStore) Allow(_ context.Context, key string, limit RateLimit) (bool, time.Duration, error) {
+	now := time.Now()
+	capacity := float64(limit.Requests)
+	perToken := limit.Per / time.Duration(limit.Requests)
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.sweep(now)
+
+	b, ok := s.buckets[key]
+	if !ok {
+		b = &tokenBucket{tokens: capacity, updated: now, idleTTL: limit.Per}
+		s.buckets[key] = b
+	} else {
+		refill := float64(now.Sub(b.updated)) / float64(perToken)
+		b.tokens = math.Min(capacity, b.tokens+refill)
+		b.updated = now
+	}
+
+	if b.tokens >= 1 {
+		b.tokens--
+		return true, 0, nil
+	}
+	return false, time.Duration((1 - b.tokens) * float64(perToken)), nil
+}
+
+// sweep drops idle buckets once a minute so the map does not grow without bound
+func (s *MemoryRateLimitStore) sweep(now time.Time) {
+	if now.Sub(s.lastSweep) < time.Minute {
+		return
+	}
+	for key, b := range s.buckets {
+		if now.Sub(b.updated) > b.idleTTL {
+			delete(s.buckets, key)
+		}
+	}
+	s.lastSweep = now
+}
@@ -0,0 +1,58 @@
+package middleware
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"net/http"
+
+	"GO2GETHER_BACK-END/internal/logging"
+	"GO2GETHER_BACK-END/internal/utils"
+)
+
+// maxRequestIDLen bounds a client-supplied request ID
+const maxRequestIDLen = 128
+
+// RequestID gives every request an ID: the X-Request-ID header of the request
+// when it is a sane token (set by a proxy or client), else a random one. The
+// ID is echoed in the response header, quoted in error bodies and stored in
+// the request context, where logging picks it up.
+func RequestID(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		id := r.Header.Get(utils.RequestIDHeader)
+		if !validRequestID(id) {
+			id = newRequestID()
+		}
+		w.Header().Set(utils.RequestIDHeader, id)
+		next.ServeHTTP(w, r.WithContext(logging.WithRequestID(r.Context(), id)))
+	})
+}
+
+// RequestIDFromContext returns the request ID set by RequestID, or ""
+func RequestIDFromContext(ctx context.Context) string {
+	return logging.RequestID(ctx)
+}
+
+func newRequestID() string {
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		return "unknown"
+	}
+	return hex.EncodeToString(b)
+}
+
+// validRequestID accepts IDs of letters, digits and -_.:
+func validRequestID(id string) bool {
+	if id == "" || len(id) > maxRequestIDLen {
+		return false
+	}
+	for _, c := range id {
+		switch {
+		case c >= 'a' && c <= 'z', c >= 'A' && c <= 'Z', c >= '0' && c <= '9':
+		case c == '-', c == '_', c == '.', c == ':':
+		default:
+			return false
+		}
+	}
+	return true
+}
@@ -34,24 +34,21 @@ func GenerateResetToken(userID uuid.UUID, email, code string, cfg *config.JWTCon
 		},
 	}
 
-	token := jwt.NewWithClaims(jwt.SigningMethodHS256, claims)
-	tokenString, err := token.SignedString([]byte(cfg.Secret))
+	kr, err := keyRingFor(cfg)
 	if err != nil {
 		return "", err
 	}
-
-	return tokenString, nil
+	return kr.Sign(PurposeReset, claims)
 }
 
 // ValidateResetToken validates and parses the reset token
 func ValidateResetToken(tokenString string, cfg *config.JWTConfig) (*ResetTokenClaims, error) {
-	token, err := jwt.ParseWithClaims(tokenString, &ResetTokenClaims{}, func(token *jwt.Token) (interface{}, error) {
-		if _, ok := token.Method.(*jwt.SigningMethodHMAC); !ok {
-			return nil, errors.New("invalid signing method")
-		}
-		return []byte(cfg.Secret), nil
-	})
+	kr, err := keyRingFor(cfg)
+	if err != nil {
+		return nil, err
+	}
 
+	token, err := kr.Parse(PurposeReset, tokenString, &ResetTokenClaims{})
 	if err != nil {
 		return nil, err
 	}
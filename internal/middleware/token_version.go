@@ -0,0 +1,92 @@
+package middleware
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+// ErrTokenUserNotFound is returned when the token's user no longer exists
+var ErrTokenUserNotFound = errors.New("token user not found")
+
+// TokenVersionCache caches users.token_version in-process so AuthMiddleware
+// does not hit the database on every request. Bumping the version in the
+// database invalidates every access token issued before the bump; other
+// replicas notice within the cache TTL.
+type TokenVersionCache struct {
+	db  *pgxpool.Pool
+	ttl time.Duration
+
+	mu      sync.RWMutex
+	entries map[uuid.UUID]tokenVersionEntry
+}
+
+type tokenVersionEntry struct {
+	version   int
+	expiresAt time.Time
+}
+
+// NewTokenVersionCache creates a cache backed by the users table
+func NewTokenVersionCache(db *pgxpool.Pool, ttl time.Duration) *TokenVersionCache {
+	return &TokenVersionCache{
+		db:      db,
+		ttl:     ttl,
+		entries: make(map[uuid.UUID]tokenVersionEntry),
+	}
+}
+
+// Version returns the current token version of the user
+func (c *TokenVersionCache) Version(ctx context.Context, userID uuid.UUID) (int, error) {
+	now := time.Now()
+
+	c.mu.RLock()
+	entry, ok := c.entries[userID]
+	c.mu.RUnlock()
+	if ok && now.Before(entry.expiresAt) {
+		return entry.version, nil
+	}
+
+	var version int
+	err := c.db.QueryRow(ctx, `SELECT token_version FROM users WHERE id = $1`, userID).Scan(&version)
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			c.Invalidate(userID)
+			return 0, ErrTokenUserNotFound
+		}
+		return 0, err
+	}
+
+	c.mu.Lock()
+	c.entries[userID] = tokenVersionEntry{version: version, expiresAt: now.Add(c.ttl)}
+	c.mu.Unlock()
+
+	return version, nil
+}
+
+// Invalidate drops the cached version of the user
+func (c *TokenVersionCache) Invalidate(userID uuid.UUID) {
+	c.mu.Lock()
+	delete(c.entries, userID)
+	c.mu.Unlock()
+}
+
+// tokenVersions is the cache consulted by AuthMiddleware; nil disables the check
+var tokenVersions *TokenVersionCache
+
+// UseTokenVersionCache enables token version checks in AuthMiddleware
+func UseTokenVersionCache(c *TokenVersionCache) {
+	tokenVersions = c
+}
+
+// InvalidateTokenVersion must be called after users.token_version is bumped
+// so this process stops accepting old tokens immediately
+func InvalidateTokenVersion(userID uuid.UUID) {
+	if tokenVersions != nil {
+		tokenVersions.Invalidate(userID)
+	}
+}
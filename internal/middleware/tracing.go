@@ -0,0 +1,41 @@
+package middleware
+
+import (
+	"net/http"
+
+	"go.opentelemetry.io/contrib/instrumentation/net/http/otelhttp"
+	"go.opentelemetry.io/otel/attribute"
+	semconv "go.opentelemetry.io/otel/semconv/v1.34.0"
+	"go.opentelemetry.io/otel/trace"
+
+	"GO2GETHER_BACK-END/internal/logging"
+)
+
+// Tracing starts a server span per request, continuing a trace from the
+// traceparent header. Once the router has matched, the span is named after
+// the route ("GET /api/trips/{trip_id}") rather than the raw path. It must
+// run inside RequestID; probes and metric scrapes are not traced.
+func Tracing(next http.Handler) http.Handler {
+	annotated := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		next.ServeHTTP(w, r)
+
+		span := trace.SpanFromContext(r.Context())
+		span.SetAttributes(attribute.String("request.id", logging.RequestID(r.Context())))
+		if route := logging.Route(r.Context()); route != "" {
+			span.SetAttributes(semconv.HTTPRoute(route))
+		}
+		if uid := logging.UserID(r.Context()); uid != "" {
+			span.SetAttributes(semconv.UserID(uid))
+		}
+	})
+	// otelhttp names the span again after serving when the mux matched
+	return otelhttp.NewHandler(annotated, "http.server",
+		otelhttp.WithFilter(func(r *http.Request) bool { return !probePaths[r.URL.Path] }),
+		otelhttp.WithSpanNameFormatter(func(_ string, r *http.Request) string {
+			if route := logging.Route(r.Context()); route != "" {
+				return r.Method + " " + route
+			}
+			return r.Method
+		}),
+	)
+}
@@ -0,0 +1,16 @@
+-- Drops the whole initial schema, including all user data.
+
+DROP TABLE IF EXISTS available_periods;
+DROP TABLE IF EXISTS availabilities;
+DROP TYPE IF EXISTS availability_status;
+DROP TABLE IF EXISTS notifications;
+DROP TABLE IF EXISTS trip_members;
+DROP TABLE IF EXISTS trips;
+DROP TABLE IF EXISTS profiles;
+DROP TABLE IF EXISTS auth_sessions;
+DROP TABLE IF EXISTS oauth_auth_codes;
+DROP TABLE IF EXISTS user_identities;
+DROP TABLE IF EXISTS mfa_recovery_codes;
+DROP TABLE IF EXISTS auth_verifications;
+DROP TABLE IF EXISTS users;
+DROP FUNCTION IF EXISTS update_updated_at_column();
@@ -1,3 +1,7 @@
+-- Initial schema: everything the API used before versioned migrations.
+-- Written with IF NOT EXISTS so databases set up from the old schema.sql and
+-- migration_add_*.sql scripts can adopt the migration history as is.
+
 -- Create users table
 CREATE TABLE IF NOT EXISTS users (
     id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
@@ -7,6 +11,31 @@ CREATE TABLE IF NOT EXISTS users (
     updated_at TIMESTAMP WITH TIME ZONE DEFAULT NOW()
 );
 
+-- Auth columns added after the original schema.sql. They are added with ALTER
+-- so a users table created by that schema gets them too.
+ALTER TABLE users ADD COLUMN IF NOT EXISTS token_version INTEGER NOT NULL DEFAULT 0;         -- bumped to revoke every issued access token
+ALTER TABLE users ADD COLUMN IF NOT EXISTS failed_login_attempts INTEGER NOT NULL DEFAULT 0; -- consecutive wrong passwords
+ALTER TABLE users ADD COLUMN IF NOT EXISTS locked_until TIMESTAMP WITH TIME ZONE;            -- login refused until this time
+ALTER TABLE users ADD COLUMN IF NOT EXISTS totp_secret TEXT;                                 -- encrypted TOTP seed; set during enrolment
+ALTER TABLE users ADD COLUMN IF NOT EXISTS totp_enabled_at TIMESTAMP WITH TIME ZONE;         -- NULL until enrolment is confirmed
+ALTER TABLE users ADD COLUMN IF NOT EXISTS totp_last_step BIGINT NOT NULL DEFAULT 0;         -- last accepted time step (replay protection)
+
+-- email_verified_at is NULL until the address is confirmed. Accounts that
+-- predate verification are treated as verified, so they are not locked out
+-- when REQUIRE_VERIFIED_EMAIL is enabled and existing Google users are linked
+-- on their next sign-in. The backfill only runs when the column is new, so
+-- unverified sign-ups on a database that already had it stay unverified.
+DO $$
+BEGIN
+    IF NOT EXISTS (
+        SELECT 1 FROM information_schema.columns
+        WHERE table_schema = current_schema() AND table_name = 'users' AND column_name = 'email_verified_at'
+    ) THEN
+        ALTER TABLE users ADD COLUMN email_verified_at TIMESTAMP WITH TIME ZONE;
+        UPDATE users SET email_verified_at = created_at WHERE email_verified_at IS NULL;
+    END IF;
+END $$;
+
 -- Create indexes for better performance
 CREATE INDEX IF NOT EXISTS idx_users_email ON users(email);
 
@@ -20,10 +49,17 @@ END;
 $$ language 'plpgsql';
 
 -- Create trigger to automatically update updated_at
-CREATE TRIGGER update_users_updated_at 
-    BEFORE UPDATE ON users 
-    FOR EACH ROW 
-    EXECUTE FUNCTION update_updated_at_column();
+DO $$
+BEGIN
+    IF NOT EXISTS (
+        SELECT 1 FROM pg_trigger WHERE tgname = 'update_users_updated_at'
+    ) THEN
+        CREATE TRIGGER update_users_updated_at
+            BEFORE UPDATE ON users
+            FOR EACH ROW
+            EXECUTE FUNCTION update_updated_at_column();
+    END IF;
+END $$;
 
 -- Create auth_verifications table for OTP/verification codes
 CREATE TABLE IF NOT EXISTS auth_verifications (
@@ -37,12 +73,88 @@ CREATE TABLE IF NOT EXISTS auth_verifications (
     created_at TIMESTAMP WITH TIME ZONE DEFAULT NOW()
 );
 
+ALTER TABLE auth_verifications ADD COLUMN IF NOT EXISTS attempts INTEGER NOT NULL DEFAULT 0; -- wrong guesses; the code is invalidated at the limit
+
 -- Create indexes for auth_verifications
 CREATE INDEX IF NOT EXISTS idx_auth_verifications_user_id ON auth_verifications(user_id);
 CREATE INDEX IF NOT EXISTS idx_auth_verifications_email ON auth_verifications(email);
 CREATE INDEX IF NOT EXISTS idx_auth_verifications_code ON auth_verifications(code);
 CREATE INDEX IF NOT EXISTS idx_auth_verifications_expires_at ON auth_verifications(expires_at);
 
+-- ---------------------------------------------------------------------------
+-- MFA Recovery Codes
+-- ---------------------------------------------------------------------------
+-- One-time codes for signing in without the authenticator app. Only hashes
+-- are stored; a new set replaces the old one.
+CREATE TABLE IF NOT EXISTS mfa_recovery_codes (
+    id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+    user_id UUID NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    code_hash TEXT NOT NULL,
+    used_at TIMESTAMP WITH TIME ZONE,
+    created_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT NOW()
+);
+
+CREATE INDEX IF NOT EXISTS idx_mfa_recovery_codes_user_id ON mfa_recovery_codes(user_id);
+
+-- ---------------------------------------------------------------------------
+-- User Identities (external sign-in providers)
+-- ---------------------------------------------------------------------------
+-- Links a provider account, by its stable subject ID, to a user. A user has at
+-- most one account per provider.
+CREATE TABLE IF NOT EXISTS user_identities (
+    id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+    user_id UUID NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    provider VARCHAR(50) NOT NULL,
+    subject VARCHAR(255) NOT NULL,
+    email VARCHAR(255) NOT NULL,
+    email_verified BOOLEAN NOT NULL DEFAULT FALSE,
+    created_at TIMESTAMP WITH TIME ZONE DEFAULT NOW(),
+    last_login_at TIMESTAMP WITH TIME ZONE,
+    CONSTRAINT user_identities_provider_subject_key UNIQUE (provider, subject),
+    CONSTRAINT user_identities_user_id_provider_key UNIQUE (user_id, provider)
+);
+
+CREATE INDEX IF NOT EXISTS idx_user_identities_user_id ON user_identities(user_id);
+
+-- ---------------------------------------------------------------------------
+-- OAuth one-time auth codes
+-- ---------------------------------------------------------------------------
+-- Issued by the OAuth callback instead of putting tokens in the redirect URL;
+-- the frontend exchanges the code once within a minute.
+CREATE TABLE IF NOT EXISTS oauth_auth_codes (
+    code_hash VARCHAR(64) PRIMARY KEY,                -- sha256 hex of the code
+    user_id UUID NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    provider VARCHAR(50) NOT NULL,
+    expires_at TIMESTAMP WITH TIME ZONE NOT NULL,
+    created_at TIMESTAMP WITH TIME ZONE DEFAULT NOW()
+);
+
+CREATE INDEX IF NOT EXISTS idx_oauth_auth_codes_expires_at ON oauth_auth_codes(expires_at);
+
+-- ---------------------------------------------------------------------------
+-- Auth Sessions (refresh tokens)
+-- ---------------------------------------------------------------------------
+-- One row per issued refresh token. Rotation revokes the old row and inserts a
+-- new one in the same family; reuse of a rotated token revokes the family.
+CREATE TABLE IF NOT EXISTS auth_sessions (
+    id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+    user_id UUID NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    family_id UUID NOT NULL,
+    token_hash VARCHAR(64) UNIQUE NOT NULL,           -- sha256 hex of the refresh token
+    user_agent VARCHAR(512),
+    ip_address VARCHAR(64),
+    expires_at TIMESTAMP WITH TIME ZONE NOT NULL,
+    revoked_at TIMESTAMP WITH TIME ZONE NULL,
+    revoked_reason VARCHAR(50) NULL,                  -- rotated | logout | logout_all | reuse_detected
+    replaced_by UUID NULL REFERENCES auth_sessions(id) ON DELETE SET NULL,
+    last_used_at TIMESTAMP WITH TIME ZONE NULL,
+    created_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT NOW()
+);
+
+CREATE INDEX IF NOT EXISTS idx_auth_sessions_user_id ON auth_sessions(user_id);
+CREATE INDEX IF NOT EXISTS idx_auth_sessions_family_id ON auth_sessions(family_id);
+CREATE INDEX IF NOT EXISTS idx_auth_sessions_expires_at ON auth_sessions(expires_at);
+
 -- ---------------------------------------------------------------------------
 -- Profiles
 -- ---------------------------------------------------------------------------
@@ -102,6 +214,8 @@ CREATE TABLE IF NOT EXISTS trips (
     updated_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT NOW()
 );
 
+ALTER TABLE trips ADD COLUMN IF NOT EXISTS require_organizer_mfa BOOLEAN NOT NULL DEFAULT FALSE; -- co-organizers need two-factor auth to manage the trip
+
 -- Trigger to auto-update updated_at on trips
 DO $$
 BEGIN
@@ -161,7 +275,12 @@ CREATE INDEX IF NOT EXISTS idx_notifications_type ON notifications(type);
 -- ---------------------------------------------------------------------------
 -- Availabilities
 -- ---------------------------------------------------------------------------
-CREATE TYPE availability_status AS ENUM ('free', 'flexible', 'busy');
+DO $$
+BEGIN
+    IF NOT EXISTS (SELECT 1 FROM pg_type WHERE typname = 'availability_status') THEN
+        CREATE TYPE availability_status AS ENUM ('free', 'flexible', 'busy');
+    END IF;
+END $$;
 
 CREATE TABLE IF NOT EXISTS availabilities (
     id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
@@ -0,0 +1,14 @@
+-- Budget breakdown of a trip per category. The API keeps a single row per
+-- trip (order_index = 1) that CreateTrip/UpdateTrip upsert.
+
+CREATE TABLE IF NOT EXISTS budget_categories (
+    trip_id UUID NOT NULL REFERENCES trips(id) ON DELETE CASCADE,
+    order_index INTEGER NOT NULL DEFAULT 1,
+    food DOUBLE PRECISION NOT NULL DEFAULT 0,
+    hotel DOUBLE PRECISION NOT NULL DEFAULT 0,
+    shopping DOUBLE PRECISION NOT NULL DEFAULT 0,
+    transport DOUBLE PRECISION NOT NULL DEFAULT 0,
+    created_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT NOW(),
+    updated_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT NOW(),
+    PRIMARY KEY (trip_id, order_index)
+);
@@ -0,0 +1 @@
+ALTER TABLE available_periods DROP COLUMN IF EXISTS rank;
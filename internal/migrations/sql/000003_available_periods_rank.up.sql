@@ -0,0 +1,4 @@
+-- Ranking of generated periods (1 = best). Present on databases created by
+-- hand; GenerateAvailablePeriods does not write it yet.
+
+ALTER TABLE available_periods ADD COLUMN IF NOT EXISTS rank INTEGER;
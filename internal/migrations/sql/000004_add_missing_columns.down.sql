@@ -0,0 +1,2 @@
+ALTER TABLE users DROP COLUMN IF EXISTS role;
+ALTER TABLE trip_members DROP COLUMN IF EXISTS invited_by;
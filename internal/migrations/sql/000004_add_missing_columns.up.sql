@@ -0,0 +1,5 @@
+-- Columns the application already reads and writes but the initial schema
+-- did not create: who invited a trip member, and the account role returned
+-- with the profile.
+ALTER TABLE trip_members ADD COLUMN IF NOT EXISTS invited_by UUID NULL REFERENCES users(id) ON DELETE SET NULL;
+ALTER TABLE users ADD COLUMN IF NOT EXISTS role VARCHAR(50) NOT NULL DEFAULT 'user';
@@ -0,0 +1,5 @@
+DROP INDEX IF EXISTS idx_trip_members_owner;
+ALTER TABLE trip_members DROP CONSTRAINT IF EXISTS trip_members_role_check;
+
+UPDATE trip_members SET role = 'creator' WHERE role IN ('owner', 'co_organizer');
+UPDATE trip_members SET role = 'member' WHERE role = 'viewer';
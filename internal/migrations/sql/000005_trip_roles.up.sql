@@ -0,0 +1,16 @@
+-- Trip roles: owner | co_organizer | member | viewer (see internal/policy).
+-- The creator's membership becomes owner; other members with the old
+-- "creator" role were co-organizers.
+UPDATE trip_members tm
+   SET role = 'owner'
+  FROM trips t
+ WHERE t.id = tm.trip_id AND t.creator_id = tm.user_id;
+
+UPDATE trip_members SET role = 'co_organizer' WHERE role = 'creator';
+UPDATE trip_members SET role = 'member' WHERE role NOT IN ('owner', 'co_organizer', 'member', 'viewer');
+
+ALTER TABLE trip_members
+    ADD CONSTRAINT trip_members_role_check CHECK (role IN ('owner', 'co_organizer', 'member', 'viewer'));
+
+-- One owner per trip
+CREATE UNIQUE INDEX IF NOT EXISTS idx_trip_members_owner ON trip_members(trip_id) WHERE role = 'owner';
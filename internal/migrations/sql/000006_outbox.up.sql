@@ -0,0 +1,48 @@
+-- Transactional outbox: domain events are inserted in the same transaction as
+-- the change they report; the dispatcher (internal/outbox) delivers them to
+-- their channels (in-app, email) and retries until every channel succeeded.
+CREATE TABLE IF NOT EXISTS outbox_events (
+    id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+    type VARCHAR(50) NOT NULL,
+    recipient_id UUID NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    -- no foreign key: the event outlives a deleted trip
+    trip_id UUID NULL,
+    payload JSONB NOT NULL,
+    channels TEXT[] NOT NULL,
+    -- identifies the change the event reports; adding it twice is a no-op
+    idempotency_key VARCHAR(255) NOT NULL UNIQUE,
+    attempts INT NOT NULL DEFAULT 0,
+    -- due time; a claim pushes it forward by the lease
+    available_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT NOW(),
+    last_error TEXT NULL,
+    processed_at TIMESTAMP WITH TIME ZONE NULL,
+    created_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT NOW()
+);
+
+CREATE INDEX IF NOT EXISTS idx_outbox_events_due ON outbox_events(available_at) WHERE processed_at IS NULL;
+CREATE INDEX IF NOT EXISTS idx_outbox_events_processed_at ON outbox_events(processed_at) WHERE processed_at IS NOT NULL;
+
+-- Channels an event was delivered on, so a retry skips them
+CREATE TABLE IF NOT EXISTS outbox_deliveries (
+    event_id UUID NOT NULL REFERENCES outbox_events(id) ON DELETE CASCADE,
+    channel VARCHAR(50) NOT NULL,
+    delivered_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT NOW(),
+    PRIMARY KEY (event_id, channel)
+);
+
+-- Events given up after a permanent error or the last attempt
+CREATE TABLE IF NOT EXISTS outbox_dead_letters (
+    id UUID PRIMARY KEY,
+    type VARCHAR(50) NOT NULL,
+    recipient_id UUID NOT NULL,
+    trip_id UUID NULL,
+    payload JSONB NOT NULL,
+    channels TEXT[] NOT NULL,
+    idempotency_key VARCHAR(255) NOT NULL,
+    attempts INT NOT NULL,
+    error TEXT NOT NULL,
+    created_at TIMESTAMP WITH TIME ZONE NOT NULL,
+    failed_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT NOW()
+);
+
+CREATE INDEX IF NOT EXISTS idx_outbox_dead_letters_failed_at ON outbox_dead_letters(failed_at);
@@ -0,0 +1,4 @@
+DROP INDEX IF EXISTS idx_notifications_user_trip;
+ALTER TABLE notifications DROP COLUMN IF EXISTS trip_id;
+DROP TABLE IF EXISTS trip_notification_preferences;
+DROP TABLE IF EXISTS notification_preferences;
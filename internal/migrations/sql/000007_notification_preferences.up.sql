@@ -0,0 +1,42 @@
+-- Notification preferences: the channels a user receives notifications on,
+-- notification types they turned off and quiet hours in their timezone,
+-- consulted before a notification is routed. Users without a row get the
+-- defaults (every channel, no quiet hours, UTC).
+CREATE TABLE IF NOT EXISTS notification_preferences (
+    user_id UUID PRIMARY KEY REFERENCES users(id) ON DELETE CASCADE,
+    in_app BOOLEAN NOT NULL DEFAULT TRUE,
+    email BOOLEAN NOT NULL DEFAULT TRUE,
+    push BOOLEAN NOT NULL DEFAULT TRUE,
+    muted_types TEXT[] NOT NULL DEFAULT '{}',
+    -- local times in timezone; start after end spans midnight
+    quiet_hours_start TIME NULL,
+    quiet_hours_end TIME NULL,
+    timezone VARCHAR(64) NOT NULL DEFAULT 'UTC',
+    updated_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT NOW(),
+    CONSTRAINT notification_preferences_quiet_hours_check
+        CHECK ((quiet_hours_start IS NULL) = (quiet_hours_end IS NULL))
+);
+
+-- Per-trip settings: a muted trip sends nothing; a NULL channel follows the
+-- user's preference
+CREATE TABLE IF NOT EXISTS trip_notification_preferences (
+    user_id UUID NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    trip_id UUID NOT NULL REFERENCES trips(id) ON DELETE CASCADE,
+    muted BOOLEAN NOT NULL DEFAULT FALSE,
+    in_app BOOLEAN NULL,
+    email BOOLEAN NULL,
+    push BOOLEAN NULL,
+    updated_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT NOW(),
+    PRIMARY KEY (user_id, trip_id)
+);
+
+-- The trip a notification is about, so it can be matched with the trip's
+-- preferences; older rows only carried it in data
+ALTER TABLE notifications ADD COLUMN IF NOT EXISTS trip_id UUID NULL;
+
+UPDATE notifications
+   SET trip_id = (data->>'trip_id')::uuid
+ WHERE trip_id IS NULL
+   AND data->>'trip_id' ~* '^[0-9a-f]{8}-[0-9a-f]{4}-[0-9a-f]{4}-[0-9a-f]{4}-[0-9a-f]{12}$';
+
+CREATE INDEX IF NOT EXISTS idx_notifications_user_trip ON notifications(user_id, trip_id);
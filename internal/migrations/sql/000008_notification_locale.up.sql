@@ -0,0 +1,2 @@
+-- Language of the emails a user receives; NULL follows EMAIL_DEFAULT_LOCALE
+ALTER TABLE notification_preferences ADD COLUMN IF NOT EXISTS locale VARCHAR(8) NULL;
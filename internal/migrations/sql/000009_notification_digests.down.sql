@@ -0,0 +1,6 @@
+DROP INDEX IF EXISTS idx_notifications_digest;
+ALTER TABLE notifications DROP COLUMN IF EXISTS digested_at;
+ALTER TABLE notification_preferences
+    DROP CONSTRAINT IF EXISTS notification_preferences_digest_frequency_check,
+    DROP COLUMN IF EXISTS last_digest_at,
+    DROP COLUMN IF EXISTS digest_frequency;
@@ -0,0 +1,16 @@
+-- Digest emails: users may get their unread notifications as one summary
+-- email per day or week instead of reading them one by one. last_digest_at
+-- is the scheduled time of the latest digest, claimed before it is sent so
+-- replicas do not send it twice.
+ALTER TABLE notification_preferences
+    ADD COLUMN IF NOT EXISTS digest_frequency VARCHAR(10) NOT NULL DEFAULT 'off',
+    ADD COLUMN IF NOT EXISTS last_digest_at TIMESTAMP WITH TIME ZONE NULL,
+    ADD CONSTRAINT notification_preferences_digest_frequency_check
+        CHECK (digest_frequency IN ('off', 'daily', 'weekly'));
+
+-- When a notification went out in a digest; each is summarized once
+ALTER TABLE notifications ADD COLUMN IF NOT EXISTS digested_at TIMESTAMP WITH TIME ZONE NULL;
+
+CREATE INDEX IF NOT EXISTS idx_notifications_digest
+    ON notifications (user_id, created_at)
+    WHERE read = false AND digested_at IS NULL;
@@ -8,9 +8,41 @@ import (
 
 // User represents a user in the system
 type User struct {
-	ID           uuid.UUID `json:"id" db:"id"`
-	Email        string    `json:"email" db:"email"`
-	PasswordHash string    `json:"-" db:"password_hash"` // Hidden from JSON responses
-	CreatedAt    time.Time `json:"created_at" db:"created_at"`
-	UpdatedAt    time.Time `json:"updated_at" db:"updated_at"`
+	ID              uuid.UUID  `json:"id" db:"id"`
+	Email           string     `json:"email" db:"email"`
+	PasswordHash    string     `json:"-" db:"password_hash"` // Hidden from JSON responses
+	EmailVerifiedAt *time.Time `json:"email_verified_at,omitempty" db:"email_verified_at"`
+	TOTPEnabledAt   *time.Time `json:"totp_enabled_at,omitempty" db:"totp_enabled_at"`
+	CreatedAt       time.Time  `json:"created_at" db:"created_at"`
+	UpdatedAt       time.Time  `json:"updated_at" db:"updated_at"`
+}
+
+// Session represents a refresh-token session issued to a user.
+// Every rotation creates a new row in the same family; TokenHash stores
+// the SHA-256 of the opaque refresh token, never the token itself.
+type Session struct {
+	ID            uuid.UUID  `json:"id" db:"id"`
+	UserID        uuid.UUID  `json:"user_id" db:"user_id"`
+	FamilyID      uuid.UUID  `json:"family_id" db:"family_id"`
+	TokenHash     string     `json:"-" db:"token_hash"`
+	UserAgent     string     `json:"user_agent" db:"user_agent"`
+	IPAddress     string     `json:"ip_address" db:"ip_address"`
+	ExpiresAt     time.Time  `json:"expires_at" db:"expires_at"`
+	RevokedAt     *time.Time `json:"revoked_at,omitempty" db:"revoked_at"`
+	RevokedReason *string    `json:"revoked_reason,omitempty" db:"revoked_reason"`
+	ReplacedBy    *uuid.UUID `json:"replaced_by,omitempty" db:"replaced_by"`
+	CreatedAt     time.Time  `json:"created_at" db:"created_at"`
+}
+
+// UserIdentity links an external sign-in provider account (by its stable
+// subject ID, not its email) to a user
+type UserIdentity struct {
+	ID            uuid.UUID  `json:"id" db:"id"`
+	UserID        uuid.UUID  `json:"user_id" db:"user_id"`
+	Provider      string     `json:"provider" db:"provider"`
+	Subject       string     `json:"subject" db:"subject"`
+	Email         string     `json:"email" db:"email"`
+	EmailVerified bool       `json:"email_verified" db:"email_verified"`
+	CreatedAt     time.Time  `json:"created_at" db:"created_at"`
+	LastLoginAt   *time.Time `json:"last_login_at,omitempty" db:"last_login_at"`
 }
@@ -0,0 +1,57 @@
+package models
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// NotificationType is the notifications.type column
+type NotificationType string
+
+const (
+	NotificationTripInvitation     NotificationType = "trip_invitation"
+	NotificationInvitationAccepted NotificationType = "invitation_accepted"
+	NotificationInvitationDeclined NotificationType = "invitation_declined"
+	NotificationTripUpdate         NotificationType = "trip_update"
+	NotificationAvailability       NotificationType = "availability_updated"
+	NotificationMemberJoined       NotificationType = "member_joined"
+	NotificationMemberLeft         NotificationType = "member_left"
+)
+
+// NotificationTypes lists the known notification types
+var NotificationTypes = []NotificationType{
+	NotificationTripInvitation,
+	NotificationInvitationAccepted,
+	NotificationInvitationDeclined,
+	NotificationTripUpdate,
+	NotificationAvailability,
+	NotificationMemberJoined,
+	NotificationMemberLeft,
+}
+
+// Valid reports whether t is one of NotificationTypes
+func (t NotificationType) Valid() bool {
+	for _, known := range NotificationTypes {
+		if t == known {
+			return true
+		}
+	}
+	return false
+}
+
+// Notification is an in-app notification of a user
+type Notification struct {
+	ID        uuid.UUID      `json:"id" db:"id"`
+	UserID    uuid.UUID      `json:"user_id" db:"user_id"`
+	TripID    *uuid.UUID     `json:"trip_id,omitempty" db:"trip_id"` // the trip it is about, if any
+	Type      string         `json:"type" db:"type"`
+	Title     string         `json:"title" db:"title"`
+	Message   *string        `json:"message,omitempty" db:"message"`
+	Data      map[string]any `json:"data,omitempty" db:"data"`
+	ActionURL *string        `json:"action_url,omitempty" db:"action_url"`
+	Read      bool           `json:"read" db:"read"`
+	CreatedAt time.Time      `json:"created_at" db:"created_at"`
+	// DigestedAt is when it was summarized in a digest email, if ever
+	DigestedAt *time.Time `json:"digested_at,omitempty" db:"digested_at"`
+}
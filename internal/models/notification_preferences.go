@@ -0,0 +1,242 @@
+package models
+
+import (
+	"slices"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// ChannelPush is the mobile push channel users can turn off. Events are not
+// routed to it yet; the setting is kept for the apps.
+const ChannelPush = "push"
+
+// DefaultTimezone is the timezone of users who have not set one
+const DefaultTimezone = "UTC"
+
+// Digest frequencies: how often a user gets their unread notifications as
+// one summary email
+const (
+	DigestOff    = "off"
+	DigestDaily  = "daily"
+	DigestWeekly = "weekly"
+)
+
+// DigestTypes are the routine notifications a digest summarizes. While a
+// user's digest is on they are not emailed one by one; other types
+// (removals, role changes, new suggested periods) are still emailed right
+// away and are left out of the digest, so nothing is emailed twice.
+var DigestTypes = []NotificationType{
+	NotificationAvailability,
+	NotificationMemberJoined,
+	NotificationMemberLeft,
+	NotificationInvitationAccepted,
+	NotificationInvitationDeclined,
+}
+
+// QuietHours is a daily window, in minutes after local midnight, during which
+// notifications are delivered silently. Start after End spans midnight.
+type QuietHours struct {
+	Start int `json:"start"`
+	End   int `json:"end"`
+}
+
+// Contains reports whether the local time of day t falls inside q
+func (q QuietHours) Contains(t time.Time) bool {
+	m := t.Hour()*60 + t.Minute()
+	if q.Start <= q.End {
+		return m >= q.Start && m < q.End
+	}
+	return m >= q.Start || m < q.End
+}
+
+// NotificationPreferences are a user's notification settings (table
+// notification_preferences)
+type NotificationPreferences struct {
+	UserID     uuid.UUID          `json:"user_id"`
+	InApp      bool               `json:"in_app"`
+	Email      bool               `json:"email"`
+	Push       bool               `json:"push"`
+	MutedTypes []NotificationType `json:"muted_types"`
+	QuietHours *QuietHours        `json:"quiet_hours,omitempty"`
+	Timezone   string             `json:"timezone"`
+	// Locale is the language of the user's emails; empty means the default
+	Locale string `json:"locale,omitempty"`
+	// DigestFrequency is DigestOff, DigestDaily or DigestWeekly
+	DigestFrequency string `json:"digest_frequency"`
+	// LastDigestAt is the scheduled time of the latest digest, nil before the
+	// first; it is not changed by Save
+	LastDigestAt *time.Time `json:"last_digest_at,omitempty"`
+	UpdatedAt    time.Time  `json:"updated_at"`
+}
+
+// DefaultNotificationPreferences are the settings of a user who saved none:
+// every channel on, no quiet hours
+func DefaultNotificationPreferences(userID uuid.UUID) NotificationPreferences {
+	return NotificationPreferences{
+		UserID:          userID,
+		InApp:           true,
+		Email:           true,
+		Push:            true,
+		MutedTypes:      []NotificationType{},
+		Timezone:        DefaultTimezone,
+		DigestFrequency: DigestOff,
+	}
+}
+
+// Location is the user's timezone; an unknown zone falls back to UTC
+func (p NotificationPreferences) Location() *time.Location {
+	if loc, err := time.LoadLocation(p.Timezone); err == nil {
+		return loc
+	}
+	return time.UTC
+}
+
+// QuietUntil reports whether now is within the user's quiet hours and, if
+// so, when they end
+func (p NotificationPreferences) QuietUntil(now time.Time) (time.Time, bool) {
+	if p.QuietHours == nil || p.QuietHours.Start == p.QuietHours.End {
+		return time.Time{}, false
+	}
+	local := now.In(p.Location())
+	if !p.QuietHours.Contains(local) {
+		return time.Time{}, false
+	}
+	end := time.Date(local.Year(), local.Month(), local.Day(),
+		p.QuietHours.End/60, p.QuietHours.End%60, 0, 0, local.Location())
+	if !end.After(local) {
+		end = end.AddDate(0, 0, 1)
+	}
+	return end, true
+}
+
+// DigestSlot is the scheduled time of the user's latest digest at or before
+// now: hour o'clock in their timezone, every day or, for weekly digests,
+// every weekday. ok is false when digests are off.
+func (p NotificationPreferences) DigestSlot(now time.Time, hour int, weekday time.Weekday) (slot time.Time, ok bool) {
+	if p.DigestFrequency != DigestDaily && p.DigestFrequency != DigestWeekly {
+		return time.Time{}, false
+	}
+	local := now.In(p.Location())
+	slot = time.Date(local.Year(), local.Month(), local.Day(), hour, 0, 0, 0, local.Location())
+	if slot.After(local) {
+		slot = slot.AddDate(0, 0, -1)
+	}
+	if p.DigestFrequency == DigestWeekly {
+		back := (int(slot.Weekday()) - int(weekday) + 7) % 7
+		slot = slot.AddDate(0, 0, -back)
+	}
+	return slot, true
+}
+
+// DigestPeriod is the span one digest of the user covers
+func (p NotificationPreferences) DigestPeriod() time.Duration {
+	if p.DigestFrequency == DigestWeekly {
+		return 7 * 24 * time.Hour
+	}
+	return 24 * time.Hour
+}
+
+// Digests reports whether notifications of typ go to the user's digest
+// instead of being emailed one by one
+func (p NotificationPreferences) Digests(typ NotificationType) bool {
+	return (p.DigestFrequency == DigestDaily || p.DigestFrequency == DigestWeekly) &&
+		slices.Contains(DigestTypes, typ)
+}
+
+// DigestDue reports whether the digest of slot has not been sent yet
+func (p NotificationPreferences) DigestDue(slot time.Time) bool {
+	return p.LastDigestAt == nil || p.LastDigestAt.Before(slot)
+}
+
+// TripNotificationPreferences override a user's preferences for one trip
+// (table trip_notification_preferences). A nil channel follows the user's
+// preference.
+type TripNotificationPreferences struct {
+	UserID    uuid.UUID `json:"user_id"`
+	TripID    uuid.UUID `json:"trip_id"`
+	Muted     bool      `json:"muted"`
+	InApp     *bool     `json:"in_app,omitempty"`
+	Email     *bool     `json:"email,omitempty"`
+	Push      *bool     `json:"push,omitempty"`
+	UpdatedAt time.Time `json:"updated_at"`
+}
+
+// IsDefault reports whether t changes nothing, so it need not be stored
+func (t TripNotificationPreferences) IsDefault() bool {
+	return !t.Muted && t.InApp == nil && t.Email == nil && t.Push == nil
+}
+
+// Allows reports whether a notification of typ about a trip with the
+// settings trip (nil when none, or not about a trip) goes out on channel
+func (p NotificationPreferences) Allows(channel string, typ NotificationType, trip *TripNotificationPreferences) bool {
+	if slices.Contains(p.MutedTypes, typ) {
+		return false
+	}
+	if trip != nil && trip.Muted {
+		return false
+	}
+	var user bool
+	var override *bool
+	switch channel {
+	case ChannelInApp:
+		user = p.InApp
+		if trip != nil {
+			override = trip.InApp
+		}
+	case ChannelEmail:
+		user = p.Email
+		if trip != nil {
+			override = trip.Email
+		}
+	case ChannelPush:
+		user = p.Push
+		if trip != nil {
+			override = trip.Push
+		}
+	default:
+		return true
+	}
+	if override != nil {
+		return *override
+	}
+	return user
+}
+
+// NotificationRoute is where one notification goes under the recipient's
+// preferences
+type NotificationRoute struct {
+	InApp bool
+	Email bool
+	Push  bool
+	// QuietUntil is the end of the quiet hours the notification arrived in,
+	// zero outside them. It is then stored without interrupting the user:
+	// nothing is streamed, and emails wait until QuietUntil.
+	QuietUntil time.Time
+	// Locale is the language emails are written in; empty means the default
+	Locale string
+	// Digest means the notification is emailed in the user's digest rather
+	// than on its own; Email is then false
+	Digest bool
+}
+
+// Quiet reports whether the notification arrived during quiet hours
+func (r NotificationRoute) Quiet() bool { return !r.QuietUntil.IsZero() }
+
+// Route applies p, and trip when the notification is about a trip with
+// settings, to a notification of typ arriving at now
+func (p NotificationPreferences) Route(typ NotificationType, trip *TripNotificationPreferences, now time.Time) NotificationRoute {
+	r := NotificationRoute{
+		InApp:  p.Allows(ChannelInApp, typ, trip),
+		Email:  p.Allows(ChannelEmail, typ, trip),
+		Push:   p.Allows(ChannelPush, typ, trip),
+		Locale: p.Locale,
+	}
+	if r.Email && p.Digests(typ) {
+		r.Email, r.Digest = false, true
+	}
+	if until, ok := p.QuietUntil(now); ok {
+		r.QuietUntil = until
+	}
+	return r
+}
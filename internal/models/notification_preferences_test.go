@@ -0,0 +1,109 @@
+package models
+
+import (
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+func TestDigestSlot(t *testing.T) {
+	bangkok := time.FixedZone("ICT", 7*3600)
+	at := func(day, hour, min int) time.Time { return time.Date(2026, 10, day, hour, min, 0, 0, bangkok) }
+	// 2026-10-12 is a Monday
+
+	tests := []struct {
+		name      string
+		frequency string
+		timezone  string
+		now       time.Time
+		want      time.Time
+		ok        bool
+	}{
+		{"off", DigestOff, "Asia/Bangkok", at(15, 9, 0), time.Time{}, false},
+		{"empty", "", "Asia/Bangkok", at(15, 9, 0), time.Time{}, false},
+		{"daily after the hour", DigestDaily, "Asia/Bangkok", at(15, 9, 0), at(15, 8, 0), true},
+		{"daily at the hour", DigestDaily, "Asia/Bangkok", at(15, 8, 0), at(15, 8, 0), true},
+		{"daily before the hour", DigestDaily, "Asia/Bangkok", at(15, 7, 59), at(14, 8, 0), true},
+		{"daily in utc", DigestDaily, "", at(15, 16, 0), time.Date(2026, 10, 15, 8, 0, 0, 0, time.UTC), true},
+		{"weekly on the weekday", DigestWeekly, "Asia/Bangkok", at(12, 9, 0), at(12, 8, 0), true},
+		{"weekly before the hour of the weekday", DigestWeekly, "Asia/Bangkok", at(12, 7, 0), at(5, 8, 0), true},
+		{"weekly mid-week", DigestWeekly, "Asia/Bangkok", at(16, 9, 0), at(12, 8, 0), true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			p := NotificationPreferences{DigestFrequency: tt.frequency, Timezone: tt.timezone}
+			got, ok := p.DigestSlot(tt.now, 8, time.Monday)
+			if ok != tt.ok {
+				t.Fatalf("DigestSlot() ok = %v, want %v", ok, tt.ok)
+			}
+			if ok && !got.Equal(tt.want) {
+				t.Errorf("DigestSlot() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestDigestDue(t *testing.T) {
+	slot := time.Date(2026, 10, 15, 1, 0, 0, 0, time.UTC)
+	before, after := slot.Add(-24*time.Hour), slot.Add(time.Hour)
+	tests := []struct {
+		name string
+		last *time.Time
+		want bool
+	}{
+		{"never sent", nil, true},
+		{"previous slot sent", &before, true},
+		{"this slot sent", &slot, false},
+		{"later slot sent", &after, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			p := NotificationPreferences{DigestFrequency: DigestDaily, LastDigestAt: tt.last}
+			if got := p.DigestDue(slot); got != tt.want {
+				t.Errorf("DigestDue() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestDigestPeriod(t *testing.T) {
+	if got := (NotificationPreferences{DigestFrequency: DigestDaily}).DigestPeriod(); got != 24*time.Hour {
+		t.Errorf("daily DigestPeriod() = %v", got)
+	}
+	if got := (NotificationPreferences{DigestFrequency: DigestWeekly}).DigestPeriod(); got != 7*24*time.Hour {
+		t.Errorf("weekly DigestPeriod() = %v", got)
+	}
+}
+
+func TestRouteLeavesDigestTypesToTheDigest(t *testing.T) {
+	off := false
+	tests := []struct {
+		name       string
+		frequency  string
+		typ        NotificationType
+		trip       *TripNotificationPreferences
+		wantEmail  bool
+		wantDigest bool
+	}{
+		{"digest off", DigestOff, NotificationMemberJoined, nil, true, false},
+		{"daily digest type", DigestDaily, NotificationMemberJoined, nil, false, true},
+		{"weekly digest type", DigestWeekly, NotificationAvailability, nil, false, true},
+		{"not a digest type", DigestDaily, NotificationTripUpdate, nil, true, false},
+		{"trip email off", DigestDaily, NotificationMemberJoined, &TripNotificationPreferences{Email: &off}, false, false},
+		{"trip muted", DigestDaily, NotificationMemberLeft, &TripNotificationPreferences{Muted: true}, false, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			p := DefaultNotificationPreferences(uuid.New())
+			p.DigestFrequency = tt.frequency
+			r := p.Route(tt.typ, tt.trip, time.Now())
+			if r.Email != tt.wantEmail || r.Digest != tt.wantDigest {
+				t.Errorf("Route() email = %v, digest = %v; want %v, %v", r.Email, r.Digest, tt.wantEmail, tt.wantDigest)
+			}
+			if !r.InApp && tt.trip == nil {
+				t.Error("Route() turned in-app off")
+			}
+		})
+	}
+}
@@ -0,0 +1,39 @@
+package models
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// Delivery channels of an outbox event
+const (
+	ChannelInApp = "in_app"
+	ChannelEmail = "email"
+)
+
+// EventPayload is what an event tells its recipient
+type EventPayload struct {
+	Title     string         `json:"title"`
+	Message   *string        `json:"message,omitempty"`
+	Data      map[string]any `json:"data,omitempty"`
+	ActionURL *string        `json:"action_url,omitempty"`
+}
+
+// OutboxEvent is a domain event waiting in outbox_events to be delivered to
+// RecipientID on each of Channels
+type OutboxEvent struct {
+	ID          uuid.UUID        `json:"id" db:"id"`
+	Type        NotificationType `json:"type" db:"type"`
+	RecipientID uuid.UUID        `json:"recipient_id" db:"recipient_id"`
+	TripID      *uuid.UUID       `json:"trip_id,omitempty" db:"trip_id"`
+	Payload     EventPayload     `json:"payload" db:"payload"`
+	Channels    []string         `json:"channels" db:"channels"`
+	// IdempotencyKey identifies the change the event reports; an event with a
+	// key already stored is not added again
+	IdempotencyKey string    `json:"idempotency_key" db:"idempotency_key"`
+	Attempts       int       `json:"attempts" db:"attempts"`
+	AvailableAt    time.Time `json:"available_at" db:"available_at"`
+	LastError      *string   `json:"last_error,omitempty" db:"last_error"`
+	CreatedAt      time.Time `json:"created_at" db:"created_at"`
+}
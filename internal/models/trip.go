@@ -18,6 +18,79 @@ type Trip struct {
 	TotalBudget float64   `json:"total_budget" db:"total_budget"`
 	Currency    string    `json:"currency" db:"currency"`
 	CreatorID   uuid.UUID `json:"creator_id" db:"creator_id"`
-	CreatedAt   time.Time `json:"created_at" db:"created_at"`
-	UpdatedAt   time.Time `json:"updated_at" db:"updated_at"`
+	// RequireOrganizerMFA: co-organizers must have two-factor auth enabled to manage the trip
+	RequireOrganizerMFA bool      `json:"require_organizer_mfa" db:"require_organizer_mfa"`
+	CreatedAt           time.Time `json:"created_at" db:"created_at"`
+	UpdatedAt           time.Time `json:"updated_at" db:"updated_at"`
+}
+
+// TripBudget is the per-category budget breakdown of a trip (budget_categories, order_index 1)
+type TripBudget struct {
+	Food      float64 `json:"food" db:"food"`
+	Hotel     float64 `json:"hotel" db:"hotel"`
+	Shopping  float64 `json:"shopping" db:"shopping"`
+	Transport float64 `json:"transport" db:"transport"`
+}
+
+// Sum adds up the categories
+func (b TripBudget) Sum() float64 {
+	return b.Food + b.Hotel + b.Shopping + b.Transport
+}
+
+// IsZero reports whether no category has a value
+func (b TripBudget) IsZero() bool {
+	return b.Food == 0 && b.Hotel == 0 && b.Shopping == 0 && b.Transport == 0
+}
+
+// TripSummary is a trip in a list, with its member count
+type TripSummary struct {
+	Trip
+	MemberCount int `json:"member_count"`
+}
+
+// TripMember is a row of trip_members. Email and the profile fields are only
+// filled by the queries that join them.
+type TripMember struct {
+	TripID                uuid.UUID  `json:"trip_id" db:"trip_id"`
+	UserID                uuid.UUID  `json:"user_id" db:"user_id"`
+	Role                  string     `json:"role" db:"role"`     // creator | member
+	Status                string     `json:"status" db:"status"` // pending | accepted | declined
+	AvailabilitySubmitted bool       `json:"availability_submitted" db:"availability_submitted"`
+	InvitedBy             *uuid.UUID `json:"invited_by,omitempty" db:"invited_by"`
+	InvitedAt             *time.Time `json:"invited_at,omitempty" db:"invited_at"`
+	JoinedAt              *time.Time `json:"joined_at,omitempty" db:"joined_at"`
+
+	Email       string  `json:"email,omitempty"`
+	Username    *string `json:"username,omitempty"`
+	DisplayName *string `json:"display_name,omitempty"`
+	AvatarURL   *string `json:"avatar_url,omitempty"`
+}
+
+// MemberStats counts the members of a trip by status
+type MemberStats struct {
+	Total            int `json:"total"`
+	Accepted         int `json:"accepted"`
+	Pending          int `json:"pending"`
+	Declined         int `json:"declined"`
+	WithAvailability int `json:"with_availability"`
+}
+
+// DayCount is the number of accepted members free on one day of a trip
+type DayCount struct {
+	Date      time.Time `json:"date"`
+	FreeCount int       `json:"free_count"`
+}
+
+// AvailablePeriod is a run of consecutive days on which enough members are free
+type AvailablePeriod struct {
+	ID                     uuid.UUID `json:"id" db:"id"`
+	TripID                 uuid.UUID `json:"trip_id" db:"trip_id"`
+	PeriodNumber           int       `json:"period_number" db:"period_number"`
+	StartDate              time.Time `json:"start_date" db:"start_date"`
+	EndDate                time.Time `json:"end_date" db:"end_date"`
+	DurationDays           int       `json:"duration_days" db:"duration_days"`
+	FreeCount              int       `json:"free_count" db:"free_count"` // fewest members free on any day of the period
+	TotalMembers           int       `json:"total_members" db:"total_members"`
+	AvailabilityPercentage float64   `json:"availability_percentage" db:"availability_percentage"`
+	CreatedAt              time.Time `json:"created_at" db:"created_at"`
 }
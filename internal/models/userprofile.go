@@ -1,11 +1,14 @@
 package models
 
-import "time"
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
 
 // Entity ของตาราง public.profiles
 type UserProfile struct {
-	ID               string     `json:"id"`
-	UserID           string     `json:"user_id"`
+	UserID           uuid.UUID  `json:"user_id"`
 	Username         string     `json:"username"`
 	FirstName        *string    `json:"first_name,omitempty"`
 	LastName         *string    `json:"last_name,omitempty"`
@@ -19,6 +22,36 @@ type UserProfile struct {
 	AllergicFood     *string    `json:"allergic_food,omitempty"`
 	AllergicDrugs    *string    `json:"allergic_drugs,omitempty"`
 	EmergencyContact *string    `json:"emergency_contact,omitempty"`
-	CreatedAt        time.Time  `json:"created_at"`
-	UpdatedAt        time.Time  `json:"updated_at"`
+	// Email, Role, CreatedAt, UpdatedAt มาจากตาราง users (join ตอนอ่าน)
+	Email     string    `json:"email"`
+	Role      string    `json:"role"`
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+}
+
+// ProfileUpdate: อัปเดตเฉพาะฟิลด์ที่ไม่เป็น nil
+// ค่า "" ของฟิลด์ string (ยกเว้น Username) จะล้างคอลัมน์เป็น NULL
+type ProfileUpdate struct {
+	Username         *string
+	FirstName        *string
+	LastName         *string
+	DisplayName      *string
+	AvatarURL        *string
+	Phone            *string
+	Bio              *string
+	FoodPreferences  *string
+	ChronicDisease   *string
+	AllergicFood     *string
+	AllergicDrugs    *string
+	EmergencyContact *string
+	BirthDate        *time.Time
+	ClearBirthDate   bool // ตั้ง birth_date เป็น NULL
+}
+
+// IsEmpty reports whether no field is set
+func (u ProfileUpdate) IsEmpty() bool {
+	return u.Username == nil && u.FirstName == nil && u.LastName == nil && u.DisplayName == nil &&
+		u.AvatarURL == nil && u.Phone == nil && u.Bio == nil && u.FoodPreferences == nil &&
+		u.ChronicDisease == nil && u.AllergicFood == nil && u.AllergicDrugs == nil &&
+		u.EmergencyContact == nil && u.BirthDate == nil && !u.ClearBirthDate
 }
@@ -0,0 +1,208 @@
+package oauth
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/rsa"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+	"golang.org/x/oauth2"
+
+	"GO2GETHER_BACK-END/internal/utils"
+)
+
+const (
+	fakeIssuer   = "https://fake-oidc.go2gether.test"
+	fakeClientID = "go2gether-test"
+	fakeKeyID    = "fake-oidc-1"
+	// FakeAuthorizePath is where routes mount the fake provider's consent endpoint
+	FakeAuthorizePath = "/api/test/oauth/fake/authorize"
+)
+
+// FakeProvider is an in-process OpenID Connect provider for development and
+// integration tests. Its authorize endpoint signs in whoever is named by the
+// login_hint query parameter without any UI, and Exchange verifies PKCE and
+// a real signed ID token through the same code path as LINE and Apple.
+//
+//	GET /api/test/oauth/fake/authorize?...&login_hint=alice@example.com[&sub=..][&email_verified=false][&name=..]
+type FakeProvider struct {
+	oidc         *oidcProvider
+	authorizeURL string
+	key          *rsa.PrivateKey
+
+	mu     sync.Mutex
+	grants map[string]fakeGrant
+}
+
+type fakeGrant struct {
+	user      UserInfo
+	challenge string
+	nonce     string
+	expiresAt time.Time
+}
+
+// NewFakeProvider creates the fake provider; redirectURL is its callback
+// (…/api/auth/fake/callback) and also decides where its authorize endpoint lives
+func NewFakeProvider(redirectURL string) (*FakeProvider, error) {
+	u, err := url.Parse(redirectURL)
+	if err != nil || u.Scheme == "" || u.Host == "" {
+		return nil, fmt.Errorf("invalid redirect URL %q", redirectURL)
+	}
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		return nil, err
+	}
+
+	return &FakeProvider{
+		oidc: &oidcProvider{
+			name: "fake",
+			oauth2: oauth2.Config{
+				ClientID:    fakeClientID,
+				RedirectURL: redirectURL,
+			},
+			issuer:             fakeIssuer,
+			keys:               staticKeys{fakeKeyID: &key.PublicKey},
+			trustEmailVerified: true,
+		},
+		authorizeURL: u.Scheme + "://" + u.Host + FakeAuthorizePath,
+		key:          key,
+		grants:       make(map[string]fakeGrant),
+	}, nil
+}
+
+func (p *FakeProvider) Name() string { return "fake" }
+
+func (p *FakeProvider) AuthCodeURL(state, verifier string) string {
+	q := url.Values{
+		"response_type":         {"code"},
+		"client_id":             {fakeClientID},
+		"redirect_uri":          {p.oidc.oauth2.RedirectURL},
+		"state":                 {state},
+		"code_challenge":        {oauth2.S256ChallengeFromVerifier(verifier)},
+		"code_challenge_method": {"S256"},
+		"nonce":                 {nonceFor(verifier)},
+	}
+	return p.authorizeURL + "?" + q.Encode()
+}
+
+// Authorize issues an authorization code for the user, as if they had
+// consented; challenge and nonce come from the AuthCodeURL parameters
+func (p *FakeProvider) Authorize(user UserInfo, challenge, nonce string) (string, error) {
+	if user.Subject == "" {
+		return "", errors.New("user subject is required")
+	}
+	b := make([]byte, 24)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	code := base64.RawURLEncoding.EncodeToString(b)
+
+	p.mu.Lock()
+	p.grants[code] = fakeGrant{user: user, challenge: challenge, nonce: nonce, expiresAt: time.Now().Add(time.Minute)}
+	p.mu.Unlock()
+	return code, nil
+}
+
+// ServeHTTP implements the authorize endpoint: it redirects straight back to
+// the callback with a code for the login_hint user
+func (p *FakeProvider) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		utils.WriteErrorResponse(w, http.StatusMethodNotAllowed, "Method not allowed", "Only GET is allowed")
+		return
+	}
+
+	q := r.URL.Query()
+	if q.Get("client_id") != fakeClientID || q.Get("redirect_uri") != p.oidc.oauth2.RedirectURL {
+		utils.WriteErrorResponse(w, http.StatusBadRequest, "Bad Request", "unknown client or redirect_uri")
+		return
+	}
+	if q.Get("code_challenge_method") != "S256" || q.Get("code_challenge") == "" {
+		utils.WriteErrorResponse(w, http.StatusBadRequest, "Bad Request", "PKCE S256 code_challenge is required")
+		return
+	}
+
+	email := strings.TrimSpace(q.Get("login_hint"))
+	if email == "" {
+		utils.WriteErrorResponse(w, http.StatusBadRequest, "Bad Request", "login_hint (email) is required")
+		return
+	}
+	user := UserInfo{
+		Subject:       q.Get("sub"),
+		Email:         email,
+		EmailVerified: true,
+		Name:          q.Get("name"),
+	}
+	if user.Subject == "" {
+		user.Subject = "fake|" + strings.ToLower(email)
+	}
+	if v := q.Get("email_verified"); v != "" {
+		user.EmailVerified, _ = strconv.ParseBool(v)
+	}
+
+	code, err := p.Authorize(user, q.Get("code_challenge"), q.Get("nonce"))
+	if err != nil {
+		utils.WriteError(w, r, err)
+		return
+	}
+
+	callback, _ := url.Parse(p.oidc.oauth2.RedirectURL)
+	cq := callback.Query()
+	cq.Set("code", code)
+	cq.Set("state", q.Get("state"))
+	callback.RawQuery = cq.Encode()
+	http.Redirect(w, r, callback.String(), http.StatusFound)
+}
+
+func (p *FakeProvider) Exchange(ctx context.Context, code, verifier string) (*UserInfo, error) {
+	p.mu.Lock()
+	grant, ok := p.grants[code]
+	delete(p.grants, code)
+	p.mu.Unlock()
+
+	if !ok || time.Now().After(grant.expiresAt) {
+		return nil, errors.New("invalid_grant: unknown or expired code")
+	}
+	if oauth2.S256ChallengeFromVerifier(verifier) != grant.challenge {
+		return nil, errors.New("invalid_grant: PKCE verification failed")
+	}
+
+	now := time.Now()
+	token := jwt.NewWithClaims(jwt.SigningMethodRS256, &idTokenClaims{
+		Email:         grant.user.Email,
+		EmailVerified: flexibleBool(grant.user.EmailVerified),
+		Name:          grant.user.Name,
+		Nonce:         grant.nonce,
+		RegisteredClaims: jwt.RegisteredClaims{
+			Issuer:    fakeIssuer,
+			Subject:   grant.user.Subject,
+			Audience:  jwt.ClaimStrings{fakeClientID},
+			IssuedAt:  jwt.NewNumericDate(now),
+			ExpiresAt: jwt.NewNumericDate(now.Add(5 * time.Minute)),
+		},
+	})
+	token.Header["kid"] = fakeKeyID
+	rawIDToken, err := token.SignedString(p.key)
+	if err != nil {
+		return nil, err
+	}
+
+	claims, err := p.oidc.verifyIDToken(ctx, rawIDToken, nonceFor(verifier))
+	if err != nil {
+		return nil, fmt.Errorf("invalid id_token: %w", err)
+	}
+	return &UserInfo{
+		Subject:       claims.Subject,
+		Email:         claims.Email,
+		EmailVerified: bool(claims.EmailVerified),
+		Name:          claims.Name,
+	}, nil
+}
@@ -0,0 +1,137 @@
+package oauth
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+
+	"golang.org/x/oauth2"
+)
+
+const fakeCallback = "http://localhost:8080/api/auth/fake/callback"
+
+// consent follows the authorization URL through the fake provider's consent
+// endpoint and returns the callback redirect
+func consent(t *testing.T, p *FakeProvider, authURL string, extra url.Values) *httptest.ResponseRecorder {
+	t.Helper()
+	u, err := url.Parse(authURL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if u.Path != FakeAuthorizePath {
+		t.Fatalf("authorization URL path = %q, want %q", u.Path, FakeAuthorizePath)
+	}
+	q := u.Query()
+	for k, v := range extra {
+		q[k] = v
+	}
+	u.RawQuery = q.Encode()
+
+	rec := httptest.NewRecorder()
+	p.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, u.String(), nil))
+	return rec
+}
+
+func newFake(t *testing.T) *FakeProvider {
+	t.Helper()
+	p, err := NewFakeProvider(fakeCallback)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return p
+}
+
+func TestFakeProviderFlow(t *testing.T) {
+	p := newFake(t)
+	verifier := oauth2.GenerateVerifier()
+
+	rec := consent(t, p, p.AuthCodeURL("state-1", verifier), url.Values{
+		"login_hint": {" Alice@Example.com "},
+		"name":       {"Alice"},
+	})
+	if rec.Code != http.StatusFound {
+		t.Fatalf("consent status = %d: %s", rec.Code, rec.Body)
+	}
+	callback, err := url.Parse(rec.Header().Get("Location"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := callback.Scheme + "://" + callback.Host + callback.Path; got != fakeCallback {
+		t.Errorf("redirected to %q, want the callback", got)
+	}
+	if callback.Query().Get("state") != "state-1" {
+		t.Errorf("state = %q, want it passed through", callback.Query().Get("state"))
+	}
+	code := callback.Query().Get("code")
+
+	user, err := p.Exchange(context.Background(), code, verifier)
+	if err != nil {
+		t.Fatalf("Exchange() error = %v", err)
+	}
+	want := UserInfo{Subject: "fake|alice@example.com", Email: "Alice@Example.com", EmailVerified: true, Name: "Alice"}
+	if *user != want {
+		t.Errorf("Exchange() = %+v, want %+v", *user, want)
+	}
+
+	// codes work once
+	if _, err := p.Exchange(context.Background(), code, verifier); err == nil {
+		t.Error("second Exchange() with the same code succeeded")
+	}
+}
+
+func TestFakeProviderExchangeChecksPKCE(t *testing.T) {
+	p := newFake(t)
+	verifier := oauth2.GenerateVerifier()
+	rec := consent(t, p, p.AuthCodeURL("s", verifier), url.Values{"login_hint": {"bob@example.com"}})
+	callback, _ := url.Parse(rec.Header().Get("Location"))
+
+	if _, err := p.Exchange(context.Background(), callback.Query().Get("code"), oauth2.GenerateVerifier()); err == nil {
+		t.Error("Exchange() with another verifier succeeded")
+	}
+	if _, err := p.Exchange(context.Background(), "unknown", verifier); err == nil {
+		t.Error("Exchange() with an unknown code succeeded")
+	}
+}
+
+func TestFakeProviderConsentOptions(t *testing.T) {
+	p := newFake(t)
+	verifier := oauth2.GenerateVerifier()
+	rec := consent(t, p, p.AuthCodeURL("s", verifier), url.Values{
+		"login_hint":     {"carol@example.com"},
+		"sub":            {"carol-1"},
+		"email_verified": {"false"},
+	})
+	callback, _ := url.Parse(rec.Header().Get("Location"))
+
+	user, err := p.Exchange(context.Background(), callback.Query().Get("code"), verifier)
+	if err != nil {
+		t.Fatalf("Exchange() error = %v", err)
+	}
+	if user.Subject != "carol-1" || user.EmailVerified {
+		t.Errorf("Exchange() = %+v, want subject carol-1 and an unverified email", *user)
+	}
+}
+
+func TestFakeProviderConsentRejectsBadRequests(t *testing.T) {
+	p := newFake(t)
+	authURL := p.AuthCodeURL("s", oauth2.GenerateVerifier())
+	tests := []struct {
+		name  string
+		extra url.Values
+	}{
+		{"no login_hint", nil},
+		{"unknown client", url.Values{"login_hint": {"a@example.com"}, "client_id": {"other"}}},
+		{"other redirect_uri", url.Values{"login_hint": {"a@example.com"}, "redirect_uri": {"http://evil.example/cb"}}},
+		{"plain PKCE", url.Values{"login_hint": {"a@example.com"}, "code_challenge_method": {"plain"}}},
+		{"no challenge", url.Values{"login_hint": {"a@example.com"}, "code_challenge": {""}}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if rec := consent(t, p, authURL, tt.extra); rec.Code != http.StatusBadRequest {
+				t.Errorf("status = %d, want 400", rec.Code)
+			}
+		})
+	}
+}
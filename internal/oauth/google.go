@@ -0,0 +1,69 @@
+package oauth
+
+import (
+	"context"
+
+	"golang.org/x/oauth2"
+	"golang.org/x/oauth2/google"
+	googleOAuth2 "google.golang.org/api/oauth2/v2"
+	"google.golang.org/api/option"
+
+	"GO2GETHER_BACK-END/internal/config"
+)
+
+// googleProvider signs in with Google and reads the user from the userinfo API
+type googleProvider struct {
+	oauth2 oauth2.Config
+}
+
+// NewGoogleProvider creates the Google sign-in provider
+func NewGoogleProvider(cfg config.GoogleOAuthConfig) Provider {
+	return &googleProvider{oauth2: oauth2.Config{
+		ClientID:     cfg.ClientID,
+		ClientSecret: cfg.ClientSecret,
+		RedirectURL:  cfg.RedirectURL,
+		Scopes: []string{
+			"https://www.googleapis.com/auth/userinfo.email",
+			"https://www.googleapis.com/auth/userinfo.profile",
+		},
+		Endpoint: google.Endpoint,
+	}}
+}
+
+func (p *googleProvider) Name() string { return "google" }
+
+func (p *googleProvider) AuthCodeURL(state, verifier string) string {
+	return p.oauth2.AuthCodeURL(state, oauth2.AccessTypeOffline, oauth2.S256ChallengeOption(verifier))
+}
+
+func (p *googleProvider) Exchange(ctx context.Context, code, verifier string) (*UserInfo, error) {
+	token, err := p.oauth2.Exchange(ctx, code, oauth2.VerifierOption(verifier))
+	if err != nil {
+		return nil, err
+	}
+
+	service, err := googleOAuth2.NewService(ctx, option.WithTokenSource(oauth2.StaticTokenSource(&oauth2.Token{
+		AccessToken: token.AccessToken,
+	})))
+	if err != nil {
+		return nil, err
+	}
+
+	userInfo, err := service.Userinfo.Get().Do()
+	if err != nil {
+		return nil, err
+	}
+
+	verified := false
+	if userInfo.VerifiedEmail != nil {
+		verified = *userInfo.VerifiedEmail
+	}
+
+	return &UserInfo{
+		Subject:       userInfo.Id,
+		Email:         userInfo.Email,
+		EmailVerified: verified,
+		Name:          userInfo.Name,
+		Picture:       userInfo.Picture,
+	}, nil
+}
@@ -0,0 +1,31 @@
+package oauth
+
+import (
+	"golang.org/x/oauth2"
+
+	"GO2GETHER_BACK-END/internal/config"
+)
+
+// NewLINEProvider creates the LINE Login (v2.1, OpenID Connect) provider.
+// LINE signs web login ID tokens with the channel secret (HS256) and only
+// returns an email when the channel has the email permission and the user
+// agrees. LINE does not assert that the email is verified.
+func NewLINEProvider(cfg config.OAuthProviderConfig) Provider {
+	return &oidcProvider{
+		name: "line",
+		oauth2: oauth2.Config{
+			ClientID:     cfg.ClientID,
+			ClientSecret: cfg.ClientSecret,
+			RedirectURL:  cfg.RedirectURL,
+			Scopes:       []string{"openid", "profile", "email"},
+			Endpoint: oauth2.Endpoint{
+				AuthURL:   "https://access.line.me/oauth2/v2.1/authorize",
+				TokenURL:  "https://api.line.me/oauth2/v2.1/token",
+				AuthStyle: oauth2.AuthStyleInParams,
+			},
+		},
+		issuer:  "https://access.line.me",
+		keys:    newJWKSCache("https://api.line.me/oauth2/v2.1/certs"),
+		hmacKey: []byte(cfg.ClientSecret),
+	}
+}
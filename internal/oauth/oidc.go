@@ -0,0 +1,271 @@
+package oauth
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rsa"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math/big"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+	"golang.org/x/oauth2"
+)
+
+// oidcProvider is a generic OpenID Connect provider: the user comes from the
+// ID token returned by the token endpoint, verified against the provider's JWKS
+type oidcProvider struct {
+	name       string
+	oauth2     oauth2.Config
+	issuer     string
+	keys       keySource
+	authParams []oauth2.AuthCodeOption
+	// clientSecret mints the client secret per exchange (Apple); nil uses oauth2.ClientSecret
+	clientSecret func() (string, error)
+	// hmacKey verifies HS256 ID tokens (LINE web login signs with the channel secret)
+	hmacKey []byte
+	// trustEmailVerified: the provider's email_verified claim is meaningful
+	trustEmailVerified bool
+}
+
+func (p *oidcProvider) Name() string { return p.name }
+
+func (p *oidcProvider) AuthCodeURL(state, verifier string) string {
+	opts := []oauth2.AuthCodeOption{
+		oauth2.S256ChallengeOption(verifier),
+		oauth2.SetAuthURLParam("nonce", nonceFor(verifier)),
+	}
+	return p.oauth2.AuthCodeURL(state, append(opts, p.authParams...)...)
+}
+
+func (p *oidcProvider) Exchange(ctx context.Context, code, verifier string) (*UserInfo, error) {
+	cfg := p.oauth2
+	if p.clientSecret != nil {
+		secret, err := p.clientSecret()
+		if err != nil {
+			return nil, err
+		}
+		cfg.ClientSecret = secret
+	}
+
+	token, err := cfg.Exchange(ctx, code, oauth2.VerifierOption(verifier))
+	if err != nil {
+		return nil, err
+	}
+	rawIDToken, _ := token.Extra("id_token").(string)
+	if rawIDToken == "" {
+		return nil, errors.New("token response has no id_token")
+	}
+
+	claims, err := p.verifyIDToken(ctx, rawIDToken, nonceFor(verifier))
+	if err != nil {
+		return nil, fmt.Errorf("invalid id_token: %w", err)
+	}
+
+	return &UserInfo{
+		Subject:       claims.Subject,
+		Email:         claims.Email,
+		EmailVerified: p.trustEmailVerified && claims.Email != "" && bool(claims.EmailVerified),
+		Name:          claims.Name,
+		Picture:       claims.Picture,
+	}, nil
+}
+
+// idTokenClaims are the OIDC claims we use
+type idTokenClaims struct {
+	Email         string       `json:"email"`
+	EmailVerified flexibleBool `json:"email_verified"`
+	Name          string       `json:"name"`
+	Picture       string       `json:"picture"`
+	Nonce         string       `json:"nonce"`
+	jwt.RegisteredClaims
+}
+
+func (p *oidcProvider) verifyIDToken(ctx context.Context, raw, nonce string) (*idTokenClaims, error) {
+	claims := &idTokenClaims{}
+	_, err := jwt.ParseWithClaims(raw, claims, func(token *jwt.Token) (interface{}, error) {
+		switch token.Method.(type) {
+		case *jwt.SigningMethodHMAC:
+			if p.hmacKey == nil || token.Method.Alg() != jwt.SigningMethodHS256.Alg() {
+				return nil, errors.New("unexpected signing method")
+			}
+			return p.hmacKey, nil
+		case *jwt.SigningMethodRSA, *jwt.SigningMethodECDSA:
+			kid, _ := token.Header["kid"].(string)
+			return p.keys.Key(ctx, kid)
+		default:
+			return nil, errors.New("unexpected signing method")
+		}
+	},
+		jwt.WithIssuer(p.issuer),
+		jwt.WithAudience(p.oauth2.ClientID),
+		jwt.WithExpirationRequired(),
+	)
+	if err != nil {
+		return nil, err
+	}
+	if claims.Subject == "" {
+		return nil, errors.New("missing sub")
+	}
+	if claims.Nonce != nonce {
+		return nil, errors.New("nonce mismatch")
+	}
+	return claims, nil
+}
+
+// flexibleBool accepts both true and "true" (Apple sends email_verified as a string)
+type flexibleBool bool
+
+func (b *flexibleBool) UnmarshalJSON(data []byte) error {
+	var v interface{}
+	if err := json.Unmarshal(data, &v); err != nil {
+		return err
+	}
+	switch t := v.(type) {
+	case bool:
+		*b = flexibleBool(t)
+	case string:
+		parsed, _ := strconv.ParseBool(t)
+		*b = flexibleBool(parsed)
+	}
+	return nil
+}
+
+// keySource resolves ID token verification keys by kid
+type keySource interface {
+	Key(ctx context.Context, kid string) (interface{}, error)
+}
+
+// jwksCache fetches and caches a provider's JSON Web Key Set. Unknown kids
+// trigger a refetch (providers rotate keys), at most once a minute.
+type jwksCache struct {
+	url    string
+	client *http.Client
+
+	mu        sync.Mutex
+	keys      map[string]interface{}
+	fetchedAt time.Time
+}
+
+const (
+	jwksMaxAge       = time.Hour
+	jwksRefetchDelay = time.Minute
+)
+
+func newJWKSCache(url string) *jwksCache {
+	return &jwksCache{url: url, client: &http.Client{Timeout: 10 * time.Second}}
+}
+
+func (c *jwksCache) Key(ctx context.Context, kid string) (interface{}, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	key, ok := c.keys[kid]
+	stale := time.Since(c.fetchedAt) > jwksMaxAge
+	if ok && !stale {
+		return key, nil
+	}
+	if stale || time.Since(c.fetchedAt) > jwksRefetchDelay {
+		if err := c.refresh(ctx); err != nil {
+			if ok {
+				return key, nil // keep serving the cached key while the provider is unreachable
+			}
+			return nil, err
+		}
+		if key, ok = c.keys[kid]; ok {
+			return key, nil
+		}
+	}
+	return nil, fmt.Errorf("unknown kid %q", kid)
+}
+
+func (c *jwksCache) refresh(ctx context.Context) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, c.url, nil)
+	if err != nil {
+		return err
+	}
+	resp, err := c.client.Do(req)
+	if err != nil {
+		return fmt.Errorf("fetch jwks: %w", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("fetch jwks: status %d", resp.StatusCode)
+	}
+
+	var set struct {
+		Keys []jwk `json:"keys"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&set); err != nil {
+		return fmt.Errorf("parse jwks: %w", err)
+	}
+
+	keys := make(map[string]interface{}, len(set.Keys))
+	for _, k := range set.Keys {
+		if pub, err := k.publicKey(); err == nil {
+			keys[k.Kid] = pub
+		}
+	}
+	c.keys = keys
+	c.fetchedAt = time.Now()
+	return nil
+}
+
+// staticKeys is a keySource with fixed keys (the fake provider)
+type staticKeys map[string]interface{}
+
+func (s staticKeys) Key(_ context.Context, kid string) (interface{}, error) {
+	if key, ok := s[kid]; ok {
+		return key, nil
+	}
+	return nil, fmt.Errorf("unknown kid %q", kid)
+}
+
+// jwk is an RSA or EC (P-256) public key in RFC 7517 form
+type jwk struct {
+	Kty string `json:"kty"`
+	Kid string `json:"kid"`
+	N   string `json:"n"`
+	E   string `json:"e"`
+	Crv string `json:"crv"`
+	X   string `json:"x"`
+	Y   string `json:"y"`
+}
+
+func (k jwk) publicKey() (interface{}, error) {
+	decode := base64.RawURLEncoding.DecodeString
+	switch k.Kty {
+	case "RSA":
+		n, err := decode(k.N)
+		if err != nil {
+			return nil, err
+		}
+		e, err := decode(k.E)
+		if err != nil {
+			return nil, err
+		}
+		return &rsa.PublicKey{N: new(big.Int).SetBytes(n), E: int(new(big.Int).SetBytes(e).Int64())}, nil
+	case "EC":
+		if k.Crv != "P-256" {
+			return nil, fmt.Errorf("unsupported curve %q", k.Crv)
+		}
+		x, err := decode(k.X)
+		if err != nil {
+			return nil, err
+		}
+		y, err := decode(k.Y)
+		if err != nil {
+			return nil, err
+		}
+		return &ecdsa.PublicKey{Curve: elliptic.P256(), X: new(big.Int).SetBytes(x), Y: new(big.Int).SetBytes(y)}, nil
+	default:
+		return nil, fmt.Errorf("unsupported kty %q", k.Kty)
+	}
+}
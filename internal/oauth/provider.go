@@ -0,0 +1,104 @@
+// Package oauth implements the external sign-in providers (Google, LINE,
+// Facebook, Apple and a fake OIDC provider for tests) behind one interface.
+package oauth
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"sort"
+
+	"GO2GETHER_BACK-END/internal/config"
+)
+
+// ErrProviderNotFound is returned for provider names that are not enabled
+var ErrProviderNotFound = errors.New("oauth provider not found")
+
+// UserInfo is what a provider tells us about the signed-in user
+type UserInfo struct {
+	Subject       string // stable provider user ID
+	Email         string // may be empty when the user declined to share it
+	EmailVerified bool   // only true when the provider asserts it
+	Name          string
+	Picture       string
+}
+
+// Provider is one external sign-in provider
+type Provider interface {
+	// Name is the provider key used in routes and user_identities.provider
+	Name() string
+	// AuthCodeURL returns the consent page URL for the signed state. Providers
+	// send the S256 challenge of verifier (PKCE) and, for OIDC, a nonce derived from it.
+	AuthCodeURL(state, verifier string) string
+	// Exchange redeems the authorization code with the same verifier and returns the user
+	Exchange(ctx context.Context, code, verifier string) (*UserInfo, error)
+}
+
+// Registry holds the providers enabled by configuration
+type Registry struct {
+	providers map[string]Provider
+}
+
+// NewRegistry builds the providers whose credentials are configured
+func NewRegistry(cfg *config.Config) (*Registry, error) {
+	r := &Registry{providers: make(map[string]Provider)}
+
+	if cfg.IsGoogleOAuthConfigured() {
+		r.Register(NewGoogleProvider(cfg.GoogleOAuth))
+	}
+	if cfg.OAuth.LINE.ClientID != "" && cfg.OAuth.LINE.ClientSecret != "" {
+		r.Register(NewLINEProvider(cfg.OAuth.LINE))
+	}
+	if cfg.OAuth.Facebook.ClientID != "" && cfg.OAuth.Facebook.ClientSecret != "" {
+		r.Register(NewFacebookProvider(cfg.OAuth.Facebook))
+	}
+	if cfg.OAuth.Apple.ClientID != "" {
+		apple, err := NewAppleProvider(cfg.OAuth.Apple)
+		if err != nil {
+			return nil, fmt.Errorf("apple sign-in: %w", err)
+		}
+		r.Register(apple)
+	}
+	if cfg.OAuth.FakeProvider && !cfg.IsProduction() {
+		fake, err := NewFakeProvider(cfg.OAuth.FakeRedirectURL)
+		if err != nil {
+			return nil, fmt.Errorf("fake oauth provider: %w", err)
+		}
+		r.Register(fake)
+	}
+
+	return r, nil
+}
+
+// Register adds (or replaces) a provider
+func (r *Registry) Register(p Provider) {
+	r.providers[p.Name()] = p
+}
+
+// Get returns the enabled provider with the name
+func (r *Registry) Get(name string) (Provider, error) {
+	p, ok := r.providers[name]
+	if !ok {
+		return nil, ErrProviderNotFound
+	}
+	return p, nil
+}
+
+// Names lists the enabled providers in a stable order
+func (r *Registry) Names() []string {
+	names := make([]string, 0, len(r.providers))
+	for name := range r.providers {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// nonceFor derives the OIDC nonce from the PKCE verifier, so the ID token is
+// bound to the same browser flow without storing anything server-side
+func nonceFor(verifier string) string {
+	sum := sha256.Sum256([]byte("nonce:" + verifier))
+	return hex.EncodeToString(sum[:])
+}
@@ -0,0 +1,138 @@
+package outbox
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+
+	"GO2GETHER_BACK-END/internal/models"
+	"GO2GETHER_BACK-END/internal/repository"
+	"GO2GETHER_BACK-END/internal/utils"
+)
+
+type permanentError struct{ err error }
+
+func (e permanentError) Error() string { return e.err.Error() }
+func (e permanentError) Unwrap() error { return e.err }
+
+// Permanent marks err as one a retry cannot fix (e.g. an invalid payload);
+// the event is dead-lettered without further attempts
+func Permanent(err error) error {
+	if err == nil {
+		return nil
+	}
+	return permanentError{err: err}
+}
+
+// IsPermanent reports whether err was marked with Permanent
+func IsPermanent(err error) bool {
+	var p permanentError
+	return errors.As(err, &p)
+}
+
+type postponedError struct{ until time.Time }
+
+func (e postponedError) Error() string { return "postponed until " + e.until.Format(time.RFC3339) }
+
+// Postpone tells the dispatcher to deliver the event on this channel again at
+// until, without counting the attempt as failed (e.g. quiet hours)
+func Postpone(until time.Time) error {
+	return postponedError{until: until}
+}
+
+// postponedUntil reports whether err was returned by Postpone, and until when
+func postponedUntil(err error) (time.Time, bool) {
+	var p postponedError
+	if errors.As(err, &p) {
+		return p.until, true
+	}
+	return time.Time{}, false
+}
+
+// Preferences routes notifications by the recipient's notification
+// preferences (service.PreferenceService)
+type Preferences interface {
+	Route(ctx context.Context, userID uuid.UUID, tripID *uuid.UUID, typ models.NotificationType) (models.NotificationRoute, error)
+}
+
+// EmailSender sends notification emails (utils.EmailService)
+type EmailSender interface {
+	// IsConfigured reports whether emails are actually delivered
+	IsConfigured() bool
+	SendNotification(ctx context.Context, to, locale string, n utils.NotificationEmail) error
+}
+
+type emailChannel struct {
+	users  repository.UserRepository
+	prefs  Preferences
+	sender EmailSender
+}
+
+// Email delivers events by email to the recipient's account address, in the
+// language they chose, unless their preferences turned email off for the
+// event or leave it to their digest; during their quiet hours the email is
+// postponed until the end. When
+// sender is not configured events are skipped, not retried.
+func Email(users repository.UserRepository, prefs Preferences, sender EmailSender) Channel {
+	return emailChannel{users: users, prefs: prefs, sender: sender}
+}
+
+func (emailChannel) Name() string { return models.ChannelEmail }
+
+func (c emailChannel) Deliver(ctx context.Context, e models.OutboxEvent) error {
+	if !c.sender.IsConfigured() {
+		slog.DebugContext(ctx, "email not configured, skipping outbox email", "event_id", e.ID.String())
+		return nil
+	}
+	route, err := c.prefs.Route(ctx, e.RecipientID, e.TripID, e.Type)
+	if err != nil {
+		return err
+	}
+	if route.Digest {
+		slog.DebugContext(ctx, "left for the recipient's digest, skipping outbox email",
+			"event_id", e.ID.String(), "recipient_id", e.RecipientID.String())
+		return nil
+	}
+	if !route.Email {
+		slog.DebugContext(ctx, "email turned off by preferences, skipping outbox email",
+			"event_id", e.ID.String(), "recipient_id", e.RecipientID.String())
+		return nil
+	}
+	if route.Quiet() {
+		return Postpone(route.QuietUntil)
+	}
+
+	to, err := c.users.Email(ctx, e.RecipientID)
+	if errors.Is(err, repository.ErrNotFound) {
+		return Permanent(fmt.Errorf("recipient %s not found", e.RecipientID))
+	}
+	if err != nil {
+		return err
+	}
+	if strings.TrimSpace(to) == "" {
+		return Permanent(errors.New("recipient has no email address"))
+	}
+
+	n := utils.NotificationEmail{
+		Type:  string(e.Type),
+		Title: e.Payload.Title,
+		Data:  e.Payload.Data,
+	}
+	if e.Payload.Message != nil {
+		n.Message = *e.Payload.Message
+	}
+	if e.Payload.ActionURL != nil {
+		n.Link = *e.Payload.ActionURL
+	}
+	err = c.sender.SendNotification(ctx, to, route.Locale, n)
+	if utils.IsPermanentMailError(err) {
+		// เช่น server ปฏิเสธผู้รับ ส่งซ้ำก็ไม่ผ่าน
+		return Permanent(err)
+	}
+	return err
+}
@@ -0,0 +1,69 @@
+package outbox
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+
+	"GO2GETHER_BACK-END/internal/models"
+	"GO2GETHER_BACK-END/internal/repository/memory"
+	"GO2GETHER_BACK-END/internal/utils"
+)
+
+// fakePrefs routes every notification with the same preferences
+type fakePrefs struct {
+	p models.NotificationPreferences
+}
+
+func (f fakePrefs) Route(_ context.Context, _ uuid.UUID, _ *uuid.UUID, typ models.NotificationType) (models.NotificationRoute, error) {
+	return f.p.Route(typ, nil, time.Now()), nil
+}
+
+// fakeSender records the notification emails it is asked to send
+type fakeSender struct{ sent []utils.NotificationEmail }
+
+func (*fakeSender) IsConfigured() bool { return true }
+
+func (f *fakeSender) SendNotification(_ context.Context, _, _ string, n utils.NotificationEmail) error {
+	f.sent = append(f.sent, n)
+	return nil
+}
+
+func TestEmailDeliverLeavesDigestTypesToTheDigest(t *testing.T) {
+	tests := []struct {
+		name      string
+		frequency string
+		typ       models.NotificationType
+		wantSent  bool
+	}{
+		{"digest off", models.DigestOff, models.NotificationMemberJoined, true},
+		{"daily digest, digest type", models.DigestDaily, models.NotificationMemberJoined, false},
+		{"weekly digest, digest type", models.DigestWeekly, models.NotificationMemberLeft, false},
+		{"daily digest, other type", models.DigestDaily, models.NotificationTripUpdate, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			store := memory.New()
+			u := store.PutUser(memory.User{Email: "member@example.com"})
+			p := models.DefaultNotificationPreferences(u.ID)
+			p.DigestFrequency = tt.frequency
+			sender := &fakeSender{}
+
+			ch := Email(store.Repositories().Users, fakePrefs{p}, sender)
+			err := ch.Deliver(context.Background(), models.OutboxEvent{
+				ID:          uuid.New(),
+				Type:        tt.typ,
+				RecipientID: u.ID,
+				Payload:     models.EventPayload{Title: "t"},
+			})
+			if err != nil {
+				t.Fatalf("Deliver() error = %v", err)
+			}
+			if sent := len(sender.sent) == 1; sent != tt.wantSent {
+				t.Errorf("email sent = %v, want %v", sent, tt.wantSent)
+			}
+		})
+	}
+}
@@ -0,0 +1,302 @@
+// Package outbox delivers domain events. The service layer writes an event to
+// outbox_events in the same transaction as the change it reports, so an event
+// exists exactly when its change was committed. The Dispatcher polls for due
+// events, delivers each on the channels it lists (in-app notification, email)
+// and retries failed channels with exponential backoff; events that keep
+// failing, or fail permanently, go to outbox_dead_letters. A channel may also
+// postpone an event (Postpone), e.g. an email during the recipient's quiet
+// hours, which does not count as a failed attempt.
+//
+// Delivery is at least once. Channels already delivered are recorded and
+// skipped on a retry, and the in-app channel derives the notification ID from
+// the event, so a retry after a crash does not notify twice.
+package outbox
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"math/rand/v2"
+	"slices"
+	"sync"
+	"time"
+
+	"GO2GETHER_BACK-END/internal/health"
+	"GO2GETHER_BACK-END/internal/metrics"
+	"GO2GETHER_BACK-END/internal/models"
+	"GO2GETHER_BACK-END/internal/repository"
+	"GO2GETHER_BACK-END/internal/worker"
+)
+
+// Channel delivers events to their recipient on one medium
+type Channel interface {
+	// Name is the channel as events list it (e.g. models.ChannelInApp)
+	Name() string
+	// Deliver sends e to e.RecipientID. Errors a retry cannot fix should be
+	// wrapped with Permanent.
+	Deliver(ctx context.Context, e models.OutboxEvent) error
+}
+
+// Config tunes the Dispatcher
+type Config struct {
+	// PollInterval is the wait between two polls for due events
+	PollInterval time.Duration
+	// BatchSize is the most events claimed by one poll
+	BatchSize int
+	// MaxAttempts is how many times an event is tried before it is dead-lettered
+	MaxAttempts int
+	// Lease hides a claimed event from other dispatchers; it also bounds the
+	// delivery of one event
+	Lease time.Duration
+	// BaseBackoff is the wait before the first retry, doubled on each further
+	// one up to MaxBackoff
+	BaseBackoff time.Duration
+	MaxBackoff  time.Duration
+	// Retention is how long processed events are kept, so their idempotency
+	// keys still dedupe; 0 keeps them forever
+	Retention time.Duration
+}
+
+// pruneEvery is how often processed events older than Retention are deleted
+const pruneEvery = time.Hour
+
+// Dispatcher delivers the events of the outbox on a worker pool
+type Dispatcher struct {
+	repo     repository.OutboxRepository
+	jobs     *worker.Pool
+	cfg      Config
+	channels map[string]Channel
+
+	tracker   health.Worker
+	lastPrune time.Time
+
+	once sync.Once
+	stop chan struct{}
+	done chan struct{}
+}
+
+// NewDispatcher creates a Dispatcher delivering the events of repo on
+// channels; each event is handled as a job of jobs
+func NewDispatcher(repo repository.OutboxRepository, jobs *worker.Pool, cfg Config, channels ...Channel) *Dispatcher {
+	if cfg.PollInterval <= 0 {
+		cfg.PollInterval = time.Second
+	}
+	if cfg.BatchSize <= 0 {
+		cfg.BatchSize = 50
+	}
+	if cfg.MaxAttempts <= 0 {
+		cfg.MaxAttempts = 1
+	}
+	if cfg.Lease <= 0 {
+		cfg.Lease = 30 * time.Second
+	}
+	if cfg.MaxBackoff < cfg.BaseBackoff {
+		cfg.MaxBackoff = cfg.BaseBackoff
+	}
+	d := &Dispatcher{
+		repo:     repo,
+		jobs:     jobs,
+		cfg:      cfg,
+		channels: make(map[string]Channel, len(channels)),
+		stop:     make(chan struct{}),
+		done:     make(chan struct{}),
+	}
+	for _, ch := range channels {
+		d.channels[ch.Name()] = ch
+	}
+	return d
+}
+
+// Tracker tracks the polls, for health.WorkerAlive
+func (d *Dispatcher) Tracker() *health.Worker { return &d.tracker }
+
+// Start polls for due events until Shutdown
+func (d *Dispatcher) Start() {
+	go d.run()
+}
+
+// Shutdown stops polling and waits for the current poll. Events already
+// handed to the worker pool finish when the pool shuts down.
+func (d *Dispatcher) Shutdown(ctx context.Context) error {
+	d.once.Do(func() { close(d.stop) })
+	select {
+	case <-d.done:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+func (d *Dispatcher) run() {
+	defer close(d.done)
+	t := time.NewTicker(d.cfg.PollInterval)
+	defer t.Stop()
+	for {
+		d.poll()
+		select {
+		case <-d.stop:
+			return
+		case <-t.C:
+		}
+	}
+}
+
+// poll claims the due events the worker pool has room for and queues them
+func (d *Dispatcher) poll() {
+	done := d.tracker.Start()
+	defer done()
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	d.prune(ctx)
+
+	limit := min(d.cfg.BatchSize, d.jobs.Capacity()-d.jobs.Depth())
+	if limit <= 0 {
+		return
+	}
+	events, err := d.repo.Claim(ctx, limit, d.cfg.Lease)
+	if err != nil {
+		slog.Error("claiming outbox events failed", "error", err)
+		return
+	}
+	for _, e := range events {
+		if err := d.jobs.Submit(ctx, func(ctx context.Context) { d.process(ctx, e) }); err != nil {
+			// ยังไม่หาย: lease หมดแล้ว poll ถัดไปจะ claim ใหม่
+			slog.Warn("outbox event not queued", "event_id", e.ID.String(), "error", err)
+		}
+	}
+}
+
+func (d *Dispatcher) prune(ctx context.Context) {
+	if d.cfg.Retention <= 0 || time.Since(d.lastPrune) < pruneEvery {
+		return
+	}
+	d.lastPrune = time.Now()
+	n, err := d.repo.Prune(ctx, time.Now().Add(-d.cfg.Retention))
+	if err != nil {
+		slog.Error("pruning outbox events failed", "error", err)
+		return
+	}
+	if n > 0 {
+		slog.Info("pruned outbox events", "count", n)
+	}
+}
+
+// process delivers e on each of its channels not delivered yet, then marks
+// it done, postpones it, schedules a retry or dead-letters it
+func (d *Dispatcher) process(ctx context.Context, e models.OutboxEvent) {
+	// เสร็จก่อน lease หมด ไม่งั้น dispatcher อื่นจะ claim ซ้ำระหว่างส่ง
+	dctx, cancel := context.WithTimeout(ctx, d.cfg.Lease)
+	defer cancel()
+
+	delivered, err := d.repo.Delivered(dctx, e.ID)
+	if err != nil {
+		d.fail(ctx, e, err)
+		return
+	}
+	var errs []error
+	var postponed time.Time
+	for _, name := range e.Channels {
+		if slices.Contains(delivered, name) {
+			continue
+		}
+		ch, ok := d.channels[name]
+		if !ok {
+			errs = append(errs, Permanent(fmt.Errorf("%s: unknown channel", name)))
+			continue
+		}
+		err := ch.Deliver(dctx, e)
+		if until, ok := postponedUntil(err); ok {
+			if postponed.IsZero() || until.Before(postponed) {
+				postponed = until
+			}
+			continue
+		}
+		metrics.OutboxDelivery(name, err == nil)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("%s: %w", name, err))
+			continue
+		}
+		if err := d.repo.MarkDelivered(dctx, e.ID, name); err != nil {
+			errs = append(errs, fmt.Errorf("%s: record delivery: %w", name, err))
+		}
+	}
+	if len(errs) > 0 {
+		d.fail(ctx, e, errs...)
+		return
+	}
+
+	sctx, scancel := settleCtx(ctx)
+	defer scancel()
+	if !postponed.IsZero() {
+		// channels ที่ส่งแล้วถูกบันทึกไว้ รอบหน้าส่งเฉพาะที่เลื่อนไว้
+		if err := d.repo.Defer(sctx, e.ID, postponed); err != nil {
+			slog.ErrorContext(ctx, "postponing outbox event failed", "event_id", e.ID.String(), "error", err)
+			return
+		}
+		metrics.OutboxEvent(string(e.Type), metrics.OutboxPostponed)
+		slog.DebugContext(ctx, "outbox event postponed", "event_id", e.ID.String(), "until", postponed)
+		return
+	}
+	if err := d.repo.Done(sctx, e.ID); err != nil {
+		slog.ErrorContext(ctx, "marking outbox event done failed", "event_id", e.ID.String(), "error", err)
+		return
+	}
+	metrics.OutboxEvent(string(e.Type), metrics.OutboxDelivered)
+	metrics.OutboxLag(time.Since(e.CreatedAt))
+}
+
+// fail retries e later, or dead-letters it when every error is permanent or
+// it has used its last attempt
+func (d *Dispatcher) fail(ctx context.Context, e models.OutboxEvent, errs ...error) {
+	err := errors.Join(errs...)
+	permanent := true
+	for _, err := range errs {
+		permanent = permanent && IsPermanent(err)
+	}
+	sctx, cancel := settleCtx(ctx)
+	defer cancel()
+
+	if permanent || e.Attempts >= d.cfg.MaxAttempts {
+		if derr := d.repo.DeadLetter(sctx, e.ID, err.Error()); derr != nil {
+			slog.ErrorContext(ctx, "dead-lettering outbox event failed", "event_id", e.ID.String(), "error", derr)
+			return
+		}
+		metrics.OutboxEvent(string(e.Type), metrics.OutboxDeadLettered)
+		slog.ErrorContext(ctx, "outbox event dead-lettered",
+			"event_id", e.ID.String(), "type", string(e.Type), "attempts", e.Attempts, "error", err)
+		return
+	}
+
+	wait := d.backoff(e.Attempts)
+	if rerr := d.repo.Retry(sctx, e.ID, time.Now().Add(wait), err.Error()); rerr != nil {
+		// lease จะหมดเอง แล้ว event ถูก claim ใหม่
+		slog.ErrorContext(ctx, "scheduling outbox retry failed", "event_id", e.ID.String(), "error", rerr)
+		return
+	}
+	metrics.OutboxEvent(string(e.Type), metrics.OutboxRetried)
+	slog.WarnContext(ctx, "outbox event delivery failed",
+		"event_id", e.ID.String(), "type", string(e.Type), "attempt", e.Attempts,
+		"retry_in", wait.String(), "error", err)
+}
+
+// backoff is the wait after attempt: BaseBackoff doubled per attempt up to
+// MaxBackoff, plus up to 20% jitter so failed events do not retry in lockstep
+func (d *Dispatcher) backoff(attempt int) time.Duration {
+	wait := d.cfg.BaseBackoff
+	for i := 1; i < attempt && wait < d.cfg.MaxBackoff; i++ {
+		wait *= 2
+	}
+	wait = min(wait, d.cfg.MaxBackoff)
+	if wait > 0 {
+		wait += rand.N(wait/5 + 1)
+	}
+	return wait
+}
+
+// settleCtx bounds the bookkeeping after a delivery, which must run even
+// when the delivery used up its time
+func settleCtx(ctx context.Context) (context.Context, context.CancelFunc) {
+	return context.WithTimeout(context.WithoutCancel(ctx), 5*time.Second)
+}
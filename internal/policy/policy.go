@@ -0,0 +1,191 @@
+// Package policy decides what a trip member may do. Every trip use case asks
+// it instead of comparing creator IDs and role strings itself, and the
+// permissions block of the trip detail is read from the same rules.
+package policy
+
+import "strings"
+
+// Role is a member's role in a trip (trip_members.role)
+type Role string
+
+const (
+	// RoleOwner is the single member who owns the trip (trips.creator_id)
+	RoleOwner Role = "owner"
+	// RoleCoOrganizer manages the trip with the owner
+	RoleCoOrganizer Role = "co_organizer"
+	// RoleMember takes part in the trip and submits availability
+	RoleMember Role = "member"
+	// RoleViewer can only look at the trip
+	RoleViewer Role = "viewer"
+	// RoleNone is a user with no membership in the trip
+	RoleNone Role = ""
+)
+
+// Roles lists the roles in order of decreasing rank
+var Roles = []Role{RoleOwner, RoleCoOrganizer, RoleMember, RoleViewer}
+
+// ParseRole reads a role as stored or sent by a client. "creator", the role
+// name used before owners and co-organizers existed, reads as owner.
+func ParseRole(s string) (Role, bool) {
+	switch r := Role(strings.ToLower(strings.TrimSpace(s))); r {
+	case RoleOwner, "creator":
+		return RoleOwner, true
+	case RoleCoOrganizer, RoleMember, RoleViewer:
+		return r, true
+	}
+	return RoleNone, false
+}
+
+// rank orders roles; a higher rank may manage lower ones
+func (r Role) rank() int {
+	switch r {
+	case RoleOwner:
+		return 4
+	case RoleCoOrganizer:
+		return 3
+	case RoleMember:
+		return 2
+	case RoleViewer:
+		return 1
+	}
+	return 0
+}
+
+// Organizer reports whether r manages the trip
+func (r Role) Organizer() bool { return r == RoleOwner || r == RoleCoOrganizer }
+
+// Action is something a user may want to do on a trip
+type Action string
+
+const (
+	ActionView               Action = "view"
+	ActionSubmitAvailability Action = "submit_availability"
+	ActionEdit               Action = "edit"
+	ActionInvite             Action = "invite"
+	ActionManageBudget       Action = "manage_budget"
+	ActionFinalizeDates      Action = "finalize_dates"
+	ActionRemoveMember       Action = "remove_member"
+	// ActionManageRoles covers promoting/demoting members and the organizer
+	// MFA requirement
+	ActionManageRoles       Action = "manage_roles"
+	ActionTransferOwnership Action = "transfer_ownership"
+	ActionDelete            Action = "delete"
+	ActionLeave             Action = "leave"
+)
+
+// grants is the policy: the roles allowed to perform each action
+var grants = map[Action][]Role{
+	ActionView:               {RoleOwner, RoleCoOrganizer, RoleMember, RoleViewer},
+	ActionSubmitAvailability: {RoleOwner, RoleCoOrganizer, RoleMember},
+	ActionEdit:               {RoleOwner, RoleCoOrganizer},
+	ActionInvite:             {RoleOwner, RoleCoOrganizer},
+	ActionManageBudget:       {RoleOwner, RoleCoOrganizer},
+	ActionFinalizeDates:      {RoleOwner, RoleCoOrganizer},
+	ActionRemoveMember:       {RoleOwner, RoleCoOrganizer},
+	ActionManageRoles:        {RoleOwner},
+	ActionTransferOwnership:  {RoleOwner},
+	ActionDelete:             {RoleOwner},
+	ActionLeave:              {RoleCoOrganizer, RoleMember, RoleViewer},
+}
+
+// mfaGated are the actions a co-organizer loses while the trip requires
+// organizer two-factor auth and they have not enabled it
+var mfaGated = map[Action]bool{
+	ActionEdit:          true,
+	ActionInvite:        true,
+	ActionManageBudget:  true,
+	ActionFinalizeDates: true,
+	ActionRemoveMember:  true,
+}
+
+// Subject is a user as seen by the policy on one trip
+type Subject struct {
+	Role Role
+	// MFABlocked: the trip requires organizer MFA and the user has not enabled it
+	MFABlocked bool
+}
+
+// Decision is the outcome of Authorize
+type Decision int
+
+const (
+	Allow Decision = iota
+	// Deny: the role does not grant the action
+	Deny
+	// DenyMFA: the role grants the action but two-factor auth is missing
+	DenyMFA
+)
+
+// Authorize decides whether s may perform a
+func Authorize(s Subject, a Action) Decision {
+	granted := false
+	for _, r := range grants[a] {
+		if r == s.Role {
+			granted = true
+			break
+		}
+	}
+	switch {
+	case !granted:
+		return Deny
+	case s.MFABlocked && s.Role == RoleCoOrganizer && mfaGated[a]:
+		return DenyMFA
+	}
+	return Allow
+}
+
+// Can reports whether s may perform a
+func (s Subject) Can(a Action) bool { return Authorize(s, a) == Allow }
+
+// CanRemove reports whether s may remove a member with role target: organizers
+// remove lower-ranked members only, so nobody removes the owner
+func (s Subject) CanRemove(target Role) bool {
+	return s.Can(ActionRemoveMember) && s.Role.rank() > target.rank()
+}
+
+// CanAssign reports whether s may change a member's role from cur to next.
+// Ownership only moves through a transfer.
+func (s Subject) CanAssign(cur, next Role) bool {
+	if cur == RoleOwner || next == RoleOwner || next == RoleNone {
+		return false
+	}
+	return s.Can(ActionManageRoles)
+}
+
+// Permissions is the set of actions the trip detail reports to the client
+type Permissions struct {
+	Role                 Role
+	CanEdit              bool
+	CanDelete            bool
+	CanInvite            bool
+	CanManageBudget      bool
+	CanFinalizeDates     bool
+	CanRemoveMembers     bool
+	CanManageRoles       bool
+	CanTransferOwnership bool
+	// MFARequired: a co-organizer lost the organizer permissions until they
+	// enable two-factor auth
+	MFARequired bool
+}
+
+// PermissionsOf evaluates every reported action for s
+func PermissionsOf(s Subject) Permissions {
+	p := Permissions{
+		Role:                 s.Role,
+		CanEdit:              s.Can(ActionEdit),
+		CanDelete:            s.Can(ActionDelete),
+		CanInvite:            s.Can(ActionInvite),
+		CanManageBudget:      s.Can(ActionManageBudget),
+		CanFinalizeDates:     s.Can(ActionFinalizeDates),
+		CanRemoveMembers:     s.Can(ActionRemoveMember),
+		CanManageRoles:       s.Can(ActionManageRoles),
+		CanTransferOwnership: s.Can(ActionTransferOwnership),
+	}
+	for a := range mfaGated {
+		if Authorize(s, a) == DenyMFA {
+			p.MFARequired = true
+			break
+		}
+	}
+	return p
+}
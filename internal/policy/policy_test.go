@@ -0,0 +1,150 @@
+package policy
+
+import "testing"
+
+func TestAuthorize(t *testing.T) {
+	type want map[Role]Decision
+	all := func(d Decision) want {
+		return want{RoleOwner: d, RoleCoOrganizer: d, RoleMember: d, RoleViewer: d, RoleNone: d}
+	}
+	organizers := want{RoleOwner: Allow, RoleCoOrganizer: Allow, RoleMember: Deny, RoleViewer: Deny, RoleNone: Deny}
+	ownerOnly := want{RoleOwner: Allow, RoleCoOrganizer: Deny, RoleMember: Deny, RoleViewer: Deny, RoleNone: Deny}
+
+	tests := []struct {
+		action Action
+		want   want
+	}{
+		{ActionView, want{RoleOwner: Allow, RoleCoOrganizer: Allow, RoleMember: Allow, RoleViewer: Allow, RoleNone: Deny}},
+		{ActionSubmitAvailability, want{RoleOwner: Allow, RoleCoOrganizer: Allow, RoleMember: Allow, RoleViewer: Deny, RoleNone: Deny}},
+		{ActionEdit, organizers},
+		{ActionInvite, organizers},
+		{ActionManageBudget, organizers},
+		{ActionFinalizeDates, organizers},
+		{ActionRemoveMember, organizers},
+		{ActionManageRoles, ownerOnly},
+		{ActionTransferOwnership, ownerOnly},
+		{ActionDelete, ownerOnly},
+		{ActionLeave, want{RoleOwner: Deny, RoleCoOrganizer: Allow, RoleMember: Allow, RoleViewer: Allow, RoleNone: Deny}},
+		{Action("unknown"), all(Deny)},
+	}
+	for _, tt := range tests {
+		for role, want := range tt.want {
+			if got := Authorize(Subject{Role: role}, tt.action); got != want {
+				t.Errorf("Authorize(%q, %s) = %v, want %v", role, tt.action, got, want)
+			}
+		}
+	}
+}
+
+func TestAuthorizeMFABlocked(t *testing.T) {
+	tests := []struct {
+		role   Role
+		action Action
+		want   Decision
+	}{
+		// co-organizers lose the gated organizer actions
+		{RoleCoOrganizer, ActionEdit, DenyMFA},
+		{RoleCoOrganizer, ActionInvite, DenyMFA},
+		{RoleCoOrganizer, ActionManageBudget, DenyMFA},
+		{RoleCoOrganizer, ActionFinalizeDates, DenyMFA},
+		{RoleCoOrganizer, ActionRemoveMember, DenyMFA},
+		// but keep what members can do
+		{RoleCoOrganizer, ActionView, Allow},
+		{RoleCoOrganizer, ActionSubmitAvailability, Allow},
+		{RoleCoOrganizer, ActionLeave, Allow},
+		// the owner is never gated; members never had the actions
+		{RoleOwner, ActionEdit, Allow},
+		{RoleOwner, ActionDelete, Allow},
+		{RoleMember, ActionEdit, Deny},
+		{RoleViewer, ActionInvite, Deny},
+	}
+	for _, tt := range tests {
+		if got := Authorize(Subject{Role: tt.role, MFABlocked: true}, tt.action); got != tt.want {
+			t.Errorf("Authorize(%q blocked, %s) = %v, want %v", tt.role, tt.action, got, tt.want)
+		}
+	}
+}
+
+func TestCanRemove(t *testing.T) {
+	tests := []struct {
+		subject Subject
+		target  Role
+		want    bool
+	}{
+		{Subject{Role: RoleOwner}, RoleCoOrganizer, true},
+		{Subject{Role: RoleOwner}, RoleMember, true},
+		{Subject{Role: RoleOwner}, RoleViewer, true},
+		{Subject{Role: RoleOwner}, RoleOwner, false},
+		{Subject{Role: RoleCoOrganizer}, RoleMember, true},
+		{Subject{Role: RoleCoOrganizer}, RoleViewer, true},
+		{Subject{Role: RoleCoOrganizer}, RoleCoOrganizer, false},
+		{Subject{Role: RoleCoOrganizer}, RoleOwner, false},
+		{Subject{Role: RoleCoOrganizer, MFABlocked: true}, RoleMember, false},
+		{Subject{Role: RoleMember}, RoleViewer, false},
+		{Subject{Role: RoleViewer}, RoleViewer, false},
+		{Subject{Role: RoleNone}, RoleViewer, false},
+	}
+	for _, tt := range tests {
+		if got := tt.subject.CanRemove(tt.target); got != tt.want {
+			t.Errorf("%+v.CanRemove(%q) = %v, want %v", tt.subject, tt.target, got, tt.want)
+		}
+	}
+}
+
+func TestCanAssign(t *testing.T) {
+	owner := Subject{Role: RoleOwner}
+	tests := []struct {
+		subject   Subject
+		cur, next Role
+		want      bool
+	}{
+		{owner, RoleMember, RoleCoOrganizer, true},
+		{owner, RoleCoOrganizer, RoleMember, true},
+		{owner, RoleMember, RoleViewer, true},
+		{owner, RoleViewer, RoleMember, true},
+		// ownership only moves through a transfer
+		{owner, RoleMember, RoleOwner, false},
+		{owner, RoleOwner, RoleMember, false},
+		{owner, RoleMember, RoleNone, false},
+		{Subject{Role: RoleCoOrganizer}, RoleMember, RoleViewer, false},
+		{Subject{Role: RoleMember}, RoleViewer, RoleMember, false},
+	}
+	for _, tt := range tests {
+		if got := tt.subject.CanAssign(tt.cur, tt.next); got != tt.want {
+			t.Errorf("%+v.CanAssign(%q, %q) = %v, want %v", tt.subject, tt.cur, tt.next, got, tt.want)
+		}
+	}
+}
+
+func TestParseRole(t *testing.T) {
+	tests := []struct {
+		in   string
+		want Role
+		ok   bool
+	}{
+		{"owner", RoleOwner, true},
+		{"creator", RoleOwner, true},
+		{" Co_Organizer ", RoleCoOrganizer, true},
+		{"member", RoleMember, true},
+		{"viewer", RoleViewer, true},
+		{"admin", RoleNone, false},
+		{"", RoleNone, false},
+	}
+	for _, tt := range tests {
+		got, ok := ParseRole(tt.in)
+		if got != tt.want || ok != tt.ok {
+			t.Errorf("ParseRole(%q) = %q, %v; want %q, %v", tt.in, got, ok, tt.want, tt.ok)
+		}
+	}
+}
+
+func TestPermissionsOf(t *testing.T) {
+	p := PermissionsOf(Subject{Role: RoleCoOrganizer, MFABlocked: true})
+	if p.CanEdit || p.CanInvite || p.CanRemoveMembers || !p.MFARequired {
+		t.Errorf("blocked co-organizer permissions = %+v", p)
+	}
+	p = PermissionsOf(Subject{Role: RoleOwner})
+	if !p.CanEdit || !p.CanDelete || !p.CanManageRoles || !p.CanTransferOwnership || p.MFARequired {
+		t.Errorf("owner permissions = %+v", p)
+	}
+}
@@ -0,0 +1,161 @@
+// Package realtime pushes notifications to connected clients as they are
+// created. NotificationsService publishes every stored notification; the Hub
+// fans it out to the open streams of its user (SSE or WebSocket), and
+// PGBridge relays it through Postgres LISTEN/NOTIFY to the hubs of the other
+// replicas.
+//
+// Streams are best effort: a client that falls behind or reconnects resumes
+// from the ID of the last notification it saw, which is read back from the
+// database.
+package realtime
+
+import (
+	"context"
+	"sync"
+
+	"github.com/google/uuid"
+
+	"GO2GETHER_BACK-END/internal/models"
+)
+
+// Publisher fans a stored notification out to the streams of its user
+type Publisher interface {
+	Publish(ctx context.Context, n models.Notification)
+}
+
+// recentSize is how many published IDs the hub remembers to drop repeats,
+// such as an outbox retry delivering a notification that already exists
+const recentSize = 1024
+
+// Hub keeps the open streams of this process by user
+type Hub struct {
+	buffer int
+
+	mu     sync.Mutex
+	subs   map[uuid.UUID]map[*Subscription]struct{}
+	closed bool
+
+	recent    map[uuid.UUID]struct{}
+	recentIDs []uuid.UUID // ring of the keys of recent
+	next      int
+}
+
+// NewHub creates a hub; each subscription buffers up to buffer notifications
+// before it is dropped as too slow
+func NewHub(buffer int) *Hub {
+	if buffer < 1 {
+		buffer = 1
+	}
+	return &Hub{
+		buffer:    buffer,
+		subs:      make(map[uuid.UUID]map[*Subscription]struct{}),
+		recent:    make(map[uuid.UUID]struct{}, recentSize),
+		recentIDs: make([]uuid.UUID, recentSize),
+	}
+}
+
+// Subscription receives the notifications of one user. C is closed when the
+// subscriber fell behind or the hub shut down; the client should then
+// reconnect and resume.
+type Subscription struct {
+	C <-chan models.Notification
+
+	c      chan models.Notification
+	userID uuid.UUID
+	hub    *Hub
+	once   sync.Once
+}
+
+// Subscribe opens a subscription to the notifications of userID
+func (h *Hub) Subscribe(userID uuid.UUID) *Subscription {
+	c := make(chan models.Notification, h.buffer)
+	s := &Subscription{C: c, c: c, userID: userID, hub: h}
+
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if h.closed {
+		s.once.Do(func() { close(c) })
+		return s
+	}
+	if h.subs[userID] == nil {
+		h.subs[userID] = make(map[*Subscription]struct{})
+	}
+	h.subs[userID][s] = struct{}{}
+	return s
+}
+
+// Close ends the subscription
+func (s *Subscription) Close() {
+	s.hub.mu.Lock()
+	defer s.hub.mu.Unlock()
+	s.hub.remove(s)
+}
+
+// remove unregisters s and closes its channel; the caller holds h.mu
+func (h *Hub) remove(s *Subscription) {
+	if subs := h.subs[s.userID]; subs != nil {
+		delete(subs, s)
+		if len(subs) == 0 {
+			delete(h.subs, s.userID)
+		}
+	}
+	s.once.Do(func() { close(s.c) })
+}
+
+// Publish sends n to the subscriptions of n.UserID without blocking.
+// Subscriptions with a full buffer are closed.
+func (h *Hub) Publish(_ context.Context, n models.Notification) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if h.closed || h.seen(n.ID) {
+		return
+	}
+	for s := range h.subs[n.UserID] {
+		select {
+		case s.c <- n:
+		default:
+			// ช้าเกินไป: ปิดให้ client reconnect แล้ว resume ด้วย Last-Event-ID
+			h.remove(s)
+		}
+	}
+}
+
+// seen records id and reports whether it was published recently; the caller
+// holds h.mu
+func (h *Hub) seen(id uuid.UUID) bool {
+	if _, ok := h.recent[id]; ok {
+		return true
+	}
+	if old := h.recentIDs[h.next]; old != uuid.Nil {
+		delete(h.recent, old)
+	}
+	h.recentIDs[h.next] = id
+	h.recent[id] = struct{}{}
+	h.next = (h.next + 1) % len(h.recentIDs)
+	return false
+}
+
+// Subscribers is the number of open subscriptions
+func (h *Hub) Subscribers() int {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	n := 0
+	for _, subs := range h.subs {
+		n += len(subs)
+	}
+	return n
+}
+
+// Shutdown closes every subscription, ending the open streams so the HTTP
+// server can shut down; later subscriptions are closed at once
+func (h *Hub) Shutdown(context.Context) error {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.closed = true
+	for _, subs := range h.subs {
+		for s := range subs {
+			h.remove(s)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,161 @@
+package realtime
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"log/slog"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+
+	"GO2GETHER_BACK-END/internal/models"
+)
+
+// channel is the LISTEN/NOTIFY channel of notifications
+const channel = "go2gether_notifications"
+
+// maxPayload keeps NOTIFY payloads under Postgres' 8000-byte limit; larger
+// notifications are sent by ID and read back by the listeners
+const maxPayload = 7000
+
+// Loader reads a notification by ID (repository.NotificationRepository)
+type Loader interface {
+	Get(ctx context.Context, id uuid.UUID) (models.Notification, error)
+}
+
+type message struct {
+	Origin       string               `json:"origin"`
+	ID           uuid.UUID            `json:"id"`
+	Notification *models.Notification `json:"notification,omitempty"`
+}
+
+// PGBridge publishes notifications to the local hub and, through Postgres
+// NOTIFY, to the hubs of the other replicas, whose Listen loop relays them
+type PGBridge struct {
+	db     *pgxpool.Pool
+	hub    *Hub
+	loader Loader
+	origin string // ignores our own NOTIFYs, already published locally
+
+	cancel context.CancelFunc
+	wg     sync.WaitGroup
+}
+
+// NewPGBridge creates a bridge between hub and the other replicas over db
+func NewPGBridge(db *pgxpool.Pool, hub *Hub, loader Loader) *PGBridge {
+	return &PGBridge{db: db, hub: hub, loader: loader, origin: uuid.NewString()}
+}
+
+// Publish implements Publisher
+func (b *PGBridge) Publish(ctx context.Context, n models.Notification) {
+	b.hub.Publish(ctx, n)
+
+	msg := message{Origin: b.origin, ID: n.ID, Notification: &n}
+	payload, err := json.Marshal(msg)
+	if err == nil && len(payload) > maxPayload {
+		msg.Notification = nil
+		payload, err = json.Marshal(msg)
+	}
+	if err != nil {
+		slog.ErrorContext(ctx, "encoding notification for NOTIFY failed", "error", err, "notification_id", n.ID.String())
+		return
+	}
+	if _, err := b.db.Exec(ctx, `SELECT pg_notify($1, $2)`, channel, string(payload)); err != nil {
+		// ผู้ใช้ที่ต่อกับ replica อื่นจะได้รับตอน reconnect/resume แทน
+		slog.WarnContext(ctx, "NOTIFY of notification failed", "error", err, "notification_id", n.ID.String())
+	}
+}
+
+// Start listens for the notifications of the other replicas until Shutdown,
+// reconnecting when the connection drops
+func (b *PGBridge) Start() {
+	ctx, cancel := context.WithCancel(context.Background())
+	b.cancel = cancel
+	b.wg.Add(1)
+	go func() {
+		defer b.wg.Done()
+		wait := time.Second
+		for {
+			err := b.listen(ctx)
+			if ctx.Err() != nil {
+				return
+			}
+			slog.Warn("notification listener disconnected", "error", err, "retry_in", wait.String())
+			select {
+			case <-ctx.Done():
+				return
+			case <-time.After(wait):
+			}
+			wait = min(wait*2, 30*time.Second)
+		}
+	}()
+}
+
+// Shutdown stops listening
+func (b *PGBridge) Shutdown(ctx context.Context) error {
+	if b.cancel == nil {
+		return nil
+	}
+	b.cancel()
+	done := make(chan struct{})
+	go func() {
+		b.wg.Wait()
+		close(done)
+	}()
+	select {
+	case <-done:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// listen holds one connection out of the pool in LISTEN and relays what
+// arrives on it
+func (b *PGBridge) listen(ctx context.Context) error {
+	pc, err := b.db.Acquire(ctx)
+	if err != nil {
+		return err
+	}
+	// ไม่คืน connection ที่ LISTEN อยู่กลับเข้า pool
+	conn := pc.Hijack()
+	defer conn.Close(context.Background())
+
+	if _, err := conn.Exec(ctx, "LISTEN "+pgx.Identifier{channel}.Sanitize()); err != nil {
+		return err
+	}
+	slog.Debug("listening for notifications", "channel", channel)
+	for {
+		pn, err := conn.WaitForNotification(ctx)
+		if err != nil {
+			return err
+		}
+		b.relay(ctx, pn.Payload)
+	}
+}
+
+func (b *PGBridge) relay(ctx context.Context, payload string) {
+	var msg message
+	if err := json.Unmarshal([]byte(payload), &msg); err != nil {
+		slog.Warn("malformed notification NOTIFY", "error", err)
+		return
+	}
+	if msg.Origin == b.origin {
+		return
+	}
+	if msg.Notification == nil {
+		n, err := b.loader.Get(ctx, msg.ID)
+		if err != nil {
+			if !errors.Is(err, context.Canceled) {
+				slog.Warn("loading notified notification failed", "error", err, "notification_id", msg.ID.String())
+			}
+			return
+		}
+		msg.Notification = &n
+	}
+	b.hub.Publish(ctx, *msg.Notification)
+}
@@ -0,0 +1,61 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"net/http"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
+
+	"GO2GETHER_BACK-END/internal/apperr"
+)
+
+// PostgreSQL error codes mapped by AppError
+const (
+	pgUniqueViolation     = "23505"
+	pgForeignKeyViolation = "23503"
+	pgCheckViolation      = "23514"
+	pgNotNullViolation    = "23502"
+	pgInvalidText         = "22P02"
+	pgStringTooLong       = "22001"
+)
+
+// AppError maps storage errors to client errors without exposing SQL,
+// constraint or column names. It returns nil for errors it does not know.
+//
+//   - no rows / ErrNotFound → 404
+//   - unique violation / ErrAlreadyExists → 409
+//   - foreign key violation → 409 (the referenced row is missing or still in use)
+//   - check / not-null violation, malformed value → 400
+//   - timeout or cancellation → 503
+func AppError(err error) *apperr.Error {
+	switch {
+	case err == nil:
+		return nil
+	case errors.Is(err, pgx.ErrNoRows), errors.Is(err, ErrNotFound):
+		return apperr.NotFound("Resource not found").WithCause(err)
+	case errors.Is(err, ErrUsernameTaken):
+		return apperr.New(http.StatusConflict, apperr.CodeUsernameTaken, "Conflict", "username already taken").WithCause(err)
+	case errors.Is(err, ErrAlreadyExists):
+		return apperr.New(http.StatusConflict, apperr.CodeAlreadyExists, "Conflict", "Resource already exists").WithCause(err)
+	case errors.Is(err, context.DeadlineExceeded), errors.Is(err, context.Canceled):
+		return apperr.New(http.StatusServiceUnavailable, apperr.CodeUnavailable, "Service unavailable",
+			"The request took too long, please try again").WithCause(err)
+	}
+
+	var pgErr *pgconn.PgError
+	if !errors.As(err, &pgErr) {
+		return nil
+	}
+	switch pgErr.Code {
+	case pgUniqueViolation:
+		return apperr.New(http.StatusConflict, apperr.CodeAlreadyExists, "Conflict", "Resource already exists").WithCause(err)
+	case pgForeignKeyViolation:
+		return apperr.New(http.StatusConflict, apperr.CodeReferenced, "Conflict",
+			"The request refers to a resource that does not exist or is still in use").WithCause(err)
+	case pgCheckViolation, pgNotNullViolation, pgInvalidText, pgStringTooLong:
+		return apperr.New(http.StatusBadRequest, apperr.CodeValidation, "Validation error", "A value is missing or invalid").WithCause(err)
+	}
+	return apperr.Internal(err)
+}
@@ -0,0 +1,54 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"testing"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
+
+	"GO2GETHER_BACK-END/internal/apperr"
+)
+
+func TestAppError(t *testing.T) {
+	pg := func(code string) error {
+		return fmt.Errorf("insert trip: %w", &pgconn.PgError{Code: code, ConstraintName: "trips_pkey"})
+	}
+	tests := []struct {
+		name   string
+		err    error
+		status int
+		code   apperr.Code
+	}{
+		{"no rows", pgx.ErrNoRows, http.StatusNotFound, apperr.CodeNotFound},
+		{"not found", fmt.Errorf("get trip: %w", ErrNotFound), http.StatusNotFound, apperr.CodeNotFound},
+		{"already exists", ErrAlreadyExists, http.StatusConflict, apperr.CodeAlreadyExists},
+		{"username taken", ErrUsernameTaken, http.StatusConflict, apperr.CodeUsernameTaken},
+		{"timeout", context.DeadlineExceeded, http.StatusServiceUnavailable, apperr.CodeUnavailable},
+		{"unique violation", pg(pgUniqueViolation), http.StatusConflict, apperr.CodeAlreadyExists},
+		{"foreign key violation", pg(pgForeignKeyViolation), http.StatusConflict, apperr.CodeReferenced},
+		{"check violation", pg(pgCheckViolation), http.StatusBadRequest, apperr.CodeValidation},
+		{"other postgres error", pg("40001"), http.StatusInternalServerError, apperr.CodeInternal},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			e := AppError(tt.err)
+			if e == nil {
+				t.Fatal("AppError() = nil")
+			}
+			if e.Status != tt.status || e.Code != tt.code {
+				t.Errorf("AppError() = %d %s, want %d %s", e.Status, e.Code, tt.status, tt.code)
+			}
+			if !errors.Is(e, tt.err) {
+				t.Error("AppError() dropped the cause")
+			}
+		})
+	}
+
+	if e := AppError(errors.New("boom")); e != nil {
+		t.Errorf("AppError(unknown) = %v, want nil", e)
+	}
+}
@@ -0,0 +1,131 @@
+package memory
+
+import (
+	"context"
+
+	"github.com/google/uuid"
+
+	"GO2GETHER_BACK-END/internal/models"
+	"GO2GETHER_BACK-END/internal/repository"
+)
+
+type userRepo struct{ s *Store }
+
+func (r userRepo) EmailVerified(_ context.Context, userID uuid.UUID) (bool, error) {
+	r.s.mu.Lock()
+	defer r.s.mu.Unlock()
+	u, ok := r.s.users[userID]
+	if !ok {
+		return false, repository.ErrNotFound
+	}
+	return u.EmailVerified, nil
+}
+
+func (r userRepo) MFAEnabled(_ context.Context, userID uuid.UUID) (bool, error) {
+	r.s.mu.Lock()
+	defer r.s.mu.Unlock()
+	u, ok := r.s.users[userID]
+	if !ok {
+		return false, repository.ErrNotFound
+	}
+	return u.MFAEnabled, nil
+}
+
+func (r userRepo) Email(_ context.Context, userID uuid.UUID) (string, error) {
+	r.s.mu.Lock()
+	defer r.s.mu.Unlock()
+	u, ok := r.s.users[userID]
+	if !ok {
+		return "", repository.ErrNotFound
+	}
+	return u.Email, nil
+}
+
+type profileRepo struct{ s *Store }
+
+func (r profileRepo) Exists(_ context.Context, userID uuid.UUID) (bool, error) {
+	r.s.mu.Lock()
+	defer r.s.mu.Unlock()
+	_, ok := r.s.profiles[userID]
+	return ok, nil
+}
+
+func (r profileRepo) Get(_ context.Context, userID uuid.UUID) (models.UserProfile, error) {
+	r.s.mu.Lock()
+	defer r.s.mu.Unlock()
+	p, ok := r.s.profiles[userID]
+	u, hasUser := r.s.users[userID]
+	if !ok || !hasUser {
+		return models.UserProfile{}, repository.ErrNotFound
+	}
+	p.Email, p.Role, p.CreatedAt, p.UpdatedAt = u.Email, u.Role, u.CreatedAt, u.CreatedAt
+	return p, nil
+}
+
+// usernameTaken reports whether another user has the username; s.mu must be held
+func (s *Store) usernameTaken(userID uuid.UUID, username string) bool {
+	for id, p := range s.profiles {
+		if id != userID && p.Username == username {
+			return true
+		}
+	}
+	return false
+}
+
+func (r profileRepo) Create(_ context.Context, p models.UserProfile) error {
+	r.s.mu.Lock()
+	defer r.s.mu.Unlock()
+	if r.s.usernameTaken(p.UserID, p.Username) {
+		return repository.ErrUsernameTaken
+	}
+	if _, ok := r.s.profiles[p.UserID]; ok {
+		return repository.ErrAlreadyExists
+	}
+	r.s.profiles[p.UserID] = p
+	return nil
+}
+
+func (r profileRepo) Update(_ context.Context, userID uuid.UUID, u models.ProfileUpdate) error {
+	r.s.mu.Lock()
+	defer r.s.mu.Unlock()
+	p, ok := r.s.profiles[userID]
+	if !ok {
+		return repository.ErrNotFound
+	}
+	if u.Username != nil {
+		if r.s.usernameTaken(userID, *u.Username) {
+			return repository.ErrUsernameTaken
+		}
+		p.Username = *u.Username
+	}
+	set := func(dst **string, v *string) {
+		if v == nil {
+			return
+		}
+		if *v == "" {
+			*dst = nil
+			return
+		}
+		s := *v
+		*dst = &s
+	}
+	set(&p.FirstName, u.FirstName)
+	set(&p.LastName, u.LastName)
+	set(&p.DisplayName, u.DisplayName)
+	set(&p.AvatarURL, u.AvatarURL)
+	set(&p.Phone, u.Phone)
+	set(&p.Bio, u.Bio)
+	set(&p.FoodPreferences, u.FoodPreferences)
+	set(&p.ChronicDisease, u.ChronicDisease)
+	set(&p.AllergicFood, u.AllergicFood)
+	set(&p.AllergicDrugs, u.AllergicDrugs)
+	set(&p.EmergencyContact, u.EmergencyContact)
+	if u.ClearBirthDate {
+		p.BirthDate = nil
+	} else if u.BirthDate != nil {
+		bd := *u.BirthDate
+		p.BirthDate = &bd
+	}
+	r.s.profiles[userID] = p
+	return nil
+}
@@ -0,0 +1,85 @@
+package memory
+
+import (
+	"context"
+	"sort"
+	"time"
+
+	"github.com/google/uuid"
+
+	"GO2GETHER_BACK-END/internal/models"
+)
+
+type availabilityRepo struct{ s *Store }
+
+func dateOnly(t time.Time) time.Time {
+	return time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, time.UTC)
+}
+
+func (r availabilityRepo) Replace(_ context.Context, tripID, userID uuid.UUID, dates []time.Time) error {
+	r.s.mu.Lock()
+	defer r.s.mu.Unlock()
+	key := memberKey{tripID, userID}
+	days := make([]time.Time, 0, len(dates))
+	for _, d := range dates {
+		days = append(days, dateOnly(d))
+	}
+	r.s.availability[key] = days
+	if m, ok := r.s.members[key]; ok {
+		m.AvailabilitySubmitted = true
+		r.s.members[key] = m
+	}
+	return nil
+}
+
+func (r availabilityRepo) Dates(_ context.Context, tripID, userID uuid.UUID) ([]time.Time, error) {
+	r.s.mu.Lock()
+	defer r.s.mu.Unlock()
+	days := append([]time.Time{}, r.s.availability[memberKey{tripID, userID}]...)
+	sort.Slice(days, func(i, j int) bool { return days[i].Before(days[j]) })
+	return days, nil
+}
+
+func (r availabilityRepo) DailyFreeCounts(_ context.Context, tripID uuid.UUID, from, to time.Time) ([]models.DayCount, error) {
+	r.s.mu.Lock()
+	defer r.s.mu.Unlock()
+
+	free := make(map[time.Time]int)
+	for key, days := range r.s.availability {
+		if key.trip != tripID || r.s.members[key].Status != "accepted" {
+			continue
+		}
+		for _, d := range days {
+			free[d]++
+		}
+	}
+
+	var daily []models.DayCount
+	for d := dateOnly(from); !d.After(dateOnly(to)); d = d.AddDate(0, 0, 1) {
+		daily = append(daily, models.DayCount{Date: d, FreeCount: free[d]})
+	}
+	return daily, nil
+}
+
+type periodRepo struct{ s *Store }
+
+func (r periodRepo) Replace(_ context.Context, tripID uuid.UUID, periods []models.AvailablePeriod) error {
+	r.s.mu.Lock()
+	defer r.s.mu.Unlock()
+	stored := make([]models.AvailablePeriod, 0, len(periods))
+	for _, p := range periods {
+		p.ID = uuid.New()
+		p.TripID = tripID
+		stored = append(stored, p)
+	}
+	r.s.periods[tripID] = stored
+	return nil
+}
+
+func (r periodRepo) List(_ context.Context, tripID uuid.UUID) ([]models.AvailablePeriod, error) {
+	r.s.mu.Lock()
+	defer r.s.mu.Unlock()
+	list := append([]models.AvailablePeriod{}, r.s.periods[tripID]...)
+	sort.SliceStable(list, func(i, j int) bool { return list[i].PeriodNumber < list[j].PeriodNumber })
+	return list, nil
+}
@@ -0,0 +1,135 @@
+// Package memory is an in-memory implementation of the repository interfaces
+// for unit tests of the service layer. It keeps the same rules the Postgres
+// schema enforces (cascading deletes, unique usernames) but is not meant for
+// production use.
+package memory
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+
+	"GO2GETHER_BACK-END/internal/models"
+	"GO2GETHER_BACK-END/internal/repository"
+)
+
+// User is an account row
+type User struct {
+	ID            uuid.UUID
+	Email         string
+	Role          string
+	EmailVerified bool
+	MFAEnabled    bool
+	CreatedAt     time.Time
+}
+
+type memberKey struct {
+	trip, user uuid.UUID
+}
+
+// Store holds the data shared by the repositories it returns
+type Store struct {
+	mu            sync.Mutex
+	users         map[uuid.UUID]User
+	profiles      map[uuid.UUID]models.UserProfile
+	trips         map[uuid.UUID]models.Trip
+	budgets       map[uuid.UUID]models.TripBudget
+	members       map[memberKey]models.TripMember
+	availability  map[memberKey][]time.Time
+	periods       map[uuid.UUID][]models.AvailablePeriod
+	notifications []models.Notification
+	outbox        []outboxRow
+	deadLetters   []DeadLetter
+	preferences   map[uuid.UUID]models.NotificationPreferences
+	tripPrefs     map[memberKey]models.TripNotificationPreferences
+}
+
+// New creates an empty Store
+func New() *Store {
+	return &Store{
+		users:        make(map[uuid.UUID]User),
+		profiles:     make(map[uuid.UUID]models.UserProfile),
+		trips:        make(map[uuid.UUID]models.Trip),
+		budgets:      make(map[uuid.UUID]models.TripBudget),
+		members:      make(map[memberKey]models.TripMember),
+		availability: make(map[memberKey][]time.Time),
+		periods:      make(map[uuid.UUID][]models.AvailablePeriod),
+		preferences:  make(map[uuid.UUID]models.NotificationPreferences),
+		tripPrefs:    make(map[memberKey]models.TripNotificationPreferences),
+	}
+}
+
+// Repositories returns every repository over s
+func (s *Store) Repositories() repository.Repositories {
+	return repository.Repositories{
+		Trips:         tripRepo{s},
+		Members:       memberRepo{s},
+		Availability:  availabilityRepo{s},
+		Periods:       periodRepo{s},
+		Notifications: notificationRepo{s},
+		Users:         userRepo{s},
+		Profiles:      profileRepo{s},
+		Outbox:        outboxRepo{s},
+		Preferences:   preferenceRepo{s},
+		Tx:            transactor{},
+	}
+}
+
+// transactor runs fn directly: the store has no rollback, so a failing fn
+// leaves the writes it made before the error
+type transactor struct{}
+
+func (transactor) InTx(ctx context.Context, fn func(ctx context.Context) error) error {
+	return fn(ctx)
+}
+
+// PutUser adds or replaces an account; the ID is generated when empty
+func (s *Store) PutUser(u User) User {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if u.ID == uuid.Nil {
+		u.ID = uuid.New()
+	}
+	if u.Role == "" {
+		u.Role = "user"
+	}
+	if u.CreatedAt.IsZero() {
+		u.CreatedAt = time.Now()
+	}
+	s.users[u.ID] = u
+	return u
+}
+
+// PutMember adds or replaces a trip membership
+func (s *Store) PutMember(m models.TripMember) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.members[memberKey{m.TripID, m.UserID}] = m
+}
+
+// Notifications returns a copy of every notification inserted
+func (s *Store) Notifications() []models.Notification {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return append([]models.Notification(nil), s.notifications...)
+}
+
+// OutboxEvents returns a copy of every event added to the outbox
+func (s *Store) OutboxEvents() []models.OutboxEvent {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	events := make([]models.OutboxEvent, len(s.outbox))
+	for i, row := range s.outbox {
+		events[i] = row.event
+	}
+	return events
+}
+
+// DeadLetters returns a copy of the dead-lettered events
+func (s *Store) DeadLetters() []DeadLetter {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return append([]DeadLetter(nil), s.deadLetters...)
+}
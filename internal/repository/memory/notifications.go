@@ -0,0 +1,167 @@
+package memory
+
+import (
+	"context"
+	"slices"
+	"sort"
+	"time"
+
+	"github.com/google/uuid"
+
+	"GO2GETHER_BACK-END/internal/models"
+	"GO2GETHER_BACK-END/internal/repository"
+)
+
+type notificationRepo struct{ s *Store }
+
+func (r notificationRepo) Insert(_ context.Context, n models.Notification) error {
+	r.s.mu.Lock()
+	defer r.s.mu.Unlock()
+	if n.ID == uuid.Nil {
+		n.ID = uuid.New()
+	}
+	for _, cur := range r.s.notifications {
+		if cur.ID == n.ID {
+			return nil
+		}
+	}
+	if n.CreatedAt.IsZero() {
+		n.CreatedAt = time.Now()
+	}
+	r.s.notifications = append(r.s.notifications, n)
+	return nil
+}
+
+func (r notificationRepo) List(_ context.Context, userID uuid.UUID, f repository.NotificationFilter) ([]models.Notification, int, error) {
+	r.s.mu.Lock()
+	defer r.s.mu.Unlock()
+	var all []models.Notification
+	for _, n := range r.s.notifications {
+		if n.UserID != userID || (f.UnreadOnly && n.Read) || (f.Type != "" && n.Type != f.Type) {
+			continue
+		}
+		all = append(all, n)
+	}
+	sort.SliceStable(all, func(i, j int) bool { return all[i].CreatedAt.After(all[j].CreatedAt) })
+	return page(all, f.Limit, f.Offset), len(all), nil
+}
+
+func (r notificationRepo) CountUnread(_ context.Context, userID uuid.UUID) (int, error) {
+	r.s.mu.Lock()
+	defer r.s.mu.Unlock()
+	count := 0
+	for _, n := range r.s.notifications {
+		if n.UserID == userID && !n.Read {
+			count++
+		}
+	}
+	return count, nil
+}
+
+func (r notificationRepo) MarkRead(_ context.Context, id, userID uuid.UUID) (bool, error) {
+	r.s.mu.Lock()
+	defer r.s.mu.Unlock()
+	for i, n := range r.s.notifications {
+		if n.ID == id && n.UserID == userID && !n.Read {
+			r.s.notifications[i].Read = true
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+func (r notificationRepo) Exists(_ context.Context, id uuid.UUID) (bool, error) {
+	r.s.mu.Lock()
+	defer r.s.mu.Unlock()
+	for _, n := range r.s.notifications {
+		if n.ID == id {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+func (r notificationRepo) MarkAllRead(_ context.Context, userID uuid.UUID) (int64, error) {
+	r.s.mu.Lock()
+	defer r.s.mu.Unlock()
+	var updated int64
+	for i, n := range r.s.notifications {
+		if n.UserID == userID && !n.Read {
+			r.s.notifications[i].Read = true
+			updated++
+		}
+	}
+	return updated, nil
+}
+
+func (r notificationRepo) Get(_ context.Context, id uuid.UUID) (models.Notification, error) {
+	r.s.mu.Lock()
+	defer r.s.mu.Unlock()
+	for _, n := range r.s.notifications {
+		if n.ID == id {
+			return n, nil
+		}
+	}
+	return models.Notification{}, repository.ErrNotFound
+}
+
+func (r notificationRepo) ListAfter(_ context.Context, userID, afterID uuid.UUID, limit int) ([]models.Notification, error) {
+	r.s.mu.Lock()
+	defer r.s.mu.Unlock()
+	var after *models.Notification
+	for i, n := range r.s.notifications {
+		if n.ID == afterID && n.UserID == userID {
+			after = &r.s.notifications[i]
+		}
+	}
+	if after == nil {
+		return nil, repository.ErrNotFound
+	}
+	var items []models.Notification
+	for _, n := range r.s.notifications {
+		if n.UserID != userID {
+			continue
+		}
+		if n.CreatedAt.After(after.CreatedAt) || (n.CreatedAt.Equal(after.CreatedAt) && n.ID.String() > after.ID.String()) {
+			items = append(items, n)
+		}
+	}
+	sort.SliceStable(items, func(i, j int) bool {
+		if !items[i].CreatedAt.Equal(items[j].CreatedAt) {
+			return items[i].CreatedAt.Before(items[j].CreatedAt)
+		}
+		return items[i].ID.String() < items[j].ID.String()
+	})
+	if len(items) > limit {
+		items = items[:limit]
+	}
+	return items, nil
+}
+
+func (r notificationRepo) ListForDigest(_ context.Context, userID uuid.UUID, from, to time.Time, limit int) ([]models.Notification, error) {
+	r.s.mu.Lock()
+	defer r.s.mu.Unlock()
+	var list []models.Notification
+	for _, n := range r.s.notifications {
+		if n.UserID == userID && !n.Read && n.DigestedAt == nil &&
+			!n.CreatedAt.Before(from) && n.CreatedAt.Before(to) {
+			list = append(list, n)
+		}
+	}
+	sort.SliceStable(list, func(i, j int) bool { return list[i].CreatedAt.Before(list[j].CreatedAt) })
+	if len(list) > limit {
+		list = list[:limit]
+	}
+	return list, nil
+}
+
+func (r notificationRepo) MarkDigested(_ context.Context, ids []uuid.UUID, at time.Time) error {
+	r.s.mu.Lock()
+	defer r.s.mu.Unlock()
+	for i, n := range r.s.notifications {
+		if n.DigestedAt == nil && slices.Contains(ids, n.ID) {
+			r.s.notifications[i].DigestedAt = &at
+		}
+	}
+	return nil
+}
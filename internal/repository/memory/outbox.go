@@ -0,0 +1,170 @@
+package memory
+
+import (
+	"context"
+	"slices"
+	"sort"
+	"time"
+
+	"github.com/google/uuid"
+
+	"GO2GETHER_BACK-END/internal/models"
+	"GO2GETHER_BACK-END/internal/repository"
+)
+
+// DeadLetter is an event the dispatcher gave up on
+type DeadLetter struct {
+	Event    models.OutboxEvent
+	Reason   string
+	FailedAt time.Time
+}
+
+type outboxRow struct {
+	event       models.OutboxEvent
+	delivered   []string
+	processedAt *time.Time
+}
+
+type outboxRepo struct{ s *Store }
+
+// row returns the unprocessed event id; the caller holds the lock
+func (r outboxRepo) row(id uuid.UUID) *outboxRow {
+	for i := range r.s.outbox {
+		if r.s.outbox[i].event.ID == id && r.s.outbox[i].processedAt == nil {
+			return &r.s.outbox[i]
+		}
+	}
+	return nil
+}
+
+func (r outboxRepo) Add(_ context.Context, e models.OutboxEvent) error {
+	r.s.mu.Lock()
+	defer r.s.mu.Unlock()
+	for _, row := range r.s.outbox {
+		if row.event.IdempotencyKey == e.IdempotencyKey {
+			return nil
+		}
+	}
+	now := time.Now()
+	if e.ID == uuid.Nil {
+		e.ID = uuid.New()
+	}
+	e.Attempts, e.LastError = 0, nil
+	e.AvailableAt, e.CreatedAt = now, now
+	r.s.outbox = append(r.s.outbox, outboxRow{event: e})
+	return nil
+}
+
+func (r outboxRepo) Claim(_ context.Context, limit int, lease time.Duration) ([]models.OutboxEvent, error) {
+	r.s.mu.Lock()
+	defer r.s.mu.Unlock()
+	now := time.Now()
+	var due []*outboxRow
+	for i := range r.s.outbox {
+		row := &r.s.outbox[i]
+		if row.processedAt == nil && !row.event.AvailableAt.After(now) {
+			due = append(due, row)
+		}
+	}
+	sort.SliceStable(due, func(i, j int) bool { return due[i].event.AvailableAt.Before(due[j].event.AvailableAt) })
+	if limit >= 0 && len(due) > limit {
+		due = due[:limit]
+	}
+	events := make([]models.OutboxEvent, len(due))
+	for i, row := range due {
+		row.event.Attempts++
+		row.event.AvailableAt = now.Add(lease)
+		events[i] = row.event
+	}
+	return events, nil
+}
+
+func (r outboxRepo) Delivered(_ context.Context, id uuid.UUID) ([]string, error) {
+	r.s.mu.Lock()
+	defer r.s.mu.Unlock()
+	for _, row := range r.s.outbox {
+		if row.event.ID == id {
+			return append([]string(nil), row.delivered...), nil
+		}
+	}
+	return nil, nil
+}
+
+func (r outboxRepo) MarkDelivered(_ context.Context, id uuid.UUID, channel string) error {
+	r.s.mu.Lock()
+	defer r.s.mu.Unlock()
+	for i := range r.s.outbox {
+		row := &r.s.outbox[i]
+		if row.event.ID == id {
+			if !slices.Contains(row.delivered, channel) {
+				row.delivered = append(row.delivered, channel)
+			}
+			return nil
+		}
+	}
+	return repository.ErrNotFound
+}
+
+func (r outboxRepo) Done(_ context.Context, id uuid.UUID) error {
+	r.s.mu.Lock()
+	defer r.s.mu.Unlock()
+	row := r.row(id)
+	if row == nil {
+		return repository.ErrNotFound
+	}
+	now := time.Now()
+	row.processedAt, row.event.LastError = &now, nil
+	return nil
+}
+
+func (r outboxRepo) Retry(_ context.Context, id uuid.UUID, at time.Time, lastErr string) error {
+	r.s.mu.Lock()
+	defer r.s.mu.Unlock()
+	row := r.row(id)
+	if row == nil {
+		return repository.ErrNotFound
+	}
+	row.event.AvailableAt, row.event.LastError = at, &lastErr
+	return nil
+}
+
+func (r outboxRepo) Defer(_ context.Context, id uuid.UUID, at time.Time) error {
+	r.s.mu.Lock()
+	defer r.s.mu.Unlock()
+	row := r.row(id)
+	if row == nil {
+		return repository.ErrNotFound
+	}
+	row.event.AvailableAt = at
+	row.event.Attempts = max(row.event.Attempts-1, 0)
+	return nil
+}
+
+func (r outboxRepo) DeadLetter(_ context.Context, id uuid.UUID, reason string) error {
+	r.s.mu.Lock()
+	defer r.s.mu.Unlock()
+	row := r.row(id)
+	if row == nil {
+		return repository.ErrNotFound
+	}
+	now := time.Now()
+	row.processedAt, row.event.LastError = &now, &reason
+	r.s.deadLetters = append(r.s.deadLetters, DeadLetter{Event: row.event, Reason: reason, FailedAt: now})
+	return nil
+}
+
+func (r outboxRepo) Prune(_ context.Context, before time.Time) (int64, error) {
+	r.s.mu.Lock()
+	defer r.s.mu.Unlock()
+	kept := r.s.outbox[:0]
+	var n int64
+	for _, row := range r.s.outbox {
+		if row.processedAt != nil && row.processedAt.Before(before) {
+			n++
+			continue
+		}
+		kept = append(kept, row)
+	}
+	r.s.outbox = kept
+	return n, nil
+}
@@ -0,0 +1,117 @@
+package memory
+
+import (
+	"context"
+	"slices"
+	"sort"
+	"time"
+
+	"github.com/google/uuid"
+
+	"GO2GETHER_BACK-END/internal/models"
+	"GO2GETHER_BACK-END/internal/repository"
+)
+
+type preferenceRepo struct{ s *Store }
+
+func (r preferenceRepo) Get(_ context.Context, userID uuid.UUID) (models.NotificationPreferences, error) {
+	r.s.mu.Lock()
+	defer r.s.mu.Unlock()
+	p, ok := r.s.preferences[userID]
+	if !ok {
+		return models.DefaultNotificationPreferences(userID), nil
+	}
+	p.MutedTypes = slices.Clone(p.MutedTypes)
+	return p, nil
+}
+
+func (r preferenceRepo) Save(_ context.Context, p models.NotificationPreferences) error {
+	r.s.mu.Lock()
+	defer r.s.mu.Unlock()
+	p.MutedTypes = slices.Clone(p.MutedTypes)
+	if p.DigestFrequency == "" {
+		p.DigestFrequency = models.DigestOff
+	}
+	p.LastDigestAt = r.s.preferences[p.UserID].LastDigestAt // set only by ClaimDigest
+	p.UpdatedAt = time.Now()
+	r.s.preferences[p.UserID] = p
+	return nil
+}
+
+func (r preferenceRepo) Trip(_ context.Context, userID, tripID uuid.UUID) (models.TripNotificationPreferences, error) {
+	r.s.mu.Lock()
+	defer r.s.mu.Unlock()
+	t, ok := r.s.tripPrefs[memberKey{trip: tripID, user: userID}]
+	if !ok {
+		return t, repository.ErrNotFound
+	}
+	return t, nil
+}
+
+func (r preferenceRepo) Trips(_ context.Context, userID uuid.UUID) ([]models.TripNotificationPreferences, error) {
+	r.s.mu.Lock()
+	defer r.s.mu.Unlock()
+	var list []models.TripNotificationPreferences
+	for key, t := range r.s.tripPrefs {
+		if key.user == userID {
+			list = append(list, t)
+		}
+	}
+	sort.Slice(list, func(i, j int) bool { return list[i].TripID.String() < list[j].TripID.String() })
+	return list, nil
+}
+
+func (r preferenceRepo) SaveTrip(_ context.Context, t models.TripNotificationPreferences) error {
+	r.s.mu.Lock()
+	defer r.s.mu.Unlock()
+	if _, ok := r.s.trips[t.TripID]; !ok {
+		return repository.ErrNotFound
+	}
+	t.UpdatedAt = time.Now()
+	r.s.tripPrefs[memberKey{trip: t.TripID, user: t.UserID}] = t
+	return nil
+}
+
+func (r preferenceRepo) DeleteTrip(_ context.Context, userID, tripID uuid.UUID) error {
+	r.s.mu.Lock()
+	defer r.s.mu.Unlock()
+	delete(r.s.tripPrefs, memberKey{trip: tripID, user: userID})
+	return nil
+}
+
+func (r preferenceRepo) DigestSubscribers(_ context.Context) ([]models.NotificationPreferences, error) {
+	r.s.mu.Lock()
+	defer r.s.mu.Unlock()
+	var list []models.NotificationPreferences
+	for _, p := range r.s.preferences {
+		if p.DigestFrequency != models.DigestOff {
+			p.MutedTypes = slices.Clone(p.MutedTypes)
+			list = append(list, p)
+		}
+	}
+	sort.Slice(list, func(i, j int) bool { return list[i].UserID.String() < list[j].UserID.String() })
+	return list, nil
+}
+
+func (r preferenceRepo) ClaimDigest(_ context.Context, userID uuid.UUID, slot time.Time) (bool, error) {
+	r.s.mu.Lock()
+	defer r.s.mu.Unlock()
+	p, ok := r.s.preferences[userID]
+	if !ok || (p.LastDigestAt != nil && !p.LastDigestAt.Before(slot)) {
+		return false, nil
+	}
+	p.LastDigestAt = &slot
+	r.s.preferences[userID] = p
+	return true, nil
+}
+
+func (r preferenceRepo) ReleaseDigest(_ context.Context, userID uuid.UUID, slot time.Time, previous *time.Time) error {
+	r.s.mu.Lock()
+	defer r.s.mu.Unlock()
+	p, ok := r.s.preferences[userID]
+	if ok && p.LastDigestAt != nil && p.LastDigestAt.Equal(slot) {
+		p.LastDigestAt = previous
+		r.s.preferences[userID] = p
+	}
+	return nil
+}
@@ -0,0 +1,278 @@
+package memory
+
+import (
+	"context"
+	"sort"
+
+	"github.com/google/uuid"
+
+	"GO2GETHER_BACK-END/internal/models"
+	"GO2GETHER_BACK-END/internal/repository"
+)
+
+type tripRepo struct{ s *Store }
+
+func (r tripRepo) Create(_ context.Context, t models.Trip, b models.TripBudget) error {
+	r.s.mu.Lock()
+	defer r.s.mu.Unlock()
+	if _, ok := r.s.trips[t.ID]; ok {
+		return repository.ErrAlreadyExists
+	}
+	r.s.trips[t.ID] = t
+	r.s.budgets[t.ID] = b
+	key := memberKey{t.ID, t.CreatorID}
+	if _, ok := r.s.members[key]; !ok {
+		at := t.CreatedAt
+		r.s.members[key] = models.TripMember{
+			TripID: t.ID, UserID: t.CreatorID, Role: "owner", Status: "accepted",
+			InvitedAt: &at, JoinedAt: &at,
+		}
+	}
+	return nil
+}
+
+func (r tripRepo) Get(_ context.Context, id uuid.UUID) (models.Trip, error) {
+	r.s.mu.Lock()
+	defer r.s.mu.Unlock()
+	t, ok := r.s.trips[id]
+	if !ok {
+		return models.Trip{}, repository.ErrNotFound
+	}
+	return t, nil
+}
+
+func (r tripRepo) ListForMember(_ context.Context, userID uuid.UUID, status string, limit, offset int) ([]models.TripSummary, int, error) {
+	r.s.mu.Lock()
+	defer r.s.mu.Unlock()
+
+	var all []models.TripSummary
+	for key, m := range r.s.members {
+		if key.user != userID || m.Status != "accepted" {
+			continue
+		}
+		t, ok := r.s.trips[key.trip]
+		if !ok || (status != "all" && t.Status != status) {
+			continue
+		}
+		count := 0
+		for k := range r.s.members {
+			if k.trip == t.ID {
+				count++
+			}
+		}
+		all = append(all, models.TripSummary{Trip: t, MemberCount: count})
+	}
+	sort.Slice(all, func(i, j int) bool { return all[i].CreatedAt.After(all[j].CreatedAt) })
+	return page(all, limit, offset), len(all), nil
+}
+
+func (r tripRepo) Update(_ context.Context, t models.Trip, b models.TripBudget) error {
+	r.s.mu.Lock()
+	defer r.s.mu.Unlock()
+	if _, ok := r.s.trips[t.ID]; !ok {
+		return nil // UPDATE of a missing row is a no-op
+	}
+	r.s.trips[t.ID] = t
+	r.s.budgets[t.ID] = b
+	return nil
+}
+
+func (r tripRepo) Delete(_ context.Context, id uuid.UUID) error {
+	r.s.mu.Lock()
+	defer r.s.mu.Unlock()
+	delete(r.s.trips, id)
+	delete(r.s.budgets, id)
+	delete(r.s.periods, id)
+	for key := range r.s.members {
+		if key.trip == id {
+			delete(r.s.members, key)
+		}
+	}
+	for key := range r.s.availability {
+		if key.trip == id {
+			delete(r.s.availability, key)
+		}
+	}
+	for key := range r.s.tripPrefs {
+		if key.trip == id {
+			delete(r.s.tripPrefs, key)
+		}
+	}
+	return nil
+}
+
+func (r tripRepo) TransferOwnership(_ context.Context, tripID, from, to uuid.UUID) error {
+	r.s.mu.Lock()
+	defer r.s.mu.Unlock()
+	t, ok := r.s.trips[tripID]
+	if !ok || t.CreatorID != from {
+		return repository.ErrNotFound
+	}
+	t.CreatorID = to
+	r.s.trips[tripID] = t
+	for user, role := range map[uuid.UUID]string{from: "co_organizer", to: "owner"} {
+		key := memberKey{tripID, user}
+		if m, ok := r.s.members[key]; ok {
+			m.Role = role
+			r.s.members[key] = m
+		}
+	}
+	return nil
+}
+
+func (r tripRepo) Budget(_ context.Context, tripID uuid.UUID) (models.TripBudget, error) {
+	r.s.mu.Lock()
+	defer r.s.mu.Unlock()
+	return r.s.budgets[tripID], nil
+}
+
+type memberRepo struct{ s *Store }
+
+func (r memberRepo) Get(_ context.Context, tripID, userID uuid.UUID) (models.TripMember, error) {
+	r.s.mu.Lock()
+	defer r.s.mu.Unlock()
+	m, ok := r.s.members[memberKey{tripID, userID}]
+	if !ok {
+		return models.TripMember{}, repository.ErrNotFound
+	}
+	return m, nil
+}
+
+// tripMembers returns the members of a trip ordered by user ID; s.mu must be held
+func (s *Store) tripMembers(tripID uuid.UUID) []models.TripMember {
+	var out []models.TripMember
+	for key, m := range s.members {
+		if key.trip == tripID {
+			out = append(out, m)
+		}
+	}
+	sort.Slice(out, func(i, j int) bool { return out[i].UserID.String() < out[j].UserID.String() })
+	return out
+}
+
+func (r memberRepo) List(_ context.Context, tripID uuid.UUID) ([]models.TripMember, error) {
+	r.s.mu.Lock()
+	defer r.s.mu.Unlock()
+	members := r.s.tripMembers(tripID)
+	for i := range members {
+		members[i].Email = r.s.users[members[i].UserID].Email
+	}
+	return members, nil
+}
+
+func (r memberRepo) ListInvitations(_ context.Context, tripID uuid.UUID) ([]models.TripMember, error) {
+	r.s.mu.Lock()
+	defer r.s.mu.Unlock()
+	var out []models.TripMember
+	for _, m := range r.s.tripMembers(tripID) {
+		switch m.Status {
+		case "pending", "accepted", "declined":
+		default:
+			continue
+		}
+		if p, ok := r.s.profiles[m.UserID]; ok {
+			username := p.Username
+			m.Username, m.DisplayName, m.AvatarURL = &username, p.DisplayName, p.AvatarURL
+		}
+		out = append(out, m)
+	}
+	// invited_at DESC NULLS LAST, user_id ASC
+	sort.SliceStable(out, func(i, j int) bool {
+		a, b := out[i].InvitedAt, out[j].InvitedAt
+		switch {
+		case a != nil && b != nil && !a.Equal(*b):
+			return a.After(*b)
+		case (a == nil) != (b == nil):
+			return a != nil
+		}
+		return false
+	})
+	return out, nil
+}
+
+func (r memberRepo) Stats(_ context.Context, tripID uuid.UUID) (models.MemberStats, error) {
+	r.s.mu.Lock()
+	defer r.s.mu.Unlock()
+	var st models.MemberStats
+	for _, m := range r.s.tripMembers(tripID) {
+		st.Total++
+		switch m.Status {
+		case "accepted":
+			st.Accepted++
+		case "pending":
+			st.Pending++
+		case "declined":
+			st.Declined++
+		}
+		if m.AvailabilitySubmitted {
+			st.WithAvailability++
+		}
+	}
+	return st, nil
+}
+
+func (r memberRepo) AcceptedUserIDs(_ context.Context, tripID uuid.UUID) ([]uuid.UUID, error) {
+	r.s.mu.Lock()
+	defer r.s.mu.Unlock()
+	var ids []uuid.UUID
+	for _, m := range r.s.tripMembers(tripID) {
+		if m.Status == "accepted" {
+			ids = append(ids, m.UserID)
+		}
+	}
+	return ids, nil
+}
+
+func (r memberRepo) Accept(_ context.Context, m models.TripMember) error {
+	r.s.mu.Lock()
+	defer r.s.mu.Unlock()
+	key := memberKey{m.TripID, m.UserID}
+	if cur, ok := r.s.members[key]; ok {
+		cur.Status = "accepted"
+		cur.JoinedAt = m.JoinedAt
+		r.s.members[key] = cur
+		return nil
+	}
+	m.Status = "accepted"
+	m.InvitedAt = m.JoinedAt
+	m.AvailabilitySubmitted = false
+	r.s.members[key] = m
+	return nil
+}
+
+func (r memberRepo) SetRole(_ context.Context, tripID, userID uuid.UUID, role string) error {
+	r.s.mu.Lock()
+	defer r.s.mu.Unlock()
+	key := memberKey{tripID, userID}
+	m, ok := r.s.members[key]
+	if !ok {
+		return repository.ErrNotFound
+	}
+	m.Role = role
+	r.s.members[key] = m
+	return nil
+}
+
+func (r memberRepo) Remove(_ context.Context, tripID, userID uuid.UUID, status string) (bool, error) {
+	r.s.mu.Lock()
+	defer r.s.mu.Unlock()
+	key := memberKey{tripID, userID}
+	m, ok := r.s.members[key]
+	if !ok || (status != "" && m.Status != status) {
+		return false, nil
+	}
+	delete(r.s.members, key)
+	return true, nil
+}
+
+// page applies LIMIT/OFFSET to items
+func page[T any](items []T, limit, offset int) []T {
+	if offset >= len(items) {
+		return []T{}
+	}
+	items = items[offset:]
+	if limit >= 0 && limit < len(items) {
+		items = items[:limit]
+	}
+	return items
+}
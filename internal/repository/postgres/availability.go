@@ -0,0 +1,109 @@
+package postgres
+
+import (
+	"context"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+
+	"GO2GETHER_BACK-END/internal/models"
+)
+
+// AvailabilityRepository implements repository.AvailabilityRepository
+type AvailabilityRepository struct {
+	db *pgxpool.Pool
+}
+
+// availStatusFree is the availability_status the app writes; 'flexible' and
+// 'busy' exist in the enum but are not used yet
+const availStatusFree = "free"
+
+func (r *AvailabilityRepository) Replace(ctx context.Context, tripID, userID uuid.UUID, dates []time.Time) error {
+	return pgx.BeginFunc(ctx, conn(ctx, r.db), func(tx pgx.Tx) error {
+		// ลบข้อมูลเดิมของ user นี้ในทริปนี้ (เพื่อ idempotent)
+		if _, err := tx.Exec(ctx,
+			`DELETE FROM availabilities WHERE trip_id = $1 AND user_id = $2`,
+			tripID, userID,
+		); err != nil {
+			return err
+		}
+
+		statuses := make([]string, len(dates))
+		for i := range statuses {
+			statuses[i] = availStatusFree
+		}
+		// ใส่ใหม่แบบ bulk ผ่าน UNNEST
+		if _, err := tx.Exec(ctx, `
+			INSERT INTO availabilities (trip_id, user_id, date, status)
+			SELECT $1, $2, d::date, s::availability_status
+			  FROM UNNEST($3::date[], $4::text[]) AS t(d, s)
+		`, tripID, userID, dates, statuses); err != nil {
+			return err
+		}
+
+		_, err := tx.Exec(ctx, `
+			UPDATE trip_members
+			   SET availability_submitted = TRUE
+			 WHERE trip_id = $1 AND user_id = $2
+		`, tripID, userID)
+		return err
+	})
+}
+
+func (r *AvailabilityRepository) Dates(ctx context.Context, tripID, userID uuid.UUID) ([]time.Time, error) {
+	rows, err := conn(ctx, r.db).Query(ctx, `
+		SELECT date
+		  FROM availabilities
+		 WHERE trip_id = $1 AND user_id = $2
+		 ORDER BY date ASC
+	`, tripID, userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	dates := make([]time.Time, 0, 32)
+	for rows.Next() {
+		var d time.Time
+		if err := rows.Scan(&d); err != nil {
+			return nil, err
+		}
+		dates = append(dates, d)
+	}
+	return dates, rows.Err()
+}
+
+func (r *AvailabilityRepository) DailyFreeCounts(ctx context.Context, tripID uuid.UUID, from, to time.Time) ([]models.DayCount, error) {
+	rows, err := conn(ctx, r.db).Query(ctx, `
+		WITH d AS (
+			SELECT generate_series($1::date, $2::date, interval '1 day')::date AS d
+		),
+		f AS (
+			SELECT a.date AS d, COUNT(*)::int AS free_count
+			FROM availabilities a
+			JOIN trip_members tm ON tm.trip_id = a.trip_id AND tm.user_id = a.user_id AND tm.status = 'accepted'
+			WHERE a.trip_id = $3 AND a.status = 'free'
+			GROUP BY a.date
+		)
+		SELECT d.d, COALESCE(f.free_count, 0) AS free_count
+		FROM d
+		LEFT JOIN f ON f.d = d.d
+		ORDER BY d.d ASC
+	`, from, to, tripID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	daily := make([]models.DayCount, 0, 128)
+	for rows.Next() {
+		var dc models.DayCount
+		if err := rows.Scan(&dc.Date, &dc.FreeCount); err != nil {
+			return nil, err
+		}
+		daily = append(daily, dc)
+	}
+	return daily, rows.Err()
+}
@@ -0,0 +1,143 @@
+package postgres
+
+import (
+	"context"
+
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5/pgxpool"
+
+	"GO2GETHER_BACK-END/internal/models"
+	"GO2GETHER_BACK-END/internal/repository"
+)
+
+// MemberRepository implements repository.MemberRepository
+type MemberRepository struct {
+	db *pgxpool.Pool
+}
+
+func (r *MemberRepository) Get(ctx context.Context, tripID, userID uuid.UUID) (models.TripMember, error) {
+	m := models.TripMember{TripID: tripID, UserID: userID}
+	err := conn(ctx, r.db).QueryRow(ctx,
+		`SELECT role, status, availability_submitted, invited_by, invited_at, joined_at
+		   FROM trip_members WHERE trip_id = $1 AND user_id = $2`,
+		tripID, userID,
+	).Scan(&m.Role, &m.Status, &m.AvailabilitySubmitted, &m.InvitedBy, &m.InvitedAt, &m.JoinedAt)
+	return m, notFound(err)
+}
+
+func (r *MemberRepository) List(ctx context.Context, tripID uuid.UUID) ([]models.TripMember, error) {
+	rows, err := conn(ctx, r.db).Query(ctx,
+		`SELECT tm.user_id, tm.role, tm.status, tm.availability_submitted, tm.invited_by, tm.invited_at, tm.joined_at,
+		        COALESCE(u.email, '')
+		   FROM trip_members tm
+		   LEFT JOIN users u ON u.id = tm.user_id
+		  WHERE tm.trip_id = $1`, tripID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	members := make([]models.TripMember, 0)
+	for rows.Next() {
+		m := models.TripMember{TripID: tripID}
+		if err := rows.Scan(&m.UserID, &m.Role, &m.Status, &m.AvailabilitySubmitted, &m.InvitedBy, &m.InvitedAt, &m.JoinedAt, &m.Email); err != nil {
+			return nil, err
+		}
+		members = append(members, m)
+	}
+	return members, rows.Err()
+}
+
+func (r *MemberRepository) ListInvitations(ctx context.Context, tripID uuid.UUID) ([]models.TripMember, error) {
+	rows, err := conn(ctx, r.db).Query(ctx, `
+		SELECT tm.user_id, tm.role, tm.status, tm.invited_by, tm.invited_at,
+		       p.username, p.display_name, p.avatar_url
+		  FROM trip_members tm
+		  LEFT JOIN profiles p ON p.user_id = tm.user_id
+		 WHERE tm.trip_id = $1
+		   AND tm.status IN ('pending','accepted','declined')
+		 ORDER BY tm.invited_at DESC NULLS LAST, tm.user_id ASC`, tripID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	members := make([]models.TripMember, 0, 16)
+	for rows.Next() {
+		m := models.TripMember{TripID: tripID}
+		if err := rows.Scan(&m.UserID, &m.Role, &m.Status, &m.InvitedBy, &m.InvitedAt, &m.Username, &m.DisplayName, &m.AvatarURL); err != nil {
+			return nil, err
+		}
+		members = append(members, m)
+	}
+	return members, rows.Err()
+}
+
+func (r *MemberRepository) Stats(ctx context.Context, tripID uuid.UUID) (models.MemberStats, error) {
+	var s models.MemberStats
+	err := conn(ctx, r.db).QueryRow(ctx,
+		`SELECT COUNT(1),
+		        COUNT(1) FILTER (WHERE status = 'accepted'),
+		        COUNT(1) FILTER (WHERE status = 'pending'),
+		        COUNT(1) FILTER (WHERE status = 'declined'),
+		        COUNT(1) FILTER (WHERE availability_submitted)
+		   FROM trip_members WHERE trip_id = $1`, tripID,
+	).Scan(&s.Total, &s.Accepted, &s.Pending, &s.Declined, &s.WithAvailability)
+	return s, err
+}
+
+func (r *MemberRepository) AcceptedUserIDs(ctx context.Context, tripID uuid.UUID) ([]uuid.UUID, error) {
+	rows, err := conn(ctx, r.db).Query(ctx,
+		`SELECT user_id FROM trip_members WHERE trip_id = $1 AND status = 'accepted'`, tripID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var ids []uuid.UUID
+	for rows.Next() {
+		var id uuid.UUID
+		if err := rows.Scan(&id); err != nil {
+			return nil, err
+		}
+		ids = append(ids, id)
+	}
+	return ids, rows.Err()
+}
+
+func (r *MemberRepository) Accept(ctx context.Context, m models.TripMember) error {
+	_, err := conn(ctx, r.db).Exec(ctx,
+		`INSERT INTO trip_members (trip_id, user_id, role, status, invited_by, invited_at, joined_at, availability_submitted)
+		 VALUES ($1, $2, $3, 'accepted', $4, $5, $5, FALSE)
+		 ON CONFLICT (trip_id, user_id) DO UPDATE
+		 SET status = 'accepted', joined_at = $5`,
+		m.TripID, m.UserID, m.Role, m.InvitedBy, m.JoinedAt,
+	)
+	return err
+}
+
+func (r *MemberRepository) SetRole(ctx context.Context, tripID, userID uuid.UUID, role string) error {
+	cmd, err := conn(ctx, r.db).Exec(ctx,
+		`UPDATE trip_members SET role = $3 WHERE trip_id = $1 AND user_id = $2`,
+		tripID, userID, role,
+	)
+	if err != nil {
+		return err
+	}
+	if cmd.RowsAffected() == 0 {
+		return repository.ErrNotFound
+	}
+	return nil
+}
+
+func (r *MemberRepository) Remove(ctx context.Context, tripID, userID uuid.UUID, status string) (bool, error) {
+	cmd, err := conn(ctx, r.db).Exec(ctx,
+		`DELETE FROM trip_members
+		  WHERE trip_id = $1 AND user_id = $2 AND ($3 = '' OR status = $3)`,
+		tripID, userID, status,
+	)
+	if err != nil {
+		return false, err
+	}
+	return cmd.RowsAffected() > 0, nil
+}
@@ -0,0 +1,224 @@
+package postgres
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+
+	"GO2GETHER_BACK-END/internal/models"
+	"GO2GETHER_BACK-END/internal/repository"
+)
+
+// NotificationRepository implements repository.NotificationRepository
+type NotificationRepository struct {
+	db *pgxpool.Pool
+}
+
+// NewNotificationRepository creates a NotificationRepository
+func NewNotificationRepository(db *pgxpool.Pool) *NotificationRepository {
+	return &NotificationRepository{db: db}
+}
+
+func (r *NotificationRepository) Insert(ctx context.Context, n models.Notification) error {
+	var data any
+	if len(n.Data) > 0 {
+		b, err := json.Marshal(n.Data)
+		if err != nil {
+			return fmt.Errorf("marshal notification data: %w", err)
+		}
+		data = string(b)
+	}
+
+	// id ที่กำหนดมาเอง (เช่นจาก outbox) ทำให้ insert ซ้ำไม่สร้างแถวใหม่
+	var id any
+	if n.ID != uuid.Nil {
+		id = n.ID
+	}
+	var createdAt any
+	if !n.CreatedAt.IsZero() {
+		createdAt = n.CreatedAt
+	}
+	cmd, err := conn(ctx, r.db).Exec(ctx, `
+		INSERT INTO notifications (id, user_id, trip_id, type, title, message, data, action_url, created_at)
+		VALUES (COALESCE($1::uuid, gen_random_uuid()), $2, $3, $4, $5, $6, $7::jsonb, $8, COALESCE($9::timestamptz, NOW()))
+		ON CONFLICT (id) DO NOTHING
+	`, id, n.UserID, n.TripID, n.Type, n.Title, n.Message, data, n.ActionURL, createdAt)
+	if err != nil {
+		return err
+	}
+	if cmd.RowsAffected() != 1 && n.ID == uuid.Nil {
+		return errors.New("unexpected number of rows affected")
+	}
+	return nil
+}
+
+func (r *NotificationRepository) List(ctx context.Context, userID uuid.UUID, f repository.NotificationFilter) ([]models.Notification, int, error) {
+	args := []any{userID}
+	where := `WHERE user_id=$1`
+	if f.UnreadOnly {
+		where += " AND read=false"
+	}
+	if f.Type != "" {
+		args = append(args, f.Type)
+		where += fmt.Sprintf(" AND type=$%d", len(args))
+	}
+
+	var total int
+	if err := conn(ctx, r.db).QueryRow(ctx, `SELECT COUNT(1) FROM notifications `+where, args...).Scan(&total); err != nil {
+		return nil, 0, err
+	}
+
+	args = append(args, f.Limit, f.Offset)
+	rows, err := conn(ctx, r.db).Query(ctx, fmt.Sprintf(`
+		SELECT `+notificationColumns+`
+		FROM notifications %s
+		ORDER BY created_at DESC
+		LIMIT $%d OFFSET $%d
+	`, where, len(args)-1, len(args)), args...)
+	if err != nil {
+		return nil, 0, err
+	}
+	defer rows.Close()
+
+	items := make([]models.Notification, 0, f.Limit)
+	for rows.Next() {
+		n, err := scanNotification(ctx, rows)
+		if err != nil {
+			return nil, 0, err
+		}
+		items = append(items, n)
+	}
+	return items, total, rows.Err()
+}
+
+const notificationColumns = `id, user_id, trip_id, type, title, message, data, action_url, read, created_at, digested_at`
+
+func scanNotification(ctx context.Context, row pgx.Row) (models.Notification, error) {
+	var n models.Notification
+	var dataRaw []byte
+	if err := row.Scan(&n.ID, &n.UserID, &n.TripID, &n.Type, &n.Title, &n.Message, &dataRaw, &n.ActionURL, &n.Read, &n.CreatedAt, &n.DigestedAt); err != nil {
+		return n, err
+	}
+	if len(dataRaw) > 0 && string(dataRaw) != "null" {
+		if err := json.Unmarshal(dataRaw, &n.Data); err != nil {
+			// ข้อมูลเสียไม่ควรทำให้ทั้งหน้า fail
+			slog.WarnContext(ctx, "unmarshal notification data failed", "error", err, "notification_id", n.ID.String())
+			n.Data = nil
+		}
+	}
+	return n, nil
+}
+
+func (r *NotificationRepository) Get(ctx context.Context, id uuid.UUID) (models.Notification, error) {
+	n, err := scanNotification(ctx, conn(ctx, r.db).QueryRow(ctx,
+		`SELECT `+notificationColumns+` FROM notifications WHERE id=$1`, id))
+	return n, notFound(err)
+}
+
+func (r *NotificationRepository) ListAfter(ctx context.Context, userID, afterID uuid.UUID, limit int) ([]models.Notification, error) {
+	var exists bool
+	if err := conn(ctx, r.db).QueryRow(ctx,
+		`SELECT EXISTS(SELECT 1 FROM notifications WHERE id=$1 AND user_id=$2)`, afterID, userID,
+	).Scan(&exists); err != nil {
+		return nil, err
+	}
+	if !exists {
+		return nil, repository.ErrNotFound
+	}
+
+	rows, err := conn(ctx, r.db).Query(ctx, `
+		SELECT `+notificationColumns+`
+		FROM notifications
+		WHERE user_id=$1
+		  AND (created_at, id) > (SELECT created_at, id FROM notifications WHERE id=$2)
+		ORDER BY created_at, id
+		LIMIT $3
+	`, userID, afterID, limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var items []models.Notification
+	for rows.Next() {
+		n, err := scanNotification(ctx, rows)
+		if err != nil {
+			return nil, err
+		}
+		items = append(items, n)
+	}
+	return items, rows.Err()
+}
+
+func (r *NotificationRepository) CountUnread(ctx context.Context, userID uuid.UUID) (int, error) {
+	var n int
+	err := conn(ctx, r.db).QueryRow(ctx,
+		`SELECT COUNT(1) FROM notifications WHERE user_id=$1 AND read=false`, userID,
+	).Scan(&n)
+	return n, err
+}
+
+func (r *NotificationRepository) MarkRead(ctx context.Context, id, userID uuid.UUID) (bool, error) {
+	cmd, err := conn(ctx, r.db).Exec(ctx,
+		`UPDATE notifications SET read=true WHERE id=$1 AND user_id=$2 AND read=false`,
+		id, userID,
+	)
+	if err != nil {
+		return false, err
+	}
+	return cmd.RowsAffected() > 0, nil
+}
+
+func (r *NotificationRepository) Exists(ctx context.Context, id uuid.UUID) (bool, error) {
+	var exists bool
+	err := conn(ctx, r.db).QueryRow(ctx, `SELECT EXISTS(SELECT 1 FROM notifications WHERE id=$1)`, id).Scan(&exists)
+	return exists, err
+}
+
+func (r *NotificationRepository) MarkAllRead(ctx context.Context, userID uuid.UUID) (int64, error) {
+	cmd, err := conn(ctx, r.db).Exec(ctx,
+		`UPDATE notifications SET read=true WHERE user_id=$1 AND read=false`, userID,
+	)
+	if err != nil {
+		return 0, err
+	}
+	return cmd.RowsAffected(), nil
+}
+
+func (r *NotificationRepository) ListForDigest(ctx context.Context, userID uuid.UUID, from, to time.Time, limit int) ([]models.Notification, error) {
+	rows, err := conn(ctx, r.db).Query(ctx, `
+		SELECT `+notificationColumns+`
+		FROM notifications
+		WHERE user_id=$1 AND read=false AND digested_at IS NULL
+		  AND created_at >= $2 AND created_at < $3
+		ORDER BY created_at, id
+		LIMIT $4
+	`, userID, from, to, limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var items []models.Notification
+	for rows.Next() {
+		n, err := scanNotification(ctx, rows)
+		if err != nil {
+			return nil, err
+		}
+		items = append(items, n)
+	}
+	return items, rows.Err()
+}
+
+func (r *NotificationRepository) MarkDigested(ctx context.Context, ids []uuid.UUID, at time.Time) error {
+	_, err := conn(ctx, r.db).Exec(ctx,
+		`UPDATE notifications SET digested_at=$2 WHERE id = ANY($1) AND digested_at IS NULL`, ids, at)
+	return err
+}
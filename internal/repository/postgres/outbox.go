@@ -0,0 +1,153 @@
+package postgres
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+
+	"GO2GETHER_BACK-END/internal/models"
+	"GO2GETHER_BACK-END/internal/repository"
+)
+
+// OutboxRepository implements repository.OutboxRepository
+type OutboxRepository struct {
+	db *pgxpool.Pool
+}
+
+func (r *OutboxRepository) Add(ctx context.Context, e models.OutboxEvent) error {
+	payload, err := json.Marshal(e.Payload)
+	if err != nil {
+		return fmt.Errorf("marshal event payload: %w", err)
+	}
+	var id any
+	if e.ID != uuid.Nil {
+		id = e.ID
+	}
+	_, err = conn(ctx, r.db).Exec(ctx, `
+		INSERT INTO outbox_events (id, type, recipient_id, trip_id, payload, channels, idempotency_key)
+		VALUES (COALESCE($1::uuid, gen_random_uuid()), $2, $3, $4, $5::jsonb, $6, $7)
+		ON CONFLICT (idempotency_key) DO NOTHING
+	`, id, e.Type, e.RecipientID, e.TripID, string(payload), e.Channels, e.IdempotencyKey)
+	return err
+}
+
+func (r *OutboxRepository) Claim(ctx context.Context, limit int, lease time.Duration) ([]models.OutboxEvent, error) {
+	// SKIP LOCKED: dispatchers ของหลาย replica ไม่แย่ง event เดียวกัน
+	rows, err := conn(ctx, r.db).Query(ctx, `
+		UPDATE outbox_events
+		   SET available_at = NOW() + $2 * INTERVAL '1 millisecond', attempts = attempts + 1
+		 WHERE id IN (
+			SELECT id FROM outbox_events
+			 WHERE processed_at IS NULL AND available_at <= NOW()
+			 ORDER BY available_at
+			 LIMIT $1
+			 FOR UPDATE SKIP LOCKED)
+		RETURNING id, type, recipient_id, trip_id, payload, channels, idempotency_key,
+		          attempts, available_at, last_error, created_at
+	`, limit, lease.Milliseconds())
+	if err != nil {
+		return nil, err
+	}
+	return pgx.CollectRows(rows, func(row pgx.CollectableRow) (models.OutboxEvent, error) {
+		var e models.OutboxEvent
+		var payload []byte
+		if err := row.Scan(&e.ID, &e.Type, &e.RecipientID, &e.TripID, &payload, &e.Channels, &e.IdempotencyKey,
+			&e.Attempts, &e.AvailableAt, &e.LastError, &e.CreatedAt); err != nil {
+			return e, err
+		}
+		if err := json.Unmarshal(payload, &e.Payload); err != nil {
+			return e, fmt.Errorf("unmarshal payload of event %s: %w", e.ID, err)
+		}
+		return e, nil
+	})
+}
+
+func (r *OutboxRepository) Delivered(ctx context.Context, id uuid.UUID) ([]string, error) {
+	rows, err := conn(ctx, r.db).Query(ctx,
+		`SELECT channel FROM outbox_deliveries WHERE event_id = $1`, id)
+	if err != nil {
+		return nil, err
+	}
+	return pgx.CollectRows(rows, pgx.RowTo[string])
+}
+
+func (r *OutboxRepository) MarkDelivered(ctx context.Context, id uuid.UUID, channel string) error {
+	_, err := conn(ctx, r.db).Exec(ctx, `
+		INSERT INTO outbox_deliveries (event_id, channel) VALUES ($1, $2)
+		ON CONFLICT (event_id, channel) DO NOTHING
+	`, id, channel)
+	return err
+}
+
+func (r *OutboxRepository) Done(ctx context.Context, id uuid.UUID) error {
+	cmd, err := conn(ctx, r.db).Exec(ctx,
+		`UPDATE outbox_events SET processed_at = NOW(), last_error = NULL WHERE id = $1`, id)
+	if err != nil {
+		return err
+	}
+	if cmd.RowsAffected() == 0 {
+		return repository.ErrNotFound
+	}
+	return nil
+}
+
+func (r *OutboxRepository) Retry(ctx context.Context, id uuid.UUID, at time.Time, lastErr string) error {
+	cmd, err := conn(ctx, r.db).Exec(ctx,
+		`UPDATE outbox_events SET available_at = $2, last_error = $3 WHERE id = $1 AND processed_at IS NULL`,
+		id, at, lastErr)
+	if err != nil {
+		return err
+	}
+	if cmd.RowsAffected() == 0 {
+		return repository.ErrNotFound
+	}
+	return nil
+}
+
+func (r *OutboxRepository) Defer(ctx context.Context, id uuid.UUID, at time.Time) error {
+	cmd, err := conn(ctx, r.db).Exec(ctx, `
+		UPDATE outbox_events SET available_at = $2, attempts = GREATEST(attempts - 1, 0)
+		 WHERE id = $1 AND processed_at IS NULL`, id, at)
+	if err != nil {
+		return err
+	}
+	if cmd.RowsAffected() == 0 {
+		return repository.ErrNotFound
+	}
+	return nil
+}
+
+func (r *OutboxRepository) DeadLetter(ctx context.Context, id uuid.UUID, reason string) error {
+	return pgx.BeginFunc(ctx, conn(ctx, r.db), func(tx pgx.Tx) error {
+		cmd, err := tx.Exec(ctx, `
+			INSERT INTO outbox_dead_letters
+			       (id, type, recipient_id, trip_id, payload, channels, idempotency_key, attempts, error, created_at)
+			SELECT id, type, recipient_id, trip_id, payload, channels, idempotency_key, attempts, $2, created_at
+			  FROM outbox_events WHERE id = $1 AND processed_at IS NULL
+			ON CONFLICT (id) DO NOTHING
+		`, id, reason)
+		if err != nil {
+			return err
+		}
+		if cmd.RowsAffected() == 0 {
+			return repository.ErrNotFound
+		}
+		_, err = tx.Exec(ctx,
+			`UPDATE outbox_events SET processed_at = NOW(), last_error = $2 WHERE id = $1`, id, reason)
+		return err
+	})
+}
+
+func (r *OutboxRepository) Prune(ctx context.Context, before time.Time) (int64, error) {
+	cmd, err := conn(ctx, r.db).Exec(ctx,
+		`DELETE FROM outbox_events WHERE processed_at IS NOT NULL AND processed_at < $1`, before)
+	if err != nil {
+		return 0, err
+	}
+	return cmd.RowsAffected(), nil
+}
@@ -0,0 +1,71 @@
+package postgres
+
+import (
+	"context"
+	"database/sql"
+
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+
+	"GO2GETHER_BACK-END/internal/models"
+)
+
+// PeriodRepository implements repository.PeriodRepository
+type PeriodRepository struct {
+	db *pgxpool.Pool
+}
+
+func (r *PeriodRepository) Replace(ctx context.Context, tripID uuid.UUID, periods []models.AvailablePeriod) error {
+	return pgx.BeginFunc(ctx, conn(ctx, r.db), func(tx pgx.Tx) error {
+		if _, err := tx.Exec(ctx, `DELETE FROM available_periods WHERE trip_id = $1`, tripID); err != nil {
+			return err
+		}
+		for _, p := range periods {
+			// ไม่อ้างคอลัมน์ rank (เลี่ยง enum ปัญหา)
+			_, err := tx.Exec(ctx, `
+				INSERT INTO available_periods
+				  (id, trip_id, period_number, start_date, end_date, duration_days,
+				   free_count, flexible_count, total_members, availability_percentage, created_at)
+				VALUES (gen_random_uuid(), $1, $2, $3, $4, $5,
+				        $6, 0, $7, $8, $9)
+			`,
+				tripID, p.PeriodNumber, p.StartDate, p.EndDate, p.DurationDays,
+				p.FreeCount, p.TotalMembers, p.AvailabilityPercentage, p.CreatedAt,
+			)
+			if err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+func (r *PeriodRepository) List(ctx context.Context, tripID uuid.UUID) ([]models.AvailablePeriod, error) {
+	// กัน NULL ด้วย COALESCE และ sql.Null* (แถวเก่าอาจไม่มี availability_percentage)
+	rows, err := conn(ctx, r.db).Query(ctx, `
+		SELECT id, period_number, start_date, end_date,
+		       COALESCE(duration_days, 0), COALESCE(free_count, 0), COALESCE(total_members, 0),
+		       availability_percentage, created_at
+		  FROM available_periods
+		 WHERE trip_id = $1
+		 ORDER BY period_number ASC, start_date ASC
+	`, tripID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	list := make([]models.AvailablePeriod, 0, 16)
+	for rows.Next() {
+		p := models.AvailablePeriod{TripID: tripID}
+		var perc sql.NullFloat64
+		if err := rows.Scan(&p.ID, &p.PeriodNumber, &p.StartDate, &p.EndDate,
+			&p.DurationDays, &p.FreeCount, &p.TotalMembers, &perc, &p.CreatedAt); err != nil {
+			return nil, err
+		}
+		p.AvailabilityPercentage = perc.Float64
+		list = append(list, p)
+	}
+	return list, rows.Err()
+}
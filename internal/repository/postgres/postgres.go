@@ -0,0 +1,89 @@
+// Package postgres implements the repository interfaces over a pgx pool
+package postgres
+
+import (
+	"context"
+	"errors"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
+	"github.com/jackc/pgx/v5/pgxpool"
+
+	"GO2GETHER_BACK-END/internal/repository"
+)
+
+// New returns every repository backed by db
+func New(db *pgxpool.Pool) repository.Repositories {
+	return repository.Repositories{
+		Trips:         &TripRepository{db: db},
+		Members:       &MemberRepository{db: db},
+		Availability:  &AvailabilityRepository{db: db},
+		Periods:       &PeriodRepository{db: db},
+		Notifications: NewNotificationRepository(db),
+		Users:         &UserRepository{db: db},
+		Profiles:      &ProfileRepository{db: db},
+		Outbox:        &OutboxRepository{db: db},
+		Preferences:   &PreferenceRepository{db: db},
+		Tx:            &Transactor{db: db},
+	}
+}
+
+type txKey struct{}
+
+// Transactor implements repository.Transactor; the transaction travels in
+// the context, where every repository of this package picks it up
+type Transactor struct {
+	db *pgxpool.Pool
+}
+
+func (t *Transactor) InTx(ctx context.Context, fn func(ctx context.Context) error) error {
+	if _, ok := ctx.Value(txKey{}).(pgx.Tx); ok {
+		return fn(ctx)
+	}
+	return pgx.BeginFunc(ctx, t.db, func(tx pgx.Tx) error {
+		return fn(context.WithValue(ctx, txKey{}, tx))
+	})
+}
+
+// querier is what pgxpool.Pool and pgx.Tx have in common; Begin on a
+// transaction starts a savepoint, so pgx.BeginFunc works on both
+type querier interface {
+	Exec(ctx context.Context, sql string, args ...any) (pgconn.CommandTag, error)
+	Query(ctx context.Context, sql string, args ...any) (pgx.Rows, error)
+	QueryRow(ctx context.Context, sql string, args ...any) pgx.Row
+	Begin(ctx context.Context) (pgx.Tx, error)
+}
+
+// conn returns the transaction of ctx (see Transactor), or db outside one
+func conn(ctx context.Context, db *pgxpool.Pool) querier {
+	if tx, ok := ctx.Value(txKey{}).(pgx.Tx); ok {
+		return tx
+	}
+	return db
+}
+
+// notFound turns pgx.ErrNoRows into repository.ErrNotFound
+func notFound(err error) error {
+	if errors.Is(err, pgx.ErrNoRows) {
+		return repository.ErrNotFound
+	}
+	return err
+}
+
+// uniqueViolation returns the constraint name of a unique violation
+func uniqueViolation(err error) (string, bool) {
+	var pgErr *pgconn.PgError
+	if errors.As(err, &pgErr) && pgErr.Code == "23505" {
+		return pgErr.ConstraintName, true
+	}
+	return "", false
+}
+
+// foreignKeyViolation returns the constraint name of a foreign key violation
+func foreignKeyViolation(err error) (string, bool) {
+	var pgErr *pgconn.PgError
+	if errors.As(err, &pgErr) && pgErr.Code == "23503" {
+		return pgErr.ConstraintName, true
+	}
+	return "", false
+}
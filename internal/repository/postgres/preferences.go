@@ -0,0 +1,168 @@
+package postgres
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+
+	"GO2GETHER_BACK-END/internal/models"
+	"GO2GETHER_BACK-END/internal/repository"
+)
+
+// PreferenceRepository implements repository.PreferenceRepository
+type PreferenceRepository struct {
+	db *pgxpool.Pool
+}
+
+// quiet hours are TIME columns; the model counts minutes after midnight
+const preferenceColumns = `user_id, in_app, email, push, muted_types,
+	(EXTRACT(EPOCH FROM quiet_hours_start) / 60)::int, (EXTRACT(EPOCH FROM quiet_hours_end) / 60)::int,
+	timezone, COALESCE(locale, ''), digest_frequency, last_digest_at, updated_at`
+
+func scanPreferences(row pgx.Row) (models.NotificationPreferences, error) {
+	var p models.NotificationPreferences
+	var muted []string
+	var start, end *int
+	err := row.Scan(&p.UserID, &p.InApp, &p.Email, &p.Push, &muted, &start, &end,
+		&p.Timezone, &p.Locale, &p.DigestFrequency, &p.LastDigestAt, &p.UpdatedAt)
+	if err != nil {
+		return p, err
+	}
+	p.MutedTypes = make([]models.NotificationType, len(muted))
+	for i, t := range muted {
+		p.MutedTypes[i] = models.NotificationType(t)
+	}
+	if start != nil && end != nil {
+		p.QuietHours = &models.QuietHours{Start: *start, End: *end}
+	}
+	return p, nil
+}
+
+func (r *PreferenceRepository) Get(ctx context.Context, userID uuid.UUID) (models.NotificationPreferences, error) {
+	p, err := scanPreferences(conn(ctx, r.db).QueryRow(ctx,
+		`SELECT `+preferenceColumns+` FROM notification_preferences WHERE user_id = $1`, userID))
+	if errors.Is(err, pgx.ErrNoRows) {
+		return models.DefaultNotificationPreferences(userID), nil
+	}
+	return p, err
+}
+
+func (r *PreferenceRepository) Save(ctx context.Context, p models.NotificationPreferences) error {
+	muted := make([]string, len(p.MutedTypes))
+	for i, t := range p.MutedTypes {
+		muted[i] = string(t)
+	}
+	var start, end *int
+	if p.QuietHours != nil {
+		start, end = &p.QuietHours.Start, &p.QuietHours.End
+	}
+	_, err := conn(ctx, r.db).Exec(ctx, `
+		INSERT INTO notification_preferences
+		       (user_id, in_app, email, push, muted_types, quiet_hours_start, quiet_hours_end, timezone, locale, digest_frequency)
+		VALUES ($1, $2, $3, $4, $5,
+		        ($6::int * INTERVAL '1 minute')::time, ($7::int * INTERVAL '1 minute')::time, $8, NULLIF($9, ''), $10)
+		ON CONFLICT (user_id) DO UPDATE
+		   SET in_app = EXCLUDED.in_app, email = EXCLUDED.email, push = EXCLUDED.push,
+		       muted_types = EXCLUDED.muted_types,
+		       quiet_hours_start = EXCLUDED.quiet_hours_start, quiet_hours_end = EXCLUDED.quiet_hours_end,
+		       timezone = EXCLUDED.timezone, locale = EXCLUDED.locale,
+		       digest_frequency = EXCLUDED.digest_frequency, updated_at = NOW()
+	`, p.UserID, p.InApp, p.Email, p.Push, muted, start, end, p.Timezone, p.Locale, digestFrequency(p.DigestFrequency))
+	return err
+}
+
+const tripPreferenceColumns = `user_id, trip_id, muted, in_app, email, push, updated_at`
+
+func scanTripPreferences(row pgx.Row) (models.TripNotificationPreferences, error) {
+	var t models.TripNotificationPreferences
+	err := row.Scan(&t.UserID, &t.TripID, &t.Muted, &t.InApp, &t.Email, &t.Push, &t.UpdatedAt)
+	return t, err
+}
+
+func (r *PreferenceRepository) Trip(ctx context.Context, userID, tripID uuid.UUID) (models.TripNotificationPreferences, error) {
+	t, err := scanTripPreferences(conn(ctx, r.db).QueryRow(ctx,
+		`SELECT `+tripPreferenceColumns+` FROM trip_notification_preferences WHERE user_id = $1 AND trip_id = $2`,
+		userID, tripID))
+	return t, notFound(err)
+}
+
+func (r *PreferenceRepository) Trips(ctx context.Context, userID uuid.UUID) ([]models.TripNotificationPreferences, error) {
+	rows, err := conn(ctx, r.db).Query(ctx,
+		`SELECT `+tripPreferenceColumns+` FROM trip_notification_preferences WHERE user_id = $1 ORDER BY trip_id`,
+		userID)
+	if err != nil {
+		return nil, err
+	}
+	return pgx.CollectRows(rows, func(row pgx.CollectableRow) (models.TripNotificationPreferences, error) {
+		return scanTripPreferences(row)
+	})
+}
+
+func (r *PreferenceRepository) SaveTrip(ctx context.Context, t models.TripNotificationPreferences) error {
+	_, err := conn(ctx, r.db).Exec(ctx, `
+		INSERT INTO trip_notification_preferences (user_id, trip_id, muted, in_app, email, push)
+		VALUES ($1, $2, $3, $4, $5, $6)
+		ON CONFLICT (user_id, trip_id) DO UPDATE
+		   SET muted = EXCLUDED.muted, in_app = EXCLUDED.in_app, email = EXCLUDED.email,
+		       push = EXCLUDED.push, updated_at = NOW()
+	`, t.UserID, t.TripID, t.Muted, t.InApp, t.Email, t.Push)
+	if _, ok := foreignKeyViolation(err); ok {
+		return repository.ErrNotFound
+	}
+	return err
+}
+
+func (r *PreferenceRepository) DeleteTrip(ctx context.Context, userID, tripID uuid.UUID) error {
+	_, err := conn(ctx, r.db).Exec(ctx,
+		`DELETE FROM trip_notification_preferences WHERE user_id = $1 AND trip_id = $2`, userID, tripID)
+	return err
+}
+
+// digestFrequency stores an empty frequency as off
+func digestFrequency(f string) string {
+	if f == "" {
+		return models.DigestOff
+	}
+	return f
+}
+
+func (r *PreferenceRepository) DigestSubscribers(ctx context.Context) ([]models.NotificationPreferences, error) {
+	rows, err := conn(ctx, r.db).Query(ctx,
+		`SELECT `+preferenceColumns+` FROM notification_preferences
+		  WHERE digest_frequency <> 'off' ORDER BY user_id`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var list []models.NotificationPreferences
+	for rows.Next() {
+		p, err := scanPreferences(rows)
+		if err != nil {
+			return nil, err
+		}
+		list = append(list, p)
+	}
+	return list, rows.Err()
+}
+
+func (r *PreferenceRepository) ClaimDigest(ctx context.Context, userID uuid.UUID, slot time.Time) (bool, error) {
+	cmd, err := conn(ctx, r.db).Exec(ctx, `
+		UPDATE notification_preferences SET last_digest_at = $2
+		 WHERE user_id = $1 AND (last_digest_at IS NULL OR last_digest_at < $2)
+	`, userID, slot)
+	if err != nil {
+		return false, err
+	}
+	return cmd.RowsAffected() > 0, nil
+}
+
+func (r *PreferenceRepository) ReleaseDigest(ctx context.Context, userID uuid.UUID, slot time.Time, previous *time.Time) error {
+	_, err := conn(ctx, r.db).Exec(ctx,
+		`UPDATE notification_preferences SET last_digest_at = $3 WHERE user_id = $1 AND last_digest_at = $2`,
+		userID, slot, previous)
+	return err
+}
@@ -0,0 +1,165 @@
+package postgres
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+
+	"GO2GETHER_BACK-END/internal/models"
+	"GO2GETHER_BACK-END/internal/repository"
+)
+
+// ProfileRepository implements repository.ProfileRepository
+type ProfileRepository struct {
+	db *pgxpool.Pool
+}
+
+func (r *ProfileRepository) Exists(ctx context.Context, userID uuid.UUID) (bool, error) {
+	var one int
+	err := conn(ctx, r.db).QueryRow(ctx, `select 1 from public.profiles where user_id = $1 limit 1`, userID).Scan(&one)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return false, nil
+	}
+	return err == nil, err
+}
+
+func (r *ProfileRepository) Get(ctx context.Context, userID uuid.UUID) (models.UserProfile, error) {
+	const q = `
+select
+	u.id,
+	p.username,
+	u.email,
+	p.first_name,
+	p.last_name,
+	p.display_name,
+	p.avatar_url,
+	p.phone,
+	p.bio,
+	p.birth_date, -- date
+	p.food_preferences,
+	p.chronic_disease,
+	p.allergic_food,
+	p.allergic_drugs,
+	p.emergency_contact,
+	u.role,
+	u.created_at,
+	u.updated_at
+from public.users u
+join public.profiles p on p.user_id = u.id
+where u.id = $1
+limit 1;
+`
+	var p models.UserProfile
+	err := conn(ctx, r.db).QueryRow(ctx, q, userID).Scan(
+		&p.UserID,
+		&p.Username,
+		&p.Email,
+		&p.FirstName,
+		&p.LastName,
+		&p.DisplayName,
+		&p.AvatarURL,
+		&p.Phone,
+		&p.Bio,
+		&p.BirthDate,
+		&p.FoodPreferences,
+		&p.ChronicDisease,
+		&p.AllergicFood,
+		&p.AllergicDrugs,
+		&p.EmergencyContact,
+		&p.Role,
+		&p.CreatedAt,
+		&p.UpdatedAt,
+	)
+	return p, notFound(err)
+}
+
+func (r *ProfileRepository) Create(ctx context.Context, p models.UserProfile) error {
+	const q = `
+insert into public.profiles(
+	user_id, username, first_name, last_name, display_name, avatar_url, phone, bio,
+	birth_date, food_preferences, chronic_disease, allergic_food, allergic_drugs, emergency_contact
+) values (
+	$1, $2, $3, $4, $5,
+	nullif($6,''), nullif($7,''), $8,
+	$9, $10, $11, $12, $13, $14
+)`
+	_, err := conn(ctx, r.db).Exec(ctx, q,
+		p.UserID, p.Username,
+		p.FirstName, p.LastName, p.DisplayName,
+		p.AvatarURL, p.Phone, p.Bio,
+		p.BirthDate,
+		p.FoodPreferences, p.ChronicDisease,
+		p.AllergicFood, p.AllergicDrugs,
+		p.EmergencyContact,
+	)
+	return profileError(err)
+}
+
+func (r *ProfileRepository) Update(ctx context.Context, userID uuid.UUID, u models.ProfileUpdate) error {
+	// สร้างชุด SET แบบไดนามิก (อัปเดตเฉพาะฟิลด์ที่ถูกส่งมา)
+	set := []string{}
+	args := []any{}
+	add := func(col string, v any) {
+		args = append(args, v)
+		set = append(set, fmt.Sprintf("%s = $%d", col, len(args)))
+	}
+	addStr := func(col string, p *string, nullIfEmpty bool) {
+		if p == nil {
+			return
+		}
+		if nullIfEmpty && *p == "" {
+			add(col, nil)
+			return
+		}
+		add(col, *p)
+	}
+
+	// username (unique) — ไม่อนุญาตให้ลบ username
+	addStr("username", u.Username, false)
+	addStr("first_name", u.FirstName, true)
+	addStr("last_name", u.LastName, true)
+	addStr("display_name", u.DisplayName, true)
+	addStr("avatar_url", u.AvatarURL, true)
+	addStr("phone", u.Phone, true)
+	addStr("bio", u.Bio, true)
+	addStr("food_preferences", u.FoodPreferences, true)
+	addStr("chronic_disease", u.ChronicDisease, true)
+	addStr("allergic_food", u.AllergicFood, true)
+	addStr("allergic_drugs", u.AllergicDrugs, true)
+	addStr("emergency_contact", u.EmergencyContact, true)
+	if u.ClearBirthDate {
+		add("birth_date", nil)
+	} else if u.BirthDate != nil {
+		add("birth_date", *u.BirthDate)
+	}
+	if len(set) == 0 {
+		return nil
+	}
+
+	args = append(args, userID)
+	q := fmt.Sprintf(`update public.profiles set %s where user_id = $%d`, strings.Join(set, ", "), len(args))
+	ct, err := conn(ctx, r.db).Exec(ctx, q, args...)
+	if err != nil {
+		return profileError(err)
+	}
+	if ct.RowsAffected() == 0 {
+		return repository.ErrNotFound
+	}
+	return nil
+}
+
+// profileError แยกเคส unique violation: username ซ้ำ หรือ user_id ซ้ำ
+func profileError(err error) error {
+	if constraint, ok := uniqueViolation(err); ok {
+		if constraint == "profiles_username_key" {
+			return repository.ErrUsernameTaken
+		}
+		return repository.ErrAlreadyExists
+	}
+	return err
+}
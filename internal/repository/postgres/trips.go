@@ -0,0 +1,172 @@
+package postgres
+
+import (
+	"context"
+	"errors"
+
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+
+	"GO2GETHER_BACK-END/internal/models"
+	"GO2GETHER_BACK-END/internal/repository"
+)
+
+// TripRepository implements repository.TripRepository
+type TripRepository struct {
+	db *pgxpool.Pool
+}
+
+const upsertBudget = `
+	INSERT INTO budget_categories (trip_id, order_index, food, hotel, shopping, transport)
+	VALUES ($1, 1, $2, $3, $4, $5)
+	ON CONFLICT (trip_id, order_index)
+	DO UPDATE SET
+		food = EXCLUDED.food,
+		hotel = EXCLUDED.hotel,
+		shopping = EXCLUDED.shopping,
+		transport = EXCLUDED.transport,
+		updated_at = now()`
+
+func (r *TripRepository) Create(ctx context.Context, t models.Trip, b models.TripBudget) error {
+	return pgx.BeginFunc(ctx, conn(ctx, r.db), func(tx pgx.Tx) error {
+		_, err := tx.Exec(ctx,
+			`INSERT INTO trips (id, name, destination, start_date, end_date, description, status, total_budget, currency, creator_id, require_organizer_mfa, created_at, updated_at)
+			 VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12, $13)`,
+			t.ID, t.Name, t.Destination, t.StartDate, t.EndDate, t.Description, t.Status, t.TotalBudget, t.Currency, t.CreatorID, t.RequireOrganizerMFA, t.CreatedAt, t.UpdatedAt,
+		)
+		if err != nil {
+			return err
+		}
+		if _, err := tx.Exec(ctx, upsertBudget, t.ID, b.Food, b.Hotel, b.Shopping, b.Transport); err != nil {
+			return err
+		}
+		_, err = tx.Exec(ctx,
+			`INSERT INTO trip_members (trip_id, user_id, role, status, availability_submitted, invited_at, joined_at)
+			 VALUES ($1, $2, 'owner', 'accepted', FALSE, $3, $3)
+			 ON CONFLICT (trip_id, user_id) DO NOTHING`,
+			t.ID, t.CreatorID, t.CreatedAt,
+		)
+		return err
+	})
+}
+
+func (r *TripRepository) Get(ctx context.Context, id uuid.UUID) (models.Trip, error) {
+	var t models.Trip
+	err := conn(ctx, r.db).QueryRow(ctx,
+		`SELECT id, name, destination, start_date, end_date, description, status, total_budget, currency, creator_id, require_organizer_mfa, created_at, updated_at
+		   FROM trips WHERE id = $1`, id,
+	).Scan(&t.ID, &t.Name, &t.Destination, &t.StartDate, &t.EndDate, &t.Description, &t.Status, &t.TotalBudget, &t.Currency, &t.CreatorID, &t.RequireOrganizerMFA, &t.CreatedAt, &t.UpdatedAt)
+	return t, notFound(err)
+}
+
+func (r *TripRepository) ListForMember(ctx context.Context, userID uuid.UUID, status string, limit, offset int) ([]models.TripSummary, int, error) {
+	var total int
+	if err := conn(ctx, r.db).QueryRow(ctx,
+		`SELECT COUNT(1)
+		   FROM trips t
+		   JOIN trip_members tm ON tm.trip_id = t.id
+		  WHERE tm.user_id = $1
+		    AND tm.status = 'accepted'
+		    AND ($2 = 'all' OR t.status = $2)`,
+		userID, status,
+	).Scan(&total); err != nil {
+		return nil, 0, err
+	}
+
+	rows, err := conn(ctx, r.db).Query(ctx,
+		`SELECT t.id, t.name, t.destination, t.start_date, t.end_date, t.description, t.status, t.total_budget, t.currency, t.creator_id, t.require_organizer_mfa, t.created_at, t.updated_at,
+		        COALESCE((SELECT COUNT(DISTINCT tm2.user_id) FROM trip_members tm2 WHERE tm2.trip_id = t.id), 0) AS member_count
+		   FROM trips t
+		   JOIN trip_members tm ON tm.trip_id = t.id
+		  WHERE tm.user_id = $1
+		    AND tm.status = 'accepted'
+		    AND ($2 = 'all' OR t.status = $2)
+		  ORDER BY t.created_at DESC
+		  LIMIT $3 OFFSET $4`, userID, status, limit, offset)
+	if err != nil {
+		return nil, 0, err
+	}
+	defer rows.Close()
+
+	items := make([]models.TripSummary, 0, limit)
+	for rows.Next() {
+		var s models.TripSummary
+		if err := rows.Scan(&s.ID, &s.Name, &s.Destination, &s.StartDate, &s.EndDate, &s.Description, &s.Status, &s.TotalBudget, &s.Currency, &s.CreatorID, &s.RequireOrganizerMFA, &s.CreatedAt, &s.UpdatedAt, &s.MemberCount); err != nil {
+			return nil, 0, err
+		}
+		items = append(items, s)
+	}
+	return items, total, rows.Err()
+}
+
+func (r *TripRepository) Update(ctx context.Context, t models.Trip, b models.TripBudget) error {
+	return pgx.BeginFunc(ctx, conn(ctx, r.db), func(tx pgx.Tx) error {
+		_, err := tx.Exec(ctx,
+			`UPDATE trips
+			    SET name = $1,
+			        destination = $2,
+			        description = $3,
+			        start_date = $4,
+			        end_date = $5,
+			        status = $6,
+			        total_budget = $7,
+			        require_organizer_mfa = $8,
+			        updated_at = $9
+			  WHERE id = $10`,
+			t.Name, t.Destination, t.Description, t.StartDate, t.EndDate, t.Status, t.TotalBudget, t.RequireOrganizerMFA, t.UpdatedAt, t.ID,
+		)
+		if err != nil {
+			return err
+		}
+		_, err = tx.Exec(ctx, upsertBudget, t.ID, b.Food, b.Hotel, b.Shopping, b.Transport)
+		return err
+	})
+}
+
+func (r *TripRepository) Delete(ctx context.Context, id uuid.UUID) error {
+	_, err := conn(ctx, r.db).Exec(ctx, `DELETE FROM trips WHERE id = $1`, id)
+	return err
+}
+
+func (r *TripRepository) TransferOwnership(ctx context.Context, tripID, from, to uuid.UUID) error {
+	return pgx.BeginFunc(ctx, conn(ctx, r.db), func(tx pgx.Tx) error {
+		cmd, err := tx.Exec(ctx,
+			`UPDATE trips SET creator_id = $3, updated_at = now() WHERE id = $1 AND creator_id = $2`,
+			tripID, from, to,
+		)
+		if err != nil {
+			return err
+		}
+		if cmd.RowsAffected() == 0 {
+			return repository.ErrNotFound
+		}
+		// ลดเจ้าของเดิมก่อน (มี owner ได้คนเดียวต่อทริป)
+		if _, err := tx.Exec(ctx,
+			`UPDATE trip_members SET role = 'co_organizer' WHERE trip_id = $1 AND user_id = $2`,
+			tripID, from,
+		); err != nil {
+			return err
+		}
+		_, err = tx.Exec(ctx,
+			`UPDATE trip_members SET role = 'owner' WHERE trip_id = $1 AND user_id = $2`,
+			tripID, to,
+		)
+		return err
+	})
+}
+
+func (r *TripRepository) Budget(ctx context.Context, tripID uuid.UUID) (models.TripBudget, error) {
+	var b models.TripBudget
+	err := conn(ctx, r.db).QueryRow(ctx,
+		`SELECT food, hotel, shopping, transport
+		   FROM budget_categories
+		  WHERE trip_id = $1 AND order_index = 1`,
+		tripID,
+	).Scan(&b.Food, &b.Hotel, &b.Shopping, &b.Transport)
+	if errors.Is(err, pgx.ErrNoRows) {
+		// ไม่มี row → ถือว่า 0 ทุกหมวด
+		return models.TripBudget{}, nil
+	}
+	return b, err
+}
@@ -0,0 +1,37 @@
+package postgres
+
+import (
+	"context"
+
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+// UserRepository implements repository.UserRepository
+type UserRepository struct {
+	db *pgxpool.Pool
+}
+
+func (r *UserRepository) EmailVerified(ctx context.Context, userID uuid.UUID) (bool, error) {
+	var verified bool
+	err := conn(ctx, r.db).QueryRow(ctx,
+		`SELECT email_verified_at IS NOT NULL FROM users WHERE id = $1`, userID,
+	).Scan(&verified)
+	return verified, notFound(err)
+}
+
+func (r *UserRepository) MFAEnabled(ctx context.Context, userID uuid.UUID) (bool, error) {
+	var enabled bool
+	err := conn(ctx, r.db).QueryRow(ctx,
+		`SELECT totp_enabled_at IS NOT NULL FROM users WHERE id = $1`, userID,
+	).Scan(&enabled)
+	return enabled, notFound(err)
+}
+
+func (r *UserRepository) Email(ctx context.Context, userID uuid.UUID) (string, error) {
+	var email string
+	err := conn(ctx, r.db).QueryRow(ctx,
+		`SELECT email FROM users WHERE id = $1`, userID,
+	).Scan(&email)
+	return email, notFound(err)
+}
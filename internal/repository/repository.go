@@ -0,0 +1,205 @@
+// Package repository defines the data access interfaces used by the service
+// layer. repository/postgres implements them over pgx; repository/memory is an
+// in-memory implementation for tests.
+package repository
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/google/uuid"
+
+	"GO2GETHER_BACK-END/internal/models"
+)
+
+var (
+	// ErrNotFound is returned when the requested row does not exist
+	ErrNotFound = errors.New("not found")
+	// ErrAlreadyExists is returned when a row with the same key exists
+	ErrAlreadyExists = errors.New("already exists")
+	// ErrUsernameTaken is returned when another profile uses the username
+	ErrUsernameTaken = errors.New("username already taken")
+)
+
+// Repositories groups every repository of one backend
+type Repositories struct {
+	Trips         TripRepository
+	Members       MemberRepository
+	Availability  AvailabilityRepository
+	Periods       PeriodRepository
+	Notifications NotificationRepository
+	Users         UserRepository
+	Profiles      ProfileRepository
+	Outbox        OutboxRepository
+	Preferences   PreferenceRepository
+	Tx            Transactor
+}
+
+// Transactor runs work in one database transaction
+type Transactor interface {
+	// InTx runs fn in a transaction that commits when fn returns nil and rolls
+	// back otherwise. Repository calls made with the ctx fn receives join the
+	// transaction; an InTx nested in fn joins the outer one.
+	InTx(ctx context.Context, fn func(ctx context.Context) error) error
+}
+
+// TripRepository stores trips and their budget breakdown
+type TripRepository interface {
+	// Create inserts the trip, its budget and the creator's membership together
+	Create(ctx context.Context, t models.Trip, b models.TripBudget) error
+	Get(ctx context.Context, id uuid.UUID) (models.Trip, error)
+	// ListForMember lists the trips userID has accepted, newest first. status
+	// "all" matches every status. It also returns the total before paging.
+	ListForMember(ctx context.Context, userID uuid.UUID, status string, limit, offset int) ([]models.TripSummary, int, error)
+	// Update saves the trip fields and the budget together
+	Update(ctx context.Context, t models.Trip, b models.TripBudget) error
+	Delete(ctx context.Context, id uuid.UUID) error
+	// TransferOwnership makes to the trip's creator and owner member and from
+	// a co-organizer, together. It returns ErrNotFound when from no longer
+	// owns the trip.
+	TransferOwnership(ctx context.Context, tripID, from, to uuid.UUID) error
+	// Budget returns the breakdown, all zero when none was saved
+	Budget(ctx context.Context, tripID uuid.UUID) (models.TripBudget, error)
+}
+
+// MemberRepository stores trip_members
+type MemberRepository interface {
+	Get(ctx context.Context, tripID, userID uuid.UUID) (models.TripMember, error)
+	// List returns every member with the user's email
+	List(ctx context.Context, tripID uuid.UUID) ([]models.TripMember, error)
+	// ListInvitations returns pending, accepted and declined members with their
+	// profile, most recently invited first
+	ListInvitations(ctx context.Context, tripID uuid.UUID) ([]models.TripMember, error)
+	Stats(ctx context.Context, tripID uuid.UUID) (models.MemberStats, error)
+	// AcceptedUserIDs lists the users whose membership is accepted
+	AcceptedUserIDs(ctx context.Context, tripID uuid.UUID) ([]uuid.UUID, error)
+	// Accept inserts m as accepted, or marks an existing membership accepted
+	// with m.JoinedAt
+	Accept(ctx context.Context, m models.TripMember) error
+	// SetRole changes the member's role; ErrNotFound when there is no membership
+	SetRole(ctx context.Context, tripID, userID uuid.UUID, role string) error
+	// Remove deletes the membership; when status is not empty only a
+	// membership in that status. It reports whether a row was deleted.
+	Remove(ctx context.Context, tripID, userID uuid.UUID, status string) (bool, error)
+}
+
+// AvailabilityRepository stores the days members are free
+type AvailabilityRepository interface {
+	// Replace swaps the user's free days for dates and marks their
+	// availability as submitted
+	Replace(ctx context.Context, tripID, userID uuid.UUID, dates []time.Time) error
+	// Dates lists the user's free days in ascending order
+	Dates(ctx context.Context, tripID, userID uuid.UUID) ([]time.Time, error)
+	// DailyFreeCounts returns, for every day from..to, how many accepted
+	// members are free
+	DailyFreeCounts(ctx context.Context, tripID uuid.UUID, from, to time.Time) ([]models.DayCount, error)
+}
+
+// PeriodRepository stores generated available periods
+type PeriodRepository interface {
+	// Replace deletes the trip's periods and inserts periods
+	Replace(ctx context.Context, tripID uuid.UUID, periods []models.AvailablePeriod) error
+	List(ctx context.Context, tripID uuid.UUID) ([]models.AvailablePeriod, error)
+}
+
+// NotificationFilter selects notifications of a user
+type NotificationFilter struct {
+	UnreadOnly bool
+	Type       string // empty for every type
+	Limit      int
+	Offset     int
+}
+
+// NotificationRepository stores in-app notifications
+type NotificationRepository interface {
+	// Insert stores n; the ID is generated when n.ID is nil and CreatedAt
+	// when zero. Inserting an ID that exists does nothing.
+	Insert(ctx context.Context, n models.Notification) error
+	// List returns a page of the user's notifications, newest first, and the
+	// total matching the filter
+	List(ctx context.Context, userID uuid.UUID, f NotificationFilter) ([]models.Notification, int, error)
+	CountUnread(ctx context.Context, userID uuid.UUID) (int, error)
+	// MarkRead marks an unread notification of the user as read and reports
+	// whether it did
+	MarkRead(ctx context.Context, id, userID uuid.UUID) (bool, error)
+	Exists(ctx context.Context, id uuid.UUID) (bool, error)
+	MarkAllRead(ctx context.Context, userID uuid.UUID) (int64, error)
+	Get(ctx context.Context, id uuid.UUID) (models.Notification, error)
+	// ListAfter returns up to limit notifications of the user created after
+	// afterID, oldest first; ErrNotFound when afterID is not the user's
+	ListAfter(ctx context.Context, userID, afterID uuid.UUID, limit int) ([]models.Notification, error)
+	// ListForDigest returns up to limit unread notifications of the user not
+	// digested yet and created in [from, to), oldest first
+	ListForDigest(ctx context.Context, userID uuid.UUID, from, to time.Time, limit int) ([]models.Notification, error)
+	// MarkDigested records that the notifications went out in a digest at at
+	MarkDigested(ctx context.Context, ids []uuid.UUID, at time.Time) error
+}
+
+// UserRepository reads account state used by business rules
+type UserRepository interface {
+	EmailVerified(ctx context.Context, userID uuid.UUID) (bool, error)
+	MFAEnabled(ctx context.Context, userID uuid.UUID) (bool, error)
+	// Email returns the account's email address
+	Email(ctx context.Context, userID uuid.UUID) (string, error)
+}
+
+// ProfileRepository stores user profiles
+type ProfileRepository interface {
+	Exists(ctx context.Context, userID uuid.UUID) (bool, error)
+	// Get returns the profile together with the account's email and role
+	Get(ctx context.Context, userID uuid.UUID) (models.UserProfile, error)
+	// Create returns ErrUsernameTaken or ErrAlreadyExists on a duplicate
+	Create(ctx context.Context, p models.UserProfile) error
+	// Update returns ErrNotFound when the user has no profile
+	Update(ctx context.Context, userID uuid.UUID, u models.ProfileUpdate) error
+}
+
+// OutboxRepository stores domain events until the dispatcher has delivered
+// them (see internal/outbox)
+type OutboxRepository interface {
+	// Add stores e; it does nothing when an event with e.IdempotencyKey
+	// exists. Call it inside Transactor.InTx with the change e reports.
+	Add(ctx context.Context, e models.OutboxEvent) error
+	// Claim leases up to limit due events for lease and counts an attempt on
+	// each; other dispatchers skip them until the lease ends
+	Claim(ctx context.Context, limit int, lease time.Duration) ([]models.OutboxEvent, error)
+	// Delivered lists the channels the event has been delivered on
+	Delivered(ctx context.Context, id uuid.UUID) ([]string, error)
+	// MarkDelivered records the delivery of the event on channel
+	MarkDelivered(ctx context.Context, id uuid.UUID, channel string) error
+	// Done marks the event processed
+	Done(ctx context.Context, id uuid.UUID) error
+	// Retry makes the event due again at at, keeping lastErr
+	Retry(ctx context.Context, id uuid.UUID, at time.Time, lastErr string) error
+	// Defer makes the event due again at at without counting the attempt its
+	// claim made, for deliveries postponed on purpose (quiet hours)
+	Defer(ctx context.Context, id uuid.UUID, at time.Time) error
+	// DeadLetter copies the event with reason to the dead-letter table and
+	// marks it processed
+	DeadLetter(ctx context.Context, id uuid.UUID, reason string) error
+	// Prune deletes the events processed before before and returns how many
+	Prune(ctx context.Context, before time.Time) (int64, error)
+}
+
+// PreferenceRepository stores notification preferences
+type PreferenceRepository interface {
+	// Get returns the user's preferences, or the defaults when none were saved
+	Get(ctx context.Context, userID uuid.UUID) (models.NotificationPreferences, error)
+	Save(ctx context.Context, p models.NotificationPreferences) error
+	// Trip returns the user's settings for the trip; ErrNotFound when none
+	Trip(ctx context.Context, userID, tripID uuid.UUID) (models.TripNotificationPreferences, error)
+	// Trips lists the user's settings of every trip
+	Trips(ctx context.Context, userID uuid.UUID) ([]models.TripNotificationPreferences, error)
+	SaveTrip(ctx context.Context, t models.TripNotificationPreferences) error
+	DeleteTrip(ctx context.Context, userID, tripID uuid.UUID) error
+	// DigestSubscribers lists the preferences of the users whose digests are on
+	DigestSubscribers(ctx context.Context) ([]models.NotificationPreferences, error)
+	// ClaimDigest records slot as the user's latest digest unless it or a
+	// later one already is, and reports whether it did. A digest is sent only
+	// by the replica that claimed it.
+	ClaimDigest(ctx context.Context, userID uuid.UUID, slot time.Time) (bool, error)
+	// ReleaseDigest undoes the claim of slot, restoring previous, when its
+	// digest could not be sent; it is then tried again
+	ReleaseDigest(ctx context.Context, userID uuid.UUID, slot time.Time, previous *time.Time) error
+}
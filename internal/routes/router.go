@@ -0,0 +1,113 @@
+package routes
+
+import (
+	"net/http"
+	"sort"
+	"strings"
+
+	"GO2GETHER_BACK-END/internal/logging"
+	"GO2GETHER_BACK-END/internal/utils"
+)
+
+// Route is one entry of the route table
+type Route struct {
+	Method  string // empty for handlers mounted for every method (swagger, fake provider)
+	Pattern string // net/http pattern path, e.g. /api/trips/{trip_id}/budget
+	Handler http.Handler
+}
+
+// Router is an http.Handler over a dedicated ServeMux that keeps the list of
+// registered routes. Paths are registered on the mux without a method so that
+// a wrong method gets a JSON 405 with an Allow header instead of the plain
+// text body of net/http.
+type Router struct {
+	mux     *http.ServeMux
+	routes  []Route
+	methods map[string]map[string]http.Handler // pattern → method → handler
+}
+
+// NewRouter creates an empty Router
+func NewRouter() *Router {
+	return &Router{
+		mux:     http.NewServeMux(),
+		methods: make(map[string]map[string]http.Handler),
+	}
+}
+
+// HandleFunc registers a handler for method and pattern path
+func (rt *Router) HandleFunc(method, pattern string, h http.HandlerFunc) {
+	rt.Handle(method, pattern, h)
+}
+
+// Handle registers a handler for method and pattern path. A GET route also
+// answers HEAD.
+func (rt *Router) Handle(method, pattern string, h http.Handler) {
+	if method == "" {
+		rt.mux.Handle(pattern, h)
+		rt.routes = append(rt.routes, Route{Pattern: pattern, Handler: h})
+		return
+	}
+
+	byMethod, ok := rt.methods[pattern]
+	if !ok {
+		byMethod = make(map[string]http.Handler)
+		rt.methods[pattern] = byMethod
+		rt.mux.Handle(pattern, rt.dispatch(byMethod))
+	}
+	if _, dup := byMethod[method]; dup {
+		panic("routes: duplicate route " + method + " " + pattern)
+	}
+	byMethod[method] = h
+	rt.routes = append(rt.routes, Route{Method: method, Pattern: pattern, Handler: h})
+}
+
+// Routes returns the registered routes in registration order
+func (rt *Router) Routes() []Route {
+	return append([]Route(nil), rt.routes...)
+}
+
+// Handler returns the handler and route pattern r would be served by, like
+// http.ServeMux.Handler. The method is not taken into account.
+func (rt *Router) Handler(r *http.Request) (http.Handler, string) {
+	return rt.mux.Handler(r)
+}
+
+// ServeHTTP implements http.Handler. The matched pattern is recorded for the
+// access log.
+func (rt *Router) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if _, pattern := rt.mux.Handler(r); pattern != "" {
+		logging.SetRoute(r.Context(), pattern)
+	}
+	rt.mux.ServeHTTP(w, r)
+}
+
+func (rt *Router) dispatch(byMethod map[string]http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		h, ok := byMethod[r.Method]
+		if !ok && r.Method == http.MethodHead {
+			h, ok = byMethod[http.MethodGet]
+		}
+		if !ok {
+			allow := allowedMethods(byMethod)
+			w.Header().Set("Allow", allow)
+			utils.WriteErrorResponse(w, http.StatusMethodNotAllowed, "Method not allowed",
+				r.Method+" is not supported on this resource; allowed: "+allow)
+			return
+		}
+		h.ServeHTTP(w, r)
+	})
+}
+
+func allowedMethods(byMethod map[string]http.Handler) string {
+	methods := make([]string, 0, len(byMethod)+1)
+	for m := range byMethod {
+		methods = append(methods, m)
+	}
+	if _, ok := byMethod[http.MethodGet]; ok {
+		if _, ok := byMethod[http.MethodHead]; !ok {
+			methods = append(methods, http.MethodHead)
+		}
+	}
+	sort.Strings(methods)
+	return strings.Join(methods, ", ")
+}
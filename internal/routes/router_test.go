@@ -0,0 +1,149 @@
+package routes
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"regexp"
+	"sort"
+	"strings"
+	"testing"
+
+	"GO2GETHER_BACK-END/internal/config"
+	"GO2GETHER_BACK-END/internal/dto"
+	"GO2GETHER_BACK-END/internal/oauth"
+)
+
+func ok(w http.ResponseWriter, _ *http.Request) { w.WriteHeader(http.StatusNoContent) }
+
+func TestRouterMethodNotAllowed(t *testing.T) {
+	rt := NewRouter()
+	rt.HandleFunc(http.MethodGet, "/api/things/{id}", ok)
+	rt.HandleFunc(http.MethodPost, "/api/things/{id}", ok)
+	rt.HandleFunc(http.MethodDelete, "/api/things/{id}", ok)
+
+	tests := []struct {
+		method string
+		status int
+	}{
+		{http.MethodGet, http.StatusNoContent},
+		{http.MethodHead, http.StatusNoContent}, // GET ตอบ HEAD ให้
+		{http.MethodPost, http.StatusNoContent},
+		{http.MethodDelete, http.StatusNoContent},
+		{http.MethodPut, http.StatusMethodNotAllowed},
+		{http.MethodPatch, http.StatusMethodNotAllowed},
+		{http.MethodOptions, http.StatusMethodNotAllowed},
+	}
+	for _, tt := range tests {
+		t.Run(tt.method, func(t *testing.T) {
+			rec := httptest.NewRecorder()
+			rt.ServeHTTP(rec, httptest.NewRequest(tt.method, "/api/things/42", nil))
+			if rec.Code != tt.status {
+				t.Fatalf("status = %d, want %d", rec.Code, tt.status)
+			}
+			if tt.status != http.StatusMethodNotAllowed {
+				return
+			}
+			if got := rec.Header().Get("Allow"); got != "DELETE, GET, HEAD, POST" {
+				t.Errorf("Allow = %q", got)
+			}
+			assertProblem(t, rec, tt.method)
+		})
+	}
+}
+
+func TestRouterAllowWithoutGet(t *testing.T) {
+	rt := NewRouter()
+	rt.HandleFunc(http.MethodPost, "/api/things", ok)
+
+	rec := httptest.NewRecorder()
+	rt.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/api/things", nil))
+	if rec.Code != http.StatusMethodNotAllowed || rec.Header().Get("Allow") != "POST" {
+		t.Errorf("status = %d, Allow = %q; want 405, POST", rec.Code, rec.Header().Get("Allow"))
+	}
+}
+
+func TestRouterDuplicateRoutePanics(t *testing.T) {
+	rt := NewRouter()
+	rt.HandleFunc(http.MethodGet, "/api/things", ok)
+	defer func() {
+		if recover() == nil {
+			t.Error("registering GET /api/things twice did not panic")
+		}
+	}()
+	rt.HandleFunc(http.MethodGet, "/api/things", ok)
+}
+
+var wildcard = regexp.MustCompile(`\{[^}]+\}`)
+
+// TestRouteTableMethodNotAllowed sends a method no route has to every path of
+// the application route table. Handlers are never reached on a 405, so nil
+// handlers are enough to build the table.
+func TestRouteTableMethodNotAllowed(t *testing.T) {
+	cfg := &config.Config{Env: config.EnvDevelopment}
+	cfg.OAuth.FakeProvider = true
+	cfg.OAuth.FakeRedirectURL = "http://localhost:8080/api/auth/fake/callback"
+	cfg.Telemetry.MetricsEnabled = true
+	cfg.Notifications.WebSocket = true
+	providers, err := oauth.NewRegistry(cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	rt := SetupRoutes(nil, nil, nil, nil, nil, nil, nil, nil, nil, nil, nil, nil, providers, cfg)
+
+	methods := map[string][]string{}
+	for _, route := range rt.Routes() {
+		if route.Method == "" {
+			continue
+		}
+		methods[route.Pattern] = append(methods[route.Pattern], route.Method)
+	}
+	if _, ok := methods[oauth.FakeAuthorizePath]; !ok {
+		t.Errorf("fake provider consent endpoint %s is not routed", oauth.FakeAuthorizePath)
+	}
+
+	for pattern, allowed := range methods {
+		want := append([]string(nil), allowed...)
+		for _, m := range allowed {
+			if m == http.MethodGet {
+				want = append(want, http.MethodHead)
+			}
+		}
+		sort.Strings(want)
+
+		path := wildcard.ReplaceAllString(pattern, "x")
+		t.Run(pattern, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodOptions, path, nil)
+			if _, got := rt.Handler(req); got != pattern {
+				t.Fatalf("%s is served by %q", path, got)
+			}
+			rec := httptest.NewRecorder()
+			rt.ServeHTTP(rec, req)
+			if rec.Code != http.StatusMethodNotAllowed {
+				t.Fatalf("status = %d, want 405", rec.Code)
+			}
+			if got := rec.Header().Get("Allow"); got != strings.Join(want, ", ") {
+				t.Errorf("Allow = %q, want %q", got, strings.Join(want, ", "))
+			}
+			assertProblem(t, rec, http.MethodOptions)
+		})
+	}
+}
+
+// assertProblem checks the problem+json body of a 405
+func assertProblem(t *testing.T, rec *httptest.ResponseRecorder, method string) {
+	t.Helper()
+	if ct := rec.Header().Get("Content-Type"); ct != "application/problem+json" {
+		t.Errorf("Content-Type = %q", ct)
+	}
+	var body dto.ErrorResponse
+	if err := json.Unmarshal(rec.Body.Bytes(), &body); err != nil {
+		t.Fatalf("body is not JSON: %v: %s", err, rec.Body)
+	}
+	if body.Status != http.StatusMethodNotAllowed || body.Code != "method_not_allowed" {
+		t.Errorf("status = %d, code = %q", body.Status, body.Code)
+	}
+	if !strings.Contains(body.Detail, method) {
+		t.Errorf("detail %q does not name %s", body.Detail, method)
+	}
+}
@@ -2,85 +2,170 @@ package routes
 
 import (
 	"net/http"
-	"strings"
 
 	"GO2GETHER_BACK-END/internal/config"
 	"GO2GETHER_BACK-END/internal/handlers"
 	"GO2GETHER_BACK-END/internal/middleware"
+	"GO2GETHER_BACK-END/internal/oauth"
+	"GO2GETHER_BACK-END/internal/utils"
 
 	httpSwagger "github.com/swaggo/http-swagger"
 )
 
-// SetupRoutes configures all application routes
+// SetupRoutes builds the application route table
 func SetupRoutes(
 	authHandler *handlers.AuthHandler,
 	healthHandler *handlers.HealthHandler,
-	googleAuthHandler *handlers.GoogleAuthHandler,
+	oauthHandler *handlers.OAuthHandler,
+	identitiesHandler *handlers.IdentitiesHandler,
 	forgotPasswordHandler *handlers.ForgotPasswordHandler,
+	emailVerificationHandler *handlers.EmailVerificationHandler,
+	mfaHandler *handlers.MFAHandler,
+	testHelpersHandler *handlers.TestHelpersHandler,
 	tripsHandler *handlers.TripsHandler,
 	profileHandler *handlers.ProfileHandler,
 	noti *handlers.NotificationsHandler,
+	keysHandler *handlers.KeysHandler,
+	providers *oauth.Registry,
 	cfg *config.Config,
-) {
+) *Router {
+	rt := NewRouter()
+	auth := func(next http.HandlerFunc) http.HandlerFunc {
+		return middleware.AuthMiddleware(next, &cfg.JWT)
+	}
+
 	// Health check routes
-	http.HandleFunc("/healthz", healthHandler.HealthCheck)
-	http.HandleFunc("/livez", healthHandler.LivenessCheck)
-	http.HandleFunc("/readyz", healthHandler.ReadinessCheck)
+	rt.HandleFunc(http.MethodGet, "/healthz", healthHandler.HealthCheck)
+	rt.HandleFunc(http.MethodGet, "/livez", healthHandler.LivenessCheck)
+	rt.HandleFunc(http.MethodGet, "/readyz", healthHandler.ReadinessCheck)
+	rt.HandleFunc(http.MethodGet, "/version", healthHandler.Version)
+
+	// Prometheus metrics
+	if cfg.Telemetry.MetricsEnabled {
+		rt.Handle(http.MethodGet, "/metrics", middleware.MetricsEndpoint(cfg.Telemetry.MetricsToken))
+	}
 
-	// Authentication routes
-	http.HandleFunc("/api/auth/register", authHandler.Register)
-	http.HandleFunc("/api/auth/login", authHandler.Login)
-	http.HandleFunc("/api/auth/profile", middleware.AuthMiddleware(authHandler.GetProfile, &cfg.JWT))
+	// Public token verification keys
+	rt.HandleFunc(http.MethodGet, "/.well-known/jwks.json", keysHandler.JWKS)
+
+	// Rate limits for the brute-forceable auth endpoints
+	authLimit := middleware.RateLimit{Requests: int(cfg.RateLimit.AuthRequests), Per: cfg.RateLimit.AuthWindow}
+	otpLimit := middleware.RateLimit{Requests: int(cfg.RateLimit.OTPRequests), Per: cfg.RateLimit.OTPWindow}
+	rateLimited := func(next http.HandlerFunc, name string, limit middleware.RateLimit, keys ...middleware.RateLimitKey) http.HandlerFunc {
+		if !cfg.RateLimit.Enabled {
+			return next
+		}
+		return middleware.RateLimitMiddleware(next, name, limit, keys...)
+	}
 
-	// Google OAuth routes
-	http.HandleFunc("/api/auth/google/login", googleAuthHandler.GoogleLogin)
-	http.HandleFunc("/api/auth/google/callback", googleAuthHandler.GoogleCallback)
+	// Authentication routes
+	rt.HandleFunc(http.MethodPost, "/api/auth/register", rateLimited(authHandler.Register, "register", authLimit, middleware.ByIP))
+	rt.HandleFunc(http.MethodPost, "/api/auth/login", rateLimited(authHandler.Login, "login", authLimit, middleware.ByIP, middleware.ByEmail))
+	rt.HandleFunc(http.MethodGet, "/api/auth/profile", auth(authHandler.GetProfile))
+	rt.HandleFunc(http.MethodPost, "/api/auth/refresh", rateLimited(authHandler.RefreshToken, "refresh", authLimit, middleware.ByIP))
+	rt.HandleFunc(http.MethodPost, "/api/auth/logout", authHandler.Logout)
+	rt.HandleFunc(http.MethodPost, "/api/auth/logout-all", auth(authHandler.LogoutAll))
+	rt.HandleFunc(http.MethodDelete, "/api/auth/account", auth(authHandler.DeleteAccount))
+
+	// Two-factor authentication (TOTP)
+	rt.HandleFunc(http.MethodGet, "/api/auth/mfa", auth(mfaHandler.Status))
+	rt.HandleFunc(http.MethodPost, "/api/auth/mfa/setup", auth(mfaHandler.Setup))
+	rt.HandleFunc(http.MethodPost, "/api/auth/mfa/enable", auth(
+		rateLimited(mfaHandler.Enable, "mfa-enable", otpLimit, middleware.ByUserID)))
+	rt.HandleFunc(http.MethodPost, "/api/auth/mfa/disable", auth(
+		rateLimited(mfaHandler.Disable, "mfa-disable", otpLimit, middleware.ByUserID)))
+	rt.HandleFunc(http.MethodPost, "/api/auth/mfa/recovery-codes", auth(
+		rateLimited(mfaHandler.RegenerateRecoveryCodes, "mfa-recovery-codes", otpLimit, middleware.ByUserID)))
+	rt.HandleFunc(http.MethodPost, "/api/auth/mfa/verify", rateLimited(mfaHandler.Verify, "mfa-verify", authLimit, middleware.ByIP))
+
+	// OAuth provider routes (POST callback is Apple's form_post)
+	rt.HandleFunc(http.MethodGet, "/api/auth/providers", oauthHandler.Providers)
+	rt.HandleFunc(http.MethodGet, "/api/auth/{provider}/login", oauthHandler.WithProvider(oauthHandler.Login))
+	rt.HandleFunc(http.MethodGet, "/api/auth/{provider}/callback", oauthHandler.WithProvider(oauthHandler.Callback))
+	rt.HandleFunc(http.MethodPost, "/api/auth/{provider}/callback", oauthHandler.WithProvider(oauthHandler.Callback))
+	rt.HandleFunc(http.MethodPost, "/api/auth/{provider}/exchange",
+		rateLimited(oauthHandler.WithProvider(oauthHandler.Exchange), "oauth-exchange", authLimit, middleware.ByIP))
 
 	// Forgot Password routes
-	http.HandleFunc("/api/auth/forgot-password", forgotPasswordHandler.ForgotPassword)
-	http.HandleFunc("/api/auth/verify-otp", forgotPasswordHandler.VerifyOTP)
-	http.HandleFunc("/api/auth/reset-password", forgotPasswordHandler.ResetPassword)
-	http.HandleFunc("/api/auth/get-otp", forgotPasswordHandler.GetOTP)
-
-	// Trip routes (GET list/POST create, and GET detail)
-	// /api/trips       → list/create
-	http.HandleFunc("/api/trips", middleware.AuthMiddleware(tripsHandler.Trips, &cfg.JWT))
-
-	// /api/trips/...   → ใช้ wrapper เพื่อตรวจ route ย่อย เช่น /api/trips/{id}/budget
-	http.HandleFunc("/api/trips/", middleware.AuthMiddleware(
-		func(w http.ResponseWriter, r *http.Request) {
-			path := r.URL.Path
-
-			// ถ้าเป็น /api/trips/{trip_id}/budget → ส่งเข้า GetTripBudget
-			if strings.HasSuffix(path, "/budget") && r.Method == http.MethodGet {
-				tripsHandler.GetTripBudget(w, r)
-				return
+	rt.HandleFunc(http.MethodPost, "/api/auth/forgot-password", rateLimited(forgotPasswordHandler.ForgotPassword, "forgot-password", otpLimit, middleware.ByIP, middleware.ByEmail))
+	rt.HandleFunc(http.MethodPost, "/api/auth/verify-otp", rateLimited(forgotPasswordHandler.VerifyOTP, "verify-otp", otpLimit, middleware.ByIP, middleware.ByEmail))
+	rt.HandleFunc(http.MethodPost, "/api/auth/reset-password", rateLimited(forgotPasswordHandler.ResetPassword, "reset-password", authLimit, middleware.ByIP))
+
+	// Email verification routes
+	rt.HandleFunc(http.MethodPost, "/api/auth/verify-email", rateLimited(emailVerificationHandler.VerifyEmail, "verify-email", otpLimit, middleware.ByIP, middleware.ByEmail))
+	rt.HandleFunc(http.MethodPost, "/api/auth/resend-verification", auth(
+		rateLimited(emailVerificationHandler.ResendVerification, "resend-verification", otpLimit, middleware.ByUserID)))
+
+	// Test helpers expose OTP codes and sent emails; never served in production
+	if !cfg.IsProduction() {
+		rt.HandleFunc(http.MethodPost, "/api/auth/get-otp", testHelpersHandler.GetOTP)
+		rt.HandleFunc(http.MethodGet, "/api/test/emails", testHelpersHandler.SentEmails)
+		rt.HandleFunc(http.MethodDelete, "/api/test/emails", testHelpersHandler.SentEmails)
+
+		// Consent endpoint of the fake OIDC provider (OAUTH_FAKE_PROVIDER=true)
+		if fake, err := providers.Get("fake"); err == nil {
+			if h, ok := fake.(http.Handler); ok {
+				rt.Handle(http.MethodGet, oauth.FakeAuthorizePath, h)
 			}
+		}
+	}
 
-			// route อื่น ๆ ใต้ /api/trips/ ยังไป handler เดิม
-			tripsHandler.Trips(w, r)
-		},
-		&cfg.JWT,
-	))
+	// Trip routes
+	rt.HandleFunc(http.MethodGet, "/api/trips", auth(tripsHandler.ListTrips))                                                         // FR1.2
+	rt.HandleFunc(http.MethodPost, "/api/trips", auth(tripsHandler.CreateTrip))                                                       // FR1.1
+	rt.HandleFunc(http.MethodPost, "/api/trips/join", auth(tripsHandler.JoinViaLink))                                                 // join via invitation link
+	rt.HandleFunc(http.MethodGet, "/api/trips/{trip_id}", auth(tripsHandler.TripDetail))                                              // FR1.3
+	rt.HandleFunc(http.MethodPut, "/api/trips/{trip_id}", auth(tripsHandler.UpdateTrip))                                              // FR1.4
+	rt.HandleFunc(http.MethodPatch, "/api/trips/{trip_id}", auth(tripsHandler.UpdateTrip))                                            // FR1.4
+	rt.HandleFunc(http.MethodDelete, "/api/trips/{trip_id}", auth(tripsHandler.DeleteTrip))                                           // FR1.5
+	rt.HandleFunc(http.MethodGet, "/api/trips/{trip_id}/budget", auth(tripsHandler.GetTripBudget))                                    // budget breakdown
+	rt.HandleFunc(http.MethodPost, "/api/trips/{trip_id}/invitations", auth(tripsHandler.InviteMembers))                              // FR3.1
+	rt.HandleFunc(http.MethodGet, "/api/trips/{trip_id}/invitations", auth(tripsHandler.ListInvitations))                             // FR3.3
+	rt.HandleFunc(http.MethodPost, "/api/trips/{trip_id}/leave", auth(tripsHandler.LeaveTrip))                                        // FR3.5
+	rt.HandleFunc(http.MethodDelete, "/api/trips/{trip_id}/members/{user_id}", auth(tripsHandler.RemoveMember))                       // FR3.6
+	rt.HandleFunc(http.MethodPut, "/api/trips/{trip_id}/members/{user_id}/role", auth(tripsHandler.UpdateMemberRole))                 // FR3.7
+	rt.HandleFunc(http.MethodPost, "/api/trips/{trip_id}/transfer-ownership", auth(tripsHandler.TransferOwnership))                   // FR3.8
+	rt.HandleFunc(http.MethodGet, "/api/trips/{trip_id}/dates", auth(tripsHandler.TripDates))                                         // 2.1
+	rt.HandleFunc(http.MethodPost, "/api/trips/{trip_id}/availability", auth(tripsHandler.SaveAvailability))                          // 2.2
+	rt.HandleFunc(http.MethodGet, "/api/trips/{trip_id}/availability/me", auth(tripsHandler.GetMyAvailability))                       // 2.3
+	rt.HandleFunc(http.MethodPost, "/api/trips/{trip_id}/availability/generate-periods", auth(tripsHandler.GenerateAvailablePeriods)) // 2.4
+	rt.HandleFunc(http.MethodGet, "/api/trips/{trip_id}/available-periods", auth(tripsHandler.GetAvailablePeriods))                   // 2.5
 
 	// Profile routes
-	// 6.1 เพิ่มโปรไฟล์: POST /api/profile  (ต้องผ่าน AuthMiddleware เพื่อให้มี userID ใน context)
+	// 6.1 เพิ่มโปรไฟล์: POST /api/profile
 	// 6.2 GET  /api/profile  (ดูโปรไฟล์ตัวเอง)
+	// 6.3 PUT  /api/profile  (แก้ไขโปรไฟล์)
 	// 6.4 GET  /api/profile/check  (ตรวจสอบว่า user มี profile หรือไม่)
-	http.HandleFunc("/api/profile", middleware.AuthMiddleware(profileHandler.Handle, &cfg.JWT))
-	http.HandleFunc("/api/profile/check", middleware.AuthMiddleware(profileHandler.Check, &cfg.JWT))
-
-	http.HandleFunc("/api/notifications", middleware.AuthMiddleware(noti.ListNotifications, &cfg.JWT))    // GET
-	http.HandleFunc("/api/notifications/read-all", middleware.AuthMiddleware(noti.MarkAllRead, &cfg.JWT)) // POST
-	http.HandleFunc("/api/notifications/", middleware.AuthMiddleware(noti.MarkRead, &cfg.JWT))            // POST /api/notifications/{id}/read
+	rt.HandleFunc(http.MethodPost, "/api/profile", auth(profileHandler.Create))
+	rt.HandleFunc(http.MethodGet, "/api/profile", auth(profileHandler.GetMe))
+	rt.HandleFunc(http.MethodPut, "/api/profile", auth(profileHandler.Update))
+	rt.HandleFunc(http.MethodGet, "/api/profile/check", auth(profileHandler.Check))
+
+	// Linked sign-in providers
+	rt.HandleFunc(http.MethodGet, "/api/profile/identities", auth(identitiesHandler.ListIdentities))
+	rt.HandleFunc(http.MethodPost, "/api/profile/identities/{provider}", auth(oauthHandler.Link))
+	rt.HandleFunc(http.MethodDelete, "/api/profile/identities/{provider}", auth(identitiesHandler.UnlinkIdentity))
+
+	// Notification routes
+	rt.HandleFunc(http.MethodGet, "/api/notifications", auth(noti.ListNotifications))
+	rt.HandleFunc(http.MethodGet, "/api/notifications/stream", middleware.QueryToken(auth(noti.Stream)))
+	if cfg.Notifications.WebSocket {
+		rt.HandleFunc(http.MethodGet, "/api/notifications/ws", middleware.QueryToken(auth(noti.WebSocket)))
+	}
+	rt.HandleFunc(http.MethodGet, "/api/notifications/preferences", auth(noti.GetPreferences))
+	rt.HandleFunc(http.MethodPut, "/api/notifications/preferences", auth(noti.UpdatePreferences))
+	rt.HandleFunc(http.MethodPost, "/api/notifications/read-all", auth(noti.MarkAllRead))
+	rt.HandleFunc(http.MethodPost, "/api/notifications/{id}/read", auth(noti.MarkRead))
 
-	// Swagger documentation (must be registered before root handler)
-	http.Handle("/swagger/", httpSwagger.Handler(
+	// Swagger documentation
+	rt.Handle("", "/swagger/", httpSwagger.Handler(
 		httpSwagger.URL("http://localhost:8080/swagger/doc.json"),
 	))
 
 	// Root route with 404 handling
-	http.HandleFunc("/", rootHandler)
+	rt.HandleFunc("", "/", rootHandler)
+
+	return rt
 }
 
 func rootHandler(w http.ResponseWriter, r *http.Request) {
@@ -91,7 +176,5 @@ func rootHandler(w http.ResponseWriter, r *http.Request) {
 	}
 
 	// For all other paths, return 404
-	w.Header().Set("Content-Type", "application/json")
-	w.WriteHeader(http.StatusNotFound)
-	w.Write([]byte(`{"error": "Not Found", "message": "The requested resource was not found"}`))
+	utils.WriteErrorResponse(w, http.StatusNotFound, "Not Found", "The requested resource was not found")
 }
@@ -0,0 +1,285 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+
+	"GO2GETHER_BACK-END/internal/apperr"
+	"GO2GETHER_BACK-END/internal/models"
+	"GO2GETHER_BACK-END/internal/policy"
+	"GO2GETHER_BACK-END/internal/validate"
+)
+
+// GeneratedPeriods is the outcome of GeneratePeriods
+type GeneratedPeriods struct {
+	Trip         models.Trip
+	Periods      []models.AvailablePeriod
+	TotalMembers int
+	// AllMembersDays counts the days on which every accepted member is free
+	AllMembersDays int
+	MinDays        int
+	MinMembers     int
+}
+
+// DateOnlyUTC drops the time of day, keeping the calendar date in UTC
+func DateOnlyUTC(t time.Time) time.Time {
+	return time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, time.UTC)
+}
+
+// DaysInclusive counts the days from a to b, both included
+func DaysInclusive(a, b time.Time) int {
+	return int(b.Sub(a).Hours()/24) + 1
+}
+
+// tripRange loads a trip whose dates are consistent after checking that
+// userID may perform a on it
+func (s *TripService) tripRange(ctx context.Context, tripID, userID uuid.UUID, a policy.Action, deny string) (models.Trip, error) {
+	t, _, err := s.authorize(ctx, tripID, userID, a, deny)
+	if err != nil {
+		return t, err
+	}
+	// basic validation (กันข้อมูลเพี้ยน)
+	if t.EndDate.Before(t.StartDate) {
+		return t, invalidField("end_date", "out_of_range", "trip end_date cannot be before start_date")
+	}
+	return t, nil
+}
+
+// Dates returns the trip for its exact date range; only participants may view it
+func (s *TripService) Dates(ctx context.Context, tripID, userID uuid.UUID) (models.Trip, error) {
+	return s.tripRange(ctx, tripID, userID, policy.ActionView, "Only trip members can view date range")
+}
+
+// SaveAvailability replaces userID's free days with dates (YYYY-MM-DD, inside
+// the trip, duplicates ignored) and notifies the creator. It returns the
+// number of days of the trip and of days saved.
+func (s *TripService) SaveAvailability(ctx context.Context, tripID, userID uuid.UUID, dates []string) (int, int, error) {
+	t, err := s.tripRange(ctx, tripID, userID, policy.ActionSubmitAvailability, "Only trip members can submit availability")
+	if err != nil {
+		return 0, 0, err
+	}
+	if len(dates) == 0 {
+		return 0, 0, invalidField("dates", "required", "dates is required and must not be empty")
+	}
+
+	start := DateOnlyUTC(t.StartDate)
+	end := DateOnlyUTC(t.EndDate)
+	total := DaysInclusive(start, end)
+
+	uniq := make(map[time.Time]struct{}, len(dates))
+	valid := make([]time.Time, 0, len(dates))
+	for _, raw := range dates {
+		raw = strings.TrimSpace(raw)
+		if raw == "" {
+			continue
+		}
+		// รองรับรูปแบบ YYYY-MM-DD เท่านั้น เพื่อความชัดเจน
+		d, err := validate.ParseDate(raw)
+		if err != nil {
+			return 0, 0, invalidField("dates", "invalid", "dates must be in YYYY-MM-DD format")
+		}
+		if d.Before(start) || d.After(end) {
+			return 0, 0, invalidField("dates", "out_of_range", "date out of trip range: "+raw)
+		}
+		if _, seen := uniq[d]; seen {
+			continue
+		}
+		uniq[d] = struct{}{}
+		valid = append(valid, d)
+	}
+	if len(valid) == 0 {
+		return 0, 0, invalid("no valid dates to save")
+	}
+
+	// แจ้ง creator ว่าสมาชิกส่งวันว่างแล้ว (event ถูก commit พร้อมวันว่าง)
+	name := s.displayName(ctx, userID)
+	err = s.tx.InTx(ctx, func(ctx context.Context) error {
+		if err := s.availability.Replace(ctx, t.ID, userID, valid); err != nil {
+			return err
+		}
+		return s.publish(ctx, t, notification{
+			to:      t.CreatorID,
+			typ:     models.NotificationAvailability,
+			title:   "Created Availability",
+			message: fmt.Sprintf("%s create availability for %s (%d days)", name, t.Name, len(valid)),
+			data: map[string]any{
+				"trip_id":           t.ID.String(),
+				"user_id":           userID.String(),
+				"submitted_days":    len(valid),
+				"tripName":          t.Name,
+				"user_display_name": name,
+			},
+		})
+	})
+	if err != nil {
+		return 0, 0, err
+	}
+	return total, len(valid), nil
+}
+
+// MyAvailability returns userID's free days and the number of days of the trip
+func (s *TripService) MyAvailability(ctx context.Context, tripID, userID uuid.UUID) ([]time.Time, int, error) {
+	// ดูของตัวเองได้ทั้ง pending/accepted
+	t, err := s.tripRange(ctx, tripID, userID, policy.ActionView, "Only trip members can view availability")
+	if err != nil {
+		return nil, 0, err
+	}
+	dates, err := s.availability.Dates(ctx, t.ID, userID)
+	if err != nil {
+		return nil, 0, err
+	}
+	for i := range dates {
+		dates[i] = DateOnlyUTC(dates[i])
+	}
+	return dates, DaysInclusive(DateOnlyUTC(t.StartDate), DateOnlyUTC(t.EndDate)), nil
+}
+
+// GeneratePeriods recomputes and stores the trip's available periods: runs of
+// at least minDays consecutive days with at least minMembers accepted members
+// free (both default to 1). Only organizers may; accepted members are notified.
+func (s *TripService) GeneratePeriods(ctx context.Context, tripID, requesterID uuid.UUID, minDays, minMembers int) (GeneratedPeriods, error) {
+	if minDays <= 0 {
+		minDays = 1
+	}
+	if minMembers <= 0 {
+		minMembers = 1
+	}
+
+	t, _, err := s.authorize(ctx, tripID, requesterID, policy.ActionFinalizeDates, "Only trip organizers can generate available periods")
+	if err != nil {
+		return GeneratedPeriods{}, err
+	}
+	if t.EndDate.Before(t.StartDate) {
+		return GeneratedPeriods{}, apperr.BadRequest("trip date range is invalid")
+	}
+	// เอาเฉพาะสถานะ accepted เป็นสมาชิกจริง
+	memberIDs, err := s.members.AcceptedUserIDs(ctx, t.ID)
+	if err != nil {
+		return GeneratedPeriods{}, err
+	}
+	if len(memberIDs) == 0 {
+		return GeneratedPeriods{}, apperr.BadRequest("no accepted members in this trip")
+	}
+
+	daily, err := s.availability.DailyFreeCounts(ctx, t.ID, t.StartDate, t.EndDate)
+	if err != nil {
+		return GeneratedPeriods{}, err
+	}
+	periods := BuildPeriods(daily, len(memberIDs), minDays, minMembers, s.now())
+
+	allMembersDays := 0
+	for _, d := range daily {
+		if d.FreeCount == len(memberIDs) {
+			allMembersDays++
+		}
+	}
+
+	err = s.tx.InTx(ctx, func(ctx context.Context) error {
+		if err := s.periods.Replace(ctx, t.ID, periods); err != nil {
+			return err
+		}
+		// แจ้งสมาชิกที่ accepted ทุกคน (ทางอีเมลด้วย) ว่ามีช่วงเวลาที่แนะนำถูกสร้างใหม่
+		msg := fmt.Sprintf("%d new suggested periods generated for %s", len(periods), t.Name)
+		for _, uid := range memberIDs {
+			err := s.publish(ctx, t, notification{
+				to:      uid,
+				typ:     models.NotificationTripUpdate,
+				title:   "Updated Avvailability Periods",
+				message: msg,
+				data: map[string]any{
+					"trip_id":          t.ID.String(),
+					"total_periods":    len(periods),
+					"min_days":         minDays,
+					"min_availability": minMembers,
+					"tripName":         t.Name,
+					"event":            "periods_generated",
+				},
+				email: true,
+			})
+			if err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return GeneratedPeriods{}, err
+	}
+
+	return GeneratedPeriods{
+		Trip:           t,
+		Periods:        periods,
+		TotalMembers:   len(memberIDs),
+		AllMembersDays: allMembersDays,
+		MinDays:        minDays,
+		MinMembers:     minMembers,
+	}, nil
+}
+
+// Periods returns the stored available periods of a trip to its members
+func (s *TripService) Periods(ctx context.Context, tripID, userID uuid.UUID) ([]models.AvailablePeriod, error) {
+	if _, _, err := s.authorize(ctx, tripID, userID, policy.ActionView, "Only trip members can view available periods"); err != nil {
+		return nil, err
+	}
+	return s.periods.List(ctx, tripID)
+}
+
+// BuildPeriods groups the days with at least minMembers free into runs of
+// consecutive days (gaps-and-islands), keeps runs of at least minDays, and
+// ranks them by fewest members free (desc), then duration (desc), then start
+// date. Periods are numbered from 1 in that order.
+func BuildPeriods(daily []models.DayCount, totalMembers, minDays, minMembers int, createdAt time.Time) []models.AvailablePeriod {
+	periods := make([]models.AvailablePeriod, 0)
+	var cur *models.AvailablePeriod
+	closeRun := func() {
+		if cur == nil {
+			return
+		}
+		cur.DurationDays = DaysInclusive(cur.StartDate, cur.EndDate)
+		if cur.DurationDays >= minDays {
+			cur.AvailabilityPercentage = float64(cur.FreeCount) / float64(totalMembers) * 100.0
+			periods = append(periods, *cur)
+		}
+		cur = nil
+	}
+
+	for _, d := range daily {
+		if d.FreeCount < minMembers {
+			closeRun()
+			continue
+		}
+		if cur != nil && d.Date.Equal(cur.EndDate.AddDate(0, 0, 1)) {
+			cur.EndDate = d.Date
+			cur.FreeCount = min(cur.FreeCount, d.FreeCount)
+			continue
+		}
+		closeRun()
+		cur = &models.AvailablePeriod{
+			StartDate:    d.Date,
+			EndDate:      d.Date,
+			FreeCount:    d.FreeCount,
+			TotalMembers: totalMembers,
+			CreatedAt:    createdAt,
+		}
+	}
+	closeRun()
+
+	sort.SliceStable(periods, func(i, j int) bool {
+		if periods[i].FreeCount != periods[j].FreeCount {
+			return periods[i].FreeCount > periods[j].FreeCount
+		}
+		if periods[i].DurationDays != periods[j].DurationDays {
+			return periods[i].DurationDays > periods[j].DurationDays
+		}
+		return periods[i].StartDate.Before(periods[j].StartDate)
+	})
+	for i := range periods {
+		periods[i].PeriodNumber = i + 1
+	}
+	return periods
+}
@@ -0,0 +1,44 @@
+package service
+
+import (
+	"net/http"
+
+	"GO2GETHER_BACK-END/internal/apperr"
+)
+
+// Errors of the service are *apperr.Error values with a message that can be
+// shown to the user; handlers write them with utils.WriteError. Any other
+// error returned by the service is a storage failure.
+
+func invalid(msg string) error {
+	return apperr.New(http.StatusBadRequest, apperr.CodeValidation, "Validation error", msg)
+}
+
+// invalidField reports one invalid request field
+func invalidField(field, code, msg string) error {
+	return apperr.Validation(apperr.Field(field, code, msg))
+}
+
+func forbidden(msg string) error { return apperr.Forbidden(msg) }
+func notFound(msg string) error  { return apperr.NotFound(msg) }
+func conflict(msg string) error  { return apperr.Conflict(msg) }
+
+var (
+	// ErrMFARequired is returned to a co-organizer without two-factor auth
+	// when the trip owner requires it
+	ErrMFARequired = apperr.New(http.StatusForbidden, apperr.CodeMFARequired, "MFA required",
+		"The trip owner requires co-organizers to use two-factor authentication. Enable it in your account settings")
+	// ErrEmailNotVerified is returned when REQUIRE_VERIFIED_EMAIL is on and the
+	// user has not confirmed their address
+	ErrEmailNotVerified = apperr.New(http.StatusForbidden, apperr.CodeEmailNotVerified, "Email not verified",
+		"Please verify your email address before continuing")
+	// ErrInvalidInvitation is returned for a malformed or expired invitation token
+	ErrInvalidInvitation = apperr.New(http.StatusUnauthorized, apperr.CodeInvalidToken, "Invalid invitation token",
+		"The invitation link is invalid or has expired")
+	// ErrEmailUnavailable is returned when invitations should be emailed but
+	// email delivery is not configured
+	ErrEmailUnavailable = apperr.New(http.StatusServiceUnavailable, apperr.CodeUnavailable, "Email unavailable",
+		"Email delivery is not configured; share the invitation link instead")
+
+	errTripNotFound = notFound("Trip not found")
+)
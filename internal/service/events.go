@@ -0,0 +1,63 @@
+package service
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"strings"
+
+	"github.com/google/uuid"
+
+	"GO2GETHER_BACK-END/internal/logging"
+	"GO2GETHER_BACK-END/internal/models"
+)
+
+// notification is an event telling one user about a change of a trip
+type notification struct {
+	to      uuid.UUID
+	typ     models.NotificationType
+	title   string
+	message string
+	data    map[string]any
+	// email also sends it by email, for changes the user must not miss
+	email bool
+}
+
+// publish adds n to the outbox. Call it inside s.tx.InTx with the change it
+// reports, so the event is committed exactly when the change is; the
+// dispatcher (internal/outbox) delivers it afterwards.
+func (s *TripService) publish(ctx context.Context, t models.Trip, n notification) error {
+	channels := []string{models.ChannelInApp}
+	if n.email {
+		channels = append(channels, models.ChannelEmail)
+	}
+	tripID := t.ID
+	msg := n.message
+	return s.outbox.Add(ctx, models.OutboxEvent{
+		Type:        n.typ,
+		RecipientID: n.to,
+		TripID:      &tripID,
+		Payload: models.EventPayload{
+			Title:     n.title,
+			Message:   &msg,
+			Data:      n.data,
+			ActionURL: s.tripURL(t.ID),
+		},
+		Channels:       channels,
+		IdempotencyKey: idempotencyKey(ctx, n.typ, t.ID, n.to),
+	})
+}
+
+// idempotencyKey identifies an event by its type, trip, recipient and the
+// request that caused it, so the same change is not reported twice to the
+// same user. The request part is the server's nonce, never the client's
+// X-Request-ID: two requests reusing an ID would otherwise have the second
+// event dropped as a duplicate. Outside a request every event is new.
+func idempotencyKey(ctx context.Context, typ models.NotificationType, tripID, to uuid.UUID) string {
+	nonce := logging.RequestNonce(ctx)
+	if nonce == "" {
+		nonce = uuid.NewString()
+	}
+	sum := sha256.Sum256([]byte(strings.Join([]string{tripID.String(), to.String(), nonce}, ":")))
+	return string(typ) + ":" + hex.EncodeToString(sum[:])
+}
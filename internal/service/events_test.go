@@ -0,0 +1,36 @@
+package service
+
+import (
+	"context"
+	"testing"
+
+	"github.com/google/uuid"
+
+	"GO2GETHER_BACK-END/internal/logging"
+	"GO2GETHER_BACK-END/internal/models"
+)
+
+func TestIdempotencyKeyIgnoresClientRequestID(t *testing.T) {
+	trip, to := uuid.New(), uuid.New()
+	typ := models.NotificationMemberJoined
+
+	// สอง request ที่ส่ง X-Request-ID เดียวกันมา
+	first := logging.WithRequestID(context.Background(), "client-chosen-id")
+	second := logging.WithRequestID(context.Background(), "client-chosen-id")
+	if idempotencyKey(first, typ, trip, to) == idempotencyKey(second, typ, trip, to) {
+		t.Fatal("requests sharing an X-Request-ID got the same idempotency key")
+	}
+
+	// ภายใน request เดียวกัน การเปลี่ยนแปลงเดียวกันได้ key เดิม
+	if idempotencyKey(first, typ, trip, to) != idempotencyKey(first, typ, trip, to) {
+		t.Fatal("the same change of one request got different idempotency keys")
+	}
+	if idempotencyKey(first, typ, trip, to) == idempotencyKey(first, typ, trip, uuid.New()) {
+		t.Fatal("different recipients got the same idempotency key")
+	}
+
+	// นอก request ทุก event เป็นของใหม่
+	if idempotencyKey(context.Background(), typ, trip, to) == idempotencyKey(context.Background(), typ, trip, to) {
+		t.Fatal("events outside a request got the same idempotency key")
+	}
+}
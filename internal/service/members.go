@@ -0,0 +1,362 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+
+	"GO2GETHER_BACK-END/internal/apperr"
+	"GO2GETHER_BACK-END/internal/metrics"
+	"GO2GETHER_BACK-END/internal/middleware"
+	"GO2GETHER_BACK-END/internal/models"
+	"GO2GETHER_BACK-END/internal/policy"
+	"GO2GETHER_BACK-END/internal/repository"
+)
+
+// invitationTTL is how long an invitation link stays valid
+const invitationTTL = 30 * 24 * time.Hour
+
+// JoinResult is the membership created or reopened by Join
+type JoinResult struct {
+	Trip     models.Trip
+	Role     string
+	JoinedAt time.Time
+}
+
+// InviteLink creates a shareable invitation link for the trip and returns it
+// with its expiry. Only organizers with a verified email may invite. The link
+// is also emailed to emails, if any, in the default language.
+func (s *TripService) InviteLink(ctx context.Context, tripID, requesterID uuid.UUID, emails []string) (string, time.Time, error) {
+	t, _, err := s.authorize(ctx, tripID, requesterID, policy.ActionInvite, "Only creator can generate invitation link")
+	if err != nil {
+		return "", time.Time{}, err
+	}
+	if err := s.requireVerifiedEmail(ctx, requesterID); err != nil {
+		return "", time.Time{}, err
+	}
+	if len(emails) > 0 && !s.mail.IsConfigured() {
+		return "", time.Time{}, ErrEmailUnavailable
+	}
+
+	token, err := middleware.GenerateInvitationToken(tripID, &s.cfg.JWT)
+	if err != nil {
+		return "", time.Time{}, apperr.Internal(err).WithTitle("Failed to generate invitation token")
+	}
+	// Create invitation link (frontend URL + token)
+	link := fmt.Sprintf("%s/trips/%s/join?token=%s", s.cfg.Frontend.URL, tripID.String(), token)
+	expires := s.now().Add(invitationTTL)
+
+	// ส่งลิงก์ทางอีเมล (เข้าคิว ไม่รอ SMTP)
+	inviter := s.displayName(ctx, requesterID)
+	for _, to := range emails {
+		if err := s.mail.SendInvitation(ctx, strings.TrimSpace(to), "", inviter, t.Name, link, expires); err != nil {
+			return "", time.Time{}, apperr.Internal(err).WithTitle("Failed to send invitation email")
+		}
+	}
+	return link, expires, nil
+}
+
+// Join adds userID to the trip of the invitation token as an accepted member,
+// or accepts their existing pending/declined membership
+func (s *TripService) Join(ctx context.Context, userID uuid.UUID, invitationToken string) (JoinResult, error) {
+	if invitationToken == "" {
+		return JoinResult{}, invalidField("invitation_token", "required", "invitation_token is required")
+	}
+	claims, err := middleware.ValidateInvitationToken(invitationToken, &s.cfg.JWT)
+	if err != nil {
+		return JoinResult{}, ErrInvalidInvitation
+	}
+
+	t, err := s.getTrip(ctx, claims.TripID)
+	if err != nil {
+		return JoinResult{}, err
+	}
+
+	now := s.now()
+	role := string(policy.RoleMember)
+	cur, err := s.members.Get(ctx, t.ID, userID)
+	switch {
+	case err == nil:
+		if strings.EqualFold(cur.Status, "accepted") {
+			return JoinResult{}, conflict("You are already a member of this trip")
+		}
+		role = cur.Role
+	case !errors.Is(err, repository.ErrNotFound):
+		return JoinResult{}, err
+	}
+
+	creatorID := t.CreatorID
+	name := s.displayName(ctx, userID)
+	err = s.tx.InTx(ctx, func(ctx context.Context) error {
+		if err := s.members.Accept(ctx, models.TripMember{
+			TripID:    t.ID,
+			UserID:    userID,
+			Role:      role,
+			InvitedBy: &creatorID,
+			JoinedAt:  &now,
+		}); err != nil {
+			return err
+		}
+		// แจ้ง creator ว่ามีสมาชิก join (ทางอีเมลด้วย ปิดได้ใน preferences)
+		return s.publish(ctx, t, notification{
+			to:      t.CreatorID,
+			typ:     models.NotificationMemberJoined,
+			title:   "Member Joined Trip",
+			message: fmt.Sprintf("%s has joined %s", name, t.Name),
+			data: map[string]any{
+				"trip_id":           t.ID.String(),
+				"user_id":           userID.String(),
+				"role":              role,
+				"tripName":          t.Name,
+				"user_display_name": name,
+			},
+			email: true,
+		})
+	})
+	if err != nil {
+		return JoinResult{}, err
+	}
+	metrics.InvitationAccepted()
+
+	return JoinResult{Trip: t, Role: role, JoinedAt: now}, nil
+}
+
+// Invitations lists the pending, accepted and declined members of a trip;
+// only organizers may view them. InvitedBy falls back to the trip creator.
+func (s *TripService) Invitations(ctx context.Context, tripID, requesterID uuid.UUID) ([]models.TripMember, models.MemberStats, error) {
+	t, _, err := s.authorize(ctx, tripID, requesterID, policy.ActionInvite, "Only creator can view invitations")
+	if err != nil {
+		return nil, models.MemberStats{}, err
+	}
+	invites, err := s.members.ListInvitations(ctx, t.ID)
+	if err != nil {
+		return nil, models.MemberStats{}, err
+	}
+	for i := range invites {
+		if invites[i].InvitedBy == nil {
+			creatorID := t.CreatorID
+			invites[i].InvitedBy = &creatorID
+		}
+	}
+	stats, err := s.members.Stats(ctx, t.ID)
+	return invites, stats, err
+}
+
+// Leave removes userID's accepted membership; the owner cannot leave before
+// transferring ownership
+func (s *TripService) Leave(ctx context.Context, tripID, userID uuid.UUID) error {
+	t, err := s.getTrip(ctx, tripID)
+	if err != nil {
+		return err
+	}
+	if userID == t.CreatorID {
+		return forbidden("Creator cannot leave their own trip. Transfer ownership first")
+	}
+
+	m, err := s.members.Get(ctx, t.ID, userID)
+	if errors.Is(err, repository.ErrNotFound) {
+		return notFound("You are not invited to this trip")
+	}
+	if err != nil {
+		return err
+	}
+	if !strings.EqualFold(m.Status, "accepted") {
+		return conflict("You are not an active member of this trip")
+	}
+	name := s.displayName(ctx, userID)
+	return s.tx.InTx(ctx, func(ctx context.Context) error {
+		removed, err := s.members.Remove(ctx, t.ID, userID, "accepted")
+		if err != nil {
+			return err
+		}
+		if !removed {
+			return conflict("You are not an active member of this trip")
+		}
+		// แจ้ง creator ว่าสมาชิกออกจากทริป (ทางอีเมลด้วย)
+		return s.publish(ctx, t, notification{
+			to:      t.CreatorID,
+			typ:     models.NotificationMemberLeft,
+			title:   "Member Left Trip",
+			message: fmt.Sprintf("%s has left %s", name, t.Name),
+			data: map[string]any{
+				"trip_id":           t.ID.String(),
+				"user_id":           userID.String(),
+				"tripName":          t.Name,
+				"user_display_name": name,
+			},
+			email: true,
+		})
+	})
+}
+
+// RemoveMember removes targetID from the trip. Organizers may remove members
+// ranked below them; nobody can remove the owner.
+func (s *TripService) RemoveMember(ctx context.Context, tripID, requesterID, targetID uuid.UUID) error {
+	t, sub, err := s.authorize(ctx, tripID, requesterID, policy.ActionRemoveMember, "Only creator can remove a member")
+	if err != nil {
+		return err
+	}
+	if targetID == t.CreatorID {
+		return forbidden("Cannot remove the trip creator")
+	}
+
+	m, err := s.members.Get(ctx, t.ID, targetID)
+	if errors.Is(err, repository.ErrNotFound) {
+		return notFound("Member not found in this trip")
+	}
+	if err != nil {
+		return err
+	}
+	if strings.EqualFold(m.Status, "removed") {
+		return conflict("Member already removed")
+	}
+	target, err := s.subject(ctx, t, targetID)
+	if err != nil {
+		return err
+	}
+	if !sub.CanRemove(target.Role) {
+		return forbidden("Only the trip owner can remove a co-organizer")
+	}
+	return s.tx.InTx(ctx, func(ctx context.Context) error {
+		removed, err := s.members.Remove(ctx, t.ID, targetID, "")
+		if err != nil {
+			return err
+		}
+		if !removed {
+			return notFound("Member not found in this trip")
+		}
+		// แจ้งผู้ถูกลบว่าโดนถอดออกจากทริป (ส่งอีเมลด้วย เพราะเข้าแอปดูทริปไม่ได้แล้ว)
+		return s.publish(ctx, t, notification{
+			to:      targetID,
+			typ:     models.NotificationTripUpdate,
+			title:   "You Were Removed from Trip",
+			message: fmt.Sprintf("You were removed from %s", t.Name),
+			data: map[string]any{
+				"trip_id":  t.ID.String(),
+				"tripName": t.Name,
+				"event":    "removed",
+			},
+			email: true,
+		})
+	})
+}
+
+// acceptedMember loads userID's membership and requires it to be accepted
+func (s *TripService) acceptedMember(ctx context.Context, tripID, userID uuid.UUID) (models.TripMember, error) {
+	m, err := s.members.Get(ctx, tripID, userID)
+	if errors.Is(err, repository.ErrNotFound) {
+		return m, notFound("Member not found in this trip")
+	}
+	if err != nil {
+		return m, err
+	}
+	if !strings.EqualFold(m.Status, "accepted") {
+		return m, conflict("The member has not joined the trip yet")
+	}
+	return m, nil
+}
+
+// SetMemberRole promotes or demotes targetID to role (co_organizer, member or
+// viewer). Only the owner may; ownership moves with TransferOwnership.
+func (s *TripService) SetMemberRole(ctx context.Context, tripID, requesterID, targetID uuid.UUID, role string) (models.TripMember, error) {
+	next, ok := policy.ParseRole(role)
+	if !ok || next == policy.RoleOwner {
+		return models.TripMember{}, invalidField("role", "invalid", "role must be co_organizer, member, or viewer")
+	}
+	t, sub, err := s.authorize(ctx, tripID, requesterID, policy.ActionManageRoles, "Only the trip owner can change member roles")
+	if err != nil {
+		return models.TripMember{}, err
+	}
+	if targetID == t.CreatorID {
+		return models.TripMember{}, forbidden("The owner's role changes only by transferring ownership")
+	}
+	m, err := s.acceptedMember(ctx, t.ID, targetID)
+	if err != nil {
+		return m, err
+	}
+	target, err := s.subject(ctx, t, targetID)
+	if err != nil {
+		return m, err
+	}
+	if !sub.CanAssign(target.Role, next) {
+		return m, forbidden("Only the trip owner can change member roles")
+	}
+	if target.Role == next {
+		return m, nil
+	}
+	err = s.tx.InTx(ctx, func(ctx context.Context) error {
+		if err := s.members.SetRole(ctx, t.ID, targetID, string(next)); err != nil {
+			return err
+		}
+		return s.publish(ctx, t, notification{
+			to:      targetID,
+			typ:     models.NotificationTripUpdate,
+			title:   "Your Role Changed",
+			message: fmt.Sprintf("Your role in %s is now %s", t.Name, next),
+			data: map[string]any{
+				"trip_id":  t.ID.String(),
+				"tripName": t.Name,
+				"role":     string(next),
+				"event":    "role_changed",
+			},
+		})
+	})
+	if errors.Is(err, repository.ErrNotFound) {
+		return m, notFound("Member not found in this trip")
+	}
+	if err != nil {
+		return m, err
+	}
+	m.Role = string(next)
+	return m, nil
+}
+
+// TransferOwnership makes newOwnerID, an accepted member, the owner of the
+// trip; the previous owner stays as a co-organizer
+func (s *TripService) TransferOwnership(ctx context.Context, tripID, requesterID, newOwnerID uuid.UUID) (models.Trip, error) {
+	t, _, err := s.authorize(ctx, tripID, requesterID, policy.ActionTransferOwnership, "Only the trip owner can transfer ownership")
+	if err != nil {
+		return t, err
+	}
+	if newOwnerID == t.CreatorID {
+		return t, conflict("This member already owns the trip")
+	}
+	if _, err := s.acceptedMember(ctx, t.ID, newOwnerID); err != nil {
+		return t, err
+	}
+	previous := t.CreatorID
+	name := s.displayName(ctx, previous)
+	err = s.tx.InTx(ctx, func(ctx context.Context) error {
+		if err := s.trips.TransferOwnership(ctx, t.ID, previous, newOwnerID); err != nil {
+			return err
+		}
+		return s.publish(ctx, t, notification{
+			to:      newOwnerID,
+			typ:     models.NotificationTripUpdate,
+			title:   "You Are Now the Trip Owner",
+			message: fmt.Sprintf("%s made you the owner of %s", name, t.Name),
+			data: map[string]any{
+				"trip_id":           t.ID.String(),
+				"tripName":          t.Name,
+				"previous_owner_id": previous.String(),
+				"user_display_name": name,
+				"event":             "ownership_transferred",
+			},
+			email: true,
+		})
+	})
+	if errors.Is(err, repository.ErrNotFound) {
+		// มีคนเปลี่ยนเจ้าของไปก่อนแล้ว
+		return t, conflict("Trip ownership changed, reload and try again")
+	}
+	if err != nil {
+		return t, err
+	}
+	t.CreatorID = newOwnerID
+	t.UpdatedAt = s.now()
+	return t, nil
+}
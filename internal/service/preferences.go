@@ -0,0 +1,149 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+
+	"GO2GETHER_BACK-END/internal/dto"
+	"GO2GETHER_BACK-END/internal/models"
+	"GO2GETHER_BACK-END/internal/repository"
+	"GO2GETHER_BACK-END/internal/validate"
+)
+
+// PreferenceService manages notification preferences and routes
+// notifications by them
+type PreferenceService struct {
+	prefs   repository.PreferenceRepository
+	members repository.MemberRepository
+	tx      repository.Transactor
+	now     func() time.Time
+}
+
+// NewPreferenceService creates a PreferenceService
+func NewPreferenceService(repos repository.Repositories) *PreferenceService {
+	return &PreferenceService{
+		prefs:   repos.Preferences,
+		members: repos.Members,
+		tx:      repos.Tx,
+		now:     time.Now,
+	}
+}
+
+// Get returns the user's preferences and their per-trip settings
+func (s *PreferenceService) Get(ctx context.Context, userID uuid.UUID) (models.NotificationPreferences, []models.TripNotificationPreferences, error) {
+	p, err := s.prefs.Get(ctx, userID)
+	if err != nil {
+		return p, nil, err
+	}
+	trips, err := s.prefs.Trips(ctx, userID)
+	return p, trips, err
+}
+
+// Update replaces the user's preferences with req. Trip settings may only be
+// made for trips the user is a member of (or invited to); trips not listed
+// go back to the user's settings.
+func (s *PreferenceService) Update(ctx context.Context, userID uuid.UUID, req dto.NotificationPreferencesRequest) (models.NotificationPreferences, []models.TripNotificationPreferences, error) {
+	p := models.DefaultNotificationPreferences(userID)
+	p.InApp = orTrue(req.Channels.InApp)
+	p.Email = orTrue(req.Channels.Email)
+	p.Push = orTrue(req.Channels.Push)
+	for _, t := range req.MutedTypes {
+		typ := models.NotificationType(strings.ToLower(strings.TrimSpace(t)))
+		if !slices.Contains(p.MutedTypes, typ) {
+			p.MutedTypes = append(p.MutedTypes, typ)
+		}
+	}
+	if q := req.QuietHours; q != nil {
+		p.QuietHours = &models.QuietHours{Start: minuteOfDay(q.Start), End: minuteOfDay(q.End)}
+	}
+	if tz := strings.TrimSpace(req.Timezone); tz != "" {
+		p.Timezone = tz
+	}
+	p.Locale = strings.ToLower(strings.TrimSpace(req.Locale))
+	if f := strings.ToLower(strings.TrimSpace(req.DigestFrequency)); f != "" {
+		p.DigestFrequency = f
+	}
+
+	trips := make([]models.TripNotificationPreferences, 0, len(req.Trips))
+	for i, t := range req.Trips {
+		tripID := uuid.MustParse(strings.TrimSpace(t.TripID)) // checked by the DTO
+		if _, err := s.members.Get(ctx, tripID, userID); errors.Is(err, repository.ErrNotFound) {
+			return p, nil, invalidField(fmt.Sprintf("trips[%d].trip_id", i), validate.CodeInvalid,
+				"You are not a member of trip "+tripID.String())
+		} else if err != nil {
+			return p, nil, err
+		}
+		trips = append(trips, models.TripNotificationPreferences{
+			UserID: userID,
+			TripID: tripID,
+			Muted:  t.Muted,
+			InApp:  t.Channels.InApp,
+			Email:  t.Channels.Email,
+			Push:   t.Channels.Push,
+		})
+	}
+
+	err := s.tx.InTx(ctx, func(ctx context.Context) error {
+		if err := s.prefs.Save(ctx, p); err != nil {
+			return err
+		}
+		old, err := s.prefs.Trips(ctx, userID)
+		if err != nil {
+			return err
+		}
+		for _, o := range old {
+			if err := s.prefs.DeleteTrip(ctx, userID, o.TripID); err != nil {
+				return err
+			}
+		}
+		for _, t := range trips {
+			if t.IsDefault() {
+				continue
+			}
+			if err := s.prefs.SaveTrip(ctx, t); errors.Is(err, repository.ErrNotFound) {
+				return errTripNotFound
+			} else if err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return p, nil, err
+	}
+	return s.Get(ctx, userID)
+}
+
+// Route decides where a notification of typ to userID goes, about tripID
+// when not nil
+func (s *PreferenceService) Route(ctx context.Context, userID uuid.UUID, tripID *uuid.UUID, typ models.NotificationType) (models.NotificationRoute, error) {
+	p, err := s.prefs.Get(ctx, userID)
+	if err != nil {
+		return models.NotificationRoute{}, fmt.Errorf("load notification preferences: %w", err)
+	}
+	var trip *models.TripNotificationPreferences
+	if tripID != nil {
+		t, err := s.prefs.Trip(ctx, userID, *tripID)
+		switch {
+		case err == nil:
+			trip = &t
+		case !errors.Is(err, repository.ErrNotFound):
+			return models.NotificationRoute{}, fmt.Errorf("load trip notification preferences: %w", err)
+		}
+	}
+	return p.Route(typ, trip, s.now()), nil
+}
+
+func orTrue(b *bool) bool { return b == nil || *b }
+
+// minuteOfDay turns HH:MM (checked by the DTO) into minutes after midnight
+func minuteOfDay(s string) int {
+	t, _ := time.Parse(dto.QuietHoursLayout, strings.TrimSpace(s))
+	return t.Hour()*60 + t.Minute()
+}
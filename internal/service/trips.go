@@ -0,0 +1,466 @@
+// Package service holds the business rules of trips: budgets, who may manage
+// a trip, membership and availability. Handlers translate HTTP requests into
+// calls on it; it reaches storage only through the repository interfaces, so
+// the rules can be exercised with repository/memory.
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+
+	"GO2GETHER_BACK-END/internal/apperr"
+	"GO2GETHER_BACK-END/internal/config"
+	"GO2GETHER_BACK-END/internal/dto"
+	"GO2GETHER_BACK-END/internal/metrics"
+	"GO2GETHER_BACK-END/internal/models"
+	"GO2GETHER_BACK-END/internal/policy"
+	"GO2GETHER_BACK-END/internal/repository"
+	"GO2GETHER_BACK-END/internal/utils"
+	"GO2GETHER_BACK-END/internal/validate"
+)
+
+// TripService implements the trip use cases
+type TripService struct {
+	trips        repository.TripRepository
+	members      repository.MemberRepository
+	availability repository.AvailabilityRepository
+	periods      repository.PeriodRepository
+	users        repository.UserRepository
+	profiles     repository.ProfileRepository
+	outbox       repository.OutboxRepository
+	tx           repository.Transactor
+	mail         InvitationMailer
+	cfg          *config.Config
+	now          func() time.Time
+}
+
+// InvitationMailer emails invitation links (utils.EmailService)
+type InvitationMailer interface {
+	IsConfigured() bool
+	SendInvitation(ctx context.Context, to, locale, inviter, tripName, link string, expires time.Time) error
+}
+
+// NewTripService creates a TripService. Notifications are written to
+// repos.Outbox together with the change they report; invitations are emailed
+// through the mailer installed with utils.UseMailer.
+func NewTripService(repos repository.Repositories, cfg *config.Config) *TripService {
+	return &TripService{
+		trips:        repos.Trips,
+		members:      repos.Members,
+		availability: repos.Availability,
+		periods:      repos.Periods,
+		users:        repos.Users,
+		profiles:     repos.Profiles,
+		outbox:       repos.Outbox,
+		tx:           repos.Tx,
+		mail:         utils.NewEmailService(&cfg.Email),
+		cfg:          cfg,
+		now:          time.Now,
+	}
+}
+
+// TripDetail is a trip with everything the detail page shows
+type TripDetail struct {
+	Trip        models.Trip
+	Budget      models.TripBudget
+	Members     []models.TripMember
+	Stats       models.MemberStats
+	Permissions policy.Permissions
+}
+
+var tripStatuses = map[string]bool{"draft": true, "published": true, "cancelled": true}
+
+// ---------- access rules ----------
+
+func (s *TripService) getTrip(ctx context.Context, tripID uuid.UUID) (models.Trip, error) {
+	t, err := s.trips.Get(ctx, tripID)
+	if errors.Is(err, repository.ErrNotFound) {
+		return t, errTripNotFound
+	}
+	return t, err
+}
+
+// subject resolves userID's role in t for the policy. trips.creator_id is
+// authoritative for the owner; for a co-organizer it also checks the trip's
+// organizer MFA requirement.
+func (s *TripService) subject(ctx context.Context, t models.Trip, userID uuid.UUID) (policy.Subject, error) {
+	if userID == t.CreatorID {
+		return policy.Subject{Role: policy.RoleOwner}, nil
+	}
+	m, err := s.members.Get(ctx, t.ID, userID)
+	if errors.Is(err, repository.ErrNotFound) {
+		return policy.Subject{Role: policy.RoleNone}, nil
+	}
+	if err != nil {
+		return policy.Subject{}, err
+	}
+	role, ok := policy.ParseRole(m.Role)
+	switch {
+	case !ok:
+		role = policy.RoleMember
+	case role == policy.RoleOwner:
+		// ไม่ใช่ creator_id ของทริป (ข้อมูลเก่า) — ถือเป็นผู้ร่วมจัด
+		role = policy.RoleCoOrganizer
+	}
+	sub := policy.Subject{Role: role}
+	if role == policy.RoleCoOrganizer && t.RequireOrganizerMFA {
+		enabled, err := s.users.MFAEnabled(ctx, userID)
+		if err != nil {
+			return policy.Subject{}, err
+		}
+		sub.MFABlocked = !enabled
+	}
+	return sub, nil
+}
+
+// check returns nil when sub may perform a, otherwise the 403 with deny as
+// message (or ErrMFARequired)
+func check(sub policy.Subject, a policy.Action, deny string) error {
+	switch policy.Authorize(sub, a) {
+	case policy.Deny:
+		return forbidden(deny)
+	case policy.DenyMFA:
+		return ErrMFARequired
+	}
+	return nil
+}
+
+// authorize loads the trip and checks that userID may perform a on it; deny
+// is the message of the 403 otherwise
+func (s *TripService) authorize(ctx context.Context, tripID, userID uuid.UUID, a policy.Action, deny string) (models.Trip, policy.Subject, error) {
+	t, err := s.getTrip(ctx, tripID)
+	if err != nil {
+		return t, policy.Subject{}, err
+	}
+	sub, err := s.subject(ctx, t, userID)
+	if err != nil {
+		return t, sub, err
+	}
+	return t, sub, check(sub, a, deny)
+}
+
+func (s *TripService) requireVerifiedEmail(ctx context.Context, userID uuid.UUID) error {
+	if !s.cfg.Auth.RequireVerifiedEmail {
+		return nil
+	}
+	verified, err := s.users.EmailVerified(ctx, userID)
+	if err != nil {
+		return err
+	}
+	if !verified {
+		return ErrEmailNotVerified
+	}
+	return nil
+}
+
+// ---------- budget rules ----------
+
+// newTripBudget applies the budget rules of a new trip: when any category is
+// set the breakdown defines the total, otherwise total_budget (kept for older
+// clients) goes to food as a whole
+func newTripBudget(total float64, b models.TripBudget) (float64, models.TripBudget, error) {
+	if b.Food < 0 || b.Hotel < 0 || b.Shopping < 0 || b.Transport < 0 {
+		return 0, b, invalid("budget categories cannot be negative")
+	}
+	if !b.IsZero() {
+		return b.Sum(), b, nil
+	}
+	if total < 0 {
+		return 0, b, invalidField("total_budget", "out_of_range", "total_budget cannot be negative")
+	}
+	if total > 0 {
+		b.Food = total
+	}
+	return total, b, nil
+}
+
+// mergeTripBudget applies an update to the current total and breakdown:
+// categories sent replace the current ones and define the total; a
+// total_budget alone replaces the total, and goes to food when the trip has
+// no breakdown yet
+func mergeTripBudget(curTotal float64, cur models.TripBudget, req dto.UpdateTripRequest) (float64, models.TripBudget, error) {
+	next := cur
+	if req.Food != nil {
+		next.Food = *req.Food
+	}
+	if req.Hotel != nil {
+		next.Hotel = *req.Hotel
+	}
+	if req.Shopping != nil {
+		next.Shopping = *req.Shopping
+	}
+	if req.Transport != nil {
+		next.Transport = *req.Transport
+	}
+	if next.Food < 0 || next.Hotel < 0 || next.Shopping < 0 || next.Transport < 0 {
+		return 0, cur, invalid("budget categories cannot be negative")
+	}
+
+	breakdownSent := req.Food != nil || req.Hotel != nil || req.Shopping != nil || req.Transport != nil
+	total := curTotal
+	if req.TotalBudget != nil {
+		if *req.TotalBudget < 0 {
+			return 0, cur, invalidField("total_budget", "out_of_range", "total_budget cannot be negative")
+		}
+		total = *req.TotalBudget
+		if !breakdownSent && cur.IsZero() {
+			next = models.TripBudget{Food: total}
+		}
+	}
+	if breakdownSent {
+		total = next.Sum()
+	}
+	return total, next, nil
+}
+
+// ---------- use cases ----------
+
+// Create validates req and stores a new trip with userID as its creator
+func (s *TripService) Create(ctx context.Context, userID uuid.UUID, req dto.CreateTripRequest) (models.Trip, models.TripBudget, error) {
+	if err := s.requireVerifiedEmail(ctx, userID); err != nil {
+		return models.Trip{}, models.TripBudget{}, err
+	}
+
+	name := strings.TrimSpace(req.Name)
+	destination := strings.TrimSpace(req.Destination)
+	status := strings.ToLower(strings.TrimSpace(req.Status))
+	var missing []apperr.FieldError
+	for _, f := range []struct{ name, value string }{
+		{"name", name}, {"destination", destination}, {"start_date", req.StartDate}, {"end_date", req.EndDate},
+	} {
+		if f.value == "" {
+			missing = append(missing, apperr.Field(f.name, "required", f.name+" is required"))
+		}
+	}
+	if len(missing) > 0 {
+		return models.Trip{}, models.TripBudget{}, apperr.Validation(missing...)
+	}
+	if status == "" {
+		status = "draft"
+	}
+	if !tripStatuses[status] {
+		return models.Trip{}, models.TripBudget{}, invalidField("status", "invalid", "status must be draft, published, or cancelled")
+	}
+
+	startAt, err := validate.ParseDateTime(req.StartDate)
+	if err != nil {
+		return models.Trip{}, models.TripBudget{}, invalidField("start_date", "invalid", "start_date must be YYYY-MM-DD or RFC3339")
+	}
+	endAt, err := validate.ParseDateTime(req.EndDate)
+	if err != nil {
+		return models.Trip{}, models.TripBudget{}, invalidField("end_date", "invalid", "end_date must be YYYY-MM-DD or RFC3339")
+	}
+	if endAt.Before(startAt) {
+		return models.Trip{}, models.TripBudget{}, invalidField("end_date", "out_of_range", "end_date cannot be before start_date")
+	}
+
+	total, budget, err := newTripBudget(req.TotalBudget, models.TripBudget{
+		Food: req.Food, Hotel: req.Hotel, Shopping: req.Shopping, Transport: req.Transport,
+	})
+	if err != nil {
+		return models.Trip{}, models.TripBudget{}, err
+	}
+
+	currency := strings.ToUpper(strings.TrimSpace(req.Currency))
+	if currency == "" {
+		currency = "THB"
+	}
+
+	now := s.now()
+	t := models.Trip{
+		ID:          uuid.New(),
+		Name:        name,
+		Destination: destination,
+		StartDate:   startAt,
+		EndDate:     endAt,
+		Description: req.Description,
+		Status:      status,
+		TotalBudget: total,
+		Currency:    currency,
+		CreatorID:   userID,
+		CreatedAt:   now,
+		UpdatedAt:   now,
+	}
+	if err := s.trips.Create(ctx, t, budget); err != nil {
+		return models.Trip{}, models.TripBudget{}, err
+	}
+	metrics.TripCreated()
+	return t, budget, nil
+}
+
+// List returns the trips userID has joined, filtered by status ("all" or a
+// trip status), and the total before paging
+func (s *TripService) List(ctx context.Context, userID uuid.UUID, status string, limit, offset int) ([]models.TripSummary, int, error) {
+	status = strings.ToLower(strings.TrimSpace(status))
+	if status == "" {
+		status = "all"
+	}
+	if status != "all" && !tripStatuses[status] {
+		return nil, 0, invalidField("status", "invalid", "invalid status")
+	}
+	return s.trips.ListForMember(ctx, userID, status, limit, offset)
+}
+
+// Detail returns a trip with its budget, members, stats and what requesterID may do
+func (s *TripService) Detail(ctx context.Context, tripID, requesterID uuid.UUID) (TripDetail, error) {
+	t, sub, err := s.authorize(ctx, tripID, requesterID, policy.ActionView, "You are not a member of this trip")
+	if err != nil {
+		return TripDetail{}, err
+	}
+	budget, err := s.trips.Budget(ctx, t.ID)
+	if err != nil {
+		return TripDetail{}, err
+	}
+	members, err := s.members.List(ctx, t.ID)
+	if err != nil {
+		return TripDetail{}, err
+	}
+	stats, err := s.members.Stats(ctx, t.ID)
+	if err != nil {
+		return TripDetail{}, err
+	}
+	return TripDetail{
+		Trip:        t,
+		Budget:      budget,
+		Members:     members,
+		Stats:       stats,
+		Permissions: policy.PermissionsOf(sub),
+	}, nil
+}
+
+// Update applies the fields set in req. Trip fields need the edit
+// permission, budget fields the budget permission, and the organizer MFA
+// requirement the permission to manage roles.
+func (s *TripService) Update(ctx context.Context, tripID, requesterID uuid.UUID, req dto.UpdateTripRequest) (models.Trip, models.TripBudget, error) {
+	cur, err := s.getTrip(ctx, tripID)
+	if err != nil {
+		return models.Trip{}, models.TripBudget{}, err
+	}
+	sub, err := s.subject(ctx, cur, requesterID)
+	if err != nil {
+		return models.Trip{}, models.TripBudget{}, err
+	}
+	budgetSent := req.TotalBudget != nil || req.Food != nil || req.Hotel != nil || req.Shopping != nil || req.Transport != nil
+	fieldsSent := req.Name != nil || req.Destination != nil || req.Description != nil || req.Status != nil ||
+		req.StartDate != nil || req.EndDate != nil
+	if fieldsSent || (!budgetSent && req.RequireOrganizerMFA == nil) {
+		if err := check(sub, policy.ActionEdit, "Only creator can update this trip"); err != nil {
+			return models.Trip{}, models.TripBudget{}, err
+		}
+	}
+	if budgetSent {
+		if err := check(sub, policy.ActionManageBudget, "Only trip organizers can manage the budget"); err != nil {
+			return models.Trip{}, models.TripBudget{}, err
+		}
+	}
+	// MFA ของผู้ร่วมจัด: เปลี่ยนได้เฉพาะเจ้าของทริป
+	if req.RequireOrganizerMFA != nil {
+		if err := check(sub, policy.ActionManageRoles, "Only the trip owner can change the MFA requirement"); err != nil {
+			return models.Trip{}, models.TripBudget{}, err
+		}
+	}
+
+	next := cur
+	if req.Name != nil {
+		next.Name = strings.TrimSpace(*req.Name)
+	}
+	if req.Destination != nil {
+		next.Destination = strings.TrimSpace(*req.Destination)
+	}
+	if req.Description != nil {
+		next.Description = *req.Description
+	}
+	if req.Status != nil {
+		st := strings.ToLower(strings.TrimSpace(*req.Status))
+		if !tripStatuses[st] {
+			return models.Trip{}, models.TripBudget{}, invalidField("status", "invalid", "status must be draft, published, or cancelled")
+		}
+		next.Status = st
+	}
+
+	if req.RequireOrganizerMFA != nil {
+		next.RequireOrganizerMFA = *req.RequireOrganizerMFA
+	}
+
+	// วันที่: ใช้ StartDate / EndDate (YYYY-MM-DD)
+	if req.StartDate != nil {
+		t, err := validate.ParseDate(*req.StartDate)
+		if err != nil {
+			return models.Trip{}, models.TripBudget{}, invalidField("start_date", "invalid", "start_date must be YYYY-MM-DD")
+		}
+		next.StartDate = t
+	}
+	if req.EndDate != nil {
+		t, err := validate.ParseDate(*req.EndDate)
+		if err != nil {
+			return models.Trip{}, models.TripBudget{}, invalidField("end_date", "invalid", "end_date must be YYYY-MM-DD")
+		}
+		next.EndDate = t
+	}
+	if next.EndDate.Before(next.StartDate) {
+		return models.Trip{}, models.TripBudget{}, invalidField("end_date", "out_of_range", "end_date cannot be before start_date")
+	}
+
+	curBudget, err := s.trips.Budget(ctx, cur.ID)
+	if err != nil {
+		return models.Trip{}, models.TripBudget{}, err
+	}
+	total, budget, err := mergeTripBudget(cur.TotalBudget, curBudget, req)
+	if err != nil {
+		return models.Trip{}, models.TripBudget{}, err
+	}
+	next.TotalBudget = total
+	next.UpdatedAt = s.now()
+
+	if err := s.trips.Update(ctx, next, budget); err != nil {
+		return models.Trip{}, models.TripBudget{}, err
+	}
+	return next, budget, nil
+}
+
+// Budget returns the trip (for its total) and breakdown; any member may view it
+func (s *TripService) Budget(ctx context.Context, tripID, userID uuid.UUID) (models.Trip, models.TripBudget, error) {
+	t, _, err := s.authorize(ctx, tripID, userID, policy.ActionView, "You are not a member of this trip")
+	if err != nil {
+		return t, models.TripBudget{}, err
+	}
+	b, err := s.trips.Budget(ctx, t.ID)
+	return t, b, err
+}
+
+// Delete removes a trip; only its owner may
+func (s *TripService) Delete(ctx context.Context, tripID, requesterID uuid.UUID) error {
+	if _, _, err := s.authorize(ctx, tripID, requesterID, policy.ActionDelete, "Only creator can delete this trip"); err != nil {
+		return err
+	}
+	return s.trips.Delete(ctx, tripID)
+}
+
+// ---------- notifications ----------
+
+// tripURL ช่วยสร้างลิงก์ไปหน้า trip ใน FE จาก FRONTEND_URL
+func (s *TripService) tripURL(tripID uuid.UUID) *string {
+	u := fmt.Sprintf("%s/trips/%s", s.cfg.Frontend.URL, tripID.String())
+	return &u
+}
+
+// displayName ดึง display_name หรือ username จาก profile
+// ถ้าไม่มี profile หรือไม่มีทั้งคู่ จะใช้ user_id เป็น fallback
+func (s *TripService) displayName(ctx context.Context, userID uuid.UUID) string {
+	p, err := s.profiles.Get(ctx, userID)
+	if err != nil {
+		return userID.String()
+	}
+	if p.DisplayName != nil && strings.TrimSpace(*p.DisplayName) != "" {
+		return *p.DisplayName
+	}
+	if strings.TrimSpace(p.Username) != "" {
+		return p.Username
+	}
+	return userID.String()
+}
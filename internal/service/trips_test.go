@@ -0,0 +1,405 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/url"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+
+	"GO2GETHER_BACK-END/internal/apperr"
+	"GO2GETHER_BACK-END/internal/config"
+	"GO2GETHER_BACK-END/internal/dto"
+	"GO2GETHER_BACK-END/internal/models"
+	"GO2GETHER_BACK-END/internal/policy"
+	"GO2GETHER_BACK-END/internal/repository/memory"
+)
+
+// fakeMailer records invitation emails
+type fakeMailer struct {
+	configured bool
+	sent       []string
+}
+
+func (m *fakeMailer) IsConfigured() bool { return m.configured }
+
+func (m *fakeMailer) SendInvitation(_ context.Context, to, _, _, _, _ string, _ time.Time) error {
+	m.sent = append(m.sent, to)
+	return nil
+}
+
+// tripFixture is a TripService over repository/memory with one trip owned by
+// owner, from 2026-12-01 to 2026-12-05
+type tripFixture struct {
+	store *memory.Store
+	svc   *TripService
+	mail  *fakeMailer
+	owner uuid.UUID
+	trip  models.Trip
+}
+
+func newTripFixture(t *testing.T) *tripFixture {
+	t.Helper()
+	cfg := &config.Config{}
+	cfg.JWT.Secret = "test-secret-at-least-32-bytes-long!!"
+	cfg.Frontend.URL = "http://app.test"
+
+	store := memory.New()
+	svc := NewTripService(store.Repositories(), cfg)
+	mail := &fakeMailer{configured: true}
+	svc.mail = mail
+	svc.now = func() time.Time { return time.Date(2026, 11, 1, 12, 0, 0, 0, time.UTC) }
+
+	owner := store.PutUser(memory.User{Email: "owner@example.com", EmailVerified: true}).ID
+	trip, _, err := svc.Create(context.Background(), owner, dto.CreateTripRequest{
+		Name: "Chiang Mai", Destination: "Chiang Mai", StartDate: "2026-12-01", EndDate: "2026-12-05",
+		Food: 1000, Hotel: 2000,
+	})
+	if err != nil {
+		t.Fatalf("Create() error = %v", err)
+	}
+	return &tripFixture{store: store, svc: svc, mail: mail, owner: owner, trip: trip}
+}
+
+// member adds an accepted member with role
+func (f *tripFixture) member(role policy.Role) uuid.UUID {
+	id := f.store.PutUser(memory.User{Email: uuid.NewString() + "@example.com"}).ID
+	f.store.PutMember(models.TripMember{TripID: f.trip.ID, UserID: id, Role: string(role), Status: "accepted"})
+	return id
+}
+
+// events returns the outbox events of typ
+func (f *tripFixture) events(typ models.NotificationType) []models.OutboxEvent {
+	var out []models.OutboxEvent
+	for _, e := range f.store.OutboxEvents() {
+		if e.Type == typ {
+			out = append(out, e)
+		}
+	}
+	return out
+}
+
+// statusOf is the HTTP status err maps to; 0 for nil, 500 for a non-apperr error
+func statusOf(err error) int {
+	if err == nil {
+		return 0
+	}
+	var e *apperr.Error
+	if errors.As(err, &e) {
+		return e.Status
+	}
+	return http.StatusInternalServerError
+}
+
+func TestCreateTrip(t *testing.T) {
+	f := newTripFixture(t)
+	if f.trip.TotalBudget != 3000 || f.trip.Status != "draft" || f.trip.Currency != "THB" {
+		t.Errorf("trip = %+v", f.trip)
+	}
+	d, err := f.svc.Detail(context.Background(), f.trip.ID, f.owner)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if d.Permissions.Role != policy.RoleOwner || !d.Permissions.CanDelete {
+		t.Errorf("owner permissions = %+v", d.Permissions)
+	}
+
+	tests := []struct {
+		name string
+		req  dto.CreateTripRequest
+	}{
+		{"missing name", dto.CreateTripRequest{Destination: "x", StartDate: "2026-12-01", EndDate: "2026-12-02"}},
+		{"end before start", dto.CreateTripRequest{Name: "x", Destination: "x", StartDate: "2026-12-02", EndDate: "2026-12-01"}},
+		{"bad status", dto.CreateTripRequest{Name: "x", Destination: "x", StartDate: "2026-12-01", EndDate: "2026-12-02", Status: "done"}},
+		{"negative budget", dto.CreateTripRequest{Name: "x", Destination: "x", StartDate: "2026-12-01", EndDate: "2026-12-02", Food: -1}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, _, err := f.svc.Create(context.Background(), f.owner, tt.req)
+			if got := statusOf(err); got != http.StatusBadRequest {
+				t.Errorf("Create() status = %d (%v), want 400", got, err)
+			}
+		})
+	}
+}
+
+func TestCreateTripRequiresVerifiedEmail(t *testing.T) {
+	f := newTripFixture(t)
+	f.svc.cfg.Auth.RequireVerifiedEmail = true
+	unverified := f.store.PutUser(memory.User{Email: "new@example.com"}).ID
+	_, _, err := f.svc.Create(context.Background(), unverified, dto.CreateTripRequest{
+		Name: "x", Destination: "x", StartDate: "2026-12-01", EndDate: "2026-12-02",
+	})
+	if !errors.Is(err, ErrEmailNotVerified) {
+		t.Errorf("Create() error = %v, want ErrEmailNotVerified", err)
+	}
+}
+
+func TestRemoveMember(t *testing.T) {
+	tests := []struct {
+		requester, target policy.Role
+		want              int
+	}{
+		{policy.RoleOwner, policy.RoleCoOrganizer, 0},
+		{policy.RoleOwner, policy.RoleMember, 0},
+		{policy.RoleCoOrganizer, policy.RoleMember, 0},
+		{policy.RoleCoOrganizer, policy.RoleViewer, 0},
+		{policy.RoleCoOrganizer, policy.RoleCoOrganizer, http.StatusForbidden},
+		{policy.RoleMember, policy.RoleViewer, http.StatusForbidden},
+		{policy.RoleViewer, policy.RoleMember, http.StatusForbidden},
+		{policy.RoleNone, policy.RoleMember, http.StatusForbidden},
+	}
+	for _, tt := range tests {
+		t.Run(string(tt.requester)+"_removes_"+string(tt.target), func(t *testing.T) {
+			f := newTripFixture(t)
+			requester := f.owner
+			switch tt.requester {
+			case policy.RoleOwner:
+			case policy.RoleNone:
+				requester = f.store.PutUser(memory.User{Email: "outsider@example.com"}).ID
+			default:
+				requester = f.member(tt.requester)
+			}
+			target := f.member(tt.target)
+
+			err := f.svc.RemoveMember(context.Background(), f.trip.ID, requester, target)
+			if got := statusOf(err); got != tt.want {
+				t.Fatalf("RemoveMember() status = %d (%v), want %d", got, err, tt.want)
+			}
+			if tt.want == 0 {
+				if events := f.events(models.NotificationTripUpdate); len(events) != 1 || events[0].RecipientID != target {
+					t.Errorf("removal events = %+v", events)
+				}
+			}
+		})
+	}
+
+	t.Run("nobody removes the owner", func(t *testing.T) {
+		f := newTripFixture(t)
+		co := f.member(policy.RoleCoOrganizer)
+		if got := statusOf(f.svc.RemoveMember(context.Background(), f.trip.ID, co, f.owner)); got != http.StatusForbidden {
+			t.Errorf("RemoveMember(owner) status = %d, want 403", got)
+		}
+	})
+}
+
+func TestSetMemberRole(t *testing.T) {
+	f := newTripFixture(t)
+	ctx := context.Background()
+	member := f.member(policy.RoleMember)
+	co := f.member(policy.RoleCoOrganizer)
+
+	m, err := f.svc.SetMemberRole(ctx, f.trip.ID, f.owner, member, "co_organizer")
+	if err != nil || m.Role != string(policy.RoleCoOrganizer) {
+		t.Fatalf("SetMemberRole() = %+v, %v", m, err)
+	}
+	if events := f.events(models.NotificationTripUpdate); len(events) != 1 || events[0].RecipientID != member {
+		t.Errorf("role change events = %+v", events)
+	}
+
+	tests := []struct {
+		name      string
+		requester uuid.UUID
+		target    uuid.UUID
+		role      string
+		want      int
+	}{
+		{"to owner", f.owner, member, "owner", http.StatusBadRequest},
+		{"unknown role", f.owner, member, "admin", http.StatusBadRequest},
+		{"owner's role", f.owner, f.owner, "member", http.StatusForbidden},
+		{"by a co-organizer", co, member, "viewer", http.StatusForbidden},
+		{"not a member", f.owner, uuid.New(), "viewer", http.StatusNotFound},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := f.svc.SetMemberRole(ctx, f.trip.ID, tt.requester, tt.target, tt.role)
+			if got := statusOf(err); got != tt.want {
+				t.Errorf("SetMemberRole() status = %d (%v), want %d", got, err, tt.want)
+			}
+		})
+	}
+}
+
+func TestTransferOwnership(t *testing.T) {
+	f := newTripFixture(t)
+	ctx := context.Background()
+	member := f.member(policy.RoleMember)
+
+	if got := statusOf(f.svc.Leave(ctx, f.trip.ID, f.owner)); got != http.StatusForbidden {
+		t.Errorf("owner Leave() status = %d, want 403", got)
+	}
+	if _, err := f.svc.TransferOwnership(ctx, f.trip.ID, member, member); statusOf(err) != http.StatusForbidden {
+		t.Errorf("TransferOwnership() by a member error = %v, want 403", err)
+	}
+
+	trip, err := f.svc.TransferOwnership(ctx, f.trip.ID, f.owner, member)
+	if err != nil || trip.CreatorID != member {
+		t.Fatalf("TransferOwnership() = %+v, %v", trip, err)
+	}
+	events := f.events(models.NotificationTripUpdate)
+	if len(events) != 1 || events[0].RecipientID != member || len(events[0].Channels) != 2 {
+		t.Errorf("transfer events = %+v", events)
+	}
+
+	// the previous owner stays as a co-organizer
+	d, err := f.svc.Detail(ctx, f.trip.ID, f.owner)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if d.Permissions.Role != policy.RoleCoOrganizer || !d.Permissions.CanEdit || d.Permissions.CanDelete {
+		t.Errorf("previous owner permissions = %+v", d.Permissions)
+	}
+	if err := f.svc.Leave(ctx, f.trip.ID, f.owner); err != nil {
+		t.Errorf("previous owner Leave() error = %v", err)
+	}
+}
+
+func TestInviteAndJoin(t *testing.T) {
+	f := newTripFixture(t)
+	ctx := context.Background()
+
+	if _, _, err := f.svc.InviteLink(ctx, f.trip.ID, f.member(policy.RoleMember), nil); statusOf(err) != http.StatusForbidden {
+		t.Errorf("InviteLink() by a member error = %v, want 403", err)
+	}
+
+	link, expires, err := f.svc.InviteLink(ctx, f.trip.ID, f.owner, []string{"friend@example.com"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !expires.After(f.svc.now()) || len(f.mail.sent) != 1 || f.mail.sent[0] != "friend@example.com" {
+		t.Errorf("InviteLink() expires = %v, emails = %v", expires, f.mail.sent)
+	}
+	u, err := url.Parse(link)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	friend := f.store.PutUser(memory.User{Email: "friend@example.com"}).ID
+	res, err := f.svc.Join(ctx, friend, u.Query().Get("token"))
+	if err != nil || res.Trip.ID != f.trip.ID || res.Role != string(policy.RoleMember) {
+		t.Fatalf("Join() = %+v, %v", res, err)
+	}
+	events := f.events(models.NotificationMemberJoined)
+	if len(events) != 1 || events[0].RecipientID != f.owner {
+		t.Errorf("join events = %+v", events)
+	}
+
+	if _, err := f.svc.Join(ctx, friend, u.Query().Get("token")); statusOf(err) != http.StatusConflict {
+		t.Errorf("second Join() error = %v, want 409", err)
+	}
+	if _, err := f.svc.Join(ctx, friend, "not-a-token"); !errors.Is(err, ErrInvalidInvitation) {
+		t.Errorf("Join() with a bad token error = %v, want ErrInvalidInvitation", err)
+	}
+
+	if err := f.svc.Leave(ctx, f.trip.ID, friend); err != nil {
+		t.Fatalf("Leave() error = %v", err)
+	}
+	if events := f.events(models.NotificationMemberLeft); len(events) != 1 || events[0].RecipientID != f.owner {
+		t.Errorf("leave events = %+v", events)
+	}
+	if err := f.svc.Leave(ctx, f.trip.ID, friend); err == nil {
+		t.Error("second Leave() succeeded")
+	}
+}
+
+func TestInviteLinkWithoutEmail(t *testing.T) {
+	f := newTripFixture(t)
+	f.mail.configured = false
+	ctx := context.Background()
+
+	if _, _, err := f.svc.InviteLink(ctx, f.trip.ID, f.owner, []string{"friend@example.com"}); !errors.Is(err, ErrEmailUnavailable) {
+		t.Errorf("InviteLink() error = %v, want ErrEmailUnavailable", err)
+	}
+	if _, _, err := f.svc.InviteLink(ctx, f.trip.ID, f.owner, nil); err != nil {
+		t.Errorf("InviteLink() without emails error = %v", err)
+	}
+}
+
+func TestOrganizerMFARequirement(t *testing.T) {
+	f := newTripFixture(t)
+	ctx := context.Background()
+	co := f.member(policy.RoleCoOrganizer)
+	require := true
+
+	if _, _, err := f.svc.Update(ctx, f.trip.ID, co, dto.UpdateTripRequest{RequireOrganizerMFA: &require}); statusOf(err) != http.StatusForbidden {
+		t.Errorf("Update() by a co-organizer error = %v, want 403", err)
+	}
+	if _, _, err := f.svc.Update(ctx, f.trip.ID, f.owner, dto.UpdateTripRequest{RequireOrganizerMFA: &require}); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, _, err := f.svc.InviteLink(ctx, f.trip.ID, co, nil); !errors.Is(err, ErrMFARequired) {
+		t.Errorf("InviteLink() error = %v, want ErrMFARequired", err)
+	}
+	d, err := f.svc.Detail(ctx, f.trip.ID, co)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !d.Permissions.MFARequired || d.Permissions.CanInvite {
+		t.Errorf("blocked co-organizer permissions = %+v", d.Permissions)
+	}
+
+	withMFA := f.member(policy.RoleCoOrganizer)
+	f.store.PutUser(memory.User{ID: withMFA, Email: "mfa@example.com", MFAEnabled: true})
+	if _, _, err := f.svc.InviteLink(ctx, f.trip.ID, withMFA, nil); err != nil {
+		t.Errorf("InviteLink() by a co-organizer with MFA error = %v", err)
+	}
+}
+
+func TestSaveAvailability(t *testing.T) {
+	f := newTripFixture(t)
+	ctx := context.Background()
+	member := f.member(policy.RoleMember)
+
+	total, saved, err := f.svc.SaveAvailability(ctx, f.trip.ID, member, []string{"2026-12-01", "2026-12-03", "2026-12-01"})
+	if err != nil || total != 5 || saved != 2 {
+		t.Fatalf("SaveAvailability() = %d, %d, %v; want 5, 2", total, saved, err)
+	}
+	if events := f.events(models.NotificationAvailability); len(events) != 1 || events[0].RecipientID != f.owner {
+		t.Errorf("availability events = %+v", events)
+	}
+	days, _, err := f.svc.MyAvailability(ctx, f.trip.ID, member)
+	if err != nil || len(days) != 2 {
+		t.Errorf("MyAvailability() = %v, %v", days, err)
+	}
+
+	tests := []struct {
+		name  string
+		user  uuid.UUID
+		dates []string
+		want  int
+	}{
+		{"viewer", f.member(policy.RoleViewer), []string{"2026-12-01"}, http.StatusForbidden},
+		{"outsider", uuid.New(), []string{"2026-12-01"}, http.StatusForbidden},
+		{"no dates", member, nil, http.StatusBadRequest},
+		{"out of range", member, []string{"2026-12-06"}, http.StatusBadRequest},
+		{"bad format", member, []string{"01/12/2026"}, http.StatusBadRequest},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, _, err := f.svc.SaveAvailability(ctx, f.trip.ID, tt.user, tt.dates)
+			if got := statusOf(err); got != tt.want {
+				t.Errorf("SaveAvailability() status = %d (%v), want %d", got, err, tt.want)
+			}
+		})
+	}
+}
+
+func TestDelete(t *testing.T) {
+	f := newTripFixture(t)
+	ctx := context.Background()
+	co := f.member(policy.RoleCoOrganizer)
+
+	if got := statusOf(f.svc.Delete(ctx, f.trip.ID, co)); got != http.StatusForbidden {
+		t.Errorf("Delete() by a co-organizer status = %d, want 403", got)
+	}
+	if err := f.svc.Delete(ctx, f.trip.ID, f.owner); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := f.svc.Detail(ctx, f.trip.ID, f.owner); statusOf(err) != http.StatusNotFound {
+		t.Errorf("Detail() after Delete() error = %v, want 404", err)
+	}
+}
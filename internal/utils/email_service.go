@@ -1,182 +1,230 @@
-// สร้างไฟล์ internal/utils/email_service.go
-// ตัวอย่างการส่ง email ด้วย Gmail SMTP
-
 package utils
 
 import (
+	"context"
+	"errors"
 	"fmt"
-	"net/smtp"
+	"time"
 
 	"GO2GETHER_BACK-END/internal/config"
+	"GO2GETHER_BACK-END/internal/metrics"
+)
+
+var (
+	defaultMailer Mailer
+	mailQueue     *MailQueue
 )
 
-// EmailService handles email sending operations
+// UseMailer sets the mailer every EmailService sends through; call it once at
+// startup. Without it each EmailService creates the one chosen by its config.
+func UseMailer(m Mailer) {
+	defaultMailer = m
+}
+
+// UseMailQueue makes emails sent from requests (codes, invitations) go
+// through q instead of being sent while the request waits; call it once at
+// startup
+func UseMailQueue(q *MailQueue) {
+	mailQueue = q
+}
+
+// EmailService renders the email templates and sends them
 type EmailService struct {
 	config *config.EmailConfig
+	mailer Mailer
 }
 
 // NewEmailService creates a new email service instance
 func NewEmailService(cfg *config.EmailConfig) *EmailService {
-	return &EmailService{
-		config: cfg,
+	m := defaultMailer
+	if m == nil {
+		m = NewMailer(cfg)
 	}
+	return &EmailService{config: cfg, mailer: m}
 }
 
-// SendVerificationCode sends verification code to user's email
-func (e *EmailService) SendVerificationCode(to, code string) error {
-	subject := "Password Reset Verification Code"
-	body := fmt.Sprintf(`
-Hello,
-
-You requested to reset your password for Go2gether.
-
-Your verification code is: %s
-
-This code will expire in 3 minutes.
-
-If you didn't request this, please ignore this email.
-
-Best regards,
-Go2gether Team
-	`, code)
-
-	return e.sendEmail(to, subject, body)
+// IsConfigured reports whether emails are actually delivered (SMTP credentials
+// set, or printed or captured by a development/test mailer)
+func (e *EmailService) IsConfigured() bool {
+	if c, ok := e.mailer.(interface{ Configured() bool }); ok {
+		return c.Configured()
+	}
+	return true
 }
 
-// sendEmail sends an email using SMTP
-func (e *EmailService) sendEmail(to, subject, body string) error {
-	// Check if credentials are set
-	if e.config.SMTPUsername == "" || e.config.SMTPPassword == "" {
-		return fmt.Errorf("email credentials not configured")
+// SendVerificationCode sends the password reset code to the user's email
+func (e *EmailService) SendVerificationCode(ctx context.Context, to, code string) error {
+	msg, err := renderEmail(tmplPasswordReset, e.locale(""), to, emailData{Code: code, ExpiresIn: 3})
+	if err != nil {
+		return err
 	}
+	return e.enqueue(ctx, tmplPasswordReset, msg)
+}
 
-	// Setup authentication
-	auth := smtp.PlainAuth("", e.config.SMTPUsername, e.config.SMTPPassword, e.config.SMTPHost)
-
-	// Compose message
-	fromEmail := e.config.FromEmail
-	if fromEmail == "" {
-		fromEmail = e.config.SMTPUsername
+// SendEmailVerification sends the email verification code and link to a newly registered user
+func (e *EmailService) SendEmailVerification(ctx context.Context, to, code, link string, expiresIn time.Duration) error {
+	msg, err := renderEmail(tmplEmailVerification, e.locale(""), to, emailData{
+		Code:      code,
+		Link:      link,
+		ExpiresIn: int(expiresIn.Minutes()),
+	})
+	if err != nil {
+		return err
 	}
+	return e.enqueue(ctx, tmplEmailVerification, msg)
+}
 
-	message := []byte(fmt.Sprintf(
-		"From: %s <%s>\r\n"+
-			"To: %s\r\n"+
-			"Subject: %s\r\n"+
-			"\r\n"+
-			"%s\r\n",
-		e.config.FromName, fromEmail, to, subject, body))
-
-	// Send email
-	addr := e.config.SMTPHost + ":" + e.config.SMTPPort
-	err := smtp.SendMail(addr, auth, fromEmail, []string{to}, message)
+// SendInvitation emails an invitation link to a trip; an empty locale means
+// the default one
+func (e *EmailService) SendInvitation(ctx context.Context, to, locale, inviter, tripName, link string, expires time.Time) error {
+	msg, err := renderEmail(tmplInvitation, e.locale(locale), to, emailData{
+		Actor:    inviter,
+		TripName: tripName,
+		Link:     link,
+		Expires:  expires.UTC().Format("2006-01-02"),
+	})
 	if err != nil {
-		return fmt.Errorf("failed to send email: %v", err)
+		return err
 	}
-
-	return nil
+	return e.enqueue(ctx, tmplInvitation, msg)
 }
 
-// ===== Alternative: SendGrid Implementation =====
-
-/*
-import (
-	"github.com/sendgrid/sendgrid-go"
-	"github.com/sendgrid/sendgrid-go/helpers/mail"
-)
-
-type SendGridEmailService struct {
-	apiKey string
-	from   string
+// NotificationEmail is a trip notification to email: its type, texts and
+// the data it was published with
+type NotificationEmail struct {
+	Type    string
+	Title   string
+	Message string
+	Link    string // opens it in the app; may be empty
+	Data    map[string]any
 }
 
-func NewSendGridEmailService() *SendGridEmailService {
-	return &SendGridEmailService{
-		apiKey: os.Getenv("SENDGRID_API_KEY"),
-		from:   os.Getenv("EMAIL_FROM"),
+// SendNotification emails a trip notification in locale (empty: the default
+// one) and waits for the mailer; the outbox retries it when it fails
+func (e *EmailService) SendNotification(ctx context.Context, to, locale string, n NotificationEmail) error {
+	name, data := notificationTemplate(n)
+	msg, err := renderEmail(name, e.locale(locale), to, data)
+	if err != nil {
+		return err
 	}
+	return e.send(ctx, name, msg)
 }
 
-func (s *SendGridEmailService) SendVerificationCode(to, code string) error {
-	from := mail.NewEmail("Go2gether", s.from)
-	subject := "Password Reset Verification Code"
-	toEmail := mail.NewEmail("", to)
-
-	plainTextContent := fmt.Sprintf(`
-Hello,
-
-You requested to reset your password for Go2gether.
-
-Your verification code is: %s
-
-This code will expire in 3 minutes.
-
-If you didn't request this, please ignore this email.
+// notificationTemplate picks the template of n and fills its data from
+// n.Data; types without a template of their own use the generic one
+func notificationTemplate(n NotificationEmail) (string, emailData) {
+	data := emailData{
+		Title:    n.Title,
+		Message:  n.Message,
+		Link:     n.Link,
+		TripName: stringOf(n.Data["tripName"]),
+		Actor:    stringOf(n.Data["user_display_name"]),
+		Role:     stringOf(n.Data["role"]),
+		Event:    stringOf(n.Data["event"]),
+		Periods:  intOf(n.Data["total_periods"]),
+		MinDays:  intOf(n.Data["min_days"]),
+	}
+	switch {
+	case data.Event == "periods_generated":
+		return tmplAvailablePeriods, data
+	case n.Type == "member_joined" || n.Type == "invitation_accepted":
+		return tmplMemberJoined, data
+	case n.Type == "member_left":
+		return tmplMemberLeft, data
+	case n.Type == "trip_update":
+		return tmplTripUpdate, data
+	}
+	return tmplNotification, data
+}
 
-Best regards,
-Go2gether Team
-	`, code)
+// DigestEmail summarizes a user's unread notifications of one period,
+// grouped by trip and type
+type DigestEmail struct {
+	Frequency string // daily | weekly
+	From, To  string // the period, in the user's timezone
+	Total     int
+	Trips     []DigestTrip
+	Link      string // the notifications page
+}
 
-	htmlContent := fmt.Sprintf(`
-<!DOCTYPE html>
-<html>
-<body style="font-family: Arial, sans-serif; line-height: 1.6; color: #333;">
-	<div style="max-width: 600px; margin: 0 auto; padding: 20px;">
-		<h2 style="color: #4CAF50;">Password Reset Request</h2>
-		<p>Hello,</p>
-		<p>You requested to reset your password for Go2gether.</p>
-		<div style="background-color: #f4f4f4; padding: 20px; margin: 20px 0; border-radius: 5px; text-align: center;">
-			<h1 style="color: #4CAF50; margin: 0; font-size: 32px; letter-spacing: 5px;">%s</h1>
-			<p style="margin: 10px 0 0 0; color: #666; font-size: 14px;">Verification Code</p>
-		</div>
-		<p style="color: #d32f2f; font-weight: bold;">⏰ This code will expire in 3 minutes.</p>
-		<p>If you didn't request this, please ignore this email.</p>
-		<hr style="border: none; border-top: 1px solid #eee; margin: 30px 0;">
-		<p style="color: #999; font-size: 12px;">Best regards,<br>Go2gether Team</p>
-	</div>
-</body>
-</html>
-	`, code)
+// DigestTrip is the part of a digest about one trip; Name is empty for
+// notifications not about a trip
+type DigestTrip struct {
+	Name   string
+	Link   string
+	Total  int
+	Groups []DigestGroup
+}
 
-	message := mail.NewSingleEmail(from, subject, toEmail, plainTextContent, htmlContent)
-	client := sendgrid.NewSendClient(s.apiKey)
+// DigestGroup counts the notifications of one type and repeats the newest
+type DigestGroup struct {
+	Type   string
+	Count  int
+	Latest []string
+}
 
-	response, err := client.Send(message)
+// SendDigest emails a digest in locale (empty: the default one) and waits
+// for the mailer
+func (e *EmailService) SendDigest(ctx context.Context, to, locale string, d DigestEmail) error {
+	msg, err := renderEmail(tmplDigest, e.locale(locale), to, d)
 	if err != nil {
-		return fmt.Errorf("failed to send email: %v", err)
+		return err
 	}
+	return e.send(ctx, tmplDigest, msg)
+}
 
-	if response.StatusCode >= 400 {
-		return fmt.Errorf("sendgrid returned error status: %d", response.StatusCode)
+func (e *EmailService) locale(l string) string {
+	if l == "" {
+		l = e.config.DefaultLocale
 	}
-
-	return nil
+	return NormalizeLocale(l)
 }
-*/
-
-// ===== How to use in forgot_password.go =====
-
-/*
-// In forgot_password.go, add email service to handler:
 
-type ForgotPasswordHandler struct {
-	db           *pgxpool.Pool
-	emailService *utils.EmailService
+// enqueue hands msg to the mail queue when there is one, so the request does
+// not wait for SMTP; otherwise it sends it right away
+func (e *EmailService) enqueue(ctx context.Context, kind string, msg Message) error {
+	if mailQueue != nil && mailQueue.mailer == e.mailer && e.IsConfigured() {
+		if err := mailQueue.Enqueue(ctx, kind, msg); err != nil {
+			return fmt.Errorf("failed to queue email: %w", err)
+		}
+		return nil
+	}
+	return e.send(ctx, kind, msg)
 }
 
-func NewForgotPasswordHandler(db *pgxpool.Pool) *ForgotPasswordHandler {
-	return &ForgotPasswordHandler{
-		db:           db,
-		emailService: utils.NewEmailService(),
+// send sends msg now; kind labels the email in metrics
+func (e *EmailService) send(ctx context.Context, kind string, msg Message) error {
+	if !e.IsConfigured() {
+		metrics.Email(kind, metrics.EmailNotConfigured)
+		return errors.New("email credentials not configured")
+	}
+	if err := e.mailer.Send(ctx, msg); err != nil {
+		metrics.Email(kind, metrics.EmailFailed)
+		return fmt.Errorf("failed to send email: %w", err)
+	}
+	if _, ok := e.mailer.(*SMTPMailer); ok {
+		metrics.Email(kind, metrics.EmailSent)
+	} else {
+		metrics.Email(kind, metrics.EmailCaptured)
 	}
+	return nil
 }
 
-// Then in ForgotPassword function, replace the TODO:
+func stringOf(v any) string {
+	s, _ := v.(string)
+	return s
+}
 
-// Send verification code via email
-err = h.emailService.SendVerificationCode(req.Email, code)
-if err != nil {
-	utils.WriteErrorResponse(w, http.StatusInternalServerError, "Failed to send email", err.Error())
-	return
+// intOf reads a number of notification data, which is float64 once it has
+// been through JSON
+func intOf(v any) int {
+	switch n := v.(type) {
+	case int:
+		return n
+	case int64:
+		return int(n)
+	case float64:
+		return int(n)
+	}
+	return 0
 }
-*/
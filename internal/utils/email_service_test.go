@@ -0,0 +1,109 @@
+package utils
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	"GO2GETHER_BACK-END/internal/config"
+)
+
+// sinkService is an EmailService whose emails end up in a MailSink, as with
+// EMAIL_MAILER=memory
+func sinkService(locale string) (*EmailService, *MailSink) {
+	sink := NewMailSink()
+	return &EmailService{config: &config.EmailConfig{Mailer: MailerMemory, DefaultLocale: locale}, mailer: sink}, sink
+}
+
+// only returns the single message captured for to
+func only(t *testing.T, sink *MailSink, to string) SentEmail {
+	t.Helper()
+	msgs := sink.Messages(to)
+	if len(msgs) != 1 {
+		t.Fatalf("captured %d emails to %s, want 1", len(msgs), to)
+	}
+	return msgs[0]
+}
+
+func TestEmailVerificationCaptured(t *testing.T) {
+	const link = "http://localhost:3000/verify-email?token=abc"
+	tests := []struct {
+		locale  string
+		subject string
+		expires string
+	}{
+		{LocaleEnglish, "Verify your Go2gether email address", "expire in 15 minutes"},
+		{LocaleThai, "ยืนยันอีเมลของคุณสำหรับ Go2gether", "หมดอายุใน 15 นาที"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.locale, func(t *testing.T) {
+			svc, sink := sinkService(tt.locale)
+			if err := svc.SendEmailVerification(context.Background(), "new@example.com", "482913", link, 15*time.Minute); err != nil {
+				t.Fatalf("SendEmailVerification() error = %v", err)
+			}
+
+			m := only(t, sink, "NEW@example.com") // the recipient filter ignores case
+			if m.To != "new@example.com" || m.Subject != tt.subject {
+				t.Errorf("to = %q, subject = %q; want new@example.com, %q", m.To, m.Subject, tt.subject)
+			}
+			for _, want := range []string{"482913", link, tt.expires} {
+				if !strings.Contains(m.Body, want) {
+					t.Errorf("text body does not contain %q:\n%s", want, m.Body)
+				}
+			}
+			if !strings.Contains(m.HTML, "482913") || !strings.Contains(m.HTML, link) {
+				t.Errorf("HTML body lacks the code or link:\n%s", m.HTML)
+			}
+		})
+	}
+}
+
+func TestPasswordResetCaptured(t *testing.T) {
+	svc, sink := sinkService("")
+	if err := svc.SendVerificationCode(context.Background(), "user@example.com", "105577"); err != nil {
+		t.Fatalf("SendVerificationCode() error = %v", err)
+	}
+
+	m := only(t, sink, "user@example.com")
+	if m.Subject != "Password Reset Verification Code" {
+		t.Errorf("subject = %q", m.Subject)
+	}
+	if !strings.Contains(m.Body, "105577") || !strings.Contains(m.HTML, "105577") {
+		t.Errorf("email does not contain the code:\n%s", m.Body)
+	}
+	if !strings.Contains(m.Body, "expire in 3 minutes") {
+		t.Errorf("email does not state the code lifetime:\n%s", m.Body)
+	}
+}
+
+func TestMailSinkFilterAndReset(t *testing.T) {
+	svc, sink := sinkService("")
+	ctx := context.Background()
+	for _, to := range []string{"a@example.com", "b@example.com", "a@example.com"} {
+		if err := svc.SendVerificationCode(ctx, to, "000000"); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if got := len(sink.Messages("")); got != 3 {
+		t.Errorf("Messages(\"\") = %d emails, want 3", got)
+	}
+	if got := len(sink.Messages("a@example.com")); got != 2 {
+		t.Errorf("Messages(a) = %d emails, want 2", got)
+	}
+	if got := len(sink.Messages("nobody@example.com")); got != 0 {
+		t.Errorf("Messages(nobody) = %d emails, want 0", got)
+	}
+
+	sink.Reset()
+	if got := len(sink.Messages("")); got != 0 {
+		t.Errorf("after Reset() the sink holds %d emails", got)
+	}
+}
+
+func TestNewMailerMemoryIsSink(t *testing.T) {
+	if _, ok := NewMailer(&config.EmailConfig{Mailer: MailerMemory}).(*MailSink); !ok {
+		t.Error("EMAIL_MAILER=memory does not create a MailSink")
+	}
+}
@@ -0,0 +1,134 @@
+package utils
+
+import (
+	"bytes"
+	"embed"
+	"fmt"
+	htmltemplate "html/template"
+	"io/fs"
+	"path"
+	"strings"
+	texttemplate "text/template"
+)
+
+// Email templates live in templates/email/<locale>/. Each email <name> has
+// <name>.txt, defining "subject" and "text", and <name>.html, defining
+// "content", which the locale's layout.html wraps.
+//
+//go:embed templates/email
+var emailFS embed.FS
+
+// Email locales; DefaultLocale is used for unknown or empty ones
+const (
+	LocaleEnglish = "en"
+	LocaleThai    = "th"
+	DefaultLocale = LocaleEnglish
+)
+
+// Email templates
+const (
+	tmplPasswordReset     = "password_reset"
+	tmplEmailVerification = "email_verification"
+	tmplInvitation        = "invitation"
+	tmplMemberJoined      = "member_joined"
+	tmplMemberLeft        = "member_left"
+	tmplAvailablePeriods  = "available_periods"
+	tmplTripUpdate        = "trip_update"
+	tmplNotification      = "notification"
+	tmplDigest            = "digest"
+)
+
+// emailData is what the templates render, except the digest (DigestEmail)
+type emailData struct {
+	Title     string
+	Message   string
+	Link      string
+	TripName  string
+	Actor     string // who did it (display name)
+	Role      string
+	Event     string // data["event"] of trip updates
+	Code      string
+	ExpiresIn int    // minutes
+	Expires   string // date, YYYY-MM-DD
+	Periods   int
+	MinDays   int
+}
+
+type emailTemplate struct {
+	text *texttemplate.Template
+	html *htmltemplate.Template
+}
+
+// emailTemplates[locale][name]; parsed at startup, a broken template panics
+var emailTemplates = mustParseEmailTemplates(emailFS)
+
+func mustParseEmailTemplates(fsys fs.FS) map[string]map[string]emailTemplate {
+	root := "templates/email"
+	locales, err := fs.ReadDir(fsys, root)
+	if err != nil {
+		panic(err)
+	}
+	out := make(map[string]map[string]emailTemplate)
+	for _, l := range locales {
+		if !l.IsDir() {
+			continue
+		}
+		dir := path.Join(root, l.Name())
+		layout := htmltemplate.Must(htmltemplate.ParseFS(fsys, path.Join(dir, "layout.html")))
+		texts, err := fs.Glob(fsys, path.Join(dir, "*.txt"))
+		if err != nil {
+			panic(err)
+		}
+		out[l.Name()] = make(map[string]emailTemplate)
+		for _, file := range texts {
+			name := strings.TrimSuffix(path.Base(file), ".txt")
+			html := htmltemplate.Must(htmltemplate.Must(layout.Clone()).ParseFS(fsys, path.Join(dir, name+".html")))
+			out[l.Name()][name] = emailTemplate{
+				text: texttemplate.Must(texttemplate.ParseFS(fsys, file)),
+				html: html,
+			}
+		}
+	}
+	if len(out[DefaultLocale]) == 0 {
+		panic("email templates: no " + DefaultLocale + " templates")
+	}
+	return out
+}
+
+// NormalizeLocale returns locale when there are templates for it, or
+// DefaultLocale. A region (th-TH) is ignored.
+func NormalizeLocale(locale string) string {
+	locale = strings.ToLower(strings.TrimSpace(locale))
+	locale, _, _ = strings.Cut(strings.ReplaceAll(locale, "_", "-"), "-")
+	if _, ok := emailTemplates[locale]; ok {
+		return locale
+	}
+	return DefaultLocale
+}
+
+// renderEmail renders template name in locale (falling back to
+// DefaultLocale) with data into a message to to
+func renderEmail(name, locale, to string, data any) (Message, error) {
+	t, ok := emailTemplates[NormalizeLocale(locale)][name]
+	if !ok {
+		if t, ok = emailTemplates[DefaultLocale][name]; !ok {
+			return Message{}, fmt.Errorf("no email template %q", name)
+		}
+	}
+	var subject, text, html bytes.Buffer
+	if err := t.text.ExecuteTemplate(&subject, "subject", data); err != nil {
+		return Message{}, fmt.Errorf("render %s subject: %w", name, err)
+	}
+	if err := t.text.ExecuteTemplate(&text, "text", data); err != nil {
+		return Message{}, fmt.Errorf("render %s text: %w", name, err)
+	}
+	if err := t.html.ExecuteTemplate(&html, "layout", data); err != nil {
+		return Message{}, fmt.Errorf("render %s html: %w", name, err)
+	}
+	return Message{
+		To:      to,
+		Subject: strings.Join(strings.Fields(subject.String()), " "),
+		Text:    strings.TrimSpace(text.String()) + "\n",
+		HTML:    html.String(),
+	}, nil
+}
@@ -0,0 +1,72 @@
+package utils
+
+import (
+	"context"
+	"log/slog"
+	"time"
+
+	"GO2GETHER_BACK-END/internal/metrics"
+	"GO2GETHER_BACK-END/internal/worker"
+)
+
+// MailQueue sends emails in the background on a worker pool, retrying
+// failed sends with exponential backoff, so a slow or unreachable SMTP server
+// never holds up a request. It is in memory: emails still queued when
+// Shutdown runs out of time are lost.
+type MailQueue struct {
+	mailer      Mailer
+	jobs        *worker.Pool
+	maxAttempts int
+	backoff     time.Duration
+}
+
+// maxMailBackoff caps the wait between two attempts
+const maxMailBackoff = time.Minute
+
+// NewMailQueue creates a queue delivering through mailer on jobs; each
+// email is tried up to maxAttempts times, backoff apart (doubled per retry)
+func NewMailQueue(mailer Mailer, jobs *worker.Pool, maxAttempts int, backoff time.Duration) *MailQueue {
+	if maxAttempts < 1 {
+		maxAttempts = 1
+	}
+	return &MailQueue{mailer: mailer, jobs: jobs, maxAttempts: maxAttempts, backoff: backoff}
+}
+
+// Enqueue queues msg; kind labels it in metrics and logs. It fails only
+// when the queue is full or shutting down.
+func (q *MailQueue) Enqueue(ctx context.Context, kind string, msg Message) error {
+	err := q.jobs.Submit(ctx, func(ctx context.Context) { q.deliver(ctx, kind, msg) })
+	if err != nil {
+		metrics.Email(kind, metrics.EmailDropped)
+		return err
+	}
+	metrics.Email(kind, metrics.EmailQueued)
+	return nil
+}
+
+func (q *MailQueue) deliver(ctx context.Context, kind string, msg Message) {
+	wait := q.backoff
+	for attempt := 1; ; attempt++ {
+		err := q.mailer.Send(ctx, msg)
+		if err == nil {
+			metrics.Email(kind, metrics.EmailSent)
+			return
+		}
+		if attempt >= q.maxAttempts || IsPermanentMailError(err) || ctx.Err() != nil {
+			metrics.Email(kind, metrics.EmailFailed)
+			slog.ErrorContext(ctx, "sending email failed", "kind", kind, "email", msg.To, "attempts", attempt, "error", err)
+			return
+		}
+		metrics.Email(kind, metrics.EmailRetried)
+		slog.WarnContext(ctx, "sending email failed, retrying", "kind", kind, "attempt", attempt,
+			"retry_in", wait.String(), "error", err)
+		select {
+		case <-ctx.Done():
+			metrics.Email(kind, metrics.EmailFailed)
+			slog.ErrorContext(ctx, "email dropped at shutdown", "kind", kind, "email", msg.To)
+			return
+		case <-time.After(wait):
+		}
+		wait = min(wait*2, maxMailBackoff)
+	}
+}
@@ -0,0 +1,66 @@
+package utils
+
+import (
+	"context"
+	"strings"
+	"sync"
+	"time"
+)
+
+// SentEmail is one message captured by a MailSink
+type SentEmail struct {
+	To      string    `json:"to"`
+	Subject string    `json:"subject"`
+	Body    string    `json:"body"` // the plain-text version
+	HTML    string    `json:"html,omitempty"`
+	SentAt  time.Time `json:"sent_at"`
+}
+
+// MailSink is the in-memory Mailer: it captures outgoing emails instead of
+// sending them. It is installed in test mode so integration tests can read
+// the codes and links that would have been emailed.
+type MailSink struct {
+	mu       sync.Mutex
+	messages []SentEmail
+}
+
+// NewMailSink creates an empty sink
+func NewMailSink() *MailSink {
+	return &MailSink{}
+}
+
+// Send implements Mailer
+func (s *MailSink) Send(_ context.Context, msg Message) error {
+	s.mu.Lock()
+	s.messages = append(s.messages, SentEmail{
+		To:      msg.To,
+		Subject: msg.Subject,
+		Body:    msg.Text,
+		HTML:    msg.HTML,
+		SentAt:  time.Now(),
+	})
+	s.mu.Unlock()
+	return nil
+}
+
+// Messages returns the captured messages, oldest first. A non-empty to
+// filters by recipient (case-insensitive).
+func (s *MailSink) Messages(to string) []SentEmail {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	out := make([]SentEmail, 0, len(s.messages))
+	for _, m := range s.messages {
+		if to == "" || strings.EqualFold(m.To, to) {
+			out = append(out, m)
+		}
+	}
+	return out
+}
+
+// Reset drops every captured message
+func (s *MailSink) Reset() {
+	s.mu.Lock()
+	s.messages = nil
+	s.mu.Unlock()
+}
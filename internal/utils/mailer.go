@@ -0,0 +1,267 @@
+package utils
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"crypto/tls"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"mime"
+	"mime/multipart"
+	"mime/quotedprintable"
+	"net"
+	"net/mail"
+	"net/smtp"
+	"net/textproto"
+	"os"
+	"strings"
+	"time"
+
+	"GO2GETHER_BACK-END/internal/config"
+)
+
+// Message is one email with a plain-text and an HTML version of the body
+type Message struct {
+	To      string
+	Subject string
+	Text    string
+	HTML    string // optional; without it the email is plain text only
+}
+
+// Mailer delivers emails
+type Mailer interface {
+	Send(ctx context.Context, msg Message) error
+}
+
+// Mailers selected by EMAIL_MAILER
+const (
+	MailerSMTP    = "smtp"
+	MailerConsole = "console"
+	MailerMemory  = "memory"
+)
+
+// NewMailer creates the mailer chosen by cfg.Mailer: SMTP (default), the
+// console (prints emails, for development) or memory (a MailSink)
+func NewMailer(cfg *config.EmailConfig) Mailer {
+	switch cfg.Mailer {
+	case MailerConsole:
+		return NewConsoleMailer(os.Stderr)
+	case MailerMemory:
+		return NewMailSink()
+	}
+	return NewSMTPMailer(cfg)
+}
+
+// rejectedError is a 5xx SMTP reply: the server refused the message and
+// sending it again will not help
+type rejectedError struct{ err error }
+
+func (e rejectedError) Error() string   { return e.err.Error() }
+func (e rejectedError) Unwrap() error   { return e.err }
+func (e rejectedError) Permanent() bool { return true }
+
+// IsPermanentMailError reports whether err is a rejection that retrying
+// cannot fix
+func IsPermanentMailError(err error) bool {
+	var r rejectedError
+	return errors.As(err, &r)
+}
+
+// ---------- SMTP ----------
+
+// SMTPMailer sends emails through an SMTP server: implicit TLS when UseSSL
+// (port 465), otherwise STARTTLS, required when UseTLS
+type SMTPMailer struct {
+	cfg *config.EmailConfig
+}
+
+// NewSMTPMailer creates an SMTPMailer
+func NewSMTPMailer(cfg *config.EmailConfig) *SMTPMailer {
+	return &SMTPMailer{cfg: cfg}
+}
+
+// Configured reports whether SMTP credentials are set
+func (m *SMTPMailer) Configured() bool {
+	return m.cfg.SMTPUsername != "" && m.cfg.SMTPPassword != "" && m.cfg.FromEmail != ""
+}
+
+func (m *SMTPMailer) from() string {
+	if m.cfg.FromEmail != "" {
+		return m.cfg.FromEmail
+	}
+	return m.cfg.SMTPUsername
+}
+
+func (m *SMTPMailer) Send(ctx context.Context, msg Message) error {
+	if m.cfg.SMTPUsername == "" || m.cfg.SMTPPassword == "" {
+		return errors.New("email credentials not configured")
+	}
+	if _, ok := ctx.Deadline(); !ok && m.cfg.SendTimeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, m.cfg.SendTimeout)
+		defer cancel()
+	}
+	from := m.from()
+	data, err := buildMIME(mail.Address{Name: m.cfg.FromName, Address: from}, msg)
+	if err != nil {
+		return err
+	}
+
+	addr := net.JoinHostPort(m.cfg.SMTPHost, m.cfg.SMTPPort)
+	var d net.Dialer
+	conn, err := d.DialContext(ctx, "tcp", addr)
+	if err != nil {
+		return fmt.Errorf("connect to %s: %w", addr, err)
+	}
+	// deadline ของ ctx ครอบทั้งการคุยกับ server ไม่ใช่แค่ตอน dial
+	if deadline, ok := ctx.Deadline(); ok {
+		_ = conn.SetDeadline(deadline)
+	}
+	stop := context.AfterFunc(ctx, func() { conn.Close() })
+	defer stop()
+
+	tlsConfig := &tls.Config{ServerName: m.cfg.SMTPHost, MinVersion: tls.VersionTLS12}
+	if m.cfg.UseSSL {
+		conn = tls.Client(conn, tlsConfig)
+	}
+	c, err := smtp.NewClient(conn, m.cfg.SMTPHost)
+	if err != nil {
+		conn.Close()
+		return smtpError(err)
+	}
+	defer c.Close()
+
+	if !m.cfg.UseSSL {
+		if ok, _ := c.Extension("STARTTLS"); ok {
+			if err := c.StartTLS(tlsConfig); err != nil {
+				return smtpError(err)
+			}
+		} else if m.cfg.UseTLS {
+			return fmt.Errorf("%s does not support STARTTLS", addr)
+		}
+	}
+	if err := c.Auth(smtp.PlainAuth("", m.cfg.SMTPUsername, m.cfg.SMTPPassword, m.cfg.SMTPHost)); err != nil {
+		return smtpError(err)
+	}
+	if err := c.Mail(from); err != nil {
+		return smtpError(err)
+	}
+	if err := c.Rcpt(msg.To); err != nil {
+		return smtpError(err)
+	}
+	w, err := c.Data()
+	if err != nil {
+		return smtpError(err)
+	}
+	if _, err := w.Write(data); err != nil {
+		return smtpError(err)
+	}
+	if err := w.Close(); err != nil {
+		return smtpError(err)
+	}
+	return smtpError(c.Quit())
+}
+
+// smtpError marks 5xx replies as rejections
+func smtpError(err error) error {
+	if err == nil {
+		return nil
+	}
+	var tp *textproto.Error
+	if errors.As(err, &tp) && tp.Code >= 500 {
+		return rejectedError{err: fmt.Errorf("smtp: %w", err)}
+	}
+	return fmt.Errorf("smtp: %w", err)
+}
+
+// buildMIME encodes msg as multipart/alternative (text, then HTML) or, with
+// no HTML, as a single text/plain part. Headers are RFC 2047 encoded, so
+// Thai subjects and names survive.
+func buildMIME(from mail.Address, msg Message) ([]byte, error) {
+	to, err := mail.ParseAddress(msg.To)
+	if err != nil {
+		return nil, rejectedError{err: fmt.Errorf("invalid recipient %q: %w", msg.To, err)}
+	}
+	var buf bytes.Buffer
+	header := func(k, v string) { fmt.Fprintf(&buf, "%s: %s\r\n", k, v) }
+	header("From", from.String())
+	header("To", to.String())
+	header("Subject", mime.QEncoding.Encode("utf-8", msg.Subject))
+	header("Date", time.Now().Format(time.RFC1123Z))
+	header("Message-ID", messageID(from.Address))
+	header("MIME-Version", "1.0")
+
+	if msg.HTML == "" {
+		header("Content-Type", `text/plain; charset="utf-8"`)
+		header("Content-Transfer-Encoding", "quoted-printable")
+		buf.WriteString("\r\n")
+		if err := writeQP(&buf, msg.Text); err != nil {
+			return nil, err
+		}
+		return buf.Bytes(), nil
+	}
+
+	mw := multipart.NewWriter(&buf)
+	header("Content-Type", `multipart/alternative; boundary="`+mw.Boundary()+`"`)
+	buf.WriteString("\r\n")
+	for _, part := range []struct{ typ, body string }{
+		{"text/plain", msg.Text},
+		{"text/html", msg.HTML},
+	} {
+		pw, err := mw.CreatePart(textproto.MIMEHeader{
+			"Content-Type":              {part.typ + `; charset="utf-8"`},
+			"Content-Transfer-Encoding": {"quoted-printable"},
+		})
+		if err != nil {
+			return nil, err
+		}
+		if err := writeQP(pw, part.body); err != nil {
+			return nil, err
+		}
+	}
+	if err := mw.Close(); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+func writeQP(w io.Writer, s string) error {
+	qp := quotedprintable.NewWriter(w)
+	if _, err := io.WriteString(qp, strings.ReplaceAll(s, "\n", "\r\n")); err != nil {
+		return err
+	}
+	return qp.Close()
+}
+
+func messageID(from string) string {
+	b := make([]byte, 12)
+	_, _ = rand.Read(b)
+	domain := "go2gether.local"
+	if _, d, ok := strings.Cut(from, "@"); ok && d != "" {
+		domain = d
+	}
+	return "<" + hex.EncodeToString(b) + "@" + domain + ">"
+}
+
+// ---------- console ----------
+
+// ConsoleMailer prints emails instead of sending them, for development
+type ConsoleMailer struct {
+	w io.Writer
+}
+
+// NewConsoleMailer creates a ConsoleMailer writing to w
+func NewConsoleMailer(w io.Writer) *ConsoleMailer {
+	return &ConsoleMailer{w: w}
+}
+
+func (m *ConsoleMailer) Send(ctx context.Context, msg Message) error {
+	slog.InfoContext(ctx, "email printed to console", "email", msg.To, "subject", msg.Subject)
+	_, err := fmt.Fprintf(m.w, "----- email -----\nTo: %s\nSubject: %s\n\n%s\n-----------------\n",
+		msg.To, msg.Subject, strings.TrimSpace(msg.Text))
+	return err
+}
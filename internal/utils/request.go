@@ -0,0 +1,168 @@
+package utils
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/netip"
+	"reflect"
+	"strings"
+
+	"GO2GETHER_BACK-END/internal/apperr"
+	"GO2GETHER_BACK-END/internal/validate"
+)
+
+var trustedProxies []netip.Prefix
+
+// UseTrustedProxies sets the proxies whose forwarding headers ClientIP
+// believes; call it once at startup. Without it the headers are ignored.
+func UseTrustedProxies(prefixes []netip.Prefix) {
+	trustedProxies = prefixes
+}
+
+func trustedProxy(addr netip.Addr) bool {
+	for _, p := range trustedProxies {
+		if p.Contains(addr) {
+			return true
+		}
+	}
+	return false
+}
+
+// ClientIP returns the client IP address of the request. X-Forwarded-For and
+// X-Real-IP are only read when the connection comes from a trusted proxy:
+// the client is then the right-most X-Forwarded-For hop that is not a
+// trusted proxy, since every hop left of it may be forged by the client.
+func ClientIP(r *http.Request) string {
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		host = r.RemoteAddr
+	}
+	remote, err := netip.ParseAddr(host)
+	if err != nil || !trustedProxy(remote.Unmap()) {
+		return host
+	}
+
+	var hops []string
+	for _, v := range r.Header.Values("X-Forwarded-For") {
+		hops = append(hops, strings.Split(v, ",")...)
+	}
+	client := remote.Unmap()
+	for i := len(hops) - 1; i >= 0; i-- {
+		hop, err := netip.ParseAddr(strings.TrimSpace(hops[i]))
+		if err != nil {
+			break // hop ที่อ่านไม่ได้ ต่อจากนี้เชื่อไม่ได้ ใช้ proxy ตัวล่าสุด
+		}
+		client = hop.Unmap()
+		if !trustedProxy(client) {
+			break
+		}
+	}
+	if len(hops) == 0 {
+		if xrip, err := netip.ParseAddr(strings.TrimSpace(r.Header.Get("X-Real-IP"))); err == nil {
+			client = xrip.Unmap()
+		}
+	}
+	return client.String()
+}
+
+// JSONPolicy is how DecodeJSON reads request bodies
+type JSONPolicy struct {
+	// MaxBodyBytes caps the body; larger bodies get 413
+	MaxBodyBytes int64
+	// AllowUnknownFields accepts fields the DTO does not declare instead of
+	// rejecting them
+	AllowUnknownFields bool
+}
+
+var jsonPolicy = JSONPolicy{MaxBodyBytes: 1 << 20}
+
+// UseJSONPolicy sets the request body policy; call it once at startup
+func UseJSONPolicy(p JSONPolicy) {
+	if p.MaxBodyBytes <= 0 {
+		p.MaxBodyBytes = 1 << 20
+	}
+	jsonPolicy = p
+}
+
+// DecodeJSON reads the JSON body of r into dst and validates it (see package
+// validate). On failure it writes the error response and returns false:
+// 413 for a body over the limit, 400 malformed_json for bad JSON, and 400
+// validation_failed with every invalid or unknown field.
+func DecodeJSON(w http.ResponseWriter, r *http.Request, dst any) bool {
+	if err := decodeJSON(w, r, dst); err != nil {
+		WriteError(w, r, err)
+		return false
+	}
+	return true
+}
+
+func decodeJSON(w http.ResponseWriter, r *http.Request, dst any) error {
+	r.Body = http.MaxBytesReader(w, r.Body, jsonPolicy.MaxBodyBytes)
+	dec := json.NewDecoder(r.Body)
+	if !jsonPolicy.AllowUnknownFields {
+		dec.DisallowUnknownFields()
+	}
+	if err := dec.Decode(dst); err != nil {
+		return jsonError(err)
+	}
+	if err := dec.Decode(&struct{}{}); err != io.EOF {
+		if err != nil {
+			return jsonError(err)
+		}
+		return apperr.New(http.StatusBadRequest, apperr.CodeMalformedJSON, "Invalid request body",
+			"Request body must contain a single JSON object")
+	}
+	return validate.Struct(dst)
+}
+
+// jsonError describes a decoding error without echoing the body
+func jsonError(err error) error {
+	var (
+		maxErr    *http.MaxBytesError
+		syntaxErr *json.SyntaxError
+		typeErr   *json.UnmarshalTypeError
+	)
+	malformed := func(detail string) error {
+		return apperr.New(http.StatusBadRequest, apperr.CodeMalformedJSON, "Invalid request body", detail)
+	}
+	switch {
+	case errors.As(err, &maxErr):
+		return apperr.New(http.StatusRequestEntityTooLarge, apperr.CodePayloadTooLarge, "Request body too large",
+			fmt.Sprintf("Request body must not be larger than %d bytes", maxErr.Limit))
+	case errors.Is(err, io.EOF):
+		return malformed("Request body must not be empty")
+	case errors.Is(err, io.ErrUnexpectedEOF):
+		return malformed("Request body contains badly-formed JSON")
+	case errors.As(err, &syntaxErr):
+		return malformed(fmt.Sprintf("Request body contains badly-formed JSON (at position %d)", syntaxErr.Offset))
+	case errors.As(err, &typeErr):
+		field := typeErr.Field
+		if field == "" {
+			return malformed("Request body must be a JSON object")
+		}
+		return apperr.Validation(apperr.Field(field, validate.CodeInvalid,
+			fmt.Sprintf("%s must be a %s", field, jsonTypeName(typeErr.Type.Kind()))))
+	case strings.HasPrefix(err.Error(), "json: unknown field "):
+		field := strings.Trim(strings.TrimPrefix(err.Error(), "json: unknown field "), `"`)
+		return apperr.Validation(apperr.Field(field, validate.CodeUnknownField, "unknown field "+field))
+	}
+	return malformed("Request body contains invalid JSON")
+}
+
+func jsonTypeName(k reflect.Kind) string {
+	switch k {
+	case reflect.String:
+		return "string"
+	case reflect.Bool:
+		return "boolean"
+	case reflect.Slice, reflect.Array:
+		return "array"
+	case reflect.Struct, reflect.Map:
+		return "object"
+	}
+	return "number"
+}
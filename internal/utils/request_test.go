@@ -0,0 +1,72 @@
+package utils
+
+import (
+	"net/http/httptest"
+	"net/netip"
+	"testing"
+)
+
+func TestClientIP(t *testing.T) {
+	UseTrustedProxies([]netip.Prefix{
+		netip.MustParsePrefix("10.0.0.0/8"),
+		netip.MustParsePrefix("fd00::/8"),
+	})
+	t.Cleanup(func() { UseTrustedProxies(nil) })
+
+	tests := []struct {
+		name   string
+		remote string
+		xff    []string
+		realIP string
+		want   string
+	}{
+		{name: "direct", remote: "203.0.113.7:4000", want: "203.0.113.7"},
+		{name: "untrusted peer headers ignored", remote: "203.0.113.7:4000",
+			xff: []string{"198.51.100.1"}, realIP: "198.51.100.2", want: "203.0.113.7"},
+		{name: "trusted proxy", remote: "10.0.0.2:4000",
+			xff: []string{"198.51.100.1"}, want: "198.51.100.1"},
+		{name: "forged first hop skipped", remote: "10.0.0.2:4000",
+			xff: []string{"1.2.3.4, 198.51.100.1"}, want: "198.51.100.1"},
+		{name: "chain of trusted proxies", remote: "10.0.0.2:4000",
+			xff: []string{"1.2.3.4, 198.51.100.1, 10.0.0.9"}, want: "198.51.100.1"},
+		{name: "several headers", remote: "10.0.0.2:4000",
+			xff: []string{"1.2.3.4", "198.51.100.1, 10.0.0.9"}, want: "198.51.100.1"},
+		{name: "garbage hop stops at last proxy", remote: "10.0.0.2:4000",
+			xff: []string{"198.51.100.1, not-an-ip, 10.0.0.9"}, want: "10.0.0.9"},
+		{name: "only trusted hops", remote: "10.0.0.2:4000",
+			xff: []string{"10.0.0.5"}, want: "10.0.0.5"},
+		{name: "x-real-ip from trusted proxy", remote: "10.0.0.2:4000",
+			realIP: "198.51.100.2", want: "198.51.100.2"},
+		{name: "invalid x-real-ip", remote: "10.0.0.2:4000",
+			realIP: "nope", want: "10.0.0.2"},
+		{name: "ipv6 proxy", remote: "[fd00::1]:4000",
+			xff: []string{"2001:db8::5"}, want: "2001:db8::5"},
+		{name: "ipv4-mapped proxy", remote: "[::ffff:10.0.0.2]:4000",
+			xff: []string{"198.51.100.1"}, want: "198.51.100.1"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := httptest.NewRequest("GET", "/", nil)
+			r.RemoteAddr = tt.remote
+			for _, v := range tt.xff {
+				r.Header.Add("X-Forwarded-For", v)
+			}
+			if tt.realIP != "" {
+				r.Header.Set("X-Real-IP", tt.realIP)
+			}
+			if got := ClientIP(r); got != tt.want {
+				t.Errorf("ClientIP() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestClientIPWithoutTrustedProxies(t *testing.T) {
+	r := httptest.NewRequest("GET", "/", nil)
+	r.RemoteAddr = "10.0.0.2:4000"
+	r.Header.Set("X-Forwarded-For", "198.51.100.1")
+	r.Header.Set("X-Real-IP", "198.51.100.1")
+	if got := ClientIP(r); got != "10.0.0.2" {
+		t.Errorf("ClientIP() = %q, want the connection address", got)
+	}
+}
@@ -1,10 +1,33 @@
 package utils
 
 import (
+	"context"
 	"encoding/json"
+	"errors"
+	"log/slog"
 	"net/http"
+
+	"GO2GETHER_BACK-END/internal/apperr"
+	"GO2GETHER_BACK-END/internal/dto"
 )
 
+// RequestIDHeader carries the request ID; middleware.RequestID sets it on the
+// response before any handler runs, so error bodies can quote it
+const RequestIDHeader = "X-Request-ID"
+
+// problemTypePrefix + code is the RFC 7807 "type" of an error
+const problemTypePrefix = "urn:go2gether:problem:"
+
+// errorMapper turns errors of a lower layer (storage) into API errors
+var errorMapper func(error) *apperr.Error
+
+// UseErrorMapper sets how WriteError maps errors that are not an
+// *apperr.Error; call it once at startup. f returns nil for errors it does
+// not know, which are sent as a 500.
+func UseErrorMapper(f func(error) *apperr.Error) {
+	errorMapper = f
+}
+
 // WriteJSONResponse writes a JSON response to the HTTP response writer
 func WriteJSONResponse(w http.ResponseWriter, status int, data interface{}) {
 	w.Header().Set("Content-Type", "application/json")
@@ -12,11 +35,64 @@ func WriteJSONResponse(w http.ResponseWriter, status int, data interface{}) {
 	json.NewEncoder(w).Encode(data)
 }
 
-// WriteErrorResponse writes an error JSON response to the HTTP response writer
+// WriteErrorResponse writes an error as problem+json with the code of its
+// status. The message of a 5xx is logged, not sent: it is usually a raw
+// driver error.
 func WriteErrorResponse(w http.ResponseWriter, status int, error, message string) {
-	response := map[string]string{
-		"error":   error,
-		"message": message,
+	e := apperr.New(status, apperr.CodeForStatus(status), error, message)
+	writeProblem(w, nil, e)
+}
+
+// WriteError writes err as problem+json. An *apperr.Error is sent as is,
+// other errors go through the mapper set by UseErrorMapper, anything left is
+// a 500.
+func WriteError(w http.ResponseWriter, r *http.Request, err error) {
+	var e *apperr.Error
+	if !errors.As(err, &e) {
+		if errorMapper != nil {
+			e = errorMapper(err)
+		}
+		if e == nil {
+			e = apperr.Internal(err)
+		}
 	}
-	WriteJSONResponse(w, status, response)
+	writeProblem(w, r, e)
+}
+
+func writeProblem(w http.ResponseWriter, r *http.Request, e *apperr.Error) {
+	requestID := w.Header().Get(RequestIDHeader)
+	detail := e.Detail
+	if e.Status >= http.StatusInternalServerError {
+		ctx := context.Background()
+		if r != nil {
+			ctx = r.Context()
+		}
+		attrs := []any{"status", e.Status, "code", e.Code, "title", e.Title, "detail", e.Detail, "request_id", requestID}
+		if e.Err != nil {
+			attrs = append(attrs, "error", e.Err)
+		}
+		slog.ErrorContext(ctx, "error response", attrs...)
+		if e.Code == apperr.CodeInternal {
+			detail = "An unexpected error occurred"
+		}
+	}
+
+	body := dto.ErrorResponse{
+		Type:      problemTypePrefix + string(e.Code),
+		Title:     e.Title,
+		Status:    e.Status,
+		Detail:    detail,
+		Code:      string(e.Code),
+		RequestID: requestID,
+		Errors:    e.Fields,
+		Error:     e.Title,
+		Message:   detail,
+	}
+	if r != nil {
+		body.Instance = r.URL.Path
+	}
+
+	w.Header().Set("Content-Type", "application/problem+json")
+	w.WriteHeader(e.Status)
+	json.NewEncoder(w).Encode(body)
 }
@@ -0,0 +1,295 @@
+// Package validate checks request DTOs declared with `validate` struct tags and
+// reports every invalid field at once as an apperr validation error.
+//
+// Rules are comma separated and run in order:
+//
+//	required      non-blank string, non-nil pointer, non-empty slice, non-zero number
+//	email         an email address
+//	min=N, max=N  length of a string or slice, value of a number
+//	oneof=a b c   one of the listed values (strings compare case-insensitively)
+//	date          a calendar date, YYYY-MM-DD
+//	datetime      YYYY-MM-DD or an RFC 3339 timestamp
+//	currency      an amount of money: not negative, at most two decimals
+//	iso4217       a three-letter currency code such as THB
+//	uuid          a UUID
+//	dive          apply the rules after it to each element of a slice
+//
+// Except for required, rules skip nil pointers and blank strings, so optional
+// fields and "" (clear this field) in updates pass. Checks across fields go in
+// a Check method (see Checker).
+package validate
+
+import (
+	"fmt"
+	"math"
+	"net/mail"
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+
+	"GO2GETHER_BACK-END/internal/apperr"
+)
+
+// DateLayout is the layout of calendar dates in requests and responses
+const DateLayout = "2006-01-02"
+
+// Field error codes
+const (
+	CodeRequired     = "required"
+	CodeInvalid      = "invalid"
+	CodeTooShort     = "too_short"
+	CodeTooLong      = "too_long"
+	CodeOutOfRange   = "out_of_range"
+	CodeNotAllowed   = "not_allowed"
+	CodeUnknownField = "unknown_field"
+)
+
+// Checker is implemented by DTOs with rules across fields. Check runs after
+// the tag rules; it may skip fields that already have errors (Errors.Has).
+type Checker interface {
+	Check(v *Errors)
+}
+
+// Errors collects field errors
+type Errors struct {
+	fields []apperr.FieldError
+}
+
+// Add records an error of field
+func (e *Errors) Add(field, code, message string) {
+	e.fields = append(e.fields, apperr.Field(field, code, message))
+}
+
+// Has reports whether field already has an error
+func (e *Errors) Has(field string) bool {
+	for _, f := range e.fields {
+		if f.Field == field {
+			return true
+		}
+	}
+	return false
+}
+
+// Err returns the collected errors as one *apperr.Error, or nil
+func (e *Errors) Err() error {
+	if len(e.fields) == 0 {
+		return nil
+	}
+	return apperr.Validation(e.fields...)
+}
+
+// Struct validates the tagged fields of the struct v points to, then its Check
+// method. Fields are named by their json tag.
+func Struct(v any) error {
+	var errs Errors
+	rv := reflect.Indirect(reflect.ValueOf(v))
+	if rv.Kind() == reflect.Struct {
+		rt := rv.Type()
+		for i := 0; i < rt.NumField(); i++ {
+			sf := rt.Field(i)
+			tag := sf.Tag.Get("validate")
+			if tag == "" || tag == "-" || !sf.IsExported() {
+				continue
+			}
+			checkValue(&errs, jsonName(sf), rv.Field(i), strings.Split(tag, ","))
+		}
+	}
+	if c, ok := v.(Checker); ok {
+		c.Check(&errs)
+	}
+	return errs.Err()
+}
+
+func jsonName(sf reflect.StructField) string {
+	name, _, _ := strings.Cut(sf.Tag.Get("json"), ",")
+	if name == "" || name == "-" {
+		return sf.Name
+	}
+	return name
+}
+
+// checkValue applies rules to one value and stops at its first error
+func checkValue(errs *Errors, field string, v reflect.Value, rules []string) {
+	for i, rule := range rules {
+		name, arg, _ := strings.Cut(strings.TrimSpace(rule), "=")
+		if name == "dive" {
+			for v.Kind() == reflect.Pointer && !v.IsNil() {
+				v = v.Elem()
+			}
+			if v.Kind() == reflect.Slice {
+				for j := 0; j < v.Len(); j++ {
+					checkValue(errs, fmt.Sprintf("%s[%d]", field, j), v.Index(j), rules[i+1:])
+				}
+			}
+			return
+		}
+		if name == "required" {
+			if isBlank(v) {
+				errs.Add(field, CodeRequired, field+" is required")
+				return
+			}
+			continue
+		}
+		if isBlank(v) && !isNumber(v) {
+			// optional and absent; numbers are checked even when zero
+			continue
+		}
+		for v.Kind() == reflect.Pointer {
+			v = v.Elem()
+		}
+		if code, msg := applyRule(name, arg, field, v); code != "" {
+			errs.Add(field, code, msg)
+			return
+		}
+	}
+}
+
+func isBlank(v reflect.Value) bool {
+	switch v.Kind() {
+	case reflect.Pointer, reflect.Interface:
+		return v.IsNil() || (v.Elem().Kind() == reflect.String && strings.TrimSpace(v.Elem().String()) == "")
+	case reflect.String:
+		return strings.TrimSpace(v.String()) == ""
+	case reflect.Slice, reflect.Map:
+		return v.Len() == 0
+	}
+	return v.IsZero()
+}
+
+func isNumber(v reflect.Value) bool {
+	switch v.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64, reflect.Float32, reflect.Float64:
+		return true
+	}
+	return false
+}
+
+// applyRule returns the code and message of a broken rule, or "" when v passes
+func applyRule(name, arg, field string, v reflect.Value) (string, string) {
+	switch name {
+	case "email":
+		if !Email(v.String()) {
+			return CodeInvalid, field + " must be a valid email address"
+		}
+	case "min", "max":
+		n, err := strconv.ParseFloat(arg, 64)
+		if err != nil {
+			panic("validate: bad " + name + " rule on " + field)
+		}
+		size, unit := measure(v)
+		switch {
+		case name == "min" && size < n && unit == "":
+			return CodeOutOfRange, fmt.Sprintf("%s must be at least %s", field, arg)
+		case name == "min" && size < n:
+			return CodeTooShort, fmt.Sprintf("%s must be at least %s %s", field, arg, unit)
+		case name == "max" && size > n && unit == "":
+			return CodeOutOfRange, fmt.Sprintf("%s must be at most %s", field, arg)
+		case name == "max" && size > n:
+			return CodeTooLong, fmt.Sprintf("%s must be at most %s %s", field, arg, unit)
+		}
+	case "oneof":
+		allowed := strings.Fields(arg)
+		if !OneOf(v.String(), allowed...) {
+			return CodeNotAllowed, fmt.Sprintf("%s must be one of: %s", field, strings.Join(allowed, ", "))
+		}
+	case "date":
+		if _, err := ParseDate(v.String()); err != nil {
+			return CodeInvalid, field + " must be YYYY-MM-DD"
+		}
+	case "datetime":
+		if _, err := ParseDateTime(v.String()); err != nil {
+			return CodeInvalid, field + " must be YYYY-MM-DD or RFC3339"
+		}
+	case "currency":
+		if !Amount(v.Float()) {
+			return CodeInvalid, field + " must be a non-negative amount with at most 2 decimals"
+		}
+	case "iso4217":
+		if !CurrencyCode(v.String()) {
+			return CodeInvalid, field + " must be a 3-letter ISO 4217 currency code"
+		}
+	case "uuid":
+		if _, err := uuid.Parse(strings.TrimSpace(v.String())); err != nil {
+			return CodeInvalid, field + " must be a UUID"
+		}
+	default:
+		panic("validate: unknown rule " + name + " on " + field)
+	}
+	return "", ""
+}
+
+// measure is the size min/max compare: characters of a string, elements of a
+// slice, or the value of a number (unit "")
+func measure(v reflect.Value) (float64, string) {
+	switch v.Kind() {
+	case reflect.String:
+		return float64(len([]rune(strings.TrimSpace(v.String())))), "characters"
+	case reflect.Slice, reflect.Map:
+		return float64(v.Len()), "items"
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return float64(v.Int()), ""
+	case reflect.Float32, reflect.Float64:
+		return v.Float(), ""
+	}
+	return 0, ""
+}
+
+// ParseDate parses a calendar date (YYYY-MM-DD) as midnight UTC
+func ParseDate(s string) (time.Time, error) {
+	return time.ParseInLocation(DateLayout, strings.TrimSpace(s), time.UTC)
+}
+
+// ParseDateTime parses YYYY-MM-DD (midnight UTC) or an RFC 3339 timestamp
+func ParseDateTime(s string) (time.Time, error) {
+	s = strings.TrimSpace(s)
+	if len(s) == len(DateLayout) {
+		return ParseDate(s)
+	}
+	return time.Parse(time.RFC3339, s)
+}
+
+// Email reports whether s is a bare email address
+func Email(s string) bool {
+	s = strings.TrimSpace(s)
+	a, err := mail.ParseAddress(s)
+	return err == nil && a.Address == s && a.Name == ""
+}
+
+// OneOf reports whether s is one of allowed, ignoring case and surrounding space
+func OneOf(s string, allowed ...string) bool {
+	s = strings.TrimSpace(s)
+	for _, a := range allowed {
+		if strings.EqualFold(s, a) {
+			return true
+		}
+	}
+	return false
+}
+
+// Amount reports whether f is a valid amount of money: finite, not negative
+// and with at most two decimals
+func Amount(f float64) bool {
+	if math.IsNaN(f) || math.IsInf(f, 0) || f < 0 {
+		return false
+	}
+	cents := f * 100
+	return math.Abs(cents-math.Round(cents)) < 1e-6
+}
+
+// CurrencyCode reports whether s is shaped like an ISO 4217 code (three
+// letters); case is ignored
+func CurrencyCode(s string) bool {
+	s = strings.TrimSpace(s)
+	if len(s) != 3 {
+		return false
+	}
+	for _, c := range strings.ToUpper(s) {
+		if c < 'A' || c > 'Z' {
+			return false
+		}
+	}
+	return true
+}
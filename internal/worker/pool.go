@@ -0,0 +1,177 @@
+// Package worker runs background jobs (e.g. outbox deliveries) on a
+// bounded pool instead of one goroutine per job, so a burst of requests
+// cannot pile up unbounded goroutines and shutdown can wait for queued jobs.
+package worker
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"sync"
+	"time"
+
+	"GO2GETHER_BACK-END/internal/health"
+	"GO2GETHER_BACK-END/internal/metrics"
+)
+
+var (
+	// ErrQueueFull is returned by Submit when every queue slot is taken
+	ErrQueueFull = errors.New("worker: queue is full")
+	// ErrStopped is returned by Submit once Shutdown has begun
+	ErrStopped = errors.New("worker: pool is shutting down")
+)
+
+// Job is a unit of background work. Its context keeps the values of the
+// context it was submitted with (request ID for logs, trace span) but not its
+// cancellation; it is cancelled when Shutdown runs out of time.
+type Job func(ctx context.Context)
+
+type queued struct {
+	ctx context.Context
+	job Job
+}
+
+// Pool runs jobs on a fixed number of goroutines fed by a bounded queue
+type Pool struct {
+	name  string
+	queue chan queued
+
+	mu      sync.RWMutex // guards stopped against sends on a closed queue
+	stopped bool
+
+	// hardStop is cancelled when Shutdown gives up waiting
+	hardStop context.Context
+	cancel   context.CancelFunc
+
+	wg      sync.WaitGroup
+	tracker health.Worker
+}
+
+// New starts a pool of workers goroutines with room for queueSize waiting
+// jobs. name labels its metrics and logs.
+func New(name string, workers, queueSize int) *Pool {
+	if workers < 1 {
+		workers = 1
+	}
+	if queueSize < 0 {
+		queueSize = 0
+	}
+	p := &Pool{name: name, queue: make(chan queued, queueSize)}
+	p.hardStop, p.cancel = context.WithCancel(context.Background())
+	metrics.RegisterWorkerPool(name, p.Depth, p.tracker.Running)
+
+	p.wg.Add(workers)
+	for range workers {
+		go p.work()
+	}
+	return p
+}
+
+func (p *Pool) work() {
+	defer p.wg.Done()
+	for q := range p.queue {
+		p.run(q)
+	}
+}
+
+func (p *Pool) run(q queued) {
+	if p.hardStop.Err() != nil {
+		// Shutdown ran out of time; what is left in the queue is dropped
+		metrics.WorkerJob(p.name, metrics.JobDropped)
+		return
+	}
+	done := p.tracker.Start()
+	defer done()
+	defer func() {
+		if v := recover(); v != nil {
+			metrics.WorkerJob(p.name, metrics.JobPanicked)
+			slog.ErrorContext(q.ctx, "background job panicked", "pool", p.name, "panic", v)
+		}
+	}()
+
+	ctx, cancel := context.WithCancel(q.ctx)
+	defer cancel()
+	stop := context.AfterFunc(p.hardStop, cancel)
+	defer stop()
+
+	q.job(ctx)
+	metrics.WorkerJob(p.name, metrics.JobDone)
+}
+
+// Submit queues job without blocking. It fails with ErrQueueFull when the
+// queue is full and ErrStopped during shutdown; the job is then dropped.
+func (p *Pool) Submit(ctx context.Context, job Job) error {
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+	if p.stopped {
+		metrics.WorkerJob(p.name, metrics.JobRejected)
+		return ErrStopped
+	}
+	select {
+	case p.queue <- queued{ctx: context.WithoutCancel(ctx), job: job}:
+		return nil
+	default:
+		metrics.WorkerJob(p.name, metrics.JobRejected)
+		return ErrQueueFull
+	}
+}
+
+// Depth is the number of jobs waiting in the queue
+func (p *Pool) Depth() int { return len(p.queue) }
+
+// Capacity is the size of the queue
+func (p *Pool) Capacity() int { return cap(p.queue) }
+
+// Tracker tracks the running jobs, for health.WorkerAlive
+func (p *Pool) Tracker() *health.Worker { return &p.tracker }
+
+// QueueCheck is an optional readiness check warning when the queue is at
+// least 90% full, i.e. jobs are about to be dropped
+func (p *Pool) QueueCheck() health.Check {
+	return health.Check{
+		Name:     p.name + "_queue",
+		Optional: true,
+		Probe: func(context.Context) error {
+			if depth, size := p.Depth(), p.Capacity(); size > 0 && depth*10 >= size*9 {
+				return fmt.Errorf("%d of %d queue slots used", depth, size)
+			}
+			return nil
+		},
+	}
+}
+
+// Shutdown stops accepting jobs and waits for the queued and running ones to
+// finish. When ctx ends first, running jobs are cancelled, jobs still queued
+// are dropped, and ctx's error is returned.
+func (p *Pool) Shutdown(ctx context.Context) error {
+	p.mu.Lock()
+	if !p.stopped {
+		p.stopped = true
+		close(p.queue)
+	}
+	p.mu.Unlock()
+
+	drained := make(chan struct{})
+	go func() {
+		p.wg.Wait()
+		close(drained)
+	}()
+
+	select {
+	case <-drained:
+		p.cancel()
+		return nil
+	case <-ctx.Done():
+	}
+
+	dropped, running := len(p.queue), p.tracker.Running()
+	p.cancel()
+	// give cancelled jobs a moment to return
+	select {
+	case <-drained:
+	case <-time.After(time.Second):
+	}
+	slog.Warn("worker pool did not drain in time", "pool", p.name, "dropped", dropped, "cancelled", running)
+	return ctx.Err()
+}